@@ -41,13 +41,24 @@ const (
 	pageLoadKey      = "pageLoad"
 	generatePassword = "generatePassword"
 	getUserDetails   = "getUserDetails"
+	getWeatherStatus = "getWeatherStatus"
+	refreshWeather   = "refreshWeather"
+	searchLocations  = "searchLocations"
+	findPostalCode   = "findPostalCode"
+
+	updateDegreeDayCrop = "updateDegreeDayCrop"
+	removeDegreeDayCrop = "removeDegreeDayCrop"
+
+	getSigningKeys   = "getSigningKeys"
+	rotateSigningKey = "rotateSigningKey"
+	revokeUserTokens = "revokeUserTokens"
 )
 
 // Init is different than the standard init because it is called outside of the object load
 func Init() error {
 	webservice.AppendToWebsocketFunctionMap("admin", handleMessage)
 	// warm up the db connection so when we hit the page the first time it's faster
-	if _, err := pageLoad(false); err != nil {
+	if _, err := pageLoad(); err != nil {
 		return err
 	}
 	return nil
@@ -58,7 +69,7 @@ func handleMessage(request, response *configs.WsMessage) {
 		var err error
 		switch *request.Type {
 		case pageLoadKey:
-			response.Data, err = pageLoad(true)
+			response.Data, err = pageLoad()
 		case generatePassword:
 			response.Data = map[string]*string{
 				"password": configs.GeneratePassword(),
@@ -79,6 +90,42 @@ func handleMessage(request, response *configs.WsMessage) {
 					}
 				}
 			}
+		case getWeatherStatus:
+			response.Data = weather.GetRefreshStatus()
+		case refreshWeather:
+			// the location to refresh is the component, without one every user's location is refreshed
+			response.Data, err = weather.RefreshForecasts(request.Component)
+		case searchLocations:
+			// the component is the input being typed in so the results go back to it
+			prefix, ok := request.Data.(string)
+			if !ok {
+				err = errors.New("the location search requires the text typed as the data")
+				break
+			}
+			response.Data, err = weather.SearchLocations(prefix, weather.DefaultSearchLimit)
+		case findPostalCode:
+			response.Data, err = lookupPostalCode(request.Data)
+		case updateDegreeDayCrop:
+			response.Data, err = weather.UpdateDegreeDayCrop(request.Data)
+		case removeDegreeDayCrop:
+			// the crop name is the component
+			response.Data, err = weather.RemoveDegreeDayCrop(request.Component)
+		case getSigningKeys:
+			response.Data, err = configs.GetJWTKeys()
+		case rotateSigningKey:
+			// the tokens already handed out keep working until the old key's grace period is over
+			if _, err = configs.RotateJWTKey(); err == nil {
+				response.Data, err = configs.GetJWTKeys()
+			}
+		case revokeUserTokens:
+			// the user is the component, every session they're logged in with is closed
+			var revoked int
+			if revoked, err = webservice.RevokeUserTokens(request.Component); err == nil {
+				response.Data = map[string]interface{}{
+					"user":    request.Component,
+					"revoked": revoked,
+				}
+			}
 		default:
 			err = fmt.Errorf("type %s not implemented", *request.Component)
 		}
@@ -110,7 +157,14 @@ func updateUserActive(userID, active *string) error {
 		currentUser, ok := configs.GrowSTLGo.Users[*userID]
 		configs.GrowSTLGo.UsersMutex.Unlock()
 		if ok {
-			return currentUser.ToggleActive(&b)
+			if err := currentUser.ToggleActive(&b); err != nil {
+				return err
+			}
+			// a deactivated user is logged out everywhere
+			if !b {
+				_, err = webservice.RevokeUserTokens(userID)
+			}
+			return err
 		}
 	}
 	return errors.New("cannot update user active flag")
@@ -157,7 +211,7 @@ func userAction(request, response *configs.WsMessage) *configs.WsMessage {
 			return response
 		}
 
-		data, err := pageLoad(false)
+		data, err := pageLoad()
 		if err != nil {
 			log.Error(err)
 			e := err.Error()
@@ -170,7 +224,7 @@ func userAction(request, response *configs.WsMessage) *configs.WsMessage {
 	return nil
 }
 
-func pageLoad(initialLoad bool) (map[string]interface{}, error) {
+func pageLoad() (map[string]interface{}, error) {
 	users := make(map[string]interface{})
 	err := audit.GetLastLogins()
 	if err != nil {
@@ -192,14 +246,41 @@ func pageLoad(initialLoad bool) (map[string]interface{}, error) {
 			"vhosts":  slices.AppendSeq(make([]string, 0, len(configs.GrowSTLGo.WebService.Vhosts)), maps.Keys(configs.GrowSTLGo.WebService.Vhosts)),
 			"version": configs.Version,
 		}
-		if initialLoad {
-			data["zipCodes"] = weather.ZipCodeTypeahead
-		}
 		return data, nil
 	}
 	return nil, errors.New("invalid configuration cannot load page data")
 }
 
+// lookupPostalCode returns the location key of a country and postal code, {"country": "CA", "zip": "M5A"}, so it can
+// be saved as a user's location
+func lookupPostalCode(data interface{}) (map[string]interface{}, error) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var request struct {
+		Country *string `json:"country,omitempty"`
+		Zip     *string `json:"zip,omitempty"`
+	}
+	if err := json.Unmarshal(bytes, &request); err != nil || request.Zip == nil {
+		return nil, errors.New("a postal code lookup requires the zip and optionally the country")
+	}
+
+	country := ""
+	if request.Country != nil {
+		country = *request.Country
+	}
+	location, zipCode, err := weather.FindPostalCode(country, *request.Zip)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"location": location,
+		"country":  zipCode.Country,
+		"zip_code": zipCode.ZipCode,
+	}, nil
+}
+
 // AddUser will add a new user to the configs
 func AddUser(userID *string, data interface{}) error {
 	if userID != nil && data != nil {
@@ -224,7 +305,11 @@ func AddUser(userID *string, data interface{}) error {
 							}
 						}
 
-						newUser.Location = user.Location
+						if user.Location != nil {
+							if _, err := weather.GetLocation(user.Location); err == nil {
+								newUser.Location = user.Location
+							}
+						}
 						return newUser.Persist(userID)
 					}
 				}
@@ -262,10 +347,7 @@ func UpdateUser(userID *string, data interface{}) error {
 					}
 				}
 				if user.Location != nil {
-					weather.ZipCodeCacheMutex.Lock()
-					_, zipOk := weather.ZipcodeLookup[*user.Location]
-					weather.ZipCodeCacheMutex.Unlock()
-					if zipOk {
+					if _, err := weather.GetLocation(user.Location); err == nil {
 						currentUser.Location = user.Location
 					}
 				}
@@ -283,7 +365,12 @@ func RemoveUser(userID *string) error {
 		user, ok := configs.GrowSTLGo.Users[*userID]
 		configs.GrowSTLGo.UsersMutex.Unlock()
 		if ok {
-			return user.Remove(userID)
+			if err := user.Remove(userID); err != nil {
+				return err
+			}
+			// the tokens of a removed user would otherwise be valid until they expire
+			_, err := webservice.RevokeUserTokens(userID)
+			return err
 		}
 	}
 	return errors.New("cannot remove user")
@@ -0,0 +1,112 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+var (
+	rollbackPackage string
+	rollbackSteps   int
+
+	dbCmd = &cobra.Command{
+		Use:   "db",
+		Short: "Manage the embedded database schema",
+		Long:  "Apply, inspect and roll back the versioned schema migrations of the embedded database",
+	}
+)
+
+func init() {
+	dbCmd.AddCommand(&cobra.Command{
+		Use:   "migrate",
+		Short: "Apply all pending migrations",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := startDBCommand(); err != nil {
+				return err
+			}
+			applied, err := configs.Migrate()
+			printMigrations(cmd.OutOrStdout(), applied)
+			return err
+		},
+	})
+
+	dbCmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show the applied and pending migrations",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := startDBCommand(); err != nil {
+				return err
+			}
+			statuses, err := configs.GetMigrationStatus()
+			if err != nil {
+				return err
+			}
+			printMigrations(cmd.OutOrStdout(), statuses)
+			return nil
+		},
+	})
+
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Roll back the last applied migrations of a package",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := startDBCommand(); err != nil {
+				return err
+			}
+			rolledBack, err := configs.Rollback(rollbackPackage, rollbackSteps)
+			printMigrations(cmd.OutOrStdout(), rolledBack)
+			return err
+		},
+	}
+	rollbackCmd.Flags().StringVarP(&rollbackPackage, "package", "p", "", "The package that owns the migrations to roll back")
+	rollbackCmd.Flags().IntVarP(&rollbackSteps, "steps", "s", 1, "The number of migrations to roll back")
+	if err := rollbackCmd.MarkFlagRequired("package"); err != nil {
+		log.Error(err)
+	}
+	dbCmd.AddCommand(rollbackCmd)
+
+	rootCmd.AddCommand(dbCmd)
+}
+
+// startDBCommand reads the config and opens the database without applying the pending migrations
+func startDBCommand() error {
+	log.SetLogLevel(logLevelStr)
+	configs.AutoMigrate = false
+	return configs.SetGrowSTLGoConfig()
+}
+
+func printMigrations(out io.Writer, statuses []*configs.MigrationStatus) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tVERSION\tAPPLIED\tDESCRIPTION")
+	for _, status := range statuses {
+		applied := "pending"
+		if status.Applied != nil {
+			applied = time.UnixMilli(*status.Applied).Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", *status.Package, *status.Version, applied, *status.Description)
+	}
+	if err := w.Flush(); err != nil {
+		log.Error(err)
+	}
+}
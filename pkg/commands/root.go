@@ -27,6 +27,7 @@ import (
 	"stl-go/grow-with-stl-go/pkg/admin"
 	"stl-go/grow-with-stl-go/pkg/audit"
 	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/garden"
 	"stl-go/grow-with-stl-go/pkg/log"
 	"stl-go/grow-with-stl-go/pkg/seeds"
 	"stl-go/grow-with-stl-go/pkg/weather"
@@ -61,7 +62,7 @@ func init() {
 	// Add the config file Flag
 	configFile := "../../etc/grow-with-stl-go.json"
 	configs.ConfigFile = &configFile
-	rootCmd.Flags().StringVarP(
+	rootCmd.PersistentFlags().StringVarP(
 		configs.ConfigFile,
 		"conf",
 		"c",
@@ -70,7 +71,7 @@ func init() {
 	)
 
 	// Add the logging level flag
-	rootCmd.Flags().StringVarP(
+	rootCmd.PersistentFlags().StringVarP(
 		&logLevelStr,
 		"loglevel",
 		"l",
@@ -95,7 +96,7 @@ func launch(_ *cobra.Command, _ []string) {
 	}
 
 	// kick off the init functions for the various packages
-	for _, function := range []func() error{audit.Init, seeds.Init, admin.Init, weather.Init} {
+	for _, function := range []func() error{audit.Init, webservice.Init, seeds.Init, garden.Init, admin.Init, weather.Init} {
 		if err := function(); err != nil {
 			log.Fatalf("error calling function %s cannot continue to start.  Error: %s", runtime.FuncForPC(reflect.ValueOf(function).Pointer()).Name(), err)
 		}
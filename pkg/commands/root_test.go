@@ -59,3 +59,41 @@ func TestVersionExecuteCommand(t *testing.T) {
 
 	require.NotNil(t, out)
 }
+
+func TestDBStatusExecuteCommand(t *testing.T) {
+	cmd := rootCmd
+	b := bytes.NewBufferString("")
+	cmd.SetOut(b)
+	cmd.SetArgs([]string{"db", "status", "-c", "../../etc/grow-with-stl-go.json"})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	require.Contains(t, string(out), "seeds")
+}
+
+func TestSeedsExportExecuteCommand(t *testing.T) {
+	cmd := rootCmd
+	b := bytes.NewBufferString("")
+	cmd.SetOut(b)
+	cmd.SetArgs([]string{"seeds", "export", "-f", "csv", "-c", "../../etc/grow-with-stl-go.json"})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	require.Contains(t, string(out), "commonName")
+}
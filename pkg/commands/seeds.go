@@ -0,0 +1,147 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+	"stl-go/grow-with-stl-go/pkg/seeds"
+)
+
+var (
+	catalogFormat string
+	importCommit  bool
+	exportOutput  string
+
+	seedsCmd = &cobra.Command{
+		Use:   "seeds",
+		Short: "Import and export the seed catalog",
+		Long:  "Bulk import and export the seed catalog as csv or json",
+	}
+)
+
+func init() {
+	importCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Validate and optionally commit a catalog import",
+		Long: "Validate every record of the file and print the report.  Records with the id of an existing seed update it, " +
+			"the others are added.  Nothing is written unless --commit is set and every record is valid",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := startSeedsCommand(); err != nil {
+				return err
+			}
+
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			report, err := seeds.Import(file, catalogFileFormat(args[0]), importCommit)
+			if err != nil {
+				return err
+			}
+			printImportReport(cmd.OutOrStdout(), report)
+			if len(report.Errors) > 0 {
+				return fmt.Errorf("%d of %d records are invalid, nothing was imported", len(report.Errors), report.Total)
+			}
+			return nil
+		},
+	}
+	importCmd.Flags().StringVarP(&catalogFormat, "format", "f", "", "The file format, csv or json, defaults to the file extension")
+	importCmd.Flags().BoolVar(&importCommit, "commit", false, "Write the import if every record is valid")
+	seedsCmd.AddCommand(importCmd)
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the seed catalog",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := startSeedsCommand(); err != nil {
+				return err
+			}
+
+			if exportOutput == "" {
+				return seeds.Export(cmd.OutOrStdout(), catalogFileFormat(exportOutput))
+			}
+
+			file, err := os.Create(exportOutput)
+			if err != nil {
+				return err
+			}
+			if err := seeds.Export(file, catalogFileFormat(exportOutput)); err != nil {
+				file.Close()
+				return err
+			}
+			return file.Close()
+		},
+	}
+	exportCmd.Flags().StringVarP(&catalogFormat, "format", "f", "", "The file format, csv or json, defaults to the file extension")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "The file to write, defaults to stdout")
+	seedsCmd.AddCommand(exportCmd)
+
+	rootCmd.AddCommand(seedsCmd)
+}
+
+// startSeedsCommand reads the config, which migrates the database, and sets up the seed tables the import and export
+// work against.  A running server doesn't see a committed import until it's restarted
+func startSeedsCommand() error {
+	log.SetLogLevel(logLevelStr)
+	if err := configs.SetGrowSTLGoConfig(); err != nil {
+		return err
+	}
+	return seeds.InitTables()
+}
+
+// catalogFileFormat is the --format flag if set, otherwise csv for .csv files and json for everything else
+func catalogFileFormat(fileName string) string {
+	if catalogFormat != "" {
+		return catalogFormat
+	}
+	if strings.EqualFold(filepath.Ext(fileName), "."+seeds.CSVFormat) {
+		return seeds.CSVFormat
+	}
+	return seeds.JSONFormat
+}
+
+func printImportReport(out io.Writer, report *seeds.ImportReport) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "records:\t%d\n", report.Total)
+	fmt.Fprintf(w, "added:\t%d\n", report.Inserted)
+	fmt.Fprintf(w, "updated:\t%d\n", report.Updated)
+	fmt.Fprintf(w, "committed:\t%t\n", report.Committed)
+	if len(report.Errors) > 0 {
+		fmt.Fprintln(w, "\nROW\tID\tERROR")
+		for _, importError := range report.Errors {
+			id := ""
+			if importError.ID != nil {
+				id = *importError.ID
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", importError.Row, id, importError.Message)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		log.Error(err)
+	}
+}
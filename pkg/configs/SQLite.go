@@ -31,11 +31,17 @@ import (
 	"stl-go/grow-with-stl-go/pkg/utils"
 )
 
+// transactions take the write lock when they begin, a transaction that reads and then writes would otherwise fail with
+// "database is locked" when another writer got there first instead of waiting for it.  The write ahead log lets a commit
+// go through while the inventory is being read instead of waiting, often past the busy timeout, for the readers to finish
+const connectionOptions = "_foreign_keys=1&_txlock=immediate&_busy_timeout=5000&_journal_mode=WAL"
+
 // Table is a struct used by various packages to create insert / update data to the embedded db
 type Table struct {
 	CreateSQL  string
 	InsertSQL  string
 	UpdateSQL  string
+	DeleteSQL  string
 	Indices    []string
 	Defaults   map[string]string
 	WriteMutex sync.Mutex
@@ -69,7 +75,10 @@ func (c *Config) checkSQLite() error {
 			GrowSTLGo.SQLite = &sqlite
 			rewriteConfig = true
 		}
-		return c.SQLite.startDB()
+		if err := c.SQLite.startDB(); err != nil {
+			return err
+		}
+		return startMigrations()
 	}
 	return errors.New("invalid config cannot check SQLite")
 }
@@ -85,7 +94,7 @@ func (sqlite *SQLite) startDB() error {
 		// encrypted db
 		if sqlite.EncryptionKey != nil {
 			if key, err := sqlite.GetEncryptionKey(); err == nil && key != nil {
-				dbname := fmt.Sprintf("%s?_pragma_key=x'%s'&_pragma_cipher_page_size=4096", *sqlite.FileName, *key)
+				dbname := fmt.Sprintf("%s?_pragma_key=x'%s'&_pragma_cipher_page_size=4096&%s", *sqlite.FileName, *key, connectionOptions)
 				sqlite.DB, err = sql.Open("sqlite3", dbname)
 				if err != nil {
 					return err
@@ -94,7 +103,7 @@ func (sqlite *SQLite) startDB() error {
 				return nil
 			}
 		}
-		db, sqliteErr := sql.Open("sqlite3", *sqlite.FileName)
+		db, sqliteErr := sql.Open("sqlite3", fmt.Sprintf("%s?%s", *sqlite.FileName, connectionOptions))
 		if sqliteErr != nil {
 			return sqliteErr
 		}
@@ -194,6 +203,52 @@ func (table *Table) CreateTable(tableName *string) error {
 	return errors.New("tables is nil cannot create tables")
 }
 
+// Exec is a helper function that will run a write statement against the embedded database while holding the table's write mutex
+func (table *Table) Exec(query string, args ...any) (int64, error) {
+	db, dbErr := GetSQLiteConnection()
+	if dbErr != nil {
+		return 0, dbErr
+	}
+
+	if db != nil && table != nil {
+		stmt, err := db.Prepare(query)
+		if err != nil {
+			return 0, err
+		}
+		defer stmt.Close()
+
+		table.WriteMutex.Lock()
+		defer table.WriteMutex.Unlock()
+		result, err := stmt.Exec(args...)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+	return 0, errors.New("table is nil cannot execute statement")
+}
+
+// ExecTx is the transactional version of Exec, the caller is responsible for committing or rolling back the transaction.
+// The write mutex is not held, the database serializes the writes of the transaction
+func (table *Table) ExecTx(tx *sql.Tx, query string, args ...any) (int64, error) {
+	if tx != nil && table != nil {
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+	return 0, errors.New("table or transaction is nil cannot execute statement")
+}
+
+// RollbackHelper rolls back a transaction that hasn't been committed, it's meant to be deferred once the transaction
+// has begun
+func RollbackHelper(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		log.Error(err)
+	}
+}
+
 func createHelper(db *sql.DB, index string) error {
 	if db != nil {
 		ctx := context.Background()
@@ -18,7 +18,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/url"
 	"os"
 	"path"
 	"path/filepath"
@@ -57,18 +56,22 @@ type Config struct {
 	UsersMutex sync.Mutex       `json:"-"`
 	Country    *Country         `json:"country,omitempty"`
 	DataDir    *string          `json:"data_dir,omitempty"`
+	FrostDates *FrostDates      `json:"frost_dates,omitempty"`
+	JWT        *JWT             `json:"jwt,omitempty"`
 	Proxy      *Proxy           `json:"proxy,omitempty"`
 	Secret     *string          `json:"secret,omitempty"`
 	SQLite     *SQLite          `json:"sqlite,omitempty"`
 	WebService *WebService      `json:"webService,omitempty"`
-	WeatherAPI *string          `json:"weather_api,omitempty"`
+	Weather    *Weather         `json:"weather,omitempty"`
+	// WeatherAPI has been replaced by weather, it is read so older configs carry over to the nws provider
+	WeatherAPI *string `json:"weather_api,omitempty"`
 }
 
 func (c *Config) checkConfig() error {
 	if c != nil {
 		checkUsers()
 
-		for _, function := range []func() error{c.checkWeatherAPI, c.checkDataDir, c.checkCountry, c.checkWebService, c.checkSQLite, c.testRewriteConfig} {
+		for _, function := range []func() error{c.checkWeather, c.checkDataDir, c.checkCountry, c.checkWebService, c.checkJWT, c.checkSQLite, c.testRewriteConfig} {
 			if err := function(); err != nil {
 				log.Errorf("error calling function %s", runtime.FuncForPC(reflect.ValueOf(function).Pointer()).Name())
 			}
@@ -100,18 +103,17 @@ func (c *Config) checkDataDir() error {
 	return errors.New("invalid config cannot check data dir")
 }
 
-func (c *Config) checkWeatherAPI() error {
-	if c != nil {
-		url, err := url.Parse("https://api.weather.gov")
-		if err != nil {
-			return err
-		}
-		s := url.String()
-		c.WeatherAPI = &s
-		rewriteConfig = true
-		return nil
+// GetImagesDir returns the directory uploaded images are stored in, it is created if it doesn't exist
+func GetImagesDir() (*string, error) {
+	if GrowSTLGo == nil || GrowSTLGo.DataDir == nil {
+		return nil, errors.New("no data dir configured, cannot store images")
+	}
+
+	dir := filepath.Join(*GrowSTLGo.DataDir, ImagesDir)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
 	}
-	return errors.New("invalid config cannot check weather api")
+	return &dir, nil
 }
 
 func (c *Config) testRewriteConfig() error {
@@ -180,6 +182,9 @@ type WsMessage struct {
 	RefreshToken   *string         `json:"refreshToken,omitempty"`
 	IsAdmin        *bool           `json:"isAdmin,omitempty"`
 	Vhost          *string
+
+	// set by the webservice from the session, it cannot be supplied by the client
+	User *string `json:"-"`
 }
 
 // SetGrowSTLGoConfig sets the config for the application
@@ -22,6 +22,7 @@ const (
 	GetPagelet      string = "getPagelet"
 	Initialize      string = "initialize"
 	Keepalive       string = "keepalive"
+	Logout          string = "logout"
 	UI              string = "ui"
 	WebsocketClient string = "websocketclient"
 
@@ -41,4 +42,8 @@ const (
 
 	// time format
 	HHMMSS = "15:04:05"
+
+	// uploaded images are stored in this directory under the data dir and served from the uri prefix
+	ImagesDir       = "images"
+	ImagesURIPrefix = "/images/"
 )
@@ -20,15 +20,22 @@ import (
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"stl-go/grow-with-stl-go/pkg/log"
 )
 
-// Country is set for our download of zip codes & latitude / longitudes
+// DefaultCountry is the country whose locations are downloaded when none are configured
+const DefaultCountry = "US"
+
+// Country is set for our download of postal codes & latitude / longitudes of each of the countries, the countries are
+// the ISO 3166 two letter codes geonames names its archives after
 type Country struct {
+	Countries []string `json:"countries,omitempty"`
+	URL       *string  `json:"url,omitempty"`
+	// Country is the single country of configs from before the list, it is carried over to the countries
 	Country *string `json:"country,omitempty"`
-	URL     *string `json:"url,omitempty"`
-	ZipFile *string `json:"zip_file,omitempty"`
 }
 
 func (c *Config) checkCountry() error {
@@ -39,14 +46,37 @@ func (c *Config) checkCountry() error {
 				return urlErr
 			}
 			urlStr := u.String()
-			country := "US"
 			c.Country = &Country{
-				Country: &country,
-				URL:     &urlStr,
+				Countries: []string{DefaultCountry},
+				URL:       &urlStr,
 			}
 			rewriteConfig = true
 			return nil
-		} else if c.Country.Country != nil && c.Country.URL != nil {
+		} else if c.Country.URL != nil {
+			if c.Country.Country != nil {
+				c.Country.Countries = append([]string{*c.Country.Country}, c.Country.Countries...)
+				c.Country.Country = nil
+				rewriteConfig = true
+			}
+
+			countries := []string{}
+			for _, country := range c.Country.Countries {
+				country = strings.ToUpper(strings.TrimSpace(country))
+				if len(country) != 2 {
+					return fmt.Errorf("country %s is not a two letter country code", country)
+				}
+				if !slices.Contains(countries, country) {
+					countries = append(countries, country)
+				}
+			}
+			if len(countries) == 0 {
+				return errors.New("no countries are configured to download locations for")
+			}
+			if !slices.Equal(countries, c.Country.Countries) {
+				c.Country.Countries = countries
+				rewriteConfig = true
+			}
+
 			_, urlErr := url.Parse(*c.Country.URL)
 			if urlErr != nil {
 				return urlErr
@@ -57,12 +87,20 @@ func (c *Config) checkCountry() error {
 	return errors.New("invalid config cannot check country")
 }
 
-// GetCountryData will get the configured country's city, state, zip, latitude / longitude information
-func (c *Country) GetCountryData() (*string, error) {
+// PrimaryCountry is the first of the configured countries, postal codes given without a country are looked up in it
+func (c *Country) PrimaryCountry() string {
+	if c != nil && len(c.Countries) > 0 {
+		return c.Countries[0]
+	}
+	return DefaultCountry
+}
+
+// GetCountryData will get the country's city, state, postal code, latitude / longitude information
+func (c *Country) GetCountryData(country string) (*string, error) {
 	defer log.FunctionTimer()()
-	if c != nil && c.URL != nil && c.Country != nil && GrowSTLGo.DataDir != nil {
-		fileName := filepath.Join(*GrowSTLGo.DataDir, fmt.Sprintf("%s.zip", *c.Country))
-		url, urlErr := url.JoinPath(*c.URL, fmt.Sprintf("%s.zip", *c.Country))
+	if c != nil && c.URL != nil && GrowSTLGo.DataDir != nil {
+		fileName := filepath.Join(*GrowSTLGo.DataDir, fmt.Sprintf("%s.zip", country))
+		url, urlErr := url.JoinPath(*c.URL, fmt.Sprintf("%s.zip", country))
 		if urlErr != nil {
 			return nil, urlErr
 		}
@@ -72,7 +110,6 @@ func (c *Country) GetCountryData() (*string, error) {
 		}
 		if statusCode != nil {
 			if *statusCode < 300 {
-				c.ZipFile = &fileName
 				return &fileName, nil
 			}
 			return nil, fmt.Errorf("error code returned from endpoint.  HTTP Status: %d", *statusCode)
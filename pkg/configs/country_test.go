@@ -20,6 +20,32 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestCountryListFunctions(t *testing.T) {
+	t.Run("Test the single country carries over to the countries", func(t *testing.T) {
+		u, country := "https://download.geonames.org/export/zip/", "us"
+		c := &Config{Country: &Country{URL: &u, Country: &country, Countries: []string{"ca", "US"}}}
+		require.NoError(t, c.checkCountry())
+		require.Equal(t, []string{"US", "CA"}, c.Country.Countries)
+		require.Nil(t, c.Country.Country)
+		require.Equal(t, "US", c.Country.PrimaryCountry())
+	})
+
+	t.Run("Test the default country", func(t *testing.T) {
+		c := &Config{}
+		require.NoError(t, c.checkCountry())
+		require.Equal(t, []string{DefaultCountry}, c.Country.Countries)
+		require.NotNil(t, c.Country.URL)
+	})
+
+	t.Run("Test invalid countries", func(t *testing.T) {
+		u := "https://download.geonames.org/export/zip/"
+		c := &Config{Country: &Country{URL: &u, Countries: []string{"USA"}}}
+		require.Error(t, c.checkCountry())
+		c = &Config{Country: &Country{URL: &u}}
+		require.Error(t, c.checkCountry())
+	})
+}
+
 func TestCountryFunctions(t *testing.T) {
 	t.Skip()
 	initConfigTest()
@@ -28,7 +54,7 @@ func TestCountryFunctions(t *testing.T) {
 		require.NoError(t, err)
 
 		if GrowSTLGo.Country != nil {
-			fileName, err := GrowSTLGo.Country.GetCountryData()
+			fileName, err := GrowSTLGo.Country.GetCountryData(GrowSTLGo.Country.PrimaryCountry())
 			require.NoError(t, err)
 			require.NotNil(t, fileName)
 		}
@@ -0,0 +1,53 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+// FrostDates is where a frost date dataset is downloaded from, without a URL the dataset shipped with the weather
+// package is used.  The file is a csv of station, name, state, latitude, longitude, lastSpringFrost, firstFallFrost
+// with the frost dates as MM-DD
+type FrostDates struct {
+	URL  *string `json:"url,omitempty"`
+	File *string `json:"file,omitempty"`
+}
+
+// GetFrostDateData will download the configured frost date dataset to the data dir
+func (f *FrostDates) GetFrostDateData() (*string, error) {
+	defer log.FunctionTimer()()
+	if f != nil && f.URL != nil && GrowSTLGo.DataDir != nil {
+		fileName := filepath.Join(*GrowSTLGo.DataDir, "frost_dates.csv")
+		statusCode, downloadErr := DownloadFile(*f.URL, http.MethodGet, fileName, nil)
+		if downloadErr != nil {
+			return nil, downloadErr
+		}
+		if statusCode != nil {
+			if *statusCode < 300 {
+				f.File = &fileName
+				return &fileName, nil
+			}
+			return nil, fmt.Errorf("error code returned from endpoint.  HTTP Status: %d", *statusCode)
+		}
+		return nil, errors.New("error code returned from get frost date endpoint")
+	}
+	return nil, errors.New("invalid frost date configuration cannot retrieve data")
+}
@@ -109,6 +109,26 @@ func HTTPRequest(url, method string, payload *string) (responseText *string, htt
 	return responseText, httpStatusCode, err
 }
 
+// HTTPGetWithHeaders is a GET that sends the request headers, such as If-Modified-Since, and returns the response
+// headers so the caller can honor Cache-Control, Expires and Last-Modified
+func HTTPGetWithHeaders(url string, headers map[string]string) (responseText *string, httpStatusCode *int, responseHeaders http.Header, err error) {
+	startTime := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+
+	body, httpStatusCode, responseHeaders, err := requestHeadersHelper(request, startTime)
+	responseText = utils.StringPointer(string(body))
+	return responseText, httpStatusCode, responseHeaders, err
+}
+
 // DownloadFile will be used by other various methods to call endpoints and get files of various types
 func DownloadFile(url, method, fileName string, payload *string) (httpStatusCode *int, err error) {
 	startTime := time.Now()
@@ -151,20 +171,26 @@ func DownloadFile(url, method, fileName string, payload *string) (httpStatusCode
 
 // requestHelper will be used by other various methods to call endpoints
 func requestHelper(r *http.Request, start time.Time) (body []byte, httpStatusCode *int, err error) {
+	body, httpStatusCode, _, err = requestHeadersHelper(r, start)
+	return body, httpStatusCode, err
+}
+
+// requestHeadersHelper is the requestHelper that also returns the response headers
+func requestHeadersHelper(r *http.Request, start time.Time) (body []byte, httpStatusCode *int, headers http.Header, err error) {
 	requestClient, clientErr := getHTTPClient()
 	if clientErr != nil {
-		return nil, nil, clientErr
+		return nil, nil, nil, clientErr
 	}
 
 	response, responseErr := requestClient.Do(r)
 	if responseErr != nil || response == nil || response.Body == nil {
-		return nil, nil, fmt.Errorf("%s returned error %s in %s", r.URL.String(), responseErr, log.FormatMilliseconds(time.Since(start).Abs().Milliseconds()))
+		return nil, nil, nil, fmt.Errorf("%s returned error %s in %s", r.URL.String(), responseErr, log.FormatMilliseconds(time.Since(start).Abs().Milliseconds()))
 	}
 
 	defer response.Body.Close()
 	body, bodyErr := io.ReadAll(response.Body)
 	if bodyErr != nil {
-		return nil, nil, fmt.Errorf("%s returned error %s in %s", r.URL.String(), bodyErr, log.FormatMilliseconds(time.Since(start).Abs().Milliseconds()))
+		return nil, nil, nil, fmt.Errorf("%s returned error %s in %s", r.URL.String(), bodyErr, log.FormatMilliseconds(time.Since(start).Abs().Milliseconds()))
 	}
 
 	httpStatusCode = &response.StatusCode
@@ -175,5 +201,5 @@ func requestHelper(r *http.Request, start time.Time) (body []byte, httpStatusCod
 		*httpStatusCode,
 		int64(uintptr(len(body))*reflect.TypeOf(body).Elem().Size()), log.FormatMilliseconds(time.Since(start).Abs().Milliseconds()))
 
-	return body, httpStatusCode, nil
+	return body, httpStatusCode, response.Header, nil
 }
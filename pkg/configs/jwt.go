@@ -0,0 +1,324 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package configs
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"stl-go/grow-with-stl-go/pkg/cryptography"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+const (
+	// DefaultJWTAlgorithm is the algorithm new signing keys are generated for when the config doesn't choose one
+	DefaultJWTAlgorithm = cryptography.EdDSA
+	// DefaultJWTGracePeriod is how long, in minutes, a retired signing key still validates the tokens it signed.  It's
+	// longer than a token lives so a rotation doesn't log anyone out
+	DefaultJWTGracePeriod = 2 * 60
+)
+
+var (
+	// the keys are read by every request and replaced by a rotation or a config reload
+	jwtMutex sync.RWMutex
+	// the decrypted private keys by kid so they're only decrypted and parsed once
+	jwtSigners = map[string]crypto.Signer{}
+)
+
+// JWT holds the keys the webservice's tokens are signed with.  The newest key signs every token and the keys it replaced
+// still validate the tokens they signed until their grace period, in minutes, is over.  The algorithm is RS256 or EdDSA
+type JWT struct {
+	Algorithm   *string       `json:"algorithm,omitempty"`
+	GracePeriod *int          `json:"grace_period,omitempty"`
+	Keys        []*SigningKey `json:"keys,omitempty"`
+}
+
+// SigningKey is a JWT signing key, the private key is a pem encoded PKCS #8 key encrypted with the config's secret.  A
+// key that has been rotated out has the time it was retired
+type SigningKey struct {
+	ID         *string `json:"kid,omitempty"`
+	Algorithm  *string `json:"algorithm,omitempty"`
+	PrivateKey *string `json:"privateKey,omitempty"`
+	Created    *int64  `json:"created,omitempty"`
+	Retired    *int64  `json:"retired,omitempty"`
+}
+
+func (c *Config) checkJWT() error {
+	if c != nil {
+		jwtMutex.Lock()
+		defer jwtMutex.Unlock()
+
+		if c.JWT == nil {
+			c.JWT = &JWT{}
+			rewriteConfig = true
+		}
+
+		if c.JWT.Algorithm == nil {
+			algorithm := DefaultJWTAlgorithm
+			c.JWT.Algorithm = &algorithm
+			rewriteConfig = true
+		}
+		algorithm, err := jwtAlgorithm(*c.JWT.Algorithm)
+		if err != nil {
+			return err
+		}
+		c.JWT.Algorithm = &algorithm
+
+		if c.JWT.GracePeriod == nil {
+			gracePeriod := DefaultJWTGracePeriod
+			c.JWT.GracePeriod = &gracePeriod
+			rewriteConfig = true
+		}
+		if *c.JWT.GracePeriod <= 0 {
+			return errors.New("the JWT grace period must be a positive number of minutes")
+		}
+
+		// the keys may have changed on disk so none of the ones already parsed are trusted
+		clear(jwtSigners)
+		keys := make([]*SigningKey, 0, len(c.JWT.Keys))
+		for _, key := range c.JWT.Keys {
+			if key == nil || key.PrivateKey == nil {
+				continue
+			}
+			if err := key.checkKey(); err != nil {
+				log.Errorf("removing a JWT signing key that cannot be used: %s", err)
+				rewriteConfig = true
+				continue
+			}
+			keys = append(keys, key)
+		}
+		c.JWT.Keys = keys
+		c.JWT.pruneKeys(time.Now())
+
+		// a new install, or a change of algorithm, gets a new key to sign with
+		if active := c.JWT.activeKey(); active == nil || *active.Algorithm != algorithm {
+			if _, err := c.JWT.rotate(); err != nil {
+				return err
+			}
+			rewriteConfig = true
+		}
+		return nil
+	}
+	return errors.New("invalid config cannot check JWT")
+}
+
+// checkKey encrypts a private key pasted into the config as plain text and fills in its kid and algorithm
+func (key *SigningKey) checkKey() error {
+	plaintext, err := cryptography.Decrypt(key.PrivateKey, GrowSTLGo.Secret)
+	if err != nil {
+		return err
+	}
+	signer, algorithm, err := cryptography.ParseSigningKey(plaintext)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(*key.PrivateKey, cryptography.ObfuscatedPrefix) {
+		if key.PrivateKey, err = cryptography.Encrypt(plaintext, GrowSTLGo.Secret); err != nil {
+			return err
+		}
+		rewriteConfig = true
+	}
+
+	kid, err := cryptography.KeyID(signer)
+	if err != nil {
+		return err
+	}
+	if key.ID == nil || key.Algorithm == nil || *key.ID != *kid || *key.Algorithm != *algorithm {
+		key.ID, key.Algorithm = kid, algorithm
+		rewriteConfig = true
+	}
+	if key.Created == nil {
+		created := time.Now().UnixMilli()
+		key.Created = &created
+		rewriteConfig = true
+	}
+	jwtSigners[*key.ID] = signer
+	return nil
+}
+
+func jwtAlgorithm(algorithm string) (string, error) {
+	switch {
+	case strings.EqualFold(algorithm, cryptography.RS256):
+		return cryptography.RS256, nil
+	case strings.EqualFold(algorithm, cryptography.EdDSA):
+		return cryptography.EdDSA, nil
+	}
+	return "", fmt.Errorf("unknown JWT algorithm %s, it must be %s or %s", algorithm, cryptography.RS256, cryptography.EdDSA)
+}
+
+// activeKey is the newest key that hasn't been retired
+func (j *JWT) activeKey() *SigningKey {
+	var active *SigningKey
+	for _, key := range j.Keys {
+		if key.Retired == nil && (active == nil || *key.Created > *active.Created) {
+			active = key
+		}
+	}
+	return active
+}
+
+// validKey is the key with the kid if it's the active key or it was retired less than the grace period ago
+func (j *JWT) validKey(kid string, now time.Time) *SigningKey {
+	gracePeriod := time.Duration(*j.GracePeriod) * time.Minute
+	for _, key := range j.Keys {
+		if *key.ID == kid && (key.Retired == nil || now.Before(time.UnixMilli(*key.Retired).Add(gracePeriod))) {
+			return key
+		}
+	}
+	return nil
+}
+
+// pruneKeys drops the keys whose grace period is over
+func (j *JWT) pruneKeys(now time.Time) {
+	keys := make([]*SigningKey, 0, len(j.Keys))
+	for _, key := range j.Keys {
+		if key.Retired == nil || j.validKey(*key.ID, now) != nil {
+			keys = append(keys, key)
+			continue
+		}
+		delete(jwtSigners, *key.ID)
+		rewriteConfig = true
+	}
+	j.Keys = keys
+}
+
+// rotate generates a new key with the configured algorithm and retires the others
+func (j *JWT) rotate() (*SigningKey, error) {
+	pemKey, err := cryptography.GenerateSigningKey(*j.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	signer, algorithm, err := cryptography.ParseSigningKey(pemKey)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := cryptography.KeyID(signer)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := cryptography.Encrypt(pemKey, GrowSTLGo.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	retired := now.UnixMilli()
+	for _, key := range j.Keys {
+		if key.Retired == nil {
+			key.Retired = &retired
+		}
+	}
+	j.pruneKeys(now)
+
+	created := now.UnixMilli()
+	key := &SigningKey{
+		ID:         kid,
+		Algorithm:  algorithm,
+		PrivateKey: cipherText,
+		Created:    &created,
+	}
+	j.Keys = append(j.Keys, key)
+	jwtSigners[*kid] = signer
+	log.Infof("JWT signing key %s generated for %s", *kid, *algorithm)
+	return key, nil
+}
+
+// public is a copy of the key without its private key
+func (key *SigningKey) public() *SigningKey {
+	return &SigningKey{
+		ID:        key.ID,
+		Algorithm: key.Algorithm,
+		Created:   key.Created,
+		Retired:   key.Retired,
+	}
+}
+
+// RotateJWTKey generates a new signing key, the key it replaces validates the tokens it signed until the grace period
+// is over.  The config is written out so the keys survive a restart
+func RotateJWTKey() (*SigningKey, error) {
+	if GrowSTLGo == nil || GrowSTLGo.JWT == nil || GrowSTLGo.JWT.Algorithm == nil {
+		return nil, errors.New("invalid config cannot rotate the JWT signing key")
+	}
+
+	jwtMutex.Lock()
+	defer jwtMutex.Unlock()
+	key, err := GrowSTLGo.JWT.rotate()
+	if err != nil {
+		return nil, err
+	}
+	if err := GrowSTLGo.persist(); err != nil {
+		return nil, err
+	}
+	return key.public(), nil
+}
+
+// GetJWTKeys returns the signing keys without their private keys, oldest first
+func GetJWTKeys() ([]*SigningKey, error) {
+	if GrowSTLGo == nil || GrowSTLGo.JWT == nil {
+		return nil, errors.New("invalid config cannot retrieve the JWT signing keys")
+	}
+
+	jwtMutex.RLock()
+	defer jwtMutex.RUnlock()
+	keys := make([]*SigningKey, 0, len(GrowSTLGo.JWT.Keys))
+	for _, key := range GrowSTLGo.JWT.Keys {
+		keys = append(keys, key.public())
+	}
+	return keys, nil
+}
+
+// GetJWTSigningKey returns the kid, algorithm and private key new tokens are signed with
+func GetJWTSigningKey() (kid, algorithm *string, signer crypto.Signer, err error) {
+	if GrowSTLGo == nil || GrowSTLGo.JWT == nil {
+		return nil, nil, nil, errors.New("invalid config cannot sign a JWT")
+	}
+
+	jwtMutex.RLock()
+	defer jwtMutex.RUnlock()
+	key := GrowSTLGo.JWT.activeKey()
+	if key == nil {
+		return nil, nil, nil, errors.New("no JWT signing key found")
+	}
+	signer, ok := jwtSigners[*key.ID]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("JWT signing key %s has not been loaded", *key.ID)
+	}
+	return key.ID, key.Algorithm, signer, nil
+}
+
+// GetJWTVerificationKey returns the algorithm and public key of the kid if it's the active key or it's still in its
+// grace period
+func GetJWTVerificationKey(kid string) (algorithm *string, publicKey crypto.PublicKey, err error) {
+	if GrowSTLGo == nil || GrowSTLGo.JWT == nil {
+		return nil, nil, errors.New("invalid config cannot validate a JWT")
+	}
+
+	jwtMutex.RLock()
+	defer jwtMutex.RUnlock()
+	key := GrowSTLGo.JWT.validKey(kid, time.Now())
+	if key == nil {
+		return nil, nil, fmt.Errorf("JWT signing key %s is unknown or has expired", kid)
+	}
+	signer, ok := jwtSigners[kid]
+	if !ok {
+		return nil, nil, fmt.Errorf("JWT signing key %s has not been loaded", kid)
+	}
+	return key.Algorithm, signer.Public(), nil
+}
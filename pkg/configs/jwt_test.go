@@ -0,0 +1,138 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package configs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/cryptography"
+)
+
+func TestJWTFunctions(t *testing.T) {
+	initConfigTest()
+	require.NoError(t, SetGrowSTLGoConfig())
+
+	t.Run("Test a signing key is generated", func(t *testing.T) {
+		require.Equal(t, DefaultJWTAlgorithm, *GrowSTLGo.JWT.Algorithm)
+		require.Equal(t, DefaultJWTGracePeriod, *GrowSTLGo.JWT.GracePeriod)
+
+		kid, algorithm, signer, err := GetJWTSigningKey()
+		require.NoError(t, err)
+		require.Equal(t, cryptography.EdDSA, *algorithm)
+		require.NotNil(t, signer)
+
+		for _, key := range GrowSTLGo.JWT.Keys {
+			require.True(t, strings.HasPrefix(*key.PrivateKey, cryptography.ObfuscatedPrefix))
+		}
+
+		_, publicKey, err := GetJWTVerificationKey(*kid)
+		require.NoError(t, err)
+		require.Equal(t, signer.Public(), publicKey)
+
+		_, _, err = GetJWTVerificationKey("bogus")
+		require.Error(t, err)
+	})
+
+	t.Run("Test rotating the signing key", func(t *testing.T) {
+		oldKid, _, _, err := GetJWTSigningKey()
+		require.NoError(t, err)
+
+		key, err := RotateJWTKey()
+		require.NoError(t, err)
+		require.NotEqual(t, *oldKid, *key.ID)
+		require.Nil(t, key.PrivateKey)
+
+		kid, _, _, err := GetJWTSigningKey()
+		require.NoError(t, err)
+		require.Equal(t, *key.ID, *kid)
+
+		// the old key still validates the tokens it signed during the grace period
+		_, _, err = GetJWTVerificationKey(*oldKid)
+		require.NoError(t, err)
+
+		keys, err := GetJWTKeys()
+		require.NoError(t, err)
+		retired := false
+		for _, listed := range keys {
+			require.Nil(t, listed.PrivateKey)
+			if *listed.ID == *oldKid {
+				require.NotNil(t, listed.Retired)
+				retired = true
+			}
+		}
+		require.True(t, retired)
+
+		// once the grace period is over it doesn't
+		jwtMutex.Lock()
+		expired := time.Now().Add(-time.Duration(*GrowSTLGo.JWT.GracePeriod+1) * time.Minute).UnixMilli()
+		for _, listed := range GrowSTLGo.JWT.Keys {
+			if *listed.ID == *oldKid {
+				listed.Retired = &expired
+			}
+		}
+		jwtMutex.Unlock()
+		_, _, err = GetJWTVerificationKey(*oldKid)
+		require.Error(t, err)
+
+		jwtMutex.Lock()
+		GrowSTLGo.JWT.pruneKeys(time.Now())
+		jwtMutex.Unlock()
+		keys, err = GetJWTKeys()
+		require.NoError(t, err)
+		for _, listed := range keys {
+			require.NotEqual(t, *oldKid, *listed.ID)
+		}
+	})
+
+	t.Run("Test the JWT config", func(t *testing.T) {
+		algorithm := "rs256"
+		GrowSTLGo.JWT.Algorithm = &algorithm
+		require.NoError(t, GrowSTLGo.checkJWT())
+		_, signingAlgorithm, _, err := GetJWTSigningKey()
+		require.NoError(t, err)
+		require.Equal(t, cryptography.RS256, *signingAlgorithm)
+
+		// a key pasted into the config is encrypted and gets its kid
+		pemKey, err := cryptography.GenerateSigningKey(cryptography.EdDSA)
+		require.NoError(t, err)
+		algorithm = cryptography.EdDSA
+		GrowSTLGo.JWT.Algorithm = &algorithm
+		GrowSTLGo.JWT.Keys = append(GrowSTLGo.JWT.Keys, &SigningKey{PrivateKey: pemKey})
+		require.NoError(t, GrowSTLGo.checkJWT())
+		kid, signingAlgorithm, _, err := GetJWTSigningKey()
+		require.NoError(t, err)
+		require.Equal(t, cryptography.EdDSA, *signingAlgorithm)
+		for _, key := range GrowSTLGo.JWT.Keys {
+			require.True(t, strings.HasPrefix(*key.PrivateKey, cryptography.ObfuscatedPrefix))
+		}
+		_, _, err = GetJWTVerificationKey(*kid)
+		require.NoError(t, err)
+
+		badAlgorithm := "HS256"
+		GrowSTLGo.JWT.Algorithm = &badAlgorithm
+		require.Error(t, GrowSTLGo.checkJWT())
+		GrowSTLGo.JWT.Algorithm = &algorithm
+
+		gracePeriod := 0
+		GrowSTLGo.JWT.GracePeriod = &gracePeriod
+		require.Error(t, GrowSTLGo.checkJWT())
+		gracePeriod = DefaultJWTGracePeriod
+		require.NoError(t, GrowSTLGo.checkJWT())
+	})
+}
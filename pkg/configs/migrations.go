@@ -0,0 +1,320 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package configs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+// Migration is a versioned change to the embedded database owned by a package
+type Migration struct {
+	Version     int
+	Description string
+	// Table is the table the migration alters, if it doesn't exist yet the migration is recorded without being run
+	// because the package's CreateTable will build the table with its current definition
+	Table   string
+	UpSQL   []string
+	DownSQL []string
+	Up      func(tx *sql.Tx) error
+	Down    func(tx *sql.Tx) error
+}
+
+// MigrationStatus is the state of a registered migration
+type MigrationStatus struct {
+	Package     *string `json:"package,omitempty"`
+	Version     *int    `json:"version,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Applied     *int64  `json:"applied,omitempty"`
+}
+
+var (
+	// AutoMigrate determines if pending migrations are applied when the database is started
+	AutoMigrate = true
+
+	migrations        = make(map[string][]*Migration)
+	migrationPackages []string
+	migrationsMutex   sync.Mutex
+
+	migrationTableName = "schema_migrations"
+	migrationTable     = &Table{
+		CreateSQL: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			package varchar(128) NOT NULL,
+			version int NOT NULL,
+			description varchar(1024),
+			applied bigint NOT NULL,
+			PRIMARY KEY (package, version))`,
+		InsertSQL: "INSERT INTO schema_migrations values(?,?,?,?)",
+		DeleteSQL: "DELETE FROM schema_migrations where package = ? and version = ?",
+	}
+)
+
+// RegisterMigrations adds a package's migrations to the registry, packages are migrated in the order they register
+// and their migrations are applied in version order.  This is expected to be called from the package's init function
+// so the migrations are known before the database is started
+func RegisterMigrations(pkg string, packageMigrations ...*Migration) {
+	migrationsMutex.Lock()
+	defer migrationsMutex.Unlock()
+	if _, ok := migrations[pkg]; !ok {
+		migrationPackages = append(migrationPackages, pkg)
+	}
+	migrations[pkg] = append(migrations[pkg], packageMigrations...)
+	slices.SortFunc(migrations[pkg], func(a, b *Migration) int {
+		return a.Version - b.Version
+	})
+}
+
+// startMigrations makes sure the schema_migrations table exists and applies the pending migrations if AutoMigrate is set
+func startMigrations() error {
+	if err := migrationTable.CreateTable(&migrationTableName); err != nil {
+		return err
+	}
+
+	if AutoMigrate {
+		applied, err := Migrate()
+		if err != nil {
+			return err
+		}
+		if len(applied) > 0 {
+			log.Infof("%d database migrations applied", len(applied))
+		}
+	}
+	return nil
+}
+
+// Migrate applies every pending migration, each in its own transaction.  It stops at the first failure
+func Migrate() ([]*MigrationStatus, error) {
+	db, dbErr := GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, dbErr
+	}
+
+	appliedVersions, err := getAppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []*MigrationStatus
+	migrationsMutex.Lock()
+	defer migrationsMutex.Unlock()
+	for _, pkg := range migrationPackages {
+		for _, migration := range migrations[pkg] {
+			if _, ok := appliedVersions[pkg][migration.Version]; ok {
+				continue
+			}
+
+			status, err := migration.apply(db, pkg)
+			if err != nil {
+				return applied, fmt.Errorf("%s migration %d '%s' failed.  Error: %s", pkg, migration.Version, migration.Description, err)
+			}
+			applied = append(applied, status)
+		}
+	}
+	return applied, nil
+}
+
+// Rollback reverts the last applied migrations for a package
+func Rollback(pkg string, steps int) ([]*MigrationStatus, error) {
+	db, dbErr := GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, dbErr
+	}
+
+	appliedVersions, err := getAppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationsMutex.Lock()
+	defer migrationsMutex.Unlock()
+	packageMigrations, ok := migrations[pkg]
+	if !ok {
+		return nil, fmt.Errorf("no migrations registered for %s", pkg)
+	}
+
+	var rolledBack []*MigrationStatus
+	for i := len(packageMigrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		migration := packageMigrations[i]
+		if _, ok := appliedVersions[pkg][migration.Version]; !ok {
+			continue
+		}
+
+		if err := migration.revert(db, pkg); err != nil {
+			return rolledBack, fmt.Errorf("%s migration %d '%s' rollback failed.  Error: %s", pkg, migration.Version, migration.Description, err)
+		}
+		rolledBack = append(rolledBack, &MigrationStatus{
+			Package:     &pkg,
+			Version:     &migration.Version,
+			Description: &migration.Description,
+		})
+	}
+	return rolledBack, nil
+}
+
+// GetMigrationStatus reports every registered migration and when it was applied, pending migrations have no applied time
+func GetMigrationStatus() ([]*MigrationStatus, error) {
+	db, dbErr := GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, dbErr
+	}
+
+	appliedVersions, err := getAppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []*MigrationStatus
+	migrationsMutex.Lock()
+	defer migrationsMutex.Unlock()
+	for _, pkg := range migrationPackages {
+		for _, migration := range migrations[pkg] {
+			status := &MigrationStatus{
+				Package:     &pkg,
+				Version:     &migration.Version,
+				Description: &migration.Description,
+			}
+			if applied, ok := appliedVersions[pkg][migration.Version]; ok {
+				status.Applied = &applied
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+// getAppliedMigrations returns the applied time of each migration keyed by package and version
+func getAppliedMigrations(db *sql.DB) (map[string]map[int]int64, error) {
+	rows, err := db.Query("select package, version, applied from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]map[int]int64)
+	for rows.Next() {
+		var pkg string
+		var version int
+		var appliedTime int64
+		if err := rows.Scan(&pkg, &version, &appliedTime); err != nil {
+			return nil, err
+		}
+		if _, ok := applied[pkg]; !ok {
+			applied[pkg] = make(map[int]int64)
+		}
+		applied[pkg][version] = appliedTime
+	}
+	return applied, rows.Err()
+}
+
+func (migration *Migration) apply(db *sql.DB, pkg string) (*MigrationStatus, error) {
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer RollbackHelper(tx)
+
+	exists := true
+	if migration.Table != "" {
+		if exists, err = TableExists(tx, migration.Table); err != nil {
+			return nil, err
+		}
+	}
+
+	if exists {
+		log.Infof("Applying %s migration %d: %s", pkg, migration.Version, migration.Description)
+		if err := runMigrationStep(ctx, tx, migration.UpSQL, migration.Up); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Debugf("Table %s does not exist yet, recording %s migration %d as applied", migration.Table, pkg, migration.Version)
+	}
+
+	applied := time.Now().UnixMilli()
+	if _, err := tx.ExecContext(ctx, migrationTable.InsertSQL, pkg, migration.Version, migration.Description, applied); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &MigrationStatus{
+		Package:     &pkg,
+		Version:     &migration.Version,
+		Description: &migration.Description,
+		Applied:     &applied,
+	}, nil
+}
+
+func (migration *Migration) revert(db *sql.DB, pkg string) error {
+	if len(migration.DownSQL) == 0 && migration.Down == nil {
+		return errors.New("migration does not support rollback")
+	}
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer RollbackHelper(tx)
+
+	log.Infof("Rolling back %s migration %d: %s", pkg, migration.Version, migration.Description)
+	if err := runMigrationStep(ctx, tx, migration.DownSQL, migration.Down); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, migrationTable.DeleteSQL, pkg, migration.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func runMigrationStep(ctx context.Context, tx *sql.Tx, statements []string, function func(tx *sql.Tx) error) error {
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("unable to execute '%s'.  Error: %s", statement, err)
+		}
+	}
+	if function != nil {
+		return function(tx)
+	}
+	return nil
+}
+
+// TableExists tests the sqlite master table to see if the table has been created
+func TableExists(tx *sql.Tx, tableName string) (bool, error) {
+	var count int
+	if err := tx.QueryRow("select count(*) from sqlite_master where type = 'table' and name = ?", tableName).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ColumnExists tests the table info to see if the table has the column
+func ColumnExists(tx *sql.Tx, tableName, columnName string) (bool, error) {
+	var count int
+	if err := tx.QueryRow("select count(*) from pragma_table_info(?) where name = ?", tableName, columnName).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,97 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package configs
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationFunctions(t *testing.T) {
+	initConfigTest()
+	require.NoError(t, SetGrowSTLGoConfig())
+
+	pkg := "migrationtest"
+	RegisterMigrations(pkg,
+		&Migration{
+			Version:     2,
+			Description: "add a column with a go function",
+			Table:       "migration_test",
+			Up: func(tx *sql.Tx) error {
+				_, err := tx.Exec("ALTER TABLE migration_test ADD COLUMN note varchar(128)")
+				return err
+			},
+			DownSQL: []string{
+				"ALTER TABLE migration_test RENAME TO migration_test_rebuild",
+				"CREATE TABLE migration_test (id int NOT NULL)",
+				"INSERT INTO migration_test select id from migration_test_rebuild",
+				"DROP TABLE migration_test_rebuild",
+			},
+		},
+		&Migration{
+			Version:     1,
+			Description: "create a table with sql",
+			UpSQL:       []string{"CREATE TABLE IF NOT EXISTS migration_test (id int NOT NULL)"},
+			DownSQL:     []string{"DROP TABLE migration_test"},
+		},
+	)
+
+	db, err := GetSQLiteConnection()
+	require.NoError(t, err)
+
+	t.Run("Test migrate", func(t *testing.T) {
+		_, err := Migrate()
+		require.NoError(t, err)
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer RollbackHelper(tx)
+		exists, err := ColumnExists(tx, "migration_test", "note")
+		require.NoError(t, err)
+		require.True(t, exists)
+	})
+
+	t.Run("Test status", func(t *testing.T) {
+		statuses, err := GetMigrationStatus()
+		require.NoError(t, err)
+		found := 0
+		for _, status := range statuses {
+			if *status.Package == pkg {
+				require.NotNil(t, status.Applied)
+				found++
+			}
+		}
+		require.Equal(t, 2, found)
+	})
+
+	t.Run("Test rollback", func(t *testing.T) {
+		rolledBack, err := Rollback(pkg, 2)
+		require.NoError(t, err)
+		require.Len(t, rolledBack, 2)
+		require.Equal(t, 2, *rolledBack[0].Version)
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer RollbackHelper(tx)
+		exists, err := TableExists(tx, "migration_test")
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		_, err = Rollback("not-registered", 1)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,111 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// the weather providers the forecasts can be retrieved from
+const (
+	// NWSProvider is api.weather.gov, it only covers the US
+	NWSProvider = "nws"
+	// OpenMeteoProvider is an Open-Meteo style forecast api
+	OpenMeteoProvider = "openmeteo"
+	// FixtureProvider serves the forecast in a local file for every location, for offline development and tests
+	FixtureProvider = "fixture"
+
+	// DefaultForecastInterval is how often, in minutes, the forecasts of the users' locations are refreshed
+	DefaultForecastInterval = 60
+	// DefaultLocationInterval is how often, in minutes, the country's location data is downloaded again
+	DefaultLocationInterval = 24 * 60
+	// DefaultObservationInterval is how often, in minutes, the daily observations of the users' locations are retrieved
+	DefaultObservationInterval = 6 * 60
+)
+
+// Weather selects the provider forecasts are retrieved from.  The URL is the provider's api, the provider's public
+// api is used without one.  The file is the forecast served by the fixture provider, without one the fixture shipped
+// with the weather package is served.  The observation URL is the Open-Meteo style historical api the daily highs and
+// lows are retrieved from, Open-Meteo's public archive is used without one.  The intervals are in minutes
+type Weather struct {
+	Provider            *string `json:"provider,omitempty"`
+	URL                 *string `json:"url,omitempty"`
+	File                *string `json:"file,omitempty"`
+	ForecastInterval    *int    `json:"forecast_interval,omitempty"`
+	LocationInterval    *int    `json:"location_interval,omitempty"`
+	ObservationURL      *string `json:"observation_url,omitempty"`
+	ObservationInterval *int    `json:"observation_interval,omitempty"`
+}
+
+func (c *Config) checkWeather() error {
+	if c != nil {
+		if c.Weather == nil {
+			// configs from before the providers carry their weather api over to the nws provider
+			provider := NWSProvider
+			c.Weather = &Weather{
+				Provider: &provider,
+				URL:      c.WeatherAPI,
+			}
+			c.WeatherAPI = nil
+			rewriteConfig = true
+		}
+
+		if c.Weather.Provider == nil {
+			provider := NWSProvider
+			c.Weather.Provider = &provider
+			rewriteConfig = true
+		}
+
+		provider := strings.ToLower(*c.Weather.Provider)
+		switch provider {
+		case NWSProvider, OpenMeteoProvider, FixtureProvider:
+			c.Weather.Provider = &provider
+		default:
+			return fmt.Errorf("unknown weather provider %s", *c.Weather.Provider)
+		}
+
+		if c.Weather.ForecastInterval == nil {
+			interval := DefaultForecastInterval
+			c.Weather.ForecastInterval = &interval
+			rewriteConfig = true
+		}
+		if c.Weather.LocationInterval == nil {
+			interval := DefaultLocationInterval
+			c.Weather.LocationInterval = &interval
+			rewriteConfig = true
+		}
+		if c.Weather.ObservationInterval == nil {
+			interval := DefaultObservationInterval
+			c.Weather.ObservationInterval = &interval
+			rewriteConfig = true
+		}
+		if *c.Weather.ForecastInterval <= 0 || *c.Weather.LocationInterval <= 0 || *c.Weather.ObservationInterval <= 0 {
+			return errors.New("the weather refresh intervals must be a positive number of minutes")
+		}
+
+		for _, configured := range []*string{c.Weather.URL, c.Weather.ObservationURL} {
+			if configured != nil {
+				if _, err := url.Parse(*configured); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return errors.New("invalid config cannot check weather")
+}
@@ -0,0 +1,65 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package configs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeatherFunctions(t *testing.T) {
+	t.Run("Test the weather api carries over to the nws provider", func(t *testing.T) {
+		weatherAPI := "https://weather.example.com"
+		c := &Config{WeatherAPI: &weatherAPI}
+		require.NoError(t, c.checkWeather())
+		require.Equal(t, NWSProvider, *c.Weather.Provider)
+		require.Equal(t, weatherAPI, *c.Weather.URL)
+		require.Nil(t, c.WeatherAPI)
+		require.Equal(t, DefaultForecastInterval, *c.Weather.ForecastInterval)
+		require.Equal(t, DefaultLocationInterval, *c.Weather.LocationInterval)
+		require.Equal(t, DefaultObservationInterval, *c.Weather.ObservationInterval)
+	})
+
+	t.Run("Test the configured provider is kept", func(t *testing.T) {
+		provider := "OpenMeteo"
+		c := &Config{Weather: &Weather{Provider: &provider}}
+		require.NoError(t, c.checkWeather())
+		require.Equal(t, OpenMeteoProvider, *c.Weather.Provider)
+		require.Nil(t, c.Weather.URL)
+	})
+
+	t.Run("Test the refresh intervals", func(t *testing.T) {
+		provider := NWSProvider
+		interval := 15
+		c := &Config{Weather: &Weather{Provider: &provider, ForecastInterval: &interval}}
+		require.NoError(t, c.checkWeather())
+		require.Equal(t, 15, *c.Weather.ForecastInterval)
+
+		interval = 0
+		require.Error(t, c.checkWeather())
+
+		interval = 15
+		observationInterval := -1
+		c.Weather.ObservationInterval = &observationInterval
+		require.Error(t, c.checkWeather())
+	})
+
+	t.Run("Test an unknown provider", func(t *testing.T) {
+		provider := "bogus"
+		c := &Config{Weather: &Weather{Provider: &provider}}
+		require.Error(t, c.checkWeather())
+	})
+}
@@ -0,0 +1,114 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package cryptography
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// the JWT algorithms a signing key can be generated for
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+
+	signingKeySize = 2048
+	signingKeyType = "PRIVATE KEY"
+)
+
+// GenerateSigningKey returns a new pem encoded PKCS #8 private key for the RS256 or EdDSA algorithm
+func GenerateSigningKey(algorithm string) (*string, error) {
+	var privateKey crypto.Signer
+	var err error
+	switch algorithm {
+	case RS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, signingKeySize)
+	case EdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("algorithm %s must be %s or %s", algorithm, RS256, EdDSA)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := pem.Encode(buf, &pem.Block{Type: signingKeyType, Bytes: der}); err != nil {
+		return nil, err
+	}
+	pemKey := buf.String()
+	return &pemKey, nil
+}
+
+// ParseSigningKey returns the private key of a pem encoded PKCS #8 signing key and the algorithm it signs with
+func ParseSigningKey(pemKey *string) (crypto.Signer, *string, error) {
+	if pemKey == nil {
+		return nil, nil, errors.New("signing key is nil")
+	}
+
+	block, _ := pem.Decode([]byte(*pemKey))
+	if block == nil || block.Type != signingKeyType {
+		return nil, nil, errors.New("signing key is not a pem encoded PKCS #8 private key")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	algorithm := RS256
+	switch privateKey := key.(type) {
+	case *rsa.PrivateKey:
+		if privateKey.N.BitLen() < signingKeySize {
+			return nil, nil, fmt.Errorf("an RSA signing key must be at least %d bits", signingKeySize)
+		}
+		return privateKey, &algorithm, nil
+	case ed25519.PrivateKey:
+		algorithm = EdDSA
+		return privateKey, &algorithm, nil
+	}
+	return nil, nil, fmt.Errorf("signing key type %T is not supported", key)
+}
+
+// KeyID is the base64 url encoded sha256 thumbprint of the public half of a signing key, the same key always has the
+// same id so it can be found again from the kid header of the tokens it signed
+func KeyID(privateKey crypto.Signer) (*string, error) {
+	if privateKey == nil {
+		return nil, errors.New("signing key is nil")
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(der)
+	kid := base64.RawURLEncoding.EncodeToString(sum[:16])
+	return &kid, nil
+}
@@ -0,0 +1,68 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package cryptography
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningFunctions(t *testing.T) {
+	t.Run("Test generating an EdDSA signing key", func(t *testing.T) {
+		pemKey, err := GenerateSigningKey(EdDSA)
+		require.NoError(t, err)
+
+		privateKey, algorithm, err := ParseSigningKey(pemKey)
+		require.NoError(t, err)
+		require.Equal(t, EdDSA, *algorithm)
+		_, ok := privateKey.(ed25519.PrivateKey)
+		require.True(t, ok)
+
+		kid, err := KeyID(privateKey)
+		require.NoError(t, err)
+		again, err := KeyID(privateKey)
+		require.NoError(t, err)
+		require.Equal(t, *kid, *again)
+	})
+
+	t.Run("Test generating an RS256 signing key", func(t *testing.T) {
+		pemKey, err := GenerateSigningKey(RS256)
+		require.NoError(t, err)
+
+		privateKey, algorithm, err := ParseSigningKey(pemKey)
+		require.NoError(t, err)
+		require.Equal(t, RS256, *algorithm)
+		_, ok := privateKey.(*rsa.PrivateKey)
+		require.True(t, ok)
+	})
+
+	t.Run("Test bad signing keys", func(t *testing.T) {
+		_, err := GenerateSigningKey("HS256")
+		require.Error(t, err)
+
+		_, _, err = ParseSigningKey(nil)
+		require.Error(t, err)
+
+		notAKey := "grow-with-stl-go!"
+		_, _, err = ParseSigningKey(&notAKey)
+		require.Error(t, err)
+
+		_, err = KeyID(nil)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,241 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package garden
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+// how much sun a bed gets, full is 6 or more hours of direct sun a day, partial is 3 to 6 and shade is less than 3
+const (
+	FullSun    = "full"
+	PartialSun = "partial"
+	Shade      = "shade"
+)
+
+var (
+	// errNotFound is returned when the bed, planting or event doesn't exist or belongs to another user
+	errNotFound = errors.New("not found")
+
+	bedTableName = "gardenBeds"
+	bedTable     = &configs.Table{
+		CreateSQL: `CREATE TABLE IF NOT EXISTS gardenBeds (
+			id varchar(64) NOT NULL PRIMARY KEY,
+			user varchar(128) NOT NULL,
+			name varchar(256) NOT NULL,
+			width real NOT NULL,
+			length real NOT NULL,
+			sun varchar(16) NOT NULL,
+			notes varchar(4096),
+			created bigint NOT NULL,
+			updated bigint NOT NULL)`,
+		InsertSQL: "INSERT INTO gardenBeds values(?,?,?,?,?,?,?,?,?)",
+		UpdateSQL: "UPDATE gardenBeds set name = ?, width = ?, length = ?, sun = ?, notes = ?, updated = ? where id = ? and user = ?",
+		DeleteSQL: "DELETE FROM gardenBeds where id = ? and user = ?",
+		Indices: []string{
+			"CREATE INDEX IF NOT EXISTS gardenbeduser on gardenBeds(user)",
+		},
+	}
+)
+
+// Bed is a garden bed of the user's, the width and length are in feet
+type Bed struct {
+	ID      *string  `json:"id,omitempty"`
+	User    *string  `json:"user,omitempty"`
+	Name    *string  `json:"name,omitempty"`
+	Width   *float64 `json:"width,omitempty"`
+	Length  *float64 `json:"length,omitempty"`
+	Sun     *string  `json:"sun,omitempty"`
+	Notes   *string  `json:"notes,omitempty"`
+	Created *int64   `json:"created,omitempty"`
+	Updated *int64   `json:"updated,omitempty"`
+}
+
+// getBeds returns the user's beds by name
+func getBeds(userID *string) ([]*Bed, error) {
+	if userID == nil {
+		return nil, errors.New("no user found, cannot retrieve beds")
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve beds, sqlite error: %s", dbErr)
+	}
+
+	rows, err := db.Query("select * from gardenBeds where user = ? order by name", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	beds := []*Bed{}
+	for rows.Next() {
+		bed, err := scanBed(rows)
+		if err != nil {
+			return nil, err
+		}
+		beds = append(beds, bed)
+	}
+	return beds, rows.Err()
+}
+
+// getBed returns the user's bed, a bed of another user's is not found
+func getBed(userID, bedID *string) (*Bed, error) {
+	if userID == nil || bedID == nil {
+		return nil, errors.New("no user or bed found, cannot retrieve bed")
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve bed, sqlite error: %s", dbErr)
+	}
+
+	bed, err := scanBed(db.QueryRow("select * from gardenBeds where id = ? and user = ?", bedID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("bed %s %w", *bedID, errNotFound)
+	}
+	return bed, err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBed(row scanner) (*Bed, error) {
+	bed := Bed{}
+	if err := row.Scan(&bed.ID, &bed.User, &bed.Name, &bed.Width, &bed.Length, &bed.Sun, &bed.Notes, &bed.Created,
+		&bed.Updated); err != nil {
+		return nil, err
+	}
+	return &bed, nil
+}
+
+// addBed creates a bed for the user, a name and positive dimensions are required and the sun defaults to full
+func addBed(userID *string, data interface{}) (*Bed, error) {
+	if userID == nil {
+		return nil, errors.New("no user found, cannot add bed")
+	}
+
+	var bed Bed
+	if err := unmarshalData(data, &bed); err != nil {
+		return nil, err
+	}
+	if err := bed.validate(); err != nil {
+		return nil, err
+	}
+
+	id := uuid.New().String()
+	now := time.Now().UnixMilli()
+	bed.ID, bed.User, bed.Created, bed.Updated = &id, userID, &now, &now
+	if _, err := bedTable.Exec(bedTable.InsertSQL, bed.ID, bed.User, bed.Name, bed.Width, bed.Length, bed.Sun, bed.Notes,
+		bed.Created, bed.Updated); err != nil {
+		return nil, err
+	}
+	return &bed, nil
+}
+
+// updateBed changes the fields of the user's bed that are in the data
+func updateBed(userID, bedID *string, data interface{}) (*Bed, error) {
+	bed, err := getBed(userID, bedID)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes Bed
+	if err := unmarshalData(data, &changes); err != nil {
+		return nil, err
+	}
+	if changes.Name != nil {
+		bed.Name = changes.Name
+	}
+	if changes.Width != nil {
+		bed.Width = changes.Width
+	}
+	if changes.Length != nil {
+		bed.Length = changes.Length
+	}
+	if changes.Sun != nil {
+		bed.Sun = changes.Sun
+	}
+	if changes.Notes != nil {
+		bed.Notes = changes.Notes
+	}
+	if err := bed.validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixMilli()
+	bed.Updated = &now
+	rows, err := bedTable.Exec(bedTable.UpdateSQL, bed.Name, bed.Width, bed.Length, bed.Sun, bed.Notes, bed.Updated, bed.ID,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("bed %s %w", *bedID, errNotFound)
+	}
+	return bed, nil
+}
+
+// removeBed deletes the user's bed along with its plantings and their events
+func removeBed(userID, bedID *string) (*Bed, error) {
+	bed, err := getBed(userID, bedID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := bedTable.Exec(bedTable.DeleteSQL, bedID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("bed %s %w", *bedID, errNotFound)
+	}
+	return bed, nil
+}
+
+// validate checks the bed and fills in the sun when it isn't given
+func (bed *Bed) validate() error {
+	if bed.Name == nil || strings.TrimSpace(*bed.Name) == "" {
+		return errors.New("a bed requires a name")
+	}
+	name := strings.TrimSpace(*bed.Name)
+	bed.Name = &name
+
+	if bed.Width == nil || *bed.Width <= 0 || bed.Length == nil || *bed.Length <= 0 {
+		return errors.New("a bed requires a width and length greater than 0")
+	}
+
+	if bed.Sun == nil || *bed.Sun == "" {
+		sun := FullSun
+		bed.Sun = &sun
+	}
+	sun := strings.ToLower(*bed.Sun)
+	switch sun {
+	case FullSun, PartialSun, Shade:
+		bed.Sun = &sun
+	default:
+		return fmt.Errorf("sun %s must be one of %s, %s or %s", *bed.Sun, FullSun, PartialSun, Shade)
+	}
+	return nil
+}
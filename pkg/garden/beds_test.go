@@ -0,0 +1,77 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package garden
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBedFunctions(t *testing.T) {
+	initGardenTest()
+	user := uuid.New().String()
+
+	t.Run("Test bed validation", func(t *testing.T) {
+		_, err := addBed(nil, map[string]interface{}{"name": "No user", "width": 4, "length": 8})
+		require.Error(t, err)
+		_, err = addBed(&user, map[string]interface{}{"name": " ", "width": 4, "length": 8})
+		require.Error(t, err)
+		_, err = addBed(&user, map[string]interface{}{"name": "Flat", "width": 0, "length": 8})
+		require.Error(t, err)
+		_, err = addBed(&user, map[string]interface{}{"name": "Dark", "width": 4, "length": 8, "sun": "moon"})
+		require.Error(t, err)
+		_, err = addBed(&user, "bogus")
+		require.Error(t, err)
+	})
+
+	t.Run("Test adding, updating and removing a bed", func(t *testing.T) {
+		bed, err := addBed(&user, map[string]interface{}{"name": " North bed ", "width": 4, "length": 12.5})
+		require.NoError(t, err)
+		require.Equal(t, "North bed", *bed.Name)
+		require.Equal(t, FullSun, *bed.Sun)
+
+		beds, err := getBeds(&user)
+		require.NoError(t, err)
+		require.Len(t, beds, 1)
+		require.Equal(t, 12.5, *beds[0].Length)
+
+		updated, err := updateBed(&user, bed.ID, map[string]interface{}{"sun": "Shade", "notes": "under the oak"})
+		require.NoError(t, err)
+		require.Equal(t, Shade, *updated.Sun)
+		require.Equal(t, "North bed", *updated.Name)
+		require.Equal(t, "under the oak", *updated.Notes)
+
+		_, err = updateBed(&user, bed.ID, map[string]interface{}{"width": -1})
+		require.Error(t, err)
+
+		// another user can't see or change the bed
+		other := uuid.New().String()
+		_, err = getBed(&other, bed.ID)
+		require.ErrorIs(t, err, errNotFound)
+		_, err = updateBed(&other, bed.ID, map[string]interface{}{"name": "Mine now"})
+		require.ErrorIs(t, err, errNotFound)
+		_, err = removeBed(&other, bed.ID)
+		require.ErrorIs(t, err, errNotFound)
+
+		removed, err := removeBed(&user, bed.ID)
+		require.NoError(t, err)
+		require.Equal(t, *bed.ID, *removed.ID)
+		beds, err = getBeds(&user)
+		require.NoError(t, err)
+		require.Empty(t, beds)
+	})
+}
@@ -0,0 +1,222 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package garden
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+// the things that happen to a planting
+const (
+	Watered    = "watered"
+	Fertilized = "fertilized"
+	Harvested  = "harvested"
+
+	// DefaultYieldUnit is used when a harvest doesn't say what its yield was measured in
+	DefaultYieldUnit = "lb"
+)
+
+var (
+	eventTableName = "gardenEvents"
+	eventTable     = &configs.Table{
+		CreateSQL: `CREATE TABLE IF NOT EXISTS gardenEvents (
+			id varchar(64) NOT NULL PRIMARY KEY,
+			user varchar(128) NOT NULL,
+			plantingID varchar(64) NOT NULL REFERENCES gardenPlantings(id) ON DELETE CASCADE,
+			bedID varchar(64) NOT NULL,
+			kind varchar(16) NOT NULL,
+			occurred bigint NOT NULL,
+			yield real,
+			yieldUnit varchar(32),
+			notes varchar(4096),
+			created bigint NOT NULL)`,
+		InsertSQL: "INSERT INTO gardenEvents values(?,?,?,?,?,?,?,?,?,?)",
+		DeleteSQL: "DELETE FROM gardenEvents where id = ? and user = ?",
+		Indices: []string{
+			"CREATE INDEX IF NOT EXISTS gardeneventuser on gardenEvents(user)",
+			"CREATE INDEX IF NOT EXISTS gardeneventplanting on gardenEvents(plantingID)",
+		},
+	}
+)
+
+// Event is something done to a planting, only a harvest has a yield
+type Event struct {
+	ID         *string  `json:"id,omitempty"`
+	User       *string  `json:"user,omitempty"`
+	PlantingID *string  `json:"planting,omitempty"`
+	BedID      *string  `json:"bed,omitempty"`
+	Kind       *string  `json:"kind,omitempty"`
+	Occurred   *int64   `json:"occurred,omitempty"`
+	Yield      *float64 `json:"yield,omitempty"`
+	YieldUnit  *string  `json:"yieldUnit,omitempty"`
+	Notes      *string  `json:"notes,omitempty"`
+	Created    *int64   `json:"created,omitempty"`
+}
+
+// eventRequest is a new event, occurred accepts the same formats as a planting's planted date and defaults to now
+type eventRequest struct {
+	PlantingID *string  `json:"planting,omitempty"`
+	Kind       *string  `json:"kind,omitempty"`
+	Occurred   *string  `json:"occurred,omitempty"`
+	Yield      *float64 `json:"yield,omitempty"`
+	YieldUnit  *string  `json:"yieldUnit,omitempty"`
+	Notes      *string  `json:"notes,omitempty"`
+}
+
+// getEvents returns the events of the user's planting in the order they occurred
+func getEvents(userID, plantingID *string) ([]*Event, error) {
+	if _, err := getPlanting(userID, plantingID); err != nil {
+		return nil, err
+	}
+	return queryEvents("select * from gardenEvents where plantingID = ? and user = ? order by occurred, created",
+		plantingID, userID)
+}
+
+func queryEvents(query string, args ...any) ([]*Event, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve events, sqlite error: %s", dbErr)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func scanEvent(row scanner) (*Event, error) {
+	event := Event{}
+	if err := row.Scan(&event.ID, &event.User, &event.PlantingID, &event.BedID, &event.Kind, &event.Occurred, &event.Yield,
+		&event.YieldUnit, &event.Notes, &event.Created); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// addEvent records that the user's planting was watered, fertilized or harvested, a harvest requires a yield
+func addEvent(userID *string, data interface{}) (*Event, error) {
+	if userID == nil {
+		return nil, errors.New("no user found, cannot add event")
+	}
+
+	var request eventRequest
+	if err := unmarshalData(data, &request); err != nil {
+		return nil, err
+	}
+	if request.PlantingID == nil || request.Kind == nil {
+		return nil, errors.New("an event requires a planting and a kind")
+	}
+	planting, err := getPlanting(userID, request.PlantingID)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := strings.ToLower(strings.TrimSpace(*request.Kind))
+	var yield *float64
+	var yieldUnit *string
+	switch kind {
+	case Watered, Fertilized:
+		if request.Yield != nil {
+			return nil, fmt.Errorf("a %s event cannot have a yield", kind)
+		}
+	case Harvested:
+		if request.Yield == nil || *request.Yield <= 0 {
+			return nil, errors.New("a harvest requires a yield greater than 0")
+		}
+		unit := DefaultYieldUnit
+		if request.YieldUnit != nil && strings.TrimSpace(*request.YieldUnit) != "" {
+			unit = strings.ToLower(strings.TrimSpace(*request.YieldUnit))
+		}
+		yield, yieldUnit = request.Yield, &unit
+	default:
+		return nil, fmt.Errorf("kind %s must be one of %s, %s or %s", *request.Kind, Watered, Fertilized, Harvested)
+	}
+
+	now := time.Now()
+	occurred := now.UnixMilli()
+	if request.Occurred != nil && *request.Occurred != "" {
+		if occurred, err = parseTime(*request.Occurred); err != nil {
+			return nil, err
+		}
+	}
+
+	id := uuid.New().String()
+	created := now.UnixMilli()
+	event := &Event{
+		ID:         &id,
+		User:       userID,
+		PlantingID: planting.ID,
+		BedID:      planting.BedID,
+		Kind:       &kind,
+		Occurred:   &occurred,
+		Yield:      yield,
+		YieldUnit:  yieldUnit,
+		Notes:      request.Notes,
+		Created:    &created,
+	}
+	if _, err := eventTable.Exec(eventTable.InsertSQL, event.ID, event.User, event.PlantingID, event.BedID, event.Kind,
+		event.Occurred, event.Yield, event.YieldUnit, event.Notes, event.Created); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// removeEvent deletes the user's event
+func removeEvent(userID, eventID *string) (*Event, error) {
+	if userID == nil || eventID == nil {
+		return nil, errors.New("no user or event found, cannot remove event")
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to remove event, sqlite error: %s", dbErr)
+	}
+
+	event, err := scanEvent(db.QueryRow("select * from gardenEvents where id = ? and user = ?", eventID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("event %s %w", *eventID, errNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := eventTable.Exec(eventTable.DeleteSQL, eventID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("event %s %w", *eventID, errNotFound)
+	}
+	return event, nil
+}
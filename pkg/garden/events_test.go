@@ -0,0 +1,93 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package garden
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventFunctions(t *testing.T) {
+	initGardenTest()
+	user := uuid.New().String()
+	seedID, cleanup := testPurchase(t, user, "Sungold")
+	defer cleanup()
+
+	bed, err := addBed(&user, map[string]interface{}{"name": "Event bed", "width": 3, "length": 6})
+	require.NoError(t, err)
+	defer func() {
+		_, err := removeBed(&user, bed.ID)
+		require.NoError(t, err)
+	}()
+	planting, err := addPlanting(&user, map[string]interface{}{"bed": *bed.ID, "seed": seedID, "planted": "2026-05-01"})
+	require.NoError(t, err)
+
+	t.Run("Test event validation", func(t *testing.T) {
+		_, err := addEvent(&user, map[string]interface{}{"planting": *planting.ID})
+		require.Error(t, err)
+		_, err = addEvent(&user, map[string]interface{}{"planting": *planting.ID, "kind": "weeded"})
+		require.Error(t, err)
+		_, err = addEvent(&user, map[string]interface{}{"planting": *planting.ID, "kind": Watered, "yield": 1})
+		require.Error(t, err)
+		_, err = addEvent(&user, map[string]interface{}{"planting": *planting.ID, "kind": Harvested})
+		require.Error(t, err)
+		_, err = addEvent(&user, map[string]interface{}{"planting": *planting.ID, "kind": Harvested, "yield": -2})
+		require.Error(t, err)
+		_, err = addEvent(&user, map[string]interface{}{"planting": uuid.New().String(), "kind": Watered})
+		require.ErrorIs(t, err, errNotFound)
+
+		other := uuid.New().String()
+		_, err = addEvent(&other, map[string]interface{}{"planting": *planting.ID, "kind": Watered})
+		require.ErrorIs(t, err, errNotFound)
+	})
+
+	t.Run("Test adding and removing events", func(t *testing.T) {
+		harvest, err := addEvent(&user, map[string]interface{}{"planting": *planting.ID, "kind": "Harvested", "yield": 1.5,
+			"yieldUnit": "Pints", "occurred": "2026-07-20"})
+		require.NoError(t, err)
+		require.Equal(t, Harvested, *harvest.Kind)
+		require.Equal(t, "pints", *harvest.YieldUnit)
+		require.Equal(t, *bed.ID, *harvest.BedID)
+
+		watered, err := addEvent(&user, map[string]interface{}{"planting": *planting.ID, "kind": Watered,
+			"occurred": "2026-05-02"})
+		require.NoError(t, err)
+		require.Nil(t, watered.Yield)
+
+		events, err := getEvents(&user, planting.ID)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.Equal(t, *watered.ID, *events[0].ID)
+		require.Equal(t, 1.5, *events[1].Yield)
+
+		other := uuid.New().String()
+		_, err = getEvents(&other, planting.ID)
+		require.ErrorIs(t, err, errNotFound)
+		_, err = removeEvent(&other, watered.ID)
+		require.ErrorIs(t, err, errNotFound)
+
+		removed, err := removeEvent(&user, watered.ID)
+		require.NoError(t, err)
+		require.Equal(t, Watered, *removed.Kind)
+
+		// removing the planting removes its events
+		_, err = removePlanting(&user, planting.ID)
+		require.NoError(t, err)
+		_, err = removeEvent(&user, harvest.ID)
+		require.ErrorIs(t, err, errNotFound)
+	})
+}
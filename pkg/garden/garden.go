@@ -0,0 +1,259 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package garden
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+	"stl-go/grow-with-stl-go/pkg/webservice"
+)
+
+const (
+	gardenKey = "garden"
+
+	getBedsRequestKey   = "getBeds"
+	addBedRequestKey    = "addBed"
+	updateBedRequestKey = "updateBed"
+	removeBedRequestKey = "removeBed"
+
+	getPlantingsRequestKey   = "getPlantings"
+	addPlantingRequestKey    = "addPlanting"
+	removePlantingRequestKey = "removePlanting"
+
+	getEventsRequestKey   = "getEvents"
+	addEventRequestKey    = "addEvent"
+	removeEventRequestKey = "removeEvent"
+
+	getHistoryRequestKey = "getHistory"
+)
+
+// tableOrder is the order the tables are created in, the plantings reference the beds and the events the plantings
+var tableOrder = []string{bedTableName, plantingTableName, eventTableName}
+
+// Init is called by the launch in the pkg/commands/root.go
+func Init() error {
+	webservice.AppendToWebsocketFunctionMap(gardenKey, handleWebsocketRequest)
+	webservice.AppendToRESTFunctionMap(gardenKey, handleRESTRequest)
+	return setupTables()
+}
+
+func setupTables() error {
+	tables := map[string]*configs.Table{
+		bedTableName:      bedTable,
+		plantingTableName: plantingTable,
+		eventTableName:    eventTable,
+	}
+	for _, tableName := range tableOrder {
+		if err := tables[tableName].CreateTable(&tableName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleWebsocketRequest serves the garden route, every request is for the authenticated user's own garden.  The bed,
+// planting or event id is the component
+func handleWebsocketRequest(request, response *configs.WsMessage) {
+	if request.Type != nil && response != nil {
+		var err error
+		switch *request.Type {
+		case getBedsRequestKey:
+			response.Data, err = getBeds(request.User)
+		case addBedRequestKey:
+			response.Data, err = addBed(request.User, request.Data)
+		case updateBedRequestKey:
+			response.Data, err = updateBed(request.User, request.Component, request.Data)
+		case removeBedRequestKey:
+			response.Data, err = removeBed(request.User, request.Component)
+		case getPlantingsRequestKey:
+			var filter *historyFilter
+			if filter, err = historyFilterFromData(request.Data); err == nil {
+				response.Data, err = getPlantings(request.User, filter)
+			}
+		case addPlantingRequestKey:
+			response.Data, err = addPlanting(request.User, request.Data)
+		case removePlantingRequestKey:
+			response.Data, err = removePlanting(request.User, request.Component)
+		case getEventsRequestKey:
+			response.Data, err = getEvents(request.User, request.Component)
+		case addEventRequestKey:
+			response.Data, err = addEvent(request.User, request.Data)
+		case removeEventRequestKey:
+			response.Data, err = removeEvent(request.User, request.Component)
+		case getHistoryRequestKey:
+			var filter *historyFilter
+			if filter, err = historyFilterFromData(request.Data); err == nil {
+				response.Data, err = getHistory(request.User, filter)
+			}
+		default:
+			err = fmt.Errorf("type %s not implemented", *request.Type)
+		}
+
+		if response.Data == nil {
+			e := "no data found"
+			log.Error(e)
+			response.Error = &e
+		}
+
+		if err != nil {
+			log.Error(err)
+			e := err.Error()
+			response.Error = &e
+			response.Data = nil
+		}
+	}
+}
+
+// handleRESTRequest serves /REST/v1.0.0/garden/{type} and /REST/v1.0.0/garden/{type}/{id} for the authenticated user
+func handleRESTRequest(w http.ResponseWriter, r *http.Request) {
+	// the path is used rather than the request uri so query parameters don't end up in the last uri part
+	restURI := strings.TrimPrefix(r.URL.Path, fmt.Sprintf("/REST/v1.0.0/%s/", gardenKey))
+	uriParts := strings.Split(restURI, "/")
+	w.Header().Set("content-type", "application/json")
+
+	userID := webservice.GetRESTUser(r)
+	var id *string
+	if len(uriParts) > 1 && uriParts[1] != "" {
+		id = &uriParts[1]
+	}
+
+	var data interface{}
+	var err error
+	status := http.StatusOK
+	switch {
+	case r.Method == http.MethodGet && uriParts[0] == getBedsRequestKey:
+		data, err = getBeds(userID)
+	case r.Method == http.MethodGet && uriParts[0] == getPlantingsRequestKey:
+		data, err = getPlantings(userID, historyFilterFromQuery(r.URL.Query()))
+	case r.Method == http.MethodGet && uriParts[0] == getEventsRequestKey:
+		data, err = getEvents(userID, id)
+	case r.Method == http.MethodGet && uriParts[0] == getHistoryRequestKey:
+		data, err = getHistory(userID, historyFilterFromQuery(r.URL.Query()))
+	case r.Method == http.MethodPost && (uriParts[0] == addBedRequestKey || uriParts[0] == addPlantingRequestKey ||
+		uriParts[0] == addEventRequestKey):
+		requestBody, ok := readRESTBody(w, r)
+		if !ok {
+			return
+		}
+		switch uriParts[0] {
+		case addBedRequestKey:
+			data, err = addBed(userID, requestBody)
+		case addPlantingRequestKey:
+			data, err = addPlanting(userID, requestBody)
+		case addEventRequestKey:
+			data, err = addEvent(userID, requestBody)
+		}
+		status = http.StatusCreated
+	case (r.Method == http.MethodPut || r.Method == http.MethodPatch) && uriParts[0] == updateBedRequestKey:
+		requestBody, ok := readRESTBody(w, r)
+		if !ok {
+			return
+		}
+		data, err = updateBed(userID, id, requestBody)
+	case r.Method == http.MethodDelete && uriParts[0] == removeBedRequestKey:
+		data, err = removeBed(userID, id)
+	case r.Method == http.MethodDelete && uriParts[0] == removePlantingRequestKey:
+		data, err = removePlanting(userID, id)
+	case r.Method == http.MethodDelete && uriParts[0] == removeEventRequestKey:
+		data, err = removeEvent(userID, id)
+	default:
+		log.Errorf("%s URI with Method %s not possible", restURI, r.Method)
+		http.Error(w, configs.NotImplementedError, http.StatusNotImplemented)
+		return
+	}
+
+	if err != nil {
+		log.Error(err)
+		if errors.Is(err, errNotFound) {
+			http.Error(w, configs.NotFoundError, http.StatusNotFound)
+			return
+		}
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+	writeRESTResponse(data, w, status)
+}
+
+func readRESTBody(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return nil, false
+	}
+
+	var requestBody map[string]interface{}
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		log.Errorf("bad request body: %s", body)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return nil, false
+	}
+	return requestBody, true
+}
+
+func writeRESTResponse(data interface{}, w http.ResponseWriter, httpStatus int) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(httpStatus)
+	if _, err = w.Write(b); err != nil {
+		log.Error(err)
+	}
+}
+
+func unmarshalData(data, v interface{}) error {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(bytes, v); err != nil {
+		return fmt.Errorf("the request data is invalid: %s", err)
+	}
+	return nil
+}
+
+// historyFilterFromQuery converts the REST query parameters into a history filter
+func historyFilterFromQuery(values url.Values) *historyFilter {
+	filter := historyFilter{}
+	if value := values.Get("bed"); value != "" {
+		filter.BedID = &value
+	}
+	if value := values.Get("season"); value != "" {
+		filter.Season = &value
+	}
+	return &filter
+}
+
+func historyFilterFromData(data interface{}) (*historyFilter, error) {
+	filter := historyFilter{}
+	if data != nil {
+		if err := unmarshalData(data, &filter); err != nil {
+			return nil, err
+		}
+	}
+	return &filter, nil
+}
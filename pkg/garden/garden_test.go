@@ -0,0 +1,166 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package garden
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+	"stl-go/grow-with-stl-go/pkg/seeds"
+)
+
+func initGardenTest() {
+	configFile := "../../etc/grow-with-stl-go.json"
+	configs.ConfigFile = &configFile
+	if err := configs.SetGrowSTLGoConfig(); err != nil {
+		log.Fatalf("config %s", err)
+	}
+	if err := seeds.Init(); err != nil {
+		log.Fatalf("Error starting the seeds db: %s", err)
+	}
+	if err := Init(); err != nil {
+		log.Fatalf("Error starting the garden db: %s", err)
+	}
+}
+
+// testPurchase records an order of a seed for the user so it can be planted, the returned function removes the order
+func testPurchase(t *testing.T, userID string, cultivar string) (string, func()) {
+	db, err := configs.GetSQLiteConnection()
+	require.NoError(t, err)
+
+	orderID, seedID := uuid.New().String(), uuid.New().String()
+	_, err = db.Exec("INSERT INTO orders values(?,?,?,?,?)", orderID, userID, time.Now().UnixMilli(), 450, "USD")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO orderLines values(?,?,?,?,?,?,?)", orderID, seedID, "Tomato", "Tomato", cultivar, 1, 450)
+	require.NoError(t, err)
+	return seedID, func() {
+		_, err := db.Exec("DELETE FROM orders where id = ?", orderID)
+		require.NoError(t, err)
+	}
+}
+
+func TestGardenRoutes(t *testing.T) {
+	initGardenTest()
+	user := uuid.New().String()
+	seedID, cleanup := testPurchase(t, user, "Cherokee Purple")
+	defer cleanup()
+
+	route := gardenKey
+	request := func(requestType string, component *string, data interface{}) *configs.WsMessage {
+		response := &configs.WsMessage{}
+		handleWebsocketRequest(&configs.WsMessage{Route: &route, Type: &requestType, User: &user, Component: component,
+			Data: data}, response)
+		return response
+	}
+
+	t.Run("Test the websocket", func(t *testing.T) {
+		response := request(addBedRequestKey, nil, map[string]interface{}{"name": "Route bed", "width": 4, "length": 8})
+		require.Nil(t, response.Error)
+		bed, ok := response.Data.(*Bed)
+		require.True(t, ok)
+		defer func() {
+			response := request(removeBedRequestKey, bed.ID, nil)
+			require.Nil(t, response.Error)
+		}()
+
+		response = request(updateBedRequestKey, bed.ID, map[string]interface{}{"sun": "partial"})
+		require.Nil(t, response.Error)
+		require.Equal(t, PartialSun, *response.Data.(*Bed).Sun)
+
+		response = request(getBedsRequestKey, nil, nil)
+		require.Nil(t, response.Error)
+		require.Len(t, response.Data, 1)
+
+		response = request(addPlantingRequestKey, nil, map[string]interface{}{"bed": *bed.ID, "seed": seedID,
+			"planted": "2026-05-01"})
+		require.Nil(t, response.Error)
+		planting, ok := response.Data.(*Planting)
+		require.True(t, ok)
+
+		response = request(addEventRequestKey, nil, map[string]interface{}{"planting": *planting.ID, "kind": Harvested,
+			"yield": 3})
+		require.Nil(t, response.Error)
+		event, ok := response.Data.(*Event)
+		require.True(t, ok)
+
+		response = request(getEventsRequestKey, planting.ID, nil)
+		require.Nil(t, response.Error)
+		require.Len(t, response.Data, 1)
+
+		response = request(getPlantingsRequestKey, nil, map[string]interface{}{"season": "2026 spring"})
+		require.Nil(t, response.Error)
+		require.Len(t, response.Data, 1)
+
+		response = request(getHistoryRequestKey, nil, map[string]interface{}{"bed": *bed.ID})
+		require.Nil(t, response.Error)
+		history, ok := response.Data.([]*BedHistory)
+		require.True(t, ok)
+		require.Equal(t, 3.0, history[0].Yield[DefaultYieldUnit])
+
+		response = request(removeEventRequestKey, event.ID, nil)
+		require.Nil(t, response.Error)
+		response = request(removePlantingRequestKey, planting.ID, nil)
+		require.Nil(t, response.Error)
+
+		response = request(getPlantingsRequestKey, nil, "bogus")
+		require.NotNil(t, response.Error)
+		require.Nil(t, response.Data)
+
+		response = request("bogus", nil, nil)
+		require.NotNil(t, response.Error)
+	})
+
+	t.Run("Test REST", func(t *testing.T) {
+		// the REST user is set by the webservice after the request is authenticated, there isn't one here
+		w := httptest.NewRecorder()
+		handleRESTRequest(w, httptest.NewRequest(http.MethodGet, "/REST/v1.0.0/garden/getBeds", nil))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = httptest.NewRecorder()
+		handleRESTRequest(w, httptest.NewRequest(http.MethodPost, "/REST/v1.0.0/garden/addBed",
+			strings.NewReader(`{"name": "REST bed", "width": 4, "length": 8}`)))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = httptest.NewRecorder()
+		handleRESTRequest(w, httptest.NewRequest(http.MethodPost, "/REST/v1.0.0/garden/addBed", strings.NewReader("{")))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = httptest.NewRecorder()
+		handleRESTRequest(w, httptest.NewRequest(http.MethodGet, "/REST/v1.0.0/garden/bogus", nil))
+		require.Equal(t, http.StatusNotImplemented, w.Code)
+
+		w = httptest.NewRecorder()
+		handleRESTRequest(w, httptest.NewRequest(http.MethodPost, "/REST/v1.0.0/garden/getBeds", nil))
+		require.Equal(t, http.StatusNotImplemented, w.Code)
+	})
+
+	t.Run("Test the REST filters", func(t *testing.T) {
+		filter := historyFilterFromQuery(map[string][]string{"bed": {"abc"}, "season": {"2026"}})
+		require.Equal(t, "abc", *filter.BedID)
+		require.Equal(t, "2026", *filter.Season)
+
+		filter = historyFilterFromQuery(map[string][]string{})
+		require.Nil(t, filter.BedID)
+		require.Nil(t, filter.Season)
+	})
+}
@@ -0,0 +1,105 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package garden
+
+import (
+	"errors"
+	"strings"
+)
+
+// BedHistory is what was planted in a bed, what happened to the plantings and the total harvest by unit
+type BedHistory struct {
+	Bed       *Bed               `json:"bed,omitempty"`
+	Plantings []*PlantingHistory `json:"plantings"`
+	Yield     map[string]float64 `json:"yield"`
+}
+
+// PlantingHistory is a planting with its events and the total harvest by unit
+type PlantingHistory struct {
+	Planting *Planting          `json:"planting,omitempty"`
+	Events   []*Event           `json:"events"`
+	Yield    map[string]float64 `json:"yield"`
+}
+
+// getHistory returns the history of each of the user's beds, or just the filtered one, limited to the plantings of the
+// filtered season or year
+func getHistory(userID *string, filter *historyFilter) ([]*BedHistory, error) {
+	if userID == nil {
+		return nil, errors.New("no user found, cannot retrieve history")
+	}
+
+	var beds []*Bed
+	if filter != nil && filter.BedID != nil && *filter.BedID != "" {
+		bed, err := getBed(userID, filter.BedID)
+		if err != nil {
+			return nil, err
+		}
+		beds = []*Bed{bed}
+	} else {
+		var err error
+		if beds, err = getBeds(userID); err != nil {
+			return nil, err
+		}
+	}
+
+	plantings, err := getPlantings(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]*BedHistory, 0, len(beds))
+	bedHistories := map[string]*BedHistory{}
+	for _, bed := range beds {
+		bedHistory := &BedHistory{Bed: bed, Plantings: []*PlantingHistory{}, Yield: map[string]float64{}}
+		bedHistories[*bed.ID] = bedHistory
+		history = append(history, bedHistory)
+	}
+
+	if len(plantings) == 0 {
+		return history, nil
+	}
+
+	plantingHistories := map[string]*PlantingHistory{}
+	ids := make([]any, 0, len(plantings)+1)
+	ids = append(ids, *userID)
+	for _, planting := range plantings {
+		bedHistory, ok := bedHistories[*planting.BedID]
+		if !ok {
+			continue
+		}
+		plantingHistory := &PlantingHistory{Planting: planting, Events: []*Event{}, Yield: map[string]float64{}}
+		plantingHistories[*planting.ID] = plantingHistory
+		bedHistory.Plantings = append(bedHistory.Plantings, plantingHistory)
+		ids = append(ids, *planting.ID)
+	}
+	if len(plantingHistories) == 0 {
+		return history, nil
+	}
+
+	events, err := queryEvents("select * from gardenEvents where user = ? and plantingID in (?"+
+		strings.Repeat(",?", len(ids)-2)+") order by occurred, created", ids...)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		plantingHistory := plantingHistories[*event.PlantingID]
+		plantingHistory.Events = append(plantingHistory.Events, event)
+		if event.Yield != nil && event.YieldUnit != nil {
+			plantingHistory.Yield[*event.YieldUnit] += *event.Yield
+			bedHistories[*plantingHistory.Planting.BedID].Yield[*event.YieldUnit] += *event.Yield
+		}
+	}
+	return history, nil
+}
@@ -0,0 +1,98 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package garden
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryFunctions(t *testing.T) {
+	initGardenTest()
+	user := uuid.New().String()
+	seedID, cleanup := testPurchase(t, user, "Sun Sugar")
+	defer cleanup()
+
+	east, err := addBed(&user, map[string]interface{}{"name": "East bed", "width": 4, "length": 8})
+	require.NoError(t, err)
+	west, err := addBed(&user, map[string]interface{}{"name": "West bed", "width": 4, "length": 8, "sun": PartialSun})
+	require.NoError(t, err)
+	defer func() {
+		for _, bed := range []*Bed{east, west} {
+			_, err := removeBed(&user, bed.ID)
+			require.NoError(t, err)
+		}
+	}()
+
+	spring, err := addPlanting(&user, map[string]interface{}{"bed": *east.ID, "seed": seedID, "planted": "2026-04-25"})
+	require.NoError(t, err)
+	summer, err := addPlanting(&user, map[string]interface{}{"bed": *east.ID, "seed": seedID, "planted": "2026-07-01"})
+	require.NoError(t, err)
+	lastYear, err := addPlanting(&user, map[string]interface{}{"bed": *west.ID, "seed": seedID, "planted": "2025-05-01"})
+	require.NoError(t, err)
+
+	for _, harvest := range []map[string]interface{}{
+		{"planting": *spring.ID, "kind": Harvested, "yield": 2, "occurred": "2026-07-10"},
+		{"planting": *spring.ID, "kind": Harvested, "yield": 1.5, "occurred": "2026-07-20"},
+		{"planting": *spring.ID, "kind": Harvested, "yield": 3, "yieldUnit": "pints", "occurred": "2026-07-21"},
+		{"planting": *summer.ID, "kind": Harvested, "yield": 4, "occurred": "2026-09-01"},
+		{"planting": *lastYear.ID, "kind": Harvested, "yield": 10, "occurred": "2025-08-01"},
+		{"planting": *spring.ID, "kind": Fertilized, "occurred": "2026-05-15"},
+	} {
+		_, err := addEvent(&user, harvest)
+		require.NoError(t, err)
+	}
+
+	t.Run("Test the history of every bed", func(t *testing.T) {
+		history, err := getHistory(&user, nil)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		require.Equal(t, *east.ID, *history[0].Bed.ID)
+		require.Len(t, history[0].Plantings, 2)
+		require.Equal(t, map[string]float64{DefaultYieldUnit: 7.5, "pints": 3}, history[0].Yield)
+		require.Equal(t, map[string]float64{DefaultYieldUnit: 3.5, "pints": 3}, history[0].Plantings[0].Yield)
+		require.Len(t, history[0].Plantings[0].Events, 4)
+		require.Equal(t, Fertilized, *history[0].Plantings[0].Events[0].Kind)
+		require.Equal(t, map[string]float64{DefaultYieldUnit: 10}, history[1].Yield)
+	})
+
+	t.Run("Test the history of a bed and season", func(t *testing.T) {
+		season := "2026 spring"
+		history, err := getHistory(&user, &historyFilter{BedID: east.ID, Season: &season})
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		require.Len(t, history[0].Plantings, 1)
+		require.Equal(t, *spring.ID, *history[0].Plantings[0].Planting.ID)
+
+		// a bed with nothing planted that season is still in the history
+		year := "2026"
+		history, err = getHistory(&user, &historyFilter{Season: &year})
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		require.Empty(t, history[1].Plantings)
+		require.Empty(t, history[1].Yield)
+
+		bogus := uuid.New().String()
+		_, err = getHistory(&user, &historyFilter{BedID: &bogus})
+		require.ErrorIs(t, err, errNotFound)
+
+		other := uuid.New().String()
+		history, err = getHistory(&other, nil)
+		require.NoError(t, err)
+		require.Empty(t, history)
+	})
+}
@@ -0,0 +1,274 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package garden
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/seeds"
+)
+
+var (
+	plantingTableName = "gardenPlantings"
+	plantingTable     = &configs.Table{
+		// the seed details are copied from the purchase so the history survives the seed being changed or removed
+		CreateSQL: `CREATE TABLE IF NOT EXISTS gardenPlantings (
+			id varchar(64) NOT NULL PRIMARY KEY,
+			user varchar(128) NOT NULL,
+			bedID varchar(64) NOT NULL REFERENCES gardenBeds(id) ON DELETE CASCADE,
+			seedID varchar(64) NOT NULL,
+			category varchar(128) NOT NULL,
+			commonName varchar(1024) NOT NULL,
+			cultivar varchar(512),
+			quantity int NOT NULL,
+			planted bigint NOT NULL,
+			season varchar(32) NOT NULL,
+			notes varchar(4096),
+			created bigint NOT NULL)`,
+		InsertSQL: "INSERT INTO gardenPlantings values(?,?,?,?,?,?,?,?,?,?,?,?)",
+		DeleteSQL: "DELETE FROM gardenPlantings where id = ? and user = ?",
+		Indices: []string{
+			"CREATE INDEX IF NOT EXISTS gardenplantinguser on gardenPlantings(user)",
+			"CREATE INDEX IF NOT EXISTS gardenplantingbed on gardenPlantings(bedID)",
+			"CREATE INDEX IF NOT EXISTS gardenplantingseason on gardenPlantings(season)",
+		},
+	}
+)
+
+// Planting is a seed the user purchased that was planted in one of their beds, the quantity is the number of plants
+// or, for seeds sown in rows, the number of row feet
+type Planting struct {
+	ID         *string `json:"id,omitempty"`
+	User       *string `json:"user,omitempty"`
+	BedID      *string `json:"bed,omitempty"`
+	SeedID     *string `json:"seed,omitempty"`
+	Category   *string `json:"category,omitempty"`
+	CommonName *string `json:"commonName,omitempty"`
+	Cultivar   *string `json:"cultivar,omitempty"`
+	Quantity   *int    `json:"quantity,omitempty"`
+	Planted    *int64  `json:"planted,omitempty"`
+	Season     *string `json:"season,omitempty"`
+	Notes      *string `json:"notes,omitempty"`
+	Created    *int64  `json:"created,omitempty"`
+}
+
+// plantingRequest is a new planting, planted accepts epoch milliseconds, YYYY-MM-DD or RFC3339 timestamps and defaults
+// to now.  The season defaults to the one of the planted date
+type plantingRequest struct {
+	BedID    *string `json:"bed,omitempty"`
+	SeedID   *string `json:"seed,omitempty"`
+	Quantity *int    `json:"quantity,omitempty"`
+	Planted  *string `json:"planted,omitempty"`
+	Season   *string `json:"season,omitempty"`
+	Notes    *string `json:"notes,omitempty"`
+}
+
+// historyFilter narrows the plantings and history to a bed, a season such as "2026 spring" or every season of a year
+type historyFilter struct {
+	BedID  *string `json:"bed,omitempty"`
+	Season *string `json:"season,omitempty"`
+}
+
+// getPlantings returns the user's plantings that match the filter in the order they were planted
+func getPlantings(userID *string, filter *historyFilter) ([]*Planting, error) {
+	if userID == nil {
+		return nil, errors.New("no user found, cannot retrieve plantings")
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve plantings, sqlite error: %s", dbErr)
+	}
+
+	query, args := filter.toSQL(userID)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plantings := []*Planting{}
+	for rows.Next() {
+		planting, err := scanPlanting(rows)
+		if err != nil {
+			return nil, err
+		}
+		plantings = append(plantings, planting)
+	}
+	return plantings, rows.Err()
+}
+
+func (filter *historyFilter) toSQL(userID *string) (string, []any) {
+	clauses := []string{"user = ?"}
+	args := []any{*userID}
+	if filter != nil {
+		if filter.BedID != nil && *filter.BedID != "" {
+			clauses = append(clauses, "bedID = ?")
+			args = append(args, *filter.BedID)
+		}
+		if filter.Season != nil && strings.TrimSpace(*filter.Season) != "" {
+			// a year matches each of its seasons
+			season := strings.ToLower(strings.TrimSpace(*filter.Season))
+			clauses = append(clauses, "(season = ? or season like ?)")
+			args = append(args, season, season+" %")
+		}
+	}
+	return fmt.Sprintf("select * from gardenPlantings where %s order by planted, created", strings.Join(clauses, " and ")), args
+}
+
+// getPlanting returns the user's planting, a planting of another user's is not found
+func getPlanting(userID, plantingID *string) (*Planting, error) {
+	if userID == nil || plantingID == nil {
+		return nil, errors.New("no user or planting found, cannot retrieve planting")
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve planting, sqlite error: %s", dbErr)
+	}
+
+	planting, err := scanPlanting(db.QueryRow("select * from gardenPlantings where id = ? and user = ?", plantingID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("planting %s %w", *plantingID, errNotFound)
+	}
+	return planting, err
+}
+
+func scanPlanting(row scanner) (*Planting, error) {
+	planting := Planting{}
+	if err := row.Scan(&planting.ID, &planting.User, &planting.BedID, &planting.SeedID, &planting.Category,
+		&planting.CommonName, &planting.Cultivar, &planting.Quantity, &planting.Planted, &planting.Season, &planting.Notes,
+		&planting.Created); err != nil {
+		return nil, err
+	}
+	return &planting, nil
+}
+
+// addPlanting records a planting of a seed the user purchased in one of their beds
+func addPlanting(userID *string, data interface{}) (*Planting, error) {
+	if userID == nil {
+		return nil, errors.New("no user found, cannot add planting")
+	}
+
+	var request plantingRequest
+	if err := unmarshalData(data, &request); err != nil {
+		return nil, err
+	}
+	if request.BedID == nil || request.SeedID == nil {
+		return nil, errors.New("a planting requires a bed and a seed")
+	}
+	if _, err := getBed(userID, request.BedID); err != nil {
+		return nil, err
+	}
+	purchased, err := seeds.GetPurchasedSeed(userID, request.SeedID)
+	if err != nil {
+		return nil, err
+	}
+
+	quantity := 1
+	if request.Quantity != nil {
+		quantity = *request.Quantity
+	}
+	if quantity <= 0 {
+		return nil, errors.New("planting quantity must be greater than 0")
+	}
+
+	now := time.Now()
+	planted := now.UnixMilli()
+	if request.Planted != nil && *request.Planted != "" {
+		if planted, err = parseTime(*request.Planted); err != nil {
+			return nil, err
+		}
+	}
+	season := seasonOf(time.UnixMilli(planted))
+	if request.Season != nil && strings.TrimSpace(*request.Season) != "" {
+		season = strings.ToLower(strings.TrimSpace(*request.Season))
+	}
+
+	id := uuid.New().String()
+	created := now.UnixMilli()
+	planting := &Planting{
+		ID:         &id,
+		User:       userID,
+		BedID:      request.BedID,
+		SeedID:     purchased.SeedID,
+		Category:   purchased.Category,
+		CommonName: purchased.CommonName,
+		Cultivar:   purchased.Cultivar,
+		Quantity:   &quantity,
+		Planted:    &planted,
+		Season:     &season,
+		Notes:      request.Notes,
+		Created:    &created,
+	}
+	if _, err := plantingTable.Exec(plantingTable.InsertSQL, planting.ID, planting.User, planting.BedID, planting.SeedID,
+		planting.Category, planting.CommonName, planting.Cultivar, planting.Quantity, planting.Planted, planting.Season,
+		planting.Notes, planting.Created); err != nil {
+		return nil, err
+	}
+	return planting, nil
+}
+
+// removePlanting deletes the user's planting along with its events
+func removePlanting(userID, plantingID *string) (*Planting, error) {
+	planting, err := getPlanting(userID, plantingID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := plantingTable.Exec(plantingTable.DeleteSQL, plantingID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("planting %s %w", *plantingID, errNotFound)
+	}
+	return planting, nil
+}
+
+// seasonOf is the year and meteorological season of the time, "2026 spring"
+func seasonOf(t time.Time) string {
+	season := "winter"
+	switch t.Month() {
+	case time.March, time.April, time.May:
+		season = "spring"
+	case time.June, time.July, time.August:
+		season = "summer"
+	case time.September, time.October, time.November:
+		season = "fall"
+	}
+	return fmt.Sprintf("%d %s", t.Year(), season)
+}
+
+// parseTime accepts epoch milliseconds, YYYY-MM-DD or RFC3339 timestamps
+func parseTime(value string) (int64, error) {
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return millis, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.UnixMilli(), nil
+		}
+	}
+	return 0, fmt.Errorf("unable to parse time %s", value)
+}
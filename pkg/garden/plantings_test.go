@@ -0,0 +1,143 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package garden
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlantingFunctions(t *testing.T) {
+	initGardenTest()
+	user := uuid.New().String()
+	seedID, cleanup := testPurchase(t, user, "Brandywine")
+	defer cleanup()
+
+	bed, err := addBed(&user, map[string]interface{}{"name": "Tomato bed", "width": 4, "length": 8})
+	require.NoError(t, err)
+	defer func() {
+		_, err := removeBed(&user, bed.ID)
+		require.NoError(t, err)
+	}()
+
+	t.Run("Test the season of a date", func(t *testing.T) {
+		require.Equal(t, "2026 spring", seasonOf(time.Date(2026, time.April, 15, 0, 0, 0, 0, time.Local)))
+		require.Equal(t, "2026 summer", seasonOf(time.Date(2026, time.June, 1, 0, 0, 0, 0, time.Local)))
+		require.Equal(t, "2026 fall", seasonOf(time.Date(2026, time.November, 30, 0, 0, 0, 0, time.Local)))
+		require.Equal(t, "2026 winter", seasonOf(time.Date(2026, time.February, 1, 0, 0, 0, 0, time.Local)))
+	})
+
+	t.Run("Test parsing times", func(t *testing.T) {
+		millis, err := parseTime("1767225600000")
+		require.NoError(t, err)
+		require.Equal(t, int64(1767225600000), millis)
+
+		millis, err = parseTime("2026-05-01T12:00:00Z")
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2026, time.May, 1, 12, 0, 0, 0, time.UTC).UnixMilli(), millis)
+
+		millis, err = parseTime("2026-05-01")
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2026, time.May, 1, 0, 0, 0, 0, time.Local).UnixMilli(), millis)
+
+		_, err = parseTime("May first")
+		require.Error(t, err)
+	})
+
+	t.Run("Test planting requires a purchase and a bed", func(t *testing.T) {
+		_, err := addPlanting(&user, map[string]interface{}{"bed": *bed.ID})
+		require.Error(t, err)
+		_, err = addPlanting(&user, map[string]interface{}{"bed": *bed.ID, "seed": uuid.New().String()})
+		require.Error(t, err)
+		_, err = addPlanting(&user, map[string]interface{}{"bed": uuid.New().String(), "seed": seedID})
+		require.ErrorIs(t, err, errNotFound)
+		_, err = addPlanting(&user, map[string]interface{}{"bed": *bed.ID, "seed": seedID, "quantity": 0})
+		require.Error(t, err)
+		_, err = addPlanting(&user, map[string]interface{}{"bed": *bed.ID, "seed": seedID, "planted": "someday"})
+		require.Error(t, err)
+
+		// the seed was purchased by the user, not by someone else
+		other := uuid.New().String()
+		otherBed, err := addBed(&other, map[string]interface{}{"name": "Other bed", "width": 4, "length": 8})
+		require.NoError(t, err)
+		defer func() {
+			_, err := removeBed(&other, otherBed.ID)
+			require.NoError(t, err)
+		}()
+		_, err = addPlanting(&other, map[string]interface{}{"bed": *otherBed.ID, "seed": seedID})
+		require.Error(t, err)
+	})
+
+	t.Run("Test adding and filtering plantings", func(t *testing.T) {
+		spring, err := addPlanting(&user, map[string]interface{}{"bed": *bed.ID, "seed": seedID, "quantity": 4,
+			"planted": "2026-04-20"})
+		require.NoError(t, err)
+		require.Equal(t, "2026 spring", *spring.Season)
+		require.Equal(t, "Brandywine", *spring.Cultivar)
+		require.Equal(t, "Tomato", *spring.Category)
+
+		fall, err := addPlanting(&user, map[string]interface{}{"bed": *bed.ID, "seed": seedID, "planted": "2026-08-01",
+			"season": "2026 Fall"})
+		require.NoError(t, err)
+		require.Equal(t, "2026 fall", *fall.Season)
+		require.Equal(t, 1, *fall.Quantity)
+
+		last, err := addPlanting(&user, map[string]interface{}{"bed": *bed.ID, "seed": seedID, "planted": "2025-06-01"})
+		require.NoError(t, err)
+
+		plantings, err := getPlantings(&user, &historyFilter{})
+		require.NoError(t, err)
+		require.Len(t, plantings, 3)
+		require.Equal(t, *last.ID, *plantings[0].ID)
+
+		season := "2026 Spring"
+		plantings, err = getPlantings(&user, &historyFilter{Season: &season})
+		require.NoError(t, err)
+		require.Len(t, plantings, 1)
+		require.Equal(t, *spring.ID, *plantings[0].ID)
+
+		year := "2026"
+		plantings, err = getPlantings(&user, &historyFilter{BedID: bed.ID, Season: &year})
+		require.NoError(t, err)
+		require.Len(t, plantings, 2)
+
+		other := uuid.New().String()
+		plantings, err = getPlantings(&other, nil)
+		require.NoError(t, err)
+		require.Empty(t, plantings)
+
+		_, err = removePlanting(&other, fall.ID)
+		require.ErrorIs(t, err, errNotFound)
+		removed, err := removePlanting(&user, fall.ID)
+		require.NoError(t, err)
+		require.Equal(t, *fall.ID, *removed.ID)
+		_, err = getPlanting(&user, fall.ID)
+		require.ErrorIs(t, err, errNotFound)
+	})
+
+	t.Run("Test removing a bed removes its plantings", func(t *testing.T) {
+		other, err := addBed(&user, map[string]interface{}{"name": "Short lived", "width": 2, "length": 2})
+		require.NoError(t, err)
+		planting, err := addPlanting(&user, map[string]interface{}{"bed": *other.ID, "seed": seedID})
+		require.NoError(t, err)
+		_, err = removeBed(&user, other.ID)
+		require.NoError(t, err)
+		_, err = getPlanting(&user, planting.ID)
+		require.ErrorIs(t, err, errNotFound)
+	})
+}
@@ -0,0 +1,284 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+	"stl-go/grow-with-stl-go/pkg/webservice"
+)
+
+const (
+	lowStockAlert = "lowStock"
+	soldOutAlert  = "soldOut"
+)
+
+var (
+	stockAlertTableName = "stockAlerts"
+	stockAlertTable     = &configs.Table{
+		CreateSQL: `CREATE TABLE IF NOT EXISTS stockAlerts (
+			id varchar(64) NOT NULL PRIMARY KEY,
+			seedID varchar(64) NOT NULL,
+			category varchar(128) NOT NULL,
+			commonName varchar(1024) NOT NULL,
+			cultivar varchar(512),
+			kind varchar(16) NOT NULL,
+			packets int NOT NULL,
+			reorderThreshold int NOT NULL,
+			created bigint NOT NULL,
+			acknowledged bigint,
+			acknowledgedBy varchar(128))`,
+		InsertSQL: "INSERT INTO stockAlerts values(?,?,?,?,?,?,?,?,?,null,null)",
+		UpdateSQL: "UPDATE stockAlerts set acknowledged = ?, acknowledgedBy = ? where id = ? and acknowledged is null",
+		Indices: []string{
+			"CREATE INDEX IF NOT EXISTS stockalertseed on stockAlerts(seedID)",
+			"CREATE INDEX IF NOT EXISTS stockalertack on stockAlerts(acknowledged)",
+		},
+	}
+
+	stockCheckOnce  sync.Once
+	stockCheckMutex sync.Mutex
+	// raisedStock is the kind of the last alert raised for each seed that is still short, keyed by seed id.  It's loaded
+	// from the newest alert of each seed on the first check and guarded by the stock check mutex
+	raisedStock map[string]string
+)
+
+// StockAlert is raised when a seed falls below its reorder threshold or sells out
+type StockAlert struct {
+	ID               *string `json:"id,omitempty"`
+	SeedID           *string `json:"seedID,omitempty"`
+	Category         *string `json:"category,omitempty"`
+	CommonName       *string `json:"commonName,omitempty"`
+	Cultivar         *string `json:"cultivar,omitempty"`
+	Kind             *string `json:"kind,omitempty"`
+	Packets          *int    `json:"packets,omitempty"`
+	ReorderThreshold *int    `json:"reorderThreshold,omitempty"`
+	Created          *int64  `json:"created,omitempty"`
+	Acknowledged     *int64  `json:"acknowledged,omitempty"`
+	AcknowledgedBy   *string `json:"acknowledgedBy,omitempty"`
+}
+
+// startStockChecker starts the low stock check alongside the other timed tasks, it is only started once
+func startStockChecker() {
+	stockCheckOnce.Do(func() {
+		go stockCheckTimer()
+	})
+}
+
+func stockCheckTimer() {
+	// move the timer to the top of the minute for execution
+	time.Sleep(time.Duration(60-time.Now().Local().Second()) * time.Second)
+	for range time.NewTicker(1 * time.Minute).C {
+		if _, err := checkStock(); err != nil {
+			log.Errorf("error checking stock levels: %s", err)
+		}
+	}
+}
+
+// checkStock raises an alert for every seed that has sold out or fallen below its reorder threshold.  A seed is alerted
+// on once, acknowledged or not, until it's restocked above its threshold, the exception is a low stock seed that sells
+// out.  New alerts are pushed to the admin sessions.  The seed ids limit the check to those seeds, the ticker checks
+// them all
+func checkStock(seedIDs ...string) ([]*StockAlert, error) {
+	stockCheckMutex.Lock()
+	defer stockCheckMutex.Unlock()
+
+	inventory, err := getInventory()
+	if err != nil {
+		return nil, err
+	}
+
+	if raisedStock == nil {
+		if raisedStock, err = getLastAlertKinds(); err != nil {
+			return nil, err
+		}
+	}
+
+	// seeds that have recovered, or been removed, are left out so they're alerted on again when they next run short, a
+	// check of some seeds leaves the others as they were
+	short := make(map[string]string)
+	if len(seedIDs) > 0 {
+		short = raisedStock
+	}
+	var raised []*StockAlert
+	for _, category := range inventory {
+		for _, item := range category.Items {
+			if len(seedIDs) > 0 && !slices.Contains(seedIDs, *item.ID) {
+				continue
+			}
+			kind := stockAlertKind(item)
+			if kind == "" {
+				delete(short, *item.ID)
+				continue
+			}
+			if last, ok := raisedStock[*item.ID]; !ok || (last != kind && kind == soldOutAlert) {
+				alert, err := raiseStockAlert(item, kind)
+				if err != nil {
+					return raised, err
+				}
+				raisedStock[*item.ID] = kind
+				raised = append(raised, alert)
+			}
+			short[*item.ID] = kind
+		}
+	}
+	raisedStock = short
+	return raised, nil
+}
+
+// stockAlertKind determines which alert, if any, the item's packets call for
+func stockAlertKind(item *InventoryItem) string {
+	if item == nil || item.ID == nil || item.Packets == nil {
+		return ""
+	}
+	switch {
+	case *item.Packets <= 0:
+		return soldOutAlert
+	case item.ReorderThreshold != nil && *item.Packets < *item.ReorderThreshold:
+		return lowStockAlert
+	}
+	return ""
+}
+
+func raiseStockAlert(item *InventoryItem, kind string) (*StockAlert, error) {
+	id := uuid.New().String()
+	created := time.Now().UnixMilli()
+	reorderThreshold := 0
+	if item.ReorderThreshold != nil {
+		reorderThreshold = *item.ReorderThreshold
+	}
+
+	alert := &StockAlert{
+		ID:               &id,
+		SeedID:           item.ID,
+		Category:         item.Category,
+		CommonName:       item.CommonName,
+		Cultivar:         item.Cultivar,
+		Kind:             &kind,
+		Packets:          item.Packets,
+		ReorderThreshold: &reorderThreshold,
+		Created:          &created,
+	}
+
+	if _, err := stockAlertTable.Exec(stockAlertTable.InsertSQL, alert.ID, alert.SeedID, alert.Category, alert.CommonName,
+		alert.Cultivar, alert.Kind, alert.Packets, alert.ReorderThreshold, alert.Created); err != nil {
+		return nil, err
+	}
+
+	log.Infof("%s alert raised for %s %s with %d packets", kind, *item.Category, *item.CommonName, *item.Packets)
+
+	route := seedsKey
+	requestType := stockAlertRequestKey
+	go webservice.NotifyAdmins(nil, &configs.WsMessage{
+		Route:        &route,
+		Type:         &requestType,
+		Component:    item.Category,
+		SubComponent: item.ID,
+		Data:         alert,
+	})
+	return alert, nil
+}
+
+// getLastAlertKinds returns the kind of the newest alert, acknowledged or not, keyed by seed id
+func getLastAlertKinds() (map[string]string, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve stock alerts, sqlite error: %s", dbErr)
+	}
+
+	rows, err := db.Query(`select seedID, kind from stockAlerts a
+		where created = (select max(created) from stockAlerts where seedID = a.seedID)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	last := make(map[string]string)
+	for rows.Next() {
+		var seedID, kind string
+		if err := rows.Scan(&seedID, &kind); err != nil {
+			return nil, err
+		}
+		last[seedID] = kind
+	}
+	return last, rows.Err()
+}
+
+// getStockAlerts returns the unacknowledged alerts, or every alert if all is set, newest first
+func getStockAlerts(all bool) ([]*StockAlert, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve stock alerts, sqlite error: %s", dbErr)
+	}
+
+	query := "select * from stockAlerts where acknowledged is null order by created desc"
+	if all {
+		query = "select * from stockAlerts order by created desc"
+	}
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []*StockAlert{}
+	for rows.Next() {
+		alert := StockAlert{}
+		if err := rows.Scan(&alert.ID, &alert.SeedID, &alert.Category, &alert.CommonName, &alert.Cultivar, &alert.Kind,
+			&alert.Packets, &alert.ReorderThreshold, &alert.Created, &alert.Acknowledged, &alert.AcknowledgedBy); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, &alert)
+	}
+	return alerts, rows.Err()
+}
+
+// acknowledgeStockAlert closes an alert, the seed isn't alerted on again until it's been restocked above its threshold
+func acknowledgeStockAlert(sessionID, userID, id *string) (*StockAlert, error) {
+	if id == nil {
+		return nil, errors.New("no alert id supplied, cannot acknowledge")
+	}
+
+	acknowledged := time.Now().UnixMilli()
+	rows, err := stockAlertTable.Exec(stockAlertTable.UpdateSQL, acknowledged, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("open alert %s not found", *id)
+	}
+
+	alert := &StockAlert{ID: id, Acknowledged: &acknowledged, AcknowledgedBy: userID}
+
+	// let the other admins know the alert has been dealt with
+	route := seedsKey
+	requestType := acknowledgeStockAlertRequestKey
+	go webservice.NotifyAdmins(sessionID, &configs.WsMessage{
+		Route:     &route,
+		Type:      &requestType,
+		Component: id,
+		Data:      alert,
+	})
+	return alert, nil
+}
@@ -0,0 +1,149 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/utils"
+)
+
+func alertsForSeed(t *testing.T, seedID *string, all bool) []*StockAlert {
+	alerts, err := getStockAlerts(all)
+	require.NoError(t, err)
+	var found []*StockAlert
+	for _, alert := range alerts {
+		if *alert.SeedID == *seedID {
+			found = append(found, alert)
+		}
+	}
+	return found
+}
+
+func TestStockAlertFunctions(t *testing.T) {
+	initInventoryTest()
+	user := uuid.New().String()
+	item, err := addInventory(nil, map[string]interface{}{
+		"category":         "Herb",
+		"genus":            "Salvia",
+		"species":          "officinalis",
+		"cultivar":         "Alert Test",
+		"commonName":       "Sage",
+		"description":      "Stock alert test sage",
+		"price":            3.00,
+		"perPacketCount":   30,
+		"packets":          6,
+		"reorderThreshold": 5,
+	})
+	require.NoError(t, err)
+	defer func() {
+		_, err := removeInventory(nil, item.Category, item.ID)
+		require.NoError(t, err)
+	}()
+
+	t.Run("Test no alert above the threshold", func(t *testing.T) {
+		_, err := checkStock()
+		require.NoError(t, err)
+		require.Empty(t, alertsForSeed(t, item.ID, true))
+	})
+
+	t.Run("Test low stock and sold out alerts", func(t *testing.T) {
+		_, err := purchase(nil, &user, map[string]interface{}{"category": "Herb", "id": *item.ID, "quantity": 2})
+		require.NoError(t, err)
+
+		// the purchase raises the alert without waiting on the stock checker
+		require.Len(t, alertsForSeed(t, item.ID, false), 1)
+
+		_, err = checkStock()
+		require.NoError(t, err)
+		alerts := alertsForSeed(t, item.ID, false)
+		require.Len(t, alerts, 1)
+		require.Equal(t, lowStockAlert, *alerts[0].Kind)
+		require.Equal(t, 4, *alerts[0].Packets)
+
+		// an open alert is not raised a second time
+		_, err = checkStock()
+		require.NoError(t, err)
+		require.Len(t, alertsForSeed(t, item.ID, false), 1)
+
+		_, err = purchase(nil, &user, map[string]interface{}{"category": "Herb", "id": *item.ID, "quantity": 4})
+		require.NoError(t, err)
+		_, err = checkStock()
+		require.NoError(t, err)
+		alerts = alertsForSeed(t, item.ID, false)
+		require.Len(t, alerts, 2)
+		require.Equal(t, soldOutAlert, *alerts[0].Kind)
+	})
+
+	t.Run("Test acknowledging alerts", func(t *testing.T) {
+		seeds := seedsKey
+		requestType := acknowledgeStockAlertRequestKey
+		alerts := alertsForSeed(t, item.ID, false)
+		request := &configs.WsMessage{
+			Route:     &seeds,
+			Type:      &requestType,
+			Component: alerts[0].ID,
+			User:      &user,
+			IsAdmin:   utils.BoolPointer(false),
+		}
+		response := &configs.WsMessage{}
+		handleWebsocketRequest(request, response)
+		require.NotNil(t, response.Error)
+
+		for _, alert := range alerts {
+			acknowledged, err := acknowledgeStockAlert(nil, &user, alert.ID)
+			require.NoError(t, err)
+			require.Equal(t, user, *acknowledged.AcknowledgedBy)
+		}
+		require.Empty(t, alertsForSeed(t, item.ID, false))
+		require.Len(t, alertsForSeed(t, item.ID, true), 2)
+
+		_, err := acknowledgeStockAlert(nil, &user, alerts[0].ID)
+		require.Error(t, err)
+	})
+
+	t.Run("Test acknowledged alerts stay quiet until the seed recovers", func(t *testing.T) {
+		raised, err := checkStock()
+		require.NoError(t, err)
+		for _, alert := range raised {
+			require.NotEqual(t, *item.ID, *alert.SeedID)
+		}
+		require.Empty(t, alertsForSeed(t, item.ID, false))
+
+		// restocking below the threshold isn't a recovery
+		_, err = restockInventory(nil, item.Category, item.ID, map[string]interface{}{"quantity": 2})
+		require.NoError(t, err)
+		_, err = checkStock()
+		require.NoError(t, err)
+		require.Empty(t, alertsForSeed(t, item.ID, false))
+
+		_, err = restockInventory(nil, item.Category, item.ID, map[string]interface{}{"quantity": 8})
+		require.NoError(t, err)
+		_, err = checkStock()
+		require.NoError(t, err)
+
+		_, err = purchase(nil, &user, map[string]interface{}{"category": "Herb", "id": *item.ID, "quantity": 7})
+		require.NoError(t, err)
+		_, err = checkStock()
+		require.NoError(t, err)
+		alerts := alertsForSeed(t, item.ID, false)
+		require.Len(t, alerts, 1)
+		require.Equal(t, lowStockAlert, *alerts[0].Kind)
+	})
+}
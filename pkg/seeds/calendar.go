@@ -0,0 +1,382 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/weather"
+)
+
+const (
+	tenderTolerance    = "tender"
+	semiHardyTolerance = "semiHardy"
+	hardyTolerance     = "hardy"
+
+	calendarDateFormat = "2006-01-02"
+)
+
+var (
+	// plantOutOffsets are the days from the last spring frost a seed goes outside by how much frost it can take
+	plantOutOffsets = map[string]int{
+		tenderTolerance:    7,
+		semiHardyTolerance: -14,
+		hardyTolerance:     -28,
+	}
+
+	growGuideTableName = "growGuides"
+	growGuideTable     = &configs.Table{
+		// the start indoors window is in weeks before the last spring frost, a seed without one is sown outside
+		CreateSQL: `CREATE TABLE IF NOT EXISTS growGuides (
+			seedID varchar(64) NOT NULL PRIMARY KEY,
+			daysToGermination int NOT NULL,
+			daysToMaturity int NOT NULL,
+			frostTolerance varchar(16) NOT NULL,
+			startIndoorsEarliest int,
+			startIndoorsLatest int)`,
+		InsertSQL: `INSERT INTO growGuides values(?,?,?,?,?,?) ON CONFLICT(seedID) DO UPDATE set
+			daysToGermination = excluded.daysToGermination, daysToMaturity = excluded.daysToMaturity,
+			frostTolerance = excluded.frostTolerance, startIndoorsEarliest = excluded.startIndoorsEarliest,
+			startIndoorsLatest = excluded.startIndoorsLatest`,
+		Defaults: map[string]string{
+			"tomato":  "insert or ignore into growGuides select id, 7, 75, 'tender', 8, 6 from seeds where category = 'Tomato'",
+			"pepper":  "insert or ignore into growGuides select id, 10, 75, 'tender', 10, 8 from seeds where category = 'Pepper'",
+			"onion":   "insert or ignore into growGuides select id, 10, 110, 'hardy', 12, 10 from seeds where category = 'Onion'",
+			"basil":   "insert or ignore into growGuides select id, 7, 60, 'tender', 6, 4 from seeds where commonName = 'Basil'",
+			"dill":    "insert or ignore into growGuides select id, 10, 45, 'semiHardy', null, null from seeds where commonName = 'Dill Weed'",
+			"oregano": "insert or ignore into growGuides select id, 10, 85, 'hardy', 10, 8 from seeds where commonName = 'Oregano'",
+			"chive":   "insert or ignore into growGuides select id, 10, 80, 'hardy', 8, 6 from seeds where commonName = 'Chive'",
+		},
+	}
+
+	// a seed's grow guide goes with it, the trigger is recreated with the tables
+	growGuideSQL = []string{
+		"DROP TRIGGER IF EXISTS seedguide_ad",
+		`CREATE TRIGGER seedguide_ad AFTER DELETE ON seeds BEGIN
+			DELETE FROM growGuides where seedID = old.id;
+		END`,
+	}
+)
+
+// GrowGuide is how a seed is grown, the start indoors window is in weeks before the last spring frost and is left
+// out for seeds that are sown directly outside
+type GrowGuide struct {
+	SeedID               *string `json:"seedID,omitempty"`
+	DaysToGermination    *int    `json:"daysToGermination,omitempty"`
+	DaysToMaturity       *int    `json:"daysToMaturity,omitempty"`
+	FrostTolerance       *string `json:"frostTolerance,omitempty"`
+	StartIndoorsEarliest *int    `json:"startIndoorsEarliest,omitempty"`
+	StartIndoorsLatest   *int    `json:"startIndoorsLatest,omitempty"`
+}
+
+// PlantingCalendar is the sow, transplant and harvest dates of seeds for a location in a year, dates are YYYY-MM-DD.
+// The frost dates come from the nearest station and are left out where it doesn't frost
+type PlantingCalendar struct {
+	Location   *string                   `json:"location,omitempty"`
+	Year       *int                      `json:"year,omitempty"`
+	Station    *weather.FrostDateStation `json:"station,omitempty"`
+	LastFrost  *string                   `json:"lastFrost,omitempty"`
+	FirstFrost *string                   `json:"firstFrost,omitempty"`
+	Seeds      []*PlantingDates          `json:"seeds"`
+}
+
+// PlantingDates is when a seed is started indoors, transplanted or sown outside and ready to harvest.  A seed that
+// isn't started indoors has a direct sow date instead of a transplant date
+type PlantingDates struct {
+	SeedID            *string    `json:"seedID,omitempty"`
+	Category          *string    `json:"category,omitempty"`
+	CommonName        *string    `json:"commonName,omitempty"`
+	Cultivar          *string    `json:"cultivar,omitempty"`
+	GrowGuide         *GrowGuide `json:"growGuide,omitempty"`
+	StartIndoorsFrom  *string    `json:"startIndoorsFrom,omitempty"`
+	StartIndoorsUntil *string    `json:"startIndoorsUntil,omitempty"`
+	Transplant        *string    `json:"transplant,omitempty"`
+	DirectSow         *string    `json:"directSow,omitempty"`
+	Harvest           *string    `json:"harvest,omitempty"`
+	// BeforeFirstFrost is false when the harvest comes after the first fall frost
+	BeforeFirstFrost *bool `json:"beforeFirstFrost,omitempty"`
+}
+
+// calendarRequest picks the location and year of a planting calendar, the user's location and the current year
+// are used when they aren't set
+type calendarRequest struct {
+	Location *string `json:"location,omitempty"`
+	Year     *int    `json:"year,omitempty"`
+}
+
+func setupGrowGuides() error {
+	if err := growGuideTable.CreateTable(&growGuideTableName); err != nil {
+		return err
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return dbErr
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer configs.RollbackHelper(tx)
+
+	if err := execStatements(tx, growGuideSQL); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (guide *GrowGuide) validate() error {
+	switch {
+	case guide.DaysToGermination == nil || *guide.DaysToGermination < 0:
+		return errors.New("daysToGermination is required and cannot be negative")
+	case guide.DaysToMaturity == nil || *guide.DaysToMaturity <= 0:
+		return errors.New("daysToMaturity is required and must be more than 0")
+	case guide.FrostTolerance == nil:
+		return errors.New("frostTolerance is required")
+	case (guide.StartIndoorsEarliest == nil) != (guide.StartIndoorsLatest == nil):
+		return errors.New("startIndoorsEarliest and startIndoorsLatest are set together")
+	case guide.StartIndoorsEarliest != nil && (*guide.StartIndoorsLatest < 0 || *guide.StartIndoorsEarliest < *guide.StartIndoorsLatest):
+		return errors.New("startIndoorsEarliest must be at least as many weeks before the last frost as startIndoorsLatest")
+	}
+
+	if _, ok := plantOutOffsets[*guide.FrostTolerance]; !ok {
+		return fmt.Errorf("frostTolerance must be %s, %s or %s", tenderTolerance, semiHardyTolerance, hardyTolerance)
+	}
+	return nil
+}
+
+// getGrowGuide returns the seed's grow guide
+func getGrowGuide(seedID *string) (*GrowGuide, error) {
+	if seedID == nil {
+		return nil, errors.New("a seed id is required")
+	}
+	guides, err := queryGrowGuides("where seedID = ?", *seedID)
+	if err != nil {
+		return nil, err
+	}
+	if guide, ok := guides[*seedID]; ok {
+		return guide, nil
+	}
+	return nil, fmt.Errorf("seed %s doesn't have a grow guide", *seedID)
+}
+
+// updateGrowGuide adds or replaces the seed's grow guide
+func updateGrowGuide(seedID *string, data interface{}) (*GrowGuide, error) {
+	if seedID == nil {
+		return nil, errors.New("a seed id is required")
+	}
+	if _, err := findItemByID(seedID); err != nil {
+		return nil, err
+	}
+
+	var guide GrowGuide
+	if err := unmarshalData(data, &guide); err != nil {
+		return nil, err
+	}
+	guide.SeedID = seedID
+	if err := guide.validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err := growGuideTable.Exec(growGuideTable.InsertSQL, guide.SeedID, guide.DaysToGermination, guide.DaysToMaturity,
+		guide.FrostTolerance, guide.StartIndoorsEarliest, guide.StartIndoorsLatest); err != nil {
+		return nil, err
+	}
+	return &guide, nil
+}
+
+func queryGrowGuides(where string, args ...any) (map[string]*GrowGuide, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to get grow guides, sqlite error: %s", dbErr)
+	}
+
+	rows, err := db.Query(`select seedID, daysToGermination, daysToMaturity, frostTolerance, startIndoorsEarliest, startIndoorsLatest
+		from growGuides `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	guides := make(map[string]*GrowGuide)
+	for rows.Next() {
+		guide := GrowGuide{}
+		var earliest, latest sql.NullInt64
+		if err := rows.Scan(&guide.SeedID, &guide.DaysToGermination, &guide.DaysToMaturity, &guide.FrostTolerance, &earliest, &latest); err != nil {
+			return nil, err
+		}
+		if earliest.Valid && latest.Valid {
+			e, l := int(earliest.Int64), int(latest.Int64)
+			guide.StartIndoorsEarliest, guide.StartIndoorsLatest = &e, &l
+		}
+		guides[*guide.SeedID] = &guide
+	}
+	return guides, rows.Err()
+}
+
+// findItemByID looks the seed up in every category of the inventory snapshot
+func findItemByID(id *string) (*InventoryItem, error) {
+	inventory, err := getInventory()
+	if err != nil {
+		return nil, err
+	}
+	for _, category := range inventory {
+		if item, ok := category.Items[*id]; ok {
+			copied := *item
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("seed %s not found", *id)
+}
+
+// getPlantingCalendar works out the planting dates for the seed, or every seed with a grow guide if it isn't set, at
+// the location given in the data or the user's location
+func getPlantingCalendar(userID, seedID *string, data interface{}) (*PlantingCalendar, error) {
+	request := calendarRequest{}
+	if data != nil {
+		if err := unmarshalData(data, &request); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if request.Location == nil {
+		if request.Location, err = weather.UserLocation(userID); err != nil {
+			return nil, err
+		}
+	}
+	zipCode, err := weather.GetLocation(request.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	station, err := weather.GetFrostDates(*zipCode.Latitude, *zipCode.Longitude)
+	if err != nil {
+		return nil, err
+	}
+
+	year := time.Now().Year()
+	if request.Year != nil {
+		year = *request.Year
+	}
+
+	where, args := "", []any{}
+	if seedID != nil {
+		where, args = "where seedID = ?", []any{*seedID}
+	}
+	guides, err := queryGrowGuides(where, args...)
+	if err != nil {
+		return nil, err
+	}
+	if seedID != nil && len(guides) == 0 {
+		return nil, fmt.Errorf("seed %s doesn't have a grow guide", *seedID)
+	}
+
+	return buildCalendar(request.Location, year, station, guides, time.Now())
+}
+
+// buildCalendar dates each guide from the station's frost dates.  Seeds go outside the days in plantOutOffsets from
+// the last frost, transplants are ready to harvest the days to maturity later and directly sown seeds also need the
+// days to germination.  Where it doesn't frost everything is sown outside from today, or the start of another year
+func buildCalendar(location *string, year int, station *weather.FrostDateStation, guides map[string]*GrowGuide,
+	now time.Time) (*PlantingCalendar, error) {
+	calendar := &PlantingCalendar{Location: location, Year: &year, Station: station, Seeds: []*PlantingDates{}}
+
+	var lastFrost, firstFrost *time.Time
+	if !station.FrostFree() {
+		var err error
+		if lastFrost, err = station.LastFrost(year); err != nil {
+			return nil, err
+		}
+		if firstFrost, err = station.FirstFrost(year); err != nil {
+			return nil, err
+		}
+		calendar.LastFrost = formatCalendarDate(*lastFrost)
+		calendar.FirstFrost = formatCalendarDate(*firstFrost)
+	}
+
+	frostFreeStart := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if year == now.Year() {
+		frostFreeStart = time.Date(year, now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	for _, guide := range guides {
+		item, err := findItemByID(guide.SeedID)
+		if err != nil {
+			// the guide of a seed that was just removed
+			continue
+		}
+
+		dates := &PlantingDates{
+			SeedID:     item.ID,
+			Category:   item.Category,
+			CommonName: item.CommonName,
+			Cultivar:   item.Cultivar,
+			GrowGuide:  guide,
+		}
+
+		var harvest time.Time
+		switch {
+		case lastFrost == nil:
+			harvest = frostFreeStart.AddDate(0, 0, *guide.DaysToGermination+*guide.DaysToMaturity)
+			dates.DirectSow = formatCalendarDate(frostFreeStart)
+		case guide.StartIndoorsEarliest != nil:
+			transplant := lastFrost.AddDate(0, 0, plantOutOffsets[*guide.FrostTolerance])
+			harvest = transplant.AddDate(0, 0, *guide.DaysToMaturity)
+			dates.StartIndoorsFrom = formatCalendarDate(lastFrost.AddDate(0, 0, -7**guide.StartIndoorsEarliest))
+			dates.StartIndoorsUntil = formatCalendarDate(lastFrost.AddDate(0, 0, -7**guide.StartIndoorsLatest))
+			dates.Transplant = formatCalendarDate(transplant)
+		default:
+			sow := lastFrost.AddDate(0, 0, plantOutOffsets[*guide.FrostTolerance])
+			harvest = sow.AddDate(0, 0, *guide.DaysToGermination+*guide.DaysToMaturity)
+			dates.DirectSow = formatCalendarDate(sow)
+		}
+		dates.Harvest = formatCalendarDate(harvest)
+
+		beforeFirstFrost := firstFrost == nil || !harvest.After(*firstFrost)
+		dates.BeforeFirstFrost = &beforeFirstFrost
+		calendar.Seeds = append(calendar.Seeds, dates)
+	}
+
+	// in the order the seeds are started
+	slices.SortFunc(calendar.Seeds, func(a, b *PlantingDates) int {
+		if c := strings.Compare(a.firstDate(), b.firstDate()); c != 0 {
+			return c
+		}
+		return strings.Compare(*a.SeedID, *b.SeedID)
+	})
+	return calendar, nil
+}
+
+func (dates *PlantingDates) firstDate() string {
+	for _, date := range []*string{dates.StartIndoorsFrom, dates.DirectSow, dates.Transplant} {
+		if date != nil {
+			return *date
+		}
+	}
+	return ""
+}
+
+func formatCalendarDate(date time.Time) *string {
+	s := date.Format(calendarDateFormat)
+	return &s
+}
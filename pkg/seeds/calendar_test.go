@@ -0,0 +1,144 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/utils"
+	"stl-go/grow-with-stl-go/pkg/weather"
+)
+
+func TestPlantingCalendar(t *testing.T) {
+	initInventoryTest()
+	item, err := addInventory(nil, map[string]interface{}{
+		"category":       "Tomato",
+		"genus":          "Solanum",
+		"species":        "lycopersicum",
+		"cultivar":       "Calendar Test",
+		"commonName":     "Tomato",
+		"description":    "Calendar test tomato",
+		"price":          "3.00",
+		"perPacketCount": 20,
+		"packets":        10,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if _, err := findItem(item.Category, item.ID); err == nil {
+			_, err := removeInventory(nil, item.Category, item.ID)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("Test grow guides are validated", func(t *testing.T) {
+		for _, guide := range []map[string]interface{}{
+			{"daysToMaturity": 75, "frostTolerance": tenderTolerance},
+			{"daysToGermination": 7, "daysToMaturity": 0, "frostTolerance": tenderTolerance},
+			{"daysToGermination": 7, "daysToMaturity": 75, "frostTolerance": "frozen"},
+			{"daysToGermination": 7, "daysToMaturity": 75, "frostTolerance": tenderTolerance, "startIndoorsEarliest": 8},
+			{"daysToGermination": 7, "daysToMaturity": 75, "frostTolerance": tenderTolerance, "startIndoorsEarliest": 4, "startIndoorsLatest": 6},
+		} {
+			_, err := updateGrowGuide(item.ID, guide)
+			require.Error(t, err, guide)
+		}
+
+		missing := "no such seed"
+		_, err := updateGrowGuide(&missing, map[string]interface{}{"daysToGermination": 7, "daysToMaturity": 75, "frostTolerance": tenderTolerance})
+		require.Error(t, err)
+		_, err = getGrowGuide(item.ID)
+		require.Error(t, err)
+	})
+
+	t.Run("Test the calendar dates", func(t *testing.T) {
+		_, err := updateGrowGuide(item.ID, map[string]interface{}{
+			"daysToGermination": 7, "daysToMaturity": 75, "frostTolerance": tenderTolerance, "startIndoorsEarliest": 8, "startIndoorsLatest": 6,
+		})
+		require.NoError(t, err)
+		guide, err := getGrowGuide(item.ID)
+		require.NoError(t, err)
+		require.Equal(t, 8, *guide.StartIndoorsEarliest)
+
+		station := &weather.FrostDateStation{LastSpringFrost: utils.StringPointer("04-05"), FirstFallFrost: utils.StringPointer("10-28")}
+		now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+		calendar, err := buildCalendar(nil, 2025, station, map[string]*GrowGuide{*item.ID: guide}, now)
+		require.NoError(t, err)
+		require.Equal(t, "2025-04-05", *calendar.LastFrost)
+		require.Len(t, calendar.Seeds, 1)
+		dates := calendar.Seeds[0]
+		require.Equal(t, "2025-02-08", *dates.StartIndoorsFrom)
+		require.Equal(t, "2025-02-22", *dates.StartIndoorsUntil)
+		require.Equal(t, "2025-04-12", *dates.Transplant)
+		require.Equal(t, "2025-06-26", *dates.Harvest)
+		require.Nil(t, dates.DirectSow)
+		require.True(t, *dates.BeforeFirstFrost)
+
+		// a hardy seed sown outside four weeks before the last frost, it needs to germinate before the days to maturity count
+		hardy := &GrowGuide{SeedID: item.ID, DaysToGermination: intPointer(10), DaysToMaturity: intPointer(200), FrostTolerance: utils.StringPointer(hardyTolerance)}
+		calendar, err = buildCalendar(nil, 2025, station, map[string]*GrowGuide{*item.ID: hardy}, now)
+		require.NoError(t, err)
+		require.Equal(t, "2025-03-08", *calendar.Seeds[0].DirectSow)
+		require.Equal(t, "2025-10-04", *calendar.Seeds[0].Harvest)
+		require.True(t, *calendar.Seeds[0].BeforeFirstFrost)
+		hardy.DaysToMaturity = intPointer(240)
+		calendar, err = buildCalendar(nil, 2025, station, map[string]*GrowGuide{*item.ID: hardy}, now)
+		require.NoError(t, err)
+		require.False(t, *calendar.Seeds[0].BeforeFirstFrost)
+
+		// without a frost everything is sown outside from today
+		calendar, err = buildCalendar(nil, 2025, &weather.FrostDateStation{}, map[string]*GrowGuide{*item.ID: guide}, now)
+		require.NoError(t, err)
+		require.Nil(t, calendar.LastFrost)
+		require.Equal(t, "2025-03-01", *calendar.Seeds[0].DirectSow)
+		require.Nil(t, calendar.Seeds[0].StartIndoorsFrom)
+	})
+
+	t.Run("Test the calendar for a location", func(t *testing.T) {
+		location := "Calendar Test, MO. 63101"
+		latitude, longitude := 38.63, -90.2
+		weather.ZipCodeCacheMutex.Lock()
+		weather.ZipcodeLookup[location] = &weather.ZipCode{Latitude: &latitude, Longitude: &longitude}
+		weather.ZipCodeCacheMutex.Unlock()
+		t.Cleanup(func() {
+			weather.ZipCodeCacheMutex.Lock()
+			delete(weather.ZipcodeLookup, location)
+			weather.ZipCodeCacheMutex.Unlock()
+		})
+
+		calendar, err := getPlantingCalendar(nil, item.ID, map[string]interface{}{"location": location, "year": 2025})
+		require.NoError(t, err)
+		require.Equal(t, "KSTL", *calendar.Station.Station)
+		require.Equal(t, "2025-04-12", *calendar.Seeds[0].Transplant)
+
+		missing := "Nowhere, ZZ. 00000"
+		_, err = getPlantingCalendar(nil, item.ID, map[string]interface{}{"location": missing})
+		require.Error(t, err)
+		_, err = getPlantingCalendar(nil, item.ID, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("Test the guide is removed with the seed", func(t *testing.T) {
+		_, err := removeInventory(nil, item.Category, item.ID)
+		require.NoError(t, err)
+		_, err = getGrowGuide(item.ID)
+		require.Error(t, err)
+	})
+}
+
+func intPointer(i int) *int {
+	return &i
+}
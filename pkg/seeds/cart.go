@@ -0,0 +1,385 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+var (
+	cartTableName = "carts"
+	cartTable     = &configs.Table{
+		CreateSQL: `CREATE TABLE IF NOT EXISTS carts (
+			user varchar(128) NOT NULL,
+			seedID varchar(64) NOT NULL,
+			quantity int NOT NULL,
+			added bigint NOT NULL,
+			PRIMARY KEY (user, seedID))`,
+		// adding a seed that is already in the cart increases the quantity rather than adding a second line
+		InsertSQL: `INSERT INTO carts values(?,?,?,?)
+			ON CONFLICT(user, seedID) DO UPDATE SET quantity = quantity + excluded.quantity`,
+		UpdateSQL: "UPDATE carts set quantity = ? where user = ? and seedID = ?",
+		DeleteSQL: "DELETE FROM carts where user = ? and seedID = ?",
+		Indices: []string{
+			"CREATE INDEX IF NOT EXISTS cartuser on carts(user)",
+		},
+	}
+)
+
+// Cart is the seeds a user intends to purchase, the prices are the current effective prices and are only fixed at checkout
+type Cart struct {
+	User     *string     `json:"user,omitempty"`
+	Lines    []*CartLine `json:"lines"`
+	Total    *Money      `json:"total,omitempty"`
+	Currency *string     `json:"currency,omitempty"`
+}
+
+// CartLine is a seed in the cart along with its current inventory details
+type CartLine struct {
+	SeedID   *string        `json:"seedID,omitempty"`
+	Quantity *int           `json:"quantity,omitempty"`
+	Added    *int64         `json:"added,omitempty"`
+	Item     *InventoryItem `json:"item,omitempty"`
+}
+
+type cartRequest struct {
+	ID       *string      `json:"id,omitempty"`
+	Quantity *interface{} `json:"quantity,omitempty"`
+}
+
+// orderRequest is a seed and quantity to be taken from the inventory when an order is placed
+type orderRequest struct {
+	SeedID   *string
+	Quantity int
+}
+
+// getCart returns the user's cart, seeds that have since been removed from the inventory are dropped from the cart
+func getCart(userID *string) (*Cart, error) {
+	if userID == nil {
+		return nil, errors.New("no user found, cannot retrieve cart")
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve cart, sqlite error: %s", dbErr)
+	}
+
+	promotions, err := getActivePromotions(db, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("select seedID, quantity, added from carts where user = ? order by added", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var total Money
+	cart := &Cart{User: userID, Lines: []*CartLine{}, Total: &total}
+	for rows.Next() {
+		line := CartLine{}
+		if err := rows.Scan(&line.SeedID, &line.Quantity, &line.Added); err != nil {
+			return nil, err
+		}
+
+		item, err := findItem(nil, line.SeedID)
+		if err != nil {
+			log.Debugf("seed %s is no longer in the inventory, skipping it in the cart for %s", *line.SeedID, *userID)
+			continue
+		}
+
+		if cart.Currency == nil {
+			cart.Currency = item.Currency
+		} else if *cart.Currency != *item.Currency {
+			return nil, fmt.Errorf("the cart has seeds priced in both %s and %s", *cart.Currency, *item.Currency)
+		}
+
+		setEffectivePrice(item, promotions)
+		line.Item = item
+		total += item.EffectivePrice.Times(*line.Quantity)
+		cart.Lines = append(cart.Lines, &line)
+	}
+	return cart, rows.Err()
+}
+
+// addToCart puts a seed in the user's cart, the stock is not reserved until checkout
+func addToCart(userID *string, data interface{}) (*Cart, error) {
+	if userID == nil {
+		return nil, errors.New("no user found, cannot add to cart")
+	}
+
+	request, quantity, err := cartRequestFromData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := findItem(nil, request.ID); err != nil {
+		return nil, err
+	}
+
+	if _, err := cartTable.Exec(cartTable.InsertSQL, userID, request.ID, quantity, time.Now().UnixMilli()); err != nil {
+		return nil, err
+	}
+	return getCart(userID)
+}
+
+// updateCart sets the quantity of a seed in the user's cart, a quantity of 0 removes it
+func updateCart(userID, seedID *string, data interface{}) (*Cart, error) {
+	if userID == nil || seedID == nil {
+		return nil, errors.New("no user or seed found, cannot update cart")
+	}
+
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var request cartRequest
+	if err := json.Unmarshal(bytes, &request); err != nil {
+		return nil, err
+	}
+
+	if request.Quantity == nil {
+		return nil, errors.New("no quantity supplied, cannot update cart")
+	}
+
+	quantity, err := determineQuantity(*request.Quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case *quantity < 0:
+		return nil, errors.New("cart quantity cannot be negative")
+	case *quantity == 0:
+		return removeFromCart(userID, seedID)
+	}
+
+	rows, err := cartTable.Exec(cartTable.UpdateSQL, quantity, userID, seedID)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("seed %s is not in the cart", *seedID)
+	}
+	return getCart(userID)
+}
+
+// removeFromCart takes a seed out of the user's cart
+func removeFromCart(userID, seedID *string) (*Cart, error) {
+	if userID == nil || seedID == nil {
+		return nil, errors.New("no user or seed found, cannot remove from cart")
+	}
+
+	rows, err := cartTable.Exec(cartTable.DeleteSQL, userID, seedID)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("seed %s is not in the cart", *seedID)
+	}
+	return getCart(userID)
+}
+
+// checkout purchases everything in the user's cart with the optional coupon.  The stock for every line is taken in one
+// transaction so either the whole cart is purchased or, if any line is short, none of it is
+func checkout(sessionID, userID, couponCode *string) (*Order, error) {
+	if userID == nil {
+		return nil, errors.New("no user found, cannot checkout")
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to checkout, sqlite error: %s", dbErr)
+	}
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer configs.RollbackHelper(tx)
+
+	requests, err := getCartRequests(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, errors.New("the cart is empty, nothing to checkout")
+	}
+
+	order, err := placeOrder(tx, userID, requests, couponCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := cartTable.ExecTx(tx, "DELETE FROM carts where user = ?", userID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// the order is placed once it's committed, failing here would have the client retry and order twice
+	if err := notifyOrder(sessionID, order); err != nil {
+		log.Errorf("order %s was placed but the inventory wasn't reloaded: %s", *order.ID, err)
+	}
+	return order, nil
+}
+
+func getCartRequests(tx *sql.Tx, userID *string) ([]*orderRequest, error) {
+	rows, err := tx.Query("select seedID, quantity from carts where user = ? order by added", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*orderRequest
+	for rows.Next() {
+		request := orderRequest{}
+		if err := rows.Scan(&request.SeedID, &request.Quantity); err != nil {
+			return nil, err
+		}
+		requests = append(requests, &request)
+	}
+	return requests, rows.Err()
+}
+
+// placeOrder takes the stock for each request and records the order as part of an existing transaction.  The packets
+// are only decremented when enough remain so concurrent orders cannot oversell a seed.  The lines are priced with the
+// promotions running now and the coupon, if there is one, is redeemed in the same transaction
+func placeOrder(tx *sql.Tx, userID *string, requests []*orderRequest, couponCode *string) (*Order, error) {
+	seeds := inventoryTables["seeds"]
+	promotions, err := getActivePromotions(tx, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	var currency *string
+	lines := make([]*OrderLine, 0, len(requests))
+	for _, request := range requests {
+		if request.Quantity <= 0 {
+			return nil, fmt.Errorf("quantity for seed %s must be greater than 0", *request.SeedID)
+		}
+
+		item := InventoryItem{ID: request.SeedID}
+		if err := tx.QueryRow("select category, commonName, cultivar, price, currency from seeds where id = ?", request.SeedID).
+			Scan(&item.Category, &item.CommonName, &item.Cultivar, &item.Price, &item.Currency); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("seed %s not found", *request.SeedID)
+			}
+			return nil, err
+		}
+
+		if currency == nil {
+			currency = item.Currency
+		} else if *currency != *item.Currency {
+			return nil, fmt.Errorf("an order cannot mix seeds priced in %s and %s", *currency, *item.Currency)
+		}
+
+		rows, err := seeds.ExecTx(tx, "UPDATE seeds set packets = packets - ? where id = ? and packets >= ?",
+			request.Quantity, request.SeedID, request.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		if rows == 0 {
+			return nil, fmt.Errorf("not enough packets of %s to fill the order", *item.CommonName)
+		}
+
+		setEffectivePrice(&item, promotions)
+		lines = append(lines, newOrderLine(&item, request.Quantity))
+	}
+
+	order := newOrder(userID, currency, lines)
+	redeem := couponCode != nil && strings.TrimSpace(*couponCode) != ""
+	if redeem {
+		if err := redeemCoupon(tx, couponCode, order); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := insertOrder(tx, order); err != nil {
+		return nil, err
+	}
+	if redeem {
+		if err := recordRedemption(tx, order); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// notifyOrder reloads the inventory after an order is committed, broadcasts the new stock of every seed on the order
+// and raises the stock alerts the order calls for rather than leaving them to the next tick of the stock checker
+func notifyOrder(sessionID *string, order *Order) error {
+	invalidateInventoryCache()
+	if _, err := getInventory(); err != nil {
+		return err
+	}
+
+	seedIDs := make([]string, 0, len(order.Lines))
+	for _, line := range order.Lines {
+		item, err := findItem(line.Category, line.SeedID)
+		if err != nil {
+			return err
+		}
+		go notifyAll(purchaseRequestKey, item.Category, item.ID, sessionID, item)
+		seedIDs = append(seedIDs, *item.ID)
+	}
+
+	if _, err := checkStock(seedIDs...); err != nil {
+		log.Errorf("error checking stock levels of order %s: %s", *order.ID, err)
+	}
+	return nil
+}
+
+func cartRequestFromData(data interface{}) (*cartRequest, int, error) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var request cartRequest
+	if err := json.Unmarshal(bytes, &request); err != nil {
+		return nil, 0, err
+	}
+
+	if request.ID == nil {
+		return nil, 0, errors.New("no seed id supplied")
+	}
+
+	quantity := 1
+	if request.Quantity != nil {
+		q, err := determineQuantity(*request.Quantity)
+		if err != nil {
+			return nil, 0, err
+		}
+		quantity = *q
+	}
+	if quantity <= 0 {
+		return nil, 0, errors.New("cart quantity must be greater than 0")
+	}
+	return &request, quantity, nil
+}
@@ -0,0 +1,179 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func addCartTestSeed(t *testing.T, cultivar string, packets int) *InventoryItem {
+	item, err := addInventory(nil, map[string]interface{}{
+		"category":       "Onion",
+		"genus":          "Allium",
+		"species":        "cepa",
+		"cultivar":       cultivar,
+		"commonName":     "Yellow",
+		"description":    "Cart test onion",
+		"price":          2.00,
+		"perPacketCount": 25,
+		"packets":        packets,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := removeInventory(nil, item.Category, item.ID)
+		require.NoError(t, err)
+	})
+	return item
+}
+
+func TestCartFunctions(t *testing.T) {
+	initInventoryTest()
+	first := addCartTestSeed(t, "Cart One", 5)
+	second := addCartTestSeed(t, "Cart Two", 1)
+
+	t.Run("Test cart maintenance", func(t *testing.T) {
+		user := uuid.New().String()
+		cart, err := addToCart(&user, map[string]interface{}{"id": *first.ID, "quantity": 2})
+		require.NoError(t, err)
+		require.Len(t, cart.Lines, 1)
+
+		cart, err = addToCart(&user, map[string]interface{}{"id": *first.ID})
+		require.NoError(t, err)
+		require.Equal(t, 3, *cart.Lines[0].Quantity)
+		require.Equal(t, Money(600), *cart.Total)
+
+		cart, err = updateCart(&user, first.ID, map[string]interface{}{"quantity": "1"})
+		require.NoError(t, err)
+		require.Equal(t, 1, *cart.Lines[0].Quantity)
+
+		cart, err = updateCart(&user, first.ID, map[string]interface{}{"quantity": 0})
+		require.NoError(t, err)
+		require.Empty(t, cart.Lines)
+
+		_, err = removeFromCart(&user, first.ID)
+		require.Error(t, err)
+
+		missing := uuid.New().String()
+		_, err = addToCart(&user, map[string]interface{}{"id": missing})
+		require.Error(t, err)
+	})
+
+	t.Run("Test checkout rejects the whole cart when a line is short", func(t *testing.T) {
+		user := uuid.New().String()
+		_, err := addToCart(&user, map[string]interface{}{"id": *first.ID, "quantity": 2})
+		require.NoError(t, err)
+		_, err = addToCart(&user, map[string]interface{}{"id": *second.ID, "quantity": 2})
+		require.NoError(t, err)
+
+		_, err = checkout(nil, &user, nil)
+		require.Error(t, err)
+
+		item, err := findItem(first.Category, first.ID)
+		require.NoError(t, err)
+		require.Equal(t, 5, *item.Packets)
+
+		cart, err := getCart(&user)
+		require.NoError(t, err)
+		require.Len(t, cart.Lines, 2)
+
+		_, err = updateCart(&user, second.ID, map[string]interface{}{"quantity": 1})
+		require.NoError(t, err)
+
+		order, err := checkout(nil, &user, nil)
+		require.NoError(t, err)
+		require.Len(t, order.Lines, 2)
+		require.Equal(t, Money(600), *order.Total)
+
+		item, err = findItem(first.Category, first.ID)
+		require.NoError(t, err)
+		require.Equal(t, 3, *item.Packets)
+
+		cart, err = getCart(&user)
+		require.NoError(t, err)
+		require.Empty(t, cart.Lines)
+
+		_, err = checkout(nil, &user, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("Test concurrent checkouts cannot oversell", func(t *testing.T) {
+		var users []string
+		for i := 0; i < 5; i++ {
+			user := uuid.New().String()
+			_, err := addToCart(&user, map[string]interface{}{"id": *first.ID, "quantity": 1})
+			require.NoError(t, err)
+			users = append(users, user)
+		}
+
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		successes := 0
+		for _, user := range users {
+			wg.Add(1)
+			go func(user string) {
+				defer wg.Done()
+				if _, err := checkout(nil, &user, nil); err == nil {
+					mutex.Lock()
+					successes++
+					mutex.Unlock()
+				}
+			}(user)
+		}
+		wg.Wait()
+
+		item, err := findItem(first.Category, first.ID)
+		require.NoError(t, err)
+		require.Equal(t, 3-successes, *item.Packets)
+		require.GreaterOrEqual(t, *item.Packets, 0)
+	})
+
+	t.Run("Test a removed seed doesn't block checkout", func(t *testing.T) {
+		kept := addCartTestSeed(t, "Cart Kept", 2)
+		removed, err := addInventory(nil, map[string]interface{}{
+			"category":       "Onion",
+			"genus":          "Allium",
+			"species":        "cepa",
+			"cultivar":       "Cart Removed",
+			"commonName":     "Yellow",
+			"description":    "Cart test onion",
+			"price":          2.00,
+			"perPacketCount": 25,
+			"packets":        2,
+		})
+		require.NoError(t, err)
+
+		user := uuid.New().String()
+		_, err = addToCart(&user, map[string]interface{}{"id": *kept.ID, "quantity": 1})
+		require.NoError(t, err)
+		_, err = addToCart(&user, map[string]interface{}{"id": *removed.ID, "quantity": 1})
+		require.NoError(t, err)
+
+		_, err = removeInventory(nil, removed.Category, removed.ID)
+		require.NoError(t, err)
+
+		cart, err := getCart(&user)
+		require.NoError(t, err)
+		require.Len(t, cart.Lines, 1)
+
+		order, err := checkout(nil, &user, nil)
+		require.NoError(t, err)
+		require.Len(t, order.Lines, 1)
+		require.Equal(t, *kept.ID, *order.Lines[0].SeedID)
+	})
+}
@@ -0,0 +1,210 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+	"stl-go/grow-with-stl-go/pkg/webservice"
+)
+
+// Category is the record stored in the categories table
+type Category struct {
+	Name         *string `json:"name,omitempty"`
+	Description  *string `json:"description,omitempty"`
+	DisplayOrder *int    `json:"displayOrder,omitempty"`
+	Image        *string `json:"image,omitempty"`
+}
+
+// loadCategories starts a new inventory snapshot with every category, including the ones that have no items yet
+func loadCategories(tx *sql.Tx) (map[string]*InventoryCategory, error) {
+	rows, err := tx.Query("select name, description, displayOrder, image from categories")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inventory := make(map[string]*InventoryCategory)
+	for rows.Next() {
+		ic := InventoryCategory{
+			Items: make(map[string]*InventoryItem),
+		}
+		if err := rows.Scan(&ic.Category, &ic.Description, &ic.DisplayOrder, &ic.Image); err != nil {
+			return nil, err
+		}
+		if ic.Category != nil {
+			inventory[*ic.Category] = &ic
+		}
+	}
+	return inventory, rows.Err()
+}
+
+// getCategories returns the categories in display order
+func getCategories() ([]*Category, error) {
+	inventory, err := getInventory()
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make([]*Category, 0, len(inventory))
+	for _, ic := range inventory {
+		if ic != nil {
+			categories = append(categories, &Category{
+				Name:         ic.Category,
+				Description:  ic.Description,
+				DisplayOrder: ic.DisplayOrder,
+				Image:        ic.Image,
+			})
+		}
+	}
+
+	slices.SortFunc(categories, func(a, b *Category) int {
+		if a.DisplayOrder != nil && b.DisplayOrder != nil && *a.DisplayOrder != *b.DisplayOrder {
+			return *a.DisplayOrder - *b.DisplayOrder
+		}
+		return strings.Compare(*a.Name, *b.Name)
+	})
+	return categories, nil
+}
+
+// categoryExists tests the category name against the categories table via the inventory snapshot
+func categoryExists(name *string) bool {
+	if name == nil {
+		return false
+	}
+	inventory, err := getInventory()
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	_, ok := inventory[*name]
+	return ok
+}
+
+func categoryFromData(data interface{}) (*Category, error) {
+	if data == nil {
+		return nil, errors.New("no category supplied")
+	}
+
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var category Category
+	if err := json.Unmarshal(bytes, &category); err != nil {
+		return nil, err
+	}
+
+	if category.Name == nil || strings.TrimSpace(*category.Name) == "" {
+		return nil, errors.New("category name is required")
+	}
+
+	if category.DisplayOrder == nil {
+		displayOrder := 0
+		category.DisplayOrder = &displayOrder
+	}
+	return &category, nil
+}
+
+// addCategory creates a new category for seeds to be assigned to
+func addCategory(sessionID *string, data interface{}) (*Category, error) {
+	category, err := categoryFromData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if categoryExists(category.Name) {
+		return nil, fmt.Errorf("category %s already exists", *category.Name)
+	}
+
+	if table, ok := inventoryTables["categories"]; ok && table != nil {
+		if _, err := table.Exec(table.InsertSQL, category.Name, category.Description, category.DisplayOrder, category.Image); err != nil {
+			return nil, err
+		}
+		return refreshCategoriesAndNotify(addCategoryRequestKey, sessionID, category)
+	}
+	return nil, errors.New("requirements not met to add category")
+}
+
+// updateCategory changes the details of a category, renaming a category cascades to the seeds assigned to it
+func updateCategory(sessionID, name *string, data interface{}) (*Category, error) {
+	if !categoryExists(name) {
+		return nil, errors.New("category not found")
+	}
+
+	category, err := categoryFromData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(*name, *category.Name) && categoryExists(category.Name) {
+		return nil, fmt.Errorf("category %s already exists", *category.Name)
+	}
+
+	if table, ok := inventoryTables["categories"]; ok && table != nil {
+		if _, err := table.Exec(table.UpdateSQL, category.Name, category.Description, category.DisplayOrder, category.Image, name); err != nil {
+			return nil, err
+		}
+		return refreshCategoriesAndNotify(updateCategoryRequestKey, sessionID, category)
+	}
+	return nil, errors.New("requirements not met to update category")
+}
+
+// removeCategory deletes a category, it is refused while seeds are still assigned to it
+func removeCategory(sessionID, name *string) (*Category, error) {
+	if !categoryExists(name) {
+		return nil, errors.New("category not found")
+	}
+
+	inventory, err := getInventory()
+	if err != nil {
+		return nil, err
+	}
+	if ic := inventory[*name]; ic != nil && len(ic.Items) > 0 {
+		return nil, fmt.Errorf("category %s still has %d seeds assigned to it", *name, len(ic.Items))
+	}
+
+	if table, ok := inventoryTables["categories"]; ok && table != nil {
+		if _, err := table.Exec(table.DeleteSQL, name); err != nil {
+			return nil, err
+		}
+		return refreshCategoriesAndNotify(removeCategoryRequestKey, sessionID, &Category{Name: name})
+	}
+	return nil, errors.New("requirements not met to remove category")
+}
+
+func refreshCategoriesAndNotify(requestType string, sessionID *string, category *Category) (*Category, error) {
+	invalidateInventoryCache()
+	if _, err := getInventory(); err != nil {
+		return nil, err
+	}
+
+	route := seedsKey
+	go webservice.NotifyAll(sessionID, &configs.WsMessage{
+		Route:     &route,
+		Type:      &requestType,
+		Component: category.Name,
+		Data:      category,
+	})
+	return category, nil
+}
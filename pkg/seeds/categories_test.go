@@ -0,0 +1,123 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+func TestCategoryFunctions(t *testing.T) {
+	initInventoryTest()
+	t.Run("Test category maintenance", func(t *testing.T) {
+		_, err := addCategory(nil, map[string]interface{}{"description": "no name"})
+		require.Error(t, err)
+
+		category, err := addCategory(nil, map[string]interface{}{"name": "Lettuce", "description": "Leafy greens", "displayOrder": 5})
+		require.NoError(t, err)
+		require.True(t, categoryExists(category.Name))
+
+		item, err := addInventory(nil, map[string]interface{}{
+			"category":       "Lettuce",
+			"genus":          "Lactuca",
+			"species":        "sativa",
+			"cultivar":       "Buttercrunch",
+			"commonName":     "Bibb",
+			"description":    "Heat tolerant butterhead",
+			"price":          2.50,
+			"perPacketCount": 200,
+			"packets":        10,
+		})
+		require.NoError(t, err)
+
+		_, err = removeCategory(nil, category.Name)
+		require.Error(t, err)
+
+		renamed := "Salad Greens"
+		_, err = updateCategory(nil, category.Name, map[string]interface{}{"name": renamed, "displayOrder": 5})
+		require.NoError(t, err)
+
+		// the rename should have cascaded to the seed
+		detail, err := findItem(&renamed, item.ID)
+		require.NoError(t, err)
+		require.Equal(t, renamed, *detail.Category)
+
+		_, err = removeInventory(nil, &renamed, item.ID)
+		require.NoError(t, err)
+
+		_, err = removeCategory(nil, &renamed)
+		require.NoError(t, err)
+		require.False(t, categoryExists(&renamed))
+
+		categories, err := getCategories()
+		require.NoError(t, err)
+		require.NotEmpty(t, categories)
+	})
+
+	t.Run("Test converting the category check constraint", func(t *testing.T) {
+		// swap in a scratch database with the original seeds table
+		originalDB := configs.GrowSTLGo.SQLite.DB
+		defer func() {
+			configs.GrowSTLGo.SQLite.DB = originalDB
+			invalidateInventoryCache()
+		}()
+
+		db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_foreign_keys=1", filepath.Join(t.TempDir(), "check.db")))
+		require.NoError(t, err)
+		defer db.Close()
+		configs.GrowSTLGo.SQLite.DB = db
+
+		_, err = db.Exec(checkConstraintSeedsSQL)
+		require.NoError(t, err)
+		_, err = db.Exec(`insert into seeds values ('1', 'Herb', 'Anethum', 'graveolens', 'Ella', 'Dill Weed', 'dwarf dill', 0, 2.67, 20, 100, null)`)
+		require.NoError(t, err)
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, categoriesUp(tx))
+		require.NoError(t, tx.Commit())
+
+		var count int
+		require.NoError(t, db.QueryRow("select count(*) from seeds where category = 'Herb'").Scan(&count))
+		require.Equal(t, 1, count)
+
+		// the constraint should now be driven by the categories table
+		_, err = db.Exec(`insert into seeds values ('2', 'Squash', 'Cucurbita', 'pepo', null, 'Zucchini', 'summer squash', 0, 2.00, 20, 100, null)`)
+		require.Error(t, err)
+		_, err = db.Exec("insert into categories (name) values ('Squash')")
+		require.NoError(t, err)
+		_, err = db.Exec(`insert into seeds values ('2', 'Squash', 'Cucurbita', 'pepo', null, 'Zucchini', 'summer squash', 0, 2.00, 20, 100, null)`)
+		require.NoError(t, err)
+
+		// rolling back is refused while a seed is outside of the original categories
+		tx, err = db.Begin()
+		require.NoError(t, err)
+		require.Error(t, categoriesDown(tx))
+		require.NoError(t, tx.Rollback())
+
+		_, err = db.Exec("delete from seeds where id = '2'")
+		require.NoError(t, err)
+		tx, err = db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, categoriesDown(tx))
+		require.NoError(t, tx.Commit())
+	})
+}
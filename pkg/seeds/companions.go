@@ -0,0 +1,471 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+const (
+	companionRelationship  = "companion"
+	antagonistRelationship = "antagonist"
+
+	outOfBoundsConflict = "outOfBounds"
+	spacingConflict     = "spacing"
+	antagonistConflict  = "antagonist"
+
+	companionSuggestion = "companion"
+
+	// neighborDistance is how close in inches two plants have to be for a companion or antagonist to affect the other
+	neighborDistance = 36.0
+)
+
+var (
+	companionRuleTableName = "companionRules"
+	companionRuleTable     = &configs.Table{
+		// the pair is stored once with the species in alphabetical order
+		CreateSQL: `CREATE TABLE IF NOT EXISTS companionRules (
+			speciesA varchar(256) NOT NULL,
+			speciesB varchar(256) NOT NULL,
+			relationship varchar(16) NOT NULL CHECK (relationship in ('companion', 'antagonist')),
+			reason varchar(1024),
+			PRIMARY KEY (speciesA, speciesB),
+			CHECK (speciesA < speciesB))`,
+		InsertSQL: `INSERT INTO companionRules values(?,?,?,?) ON CONFLICT(speciesA, speciesB) DO UPDATE set
+			relationship = excluded.relationship, reason = excluded.reason`,
+		DeleteSQL: "DELETE FROM companionRules where speciesA = ? and speciesB = ?",
+		Defaults: map[string]string{
+			"basil tomato": `insert or ignore into companionRules values ('ocimum basilicum', 'solanum lycopersicum', 'companion',
+				'basil repels thrips and hornworms')`,
+			"basil pepper": `insert or ignore into companionRules values ('capsicum annuum', 'ocimum basilicum', 'companion',
+				'basil repels aphids, spider mites and thrips')`,
+			"chive tomato": `insert or ignore into companionRules values ('allium schoenoprasum', 'solanum lycopersicum', 'companion',
+				'chives deter aphids')`,
+			"onion pepper": `insert or ignore into companionRules values ('allium cepa', 'capsicum annuum', 'companion',
+				'onions deter aphids and slugs')`,
+			"oregano pepper": `insert or ignore into companionRules values ('capsicum annuum', 'origanum vulgare', 'companion',
+				'oregano is a ground cover that keeps the soil moist')`,
+			"dill tomato": `insert or ignore into companionRules values ('anethum graveolens', 'solanum lycopersicum', 'antagonist',
+				'mature dill stunts the growth of tomatoes')`,
+			"onion bean": `insert or ignore into companionRules values ('allium cepa', 'phaseolus vulgaris', 'antagonist',
+				'onions stunt the growth of beans')`,
+		},
+	}
+
+	spacingRuleTableName = "spacingRules"
+	spacingRuleTable     = &configs.Table{
+		// the spacing is the inches between plants
+		CreateSQL: `CREATE TABLE IF NOT EXISTS spacingRules (
+			species varchar(256) NOT NULL PRIMARY KEY,
+			spacing real NOT NULL CHECK (spacing > 0))`,
+		InsertSQL: "INSERT INTO spacingRules values(?,?) ON CONFLICT(species) DO UPDATE set spacing = excluded.spacing",
+		DeleteSQL: "DELETE FROM spacingRules where species = ?",
+		Defaults: map[string]string{
+			"tomato":  "insert or ignore into spacingRules values ('solanum lycopersicum', 24)",
+			"pepper":  "insert or ignore into spacingRules values ('capsicum annuum', 18)",
+			"onion":   "insert or ignore into spacingRules values ('allium cepa', 4)",
+			"basil":   "insert or ignore into spacingRules values ('ocimum basilicum', 12)",
+			"dill":    "insert or ignore into spacingRules values ('anethum graveolens', 12)",
+			"oregano": "insert or ignore into spacingRules values ('origanum vulgare', 12)",
+			"chive":   "insert or ignore into spacingRules values ('allium schoenoprasum', 8)",
+		},
+	}
+)
+
+// CompanionRule is how two species affect each other when they are planted near each other, the species are the genus
+// and species of a seed such as "solanum lycopersicum"
+type CompanionRule struct {
+	SpeciesA     *string `json:"speciesA,omitempty"`
+	SpeciesB     *string `json:"speciesB,omitempty"`
+	Relationship *string `json:"relationship,omitempty"`
+	Reason       *string `json:"reason,omitempty"`
+}
+
+// SpacingRule is how many inches apart plants of a species need to be
+type SpacingRule struct {
+	Species *string  `json:"species,omitempty"`
+	Spacing *float64 `json:"spacing,omitempty"`
+}
+
+// PlantingRules are every companion and spacing rule
+type PlantingRules struct {
+	Companions []*CompanionRule `json:"companions"`
+	Spacing    []*SpacingRule   `json:"spacing"`
+}
+
+// LayoutPlant is a seed planted in a bed layout, x and y are the feet from the corner of the bed
+type LayoutPlant struct {
+	SeedID *string  `json:"seed,omitempty"`
+	X      *float64 `json:"x,omitempty"`
+	Y      *float64 `json:"y,omitempty"`
+}
+
+// layoutRequest is a proposed layout of a bed, the width and length are in feet like a garden bed
+type layoutRequest struct {
+	Width  *float64       `json:"width,omitempty"`
+	Length *float64       `json:"length,omitempty"`
+	Plants []*LayoutPlant `json:"plants,omitempty"`
+}
+
+// LayoutReport is the result of validating a bed layout, a layout is valid when it has no conflicts
+type LayoutReport struct {
+	Valid       bool                `json:"valid"`
+	Conflicts   []*LayoutConflict   `json:"conflicts"`
+	Suggestions []*LayoutSuggestion `json:"suggestions"`
+}
+
+// LayoutConflict is a problem with the layout, the plants are the indexes of the plants in the layout
+type LayoutConflict struct {
+	Kind    string `json:"kind"`
+	Plants  []int  `json:"plants"`
+	Message string `json:"message"`
+}
+
+// LayoutSuggestion is a species that would help the plants of the layout along with the seeds in inventory of it
+type LayoutSuggestion struct {
+	Kind    string   `json:"kind"`
+	Species string   `json:"species"`
+	For     []string `json:"for"`
+	Seeds   []string `json:"seeds"`
+	Reasons []string `json:"reasons"`
+}
+
+func setupPlantingRules() error {
+	if err := companionRuleTable.CreateTable(&companionRuleTableName); err != nil {
+		return err
+	}
+	return spacingRuleTable.CreateTable(&spacingRuleTableName)
+}
+
+// normalizeSpecies lower cases the species and collapses the spaces so "Solanum  Lycopersicum" matches the rules
+func normalizeSpecies(species string) string {
+	return strings.Join(strings.Fields(strings.ToLower(species)), " ")
+}
+
+// itemSpecies is the genus and species of the seed, empty when the seed doesn't have them
+func itemSpecies(item *InventoryItem) string {
+	if item == nil || item.Genus == nil || item.Species == nil {
+		return ""
+	}
+	return normalizeSpecies(*item.Genus + " " + *item.Species)
+}
+
+// getPlantingRules returns every companion and spacing rule
+func getPlantingRules() (*PlantingRules, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve planting rules, sqlite error: %s", dbErr)
+	}
+
+	rules := PlantingRules{Companions: []*CompanionRule{}, Spacing: []*SpacingRule{}}
+	rows, err := db.Query("select speciesA, speciesB, relationship, reason from companionRules order by speciesA, speciesB")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rule := CompanionRule{}
+		if err := rows.Scan(&rule.SpeciesA, &rule.SpeciesB, &rule.Relationship, &rule.Reason); err != nil {
+			return nil, err
+		}
+		rules.Companions = append(rules.Companions, &rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	spacingRows, err := db.Query("select species, spacing from spacingRules order by species")
+	if err != nil {
+		return nil, err
+	}
+	defer spacingRows.Close()
+	for spacingRows.Next() {
+		rule := SpacingRule{}
+		if err := spacingRows.Scan(&rule.Species, &rule.Spacing); err != nil {
+			return nil, err
+		}
+		rules.Spacing = append(rules.Spacing, &rule)
+	}
+	return &rules, spacingRows.Err()
+}
+
+// updateCompanionRule adds or replaces the relationship between two species
+func updateCompanionRule(data interface{}) (*CompanionRule, error) {
+	var rule CompanionRule
+	if err := unmarshalData(data, &rule); err != nil {
+		return nil, err
+	}
+	if rule.SpeciesA == nil || rule.SpeciesB == nil {
+		return nil, errors.New("a companion rule requires speciesA and speciesB")
+	}
+	speciesA, speciesB, err := speciesPair(*rule.SpeciesA, *rule.SpeciesB)
+	if err != nil {
+		return nil, err
+	}
+	if rule.Relationship == nil || (*rule.Relationship != companionRelationship && *rule.Relationship != antagonistRelationship) {
+		return nil, fmt.Errorf("relationship must be %s or %s", companionRelationship, antagonistRelationship)
+	}
+
+	rule.SpeciesA, rule.SpeciesB = &speciesA, &speciesB
+	if _, err := companionRuleTable.Exec(companionRuleTable.InsertSQL, rule.SpeciesA, rule.SpeciesB, rule.Relationship,
+		rule.Reason); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// removeCompanionRule deletes the relationship between two species, they can be given in either order
+func removeCompanionRule(speciesA, speciesB *string) (*CompanionRule, error) {
+	if speciesA == nil || speciesB == nil {
+		return nil, errors.New("both species are required to remove a companion rule")
+	}
+	a, b, err := speciesPair(*speciesA, *speciesB)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := companionRuleTable.Exec(companionRuleTable.DeleteSQL, a, b)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("there is no companion rule for %s and %s", a, b)
+	}
+	return &CompanionRule{SpeciesA: &a, SpeciesB: &b}, nil
+}
+
+// speciesPair normalizes the species and puts them in the order they are stored in
+func speciesPair(speciesA, speciesB string) (string, string, error) {
+	a, b := normalizeSpecies(speciesA), normalizeSpecies(speciesB)
+	if a == "" || b == "" {
+		return "", "", errors.New("a companion rule requires speciesA and speciesB")
+	}
+	if a == b {
+		return "", "", errors.New("a companion rule requires two different species")
+	}
+	if b < a {
+		a, b = b, a
+	}
+	return a, b, nil
+}
+
+// updateSpacingRule adds or replaces the spacing of a species
+func updateSpacingRule(data interface{}) (*SpacingRule, error) {
+	var rule SpacingRule
+	if err := unmarshalData(data, &rule); err != nil {
+		return nil, err
+	}
+	if rule.Species == nil || normalizeSpecies(*rule.Species) == "" {
+		return nil, errors.New("a spacing rule requires a species")
+	}
+	if rule.Spacing == nil || *rule.Spacing <= 0 {
+		return nil, errors.New("a spacing rule requires a spacing greater than 0")
+	}
+
+	species := normalizeSpecies(*rule.Species)
+	rule.Species = &species
+	if _, err := spacingRuleTable.Exec(spacingRuleTable.InsertSQL, rule.Species, rule.Spacing); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// removeSpacingRule deletes the spacing of a species
+func removeSpacingRule(species *string) (*SpacingRule, error) {
+	if species == nil || normalizeSpecies(*species) == "" {
+		return nil, errors.New("a species is required to remove a spacing rule")
+	}
+
+	normalized := normalizeSpecies(*species)
+	rows, err := spacingRuleTable.Exec(spacingRuleTable.DeleteSQL, normalized)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("there is no spacing rule for %s", normalized)
+	}
+	return &SpacingRule{Species: &normalized}, nil
+}
+
+// validateLayout checks a proposed bed layout against the planting rules.  Plants outside of the bed, plants closer
+// than the spacing of their species and antagonists that are neighbors are conflicts.  Companions of the species in
+// the layout that aren't in it are suggested along with the seeds in inventory of them
+func validateLayout(data interface{}) (*LayoutReport, error) {
+	var request layoutRequest
+	if data == nil {
+		return nil, errors.New("a layout is required")
+	}
+	if err := unmarshalData(data, &request); err != nil {
+		return nil, err
+	}
+	if request.Width == nil || *request.Width <= 0 || request.Length == nil || *request.Length <= 0 {
+		return nil, errors.New("a layout requires a width and length greater than 0")
+	}
+
+	species := make([]string, len(request.Plants))
+	for i, plant := range request.Plants {
+		if plant == nil || plant.SeedID == nil || plant.X == nil || plant.Y == nil {
+			return nil, fmt.Errorf("plant %d requires a seed, x and y", i)
+		}
+		item, err := findItemByID(plant.SeedID)
+		if err != nil {
+			return nil, err
+		}
+		species[i] = itemSpecies(item)
+	}
+
+	rules, err := getPlantingRules()
+	if err != nil {
+		return nil, err
+	}
+	spacing := make(map[string]float64, len(rules.Spacing))
+	for _, rule := range rules.Spacing {
+		spacing[*rule.Species] = *rule.Spacing
+	}
+	relationships := make(map[[2]string]*CompanionRule, len(rules.Companions))
+	for _, rule := range rules.Companions {
+		relationships[[2]string{*rule.SpeciesA, *rule.SpeciesB}] = rule
+	}
+
+	report := &LayoutReport{Conflicts: []*LayoutConflict{}, Suggestions: []*LayoutSuggestion{}}
+	for i, plant := range request.Plants {
+		if *plant.X < 0 || *plant.X > *request.Width || *plant.Y < 0 || *plant.Y > *request.Length {
+			report.Conflicts = append(report.Conflicts, &LayoutConflict{
+				Kind:    outOfBoundsConflict,
+				Plants:  []int{i},
+				Message: fmt.Sprintf("plant %d at %g, %g is outside of the %g by %g bed", i, *plant.X, *plant.Y, *request.Width, *request.Length),
+			})
+		}
+	}
+
+	for i := range request.Plants {
+		for j := i + 1; j < len(request.Plants); j++ {
+			// the positions are in feet and the rules in inches
+			distance := math.Hypot(*request.Plants[i].X-*request.Plants[j].X, *request.Plants[i].Y-*request.Plants[j].Y) * 12
+			if required, ok := requiredSpacing(spacing, species[i], species[j]); ok && distance < required {
+				report.Conflicts = append(report.Conflicts, &LayoutConflict{
+					Kind:    spacingConflict,
+					Plants:  []int{i, j},
+					Message: fmt.Sprintf("plants %d and %d are %.1f inches apart and need %.1f", i, j, distance, required),
+				})
+			}
+
+			if distance > neighborDistance || species[i] == "" || species[j] == "" || species[i] == species[j] {
+				continue
+			}
+			a, b := species[i], species[j]
+			if b < a {
+				a, b = b, a
+			}
+			if rule, ok := relationships[[2]string{a, b}]; ok && *rule.Relationship == antagonistRelationship {
+				message := fmt.Sprintf("plants %d and %d are %s and %s which shouldn't be planted together", i, j, species[i],
+					species[j])
+				if rule.Reason != nil {
+					message = fmt.Sprintf("%s, %s", message, *rule.Reason)
+				}
+				report.Conflicts = append(report.Conflicts, &LayoutConflict{Kind: antagonistConflict, Plants: []int{i, j},
+					Message: message})
+			}
+		}
+	}
+
+	suggestions, err := companionSuggestions(species, rules.Companions)
+	if err != nil {
+		return nil, err
+	}
+	report.Suggestions = suggestions
+	report.Valid = len(report.Conflicts) == 0
+	return report, nil
+}
+
+// requiredSpacing is the average of the spacing of the two species, or the spacing of the one with a rule
+func requiredSpacing(spacing map[string]float64, speciesA, speciesB string) (float64, bool) {
+	a, aOK := spacing[speciesA]
+	b, bOK := spacing[speciesB]
+	switch {
+	case aOK && bOK:
+		return (a + b) / 2, true
+	case aOK:
+		return a, true
+	case bOK:
+		return b, true
+	}
+	return 0, false
+}
+
+// companionSuggestions finds the companions of the species in the layout that aren't already in it
+func companionSuggestions(species []string, companions []*CompanionRule) ([]*LayoutSuggestion, error) {
+	inLayout := make(map[string]bool, len(species))
+	for _, s := range species {
+		if s != "" {
+			inLayout[s] = true
+		}
+	}
+
+	suggested := map[string]*LayoutSuggestion{}
+	for _, rule := range companions {
+		if *rule.Relationship != companionRelationship {
+			continue
+		}
+		for _, pair := range [][2]string{{*rule.SpeciesA, *rule.SpeciesB}, {*rule.SpeciesB, *rule.SpeciesA}} {
+			planted, companion := pair[0], pair[1]
+			if !inLayout[planted] || inLayout[companion] {
+				continue
+			}
+			suggestion, ok := suggested[companion]
+			if !ok {
+				suggestion = &LayoutSuggestion{Kind: companionSuggestion, Species: companion, For: []string{}, Seeds: []string{},
+					Reasons: []string{}}
+				suggested[companion] = suggestion
+			}
+			suggestion.For = append(suggestion.For, planted)
+			if rule.Reason != nil {
+				suggestion.Reasons = append(suggestion.Reasons, *rule.Reason)
+			}
+		}
+	}
+	if len(suggested) == 0 {
+		return []*LayoutSuggestion{}, nil
+	}
+
+	inventory, err := getInventory()
+	if err != nil {
+		return nil, err
+	}
+	for _, category := range inventory {
+		for _, item := range category.Items {
+			if suggestion, ok := suggested[itemSpecies(item)]; ok {
+				suggestion.Seeds = append(suggestion.Seeds, *item.ID)
+			}
+		}
+	}
+
+	suggestions := make([]*LayoutSuggestion, 0, len(suggested))
+	for _, suggestion := range suggested {
+		slices.Sort(suggestion.For)
+		slices.Sort(suggestion.Seeds)
+		suggestions = append(suggestions, suggestion)
+	}
+	slices.SortFunc(suggestions, func(a, b *LayoutSuggestion) int {
+		return strings.Compare(a.Species, b.Species)
+	})
+	return suggestions, nil
+}
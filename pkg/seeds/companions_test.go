@@ -0,0 +1,188 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+// seedIDByCultivar finds a default seed in the inventory
+func seedIDByCultivar(t *testing.T, cultivar string) string {
+	inventory, err := getInventory()
+	require.NoError(t, err)
+	for _, category := range inventory {
+		for _, item := range category.Items {
+			if item.Cultivar != nil && *item.Cultivar == cultivar {
+				return *item.ID
+			}
+		}
+	}
+	require.Failf(t, "seed not found", "no seed with the cultivar %s", cultivar)
+	return ""
+}
+
+func TestCompanionFunctions(t *testing.T) {
+	initInventoryTest()
+	tomato := seedIDByCultivar(t, "San Marzano")
+	basil := seedIDByCultivar(t, "Genovese")
+	dill := seedIDByCultivar(t, "Ella")
+	pepper := seedIDByCultivar(t, "Ozark Giant")
+
+	t.Run("Test the default rules", func(t *testing.T) {
+		rules, err := getPlantingRules()
+		require.NoError(t, err)
+		require.NotEmpty(t, rules.Companions)
+		require.NotEmpty(t, rules.Spacing)
+		for _, rule := range rules.Companions {
+			require.Less(t, *rule.SpeciesA, *rule.SpeciesB)
+		}
+	})
+
+	t.Run("Test a valid layout", func(t *testing.T) {
+		report, err := validateLayout(map[string]interface{}{
+			"width":  4,
+			"length": 8,
+			"plants": []map[string]interface{}{
+				{"seed": tomato, "x": 1, "y": 1},
+				{"seed": tomato, "x": 1, "y": 3.5},
+				{"seed": basil, "x": 2.5, "y": 2},
+			},
+		})
+		require.NoError(t, err)
+		require.True(t, report.Valid)
+		require.Empty(t, report.Conflicts)
+
+		// chives help the tomatoes and aren't in the layout, basil already is
+		require.Len(t, report.Suggestions, 2)
+		require.Equal(t, "allium schoenoprasum", report.Suggestions[0].Species)
+		require.Equal(t, []string{"solanum lycopersicum"}, report.Suggestions[0].For)
+		require.Len(t, report.Suggestions[0].Seeds, 1)
+		require.Equal(t, "capsicum annuum", report.Suggestions[1].Species)
+		require.Equal(t, []string{"ocimum basilicum"}, report.Suggestions[1].For)
+		require.NotEmpty(t, report.Suggestions[1].Seeds)
+	})
+
+	t.Run("Test layout conflicts", func(t *testing.T) {
+		report, err := validateLayout(map[string]interface{}{
+			"width":  4,
+			"length": 8,
+			"plants": []map[string]interface{}{
+				{"seed": tomato, "x": 1, "y": 1},
+				{"seed": tomato, "x": 1, "y": 2},
+				{"seed": dill, "x": 3, "y": 1},
+				{"seed": pepper, "x": 5, "y": 7},
+				// the dill is far enough away from this tomato to not be a neighbor
+				{"seed": tomato, "x": 1, "y": 7},
+			},
+		})
+		require.NoError(t, err)
+		require.False(t, report.Valid)
+
+		kinds := map[string][][]int{}
+		for _, conflict := range report.Conflicts {
+			kinds[conflict.Kind] = append(kinds[conflict.Kind], conflict.Plants)
+		}
+		require.Equal(t, [][]int{{3}}, kinds[outOfBoundsConflict])
+		require.Equal(t, [][]int{{0, 1}}, kinds[spacingConflict])
+		require.Equal(t, [][]int{{0, 2}, {1, 2}}, kinds[antagonistConflict])
+	})
+
+	t.Run("Test invalid layouts", func(t *testing.T) {
+		_, err := validateLayout(nil)
+		require.Error(t, err)
+		_, err = validateLayout(map[string]interface{}{"width": 0, "length": 8})
+		require.Error(t, err)
+		_, err = validateLayout(map[string]interface{}{"width": 4, "length": 8,
+			"plants": []map[string]interface{}{{"seed": tomato, "x": 1}}})
+		require.Error(t, err)
+		_, err = validateLayout(map[string]interface{}{"width": 4, "length": 8,
+			"plants": []map[string]interface{}{{"seed": "bogus", "x": 1, "y": 1}}})
+		require.Error(t, err)
+	})
+
+	t.Run("Test maintaining the rules", func(t *testing.T) {
+		_, err := updateCompanionRule(map[string]interface{}{"speciesA": "Lactuca sativa", "speciesB": "lactuca  sativa",
+			"relationship": companionRelationship})
+		require.Error(t, err)
+		_, err = updateCompanionRule(map[string]interface{}{"speciesA": "Lactuca sativa", "speciesB": "Daucus carota",
+			"relationship": "friend"})
+		require.Error(t, err)
+
+		rule, err := updateCompanionRule(map[string]interface{}{"speciesA": "Lactuca sativa", "speciesB": "Daucus  Carota",
+			"relationship": companionRelationship, "reason": "carrots loosen the soil"})
+		require.NoError(t, err)
+		require.Equal(t, "daucus carota", *rule.SpeciesA)
+		require.Equal(t, "lactuca sativa", *rule.SpeciesB)
+
+		rule, err = updateCompanionRule(map[string]interface{}{"speciesA": "daucus carota", "speciesB": "lactuca sativa",
+			"relationship": antagonistRelationship})
+		require.NoError(t, err)
+		require.Equal(t, antagonistRelationship, *rule.Relationship)
+
+		a, b := "Lactuca sativa", "Daucus carota"
+		_, err = removeCompanionRule(&a, &b)
+		require.NoError(t, err)
+		_, err = removeCompanionRule(&a, &b)
+		require.Error(t, err)
+
+		_, err = updateSpacingRule(map[string]interface{}{"species": "Lactuca sativa", "spacing": 0})
+		require.Error(t, err)
+		spacing, err := updateSpacingRule(map[string]interface{}{"species": "Lactuca sativa", "spacing": 10})
+		require.NoError(t, err)
+		require.Equal(t, "lactuca sativa", *spacing.Species)
+		_, err = removeSpacingRule(&a)
+		require.NoError(t, err)
+		_, err = removeSpacingRule(&a)
+		require.Error(t, err)
+	})
+
+	t.Run("Test the websocket", func(t *testing.T) {
+		route := seedsKey
+		requestType := validateLayoutRequestKey
+		request := &configs.WsMessage{Route: &route, Type: &requestType, Data: map[string]interface{}{
+			"width": 4, "length": 4, "plants": []map[string]interface{}{{"seed": pepper, "x": 2, "y": 2}},
+		}}
+		response := &configs.WsMessage{}
+		handleWebsocketRequest(request, response)
+		require.Nil(t, response.Error)
+		report, ok := response.Data.(*LayoutReport)
+		require.True(t, ok)
+		require.True(t, report.Valid)
+
+		// maintaining the rules is restricted to admins
+		requestType = updateSpacingRuleRequestKey
+		request.Data = map[string]interface{}{"species": "Lactuca sativa", "spacing": 10}
+		response = &configs.WsMessage{}
+		handleWebsocketRequest(request, response)
+		require.NotNil(t, response.Error)
+
+		isAdmin := true
+		request.IsAdmin = &isAdmin
+		response = &configs.WsMessage{}
+		handleWebsocketRequest(request, response)
+		require.Nil(t, response.Error)
+
+		requestType = removeSpacingRuleRequestKey
+		species := "lactuca sativa"
+		request.Component = &species
+		response = &configs.WsMessage{}
+		handleWebsocketRequest(request, response)
+		require.Nil(t, response.Error)
+	})
+}
@@ -0,0 +1,260 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+	"stl-go/grow-with-stl-go/pkg/webservice"
+)
+
+const (
+	maxImageSize      = 5 << 20
+	maxImageDimension = 8000
+	thumbnailSize     = 256
+	imageFormField    = "image"
+)
+
+// imageExtensions are the accepted content types and the extension they are stored with
+var imageExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// UploadedImage is where an uploaded image and its thumbnail can be fetched from
+type UploadedImage struct {
+	Image     *string        `json:"image,omitempty"`
+	Thumbnail *string        `json:"thumbnail,omitempty"`
+	Item      *InventoryItem `json:"item,omitempty"`
+}
+
+// uploadImageREST accepts a multipart image upload in the image field.  If the category and id form fields are set
+// the seed is updated to use the new image
+func uploadImageREST(w http.ResponseWriter, r *http.Request) {
+	if !webservice.IsRESTAdmin(r) {
+		http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+		return
+	}
+
+	// leave some room for the multipart boundaries and the other form fields
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageSize+(1<<16))
+	if err := r.ParseMultipartForm(maxImageSize); err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile(imageFormField)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImageSize+1))
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+
+	uploaded, err := storeImage(data)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+
+	if category, id := r.FormValue("category"), r.FormValue("id"); id != "" {
+		uploaded.Item, err = setItemImage(&category, &id, uploaded.Image)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+			return
+		}
+	}
+	writeRESTResponse(uploaded, w, http.StatusCreated)
+}
+
+// storeImage validates the image, writes it and its thumbnail under the data dir named by the sha256 of the content
+// and returns the uris they are served from.  Uploading the same image twice reuses the stored files
+func storeImage(data []byte) (*UploadedImage, error) {
+	if len(data) == 0 {
+		return nil, errors.New("the image is empty")
+	}
+	if len(data) > maxImageSize {
+		return nil, fmt.Errorf("the image is larger than the %d byte limit", maxImageSize)
+	}
+
+	contentType := http.DetectContentType(data)
+	extension, ok := imageExtensions[contentType]
+	if !ok {
+		return nil, fmt.Errorf("images of type %s are not supported", contentType)
+	}
+
+	// check the dimensions before decoding so a small file can't expand into an enormous image
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the image.  Error: %s", err)
+	}
+	if config.Width > maxImageDimension || config.Height > maxImageDimension {
+		return nil, fmt.Errorf("the image is larger than %dx%d", maxImageDimension, maxImageDimension)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the image.  Error: %s", err)
+	}
+
+	dir, err := configs.GetImagesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	imageName := fmt.Sprintf("%s.%s", hash, extension)
+	thumbnailName := fmt.Sprintf("%s-thumb.%s", hash, extension)
+
+	if err := writeImageFile(filepath.Join(*dir, imageName), data); err != nil {
+		return nil, err
+	}
+
+	var thumbnail bytes.Buffer
+	if err := encodeImage(&thumbnail, contentType, resizeImage(img, thumbnailSize)); err != nil {
+		return nil, err
+	}
+	if err := writeImageFile(filepath.Join(*dir, thumbnailName), thumbnail.Bytes()); err != nil {
+		return nil, err
+	}
+
+	imageURI := configs.ImagesURIPrefix + imageName
+	thumbnailURI := configs.ImagesURIPrefix + thumbnailName
+	log.Debugf("image stored as %s", imageURI)
+	return &UploadedImage{Image: &imageURI, Thumbnail: &thumbnailURI}, nil
+}
+
+// writeImageFile writes the file via a temp file and rename so a partial image is never served
+func writeImageFile(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func encodeImage(w io.Writer, contentType string, img image.Image) error {
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	case "image/gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return png.Encode(w, img)
+	}
+}
+
+// resizeImage scales the image to fit in a size x size box keeping its aspect ratio.  Each destination pixel is the
+// average of the source pixels it covers, images that already fit are returned as they are
+func resizeImage(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return src
+	}
+
+	newWidth, newHeight := size, height*size/width
+	if height > width {
+		newWidth, newHeight = width*size/height, size
+	}
+	newWidth, newHeight = max(newWidth, 1), max(newHeight, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		y0 := bounds.Min.Y + y*height/newHeight
+		y1 := max(bounds.Min.Y+(y+1)*height/newHeight, y0+1)
+		for x := 0; x < newWidth; x++ {
+			x0 := bounds.Min.X + x*width/newWidth
+			x1 := max(bounds.Min.X+(x+1)*width/newWidth, x0+1)
+
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					count++
+				}
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / count >> 8)
+			dst.Pix[offset+1] = uint8(g / count >> 8)
+			dst.Pix[offset+2] = uint8(b / count >> 8)
+			dst.Pix[offset+3] = uint8(a / count >> 8)
+		}
+	}
+	return dst
+}
+
+// setItemImage points an existing seed at an uploaded image
+func setItemImage(categoryName, id, imageURI *string) (*InventoryItem, error) {
+	item, err := findItem(categoryName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the image is written so a purchase or restock made since the item was read isn't overwritten
+	if err := setItemImageInDB(item.ID, imageURI); err != nil {
+		return nil, err
+	}
+
+	invalidateInventoryCache()
+	item, err = findItem(item.Category, item.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	go notifyAll(updateInventoryRequestKey, item.Category, item.ID, nil, item)
+	return item, nil
+}
@@ -0,0 +1,114 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestImageFunctions(t *testing.T) {
+	initInventoryTest()
+
+	t.Run("Test storing an image and its thumbnail", func(t *testing.T) {
+		data := testPNG(t, 600, 300)
+		uploaded, err := storeImage(data)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(*uploaded.Image, configs.ImagesURIPrefix))
+		require.True(t, strings.HasSuffix(*uploaded.Thumbnail, "-thumb.png"))
+
+		dir, err := configs.GetImagesDir()
+		require.NoError(t, err)
+		thumbnail, err := os.Open(filepath.Join(*dir, strings.TrimPrefix(*uploaded.Thumbnail, configs.ImagesURIPrefix)))
+		require.NoError(t, err)
+		defer thumbnail.Close()
+		config, format, err := image.DecodeConfig(thumbnail)
+		require.NoError(t, err)
+		require.Equal(t, "png", format)
+		require.Equal(t, thumbnailSize, config.Width)
+		require.Equal(t, thumbnailSize/2, config.Height)
+
+		// the same content is stored under the same name
+		again, err := storeImage(data)
+		require.NoError(t, err)
+		require.Equal(t, *uploaded.Image, *again.Image)
+	})
+
+	t.Run("Test invalid images are refused", func(t *testing.T) {
+		_, err := storeImage([]byte("this is not an image"))
+		require.Error(t, err)
+
+		_, err = storeImage(nil)
+		require.Error(t, err)
+
+		// a png signature followed by garbage passes the content sniffing but not the decode
+		_, err = storeImage(append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), make([]byte, 64)...))
+		require.Error(t, err)
+
+		_, err = storeImage(make([]byte, maxImageSize+1))
+		require.Error(t, err)
+	})
+
+	t.Run("Test setting an image keeps the stock", func(t *testing.T) {
+		uploaded, err := storeImage(testPNG(t, 64, 64))
+		require.NoError(t, err)
+
+		inventory, err := getInventory()
+		require.NoError(t, err)
+		var current *InventoryItem
+		for _, category := range inventory {
+			for _, item := range category.Items {
+				current = item
+				break
+			}
+			if current != nil {
+				break
+			}
+		}
+		require.NotNil(t, current)
+
+		// the restock isn't in the cached inventory the image is set from yet
+		require.NoError(t, restockItemInDB(current.ID, 3))
+		item, err := setItemImage(current.Category, current.ID, uploaded.Image)
+		require.NoError(t, err)
+		require.Equal(t, *uploaded.Image, *item.Image)
+		require.Equal(t, *current.Packets+3, *item.Packets)
+
+		_, err = setItemImage(current.Category, current.ID, current.Image)
+		require.NoError(t, err)
+	})
+}
@@ -15,6 +15,8 @@
 package seeds
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"sync"
@@ -26,23 +28,50 @@ import (
 )
 
 var (
+	// tables are created in this order so the foreign keys are satisfied when the defaults are inserted
+	inventoryTableOrder = []string{"categories", "seeds"}
+
 	inventoryTables = map[string]*configs.Table{
+		"categories": {
+			CreateSQL: `CREATE TABLE IF NOT EXISTS categories (
+				name varchar(128) NOT NULL PRIMARY KEY,
+				description varchar(2048),
+				displayOrder int NOT NULL default 0,
+				image varchar(1024))`,
+			InsertSQL: "INSERT INTO categories values(?,?,?,?)",
+			UpdateSQL: "UPDATE categories set name = ?, description = ?, displayOrder = ?, image = ? where name = ?",
+			DeleteSQL: "DELETE FROM categories where name = ?",
+			Defaults: map[string]string{
+				"herb": `insert or ignore into categories values ('Herb', 'Culinary herbs for the kitchen garden and containers',
+				1, '/common/images/herbs/genovese_basil.jpg')`,
+				"onion": `insert or ignore into categories values ('Onion', 'Long, intermediate and short day onions for slicing and storage',
+				2, '/common/images/onions/walla_walla.jpg')`,
+				"pepper": `insert or ignore into categories values ('Pepper', 'Sweet and hot peppers',
+				3, '/common/images/peppers/poblano.jpg')`,
+				"tomato": `insert or ignore into categories values ('Tomato', 'Determinate and indeterminate tomatoes',
+				4, '/common/images/tomatoes/indeterminate/san_marzano.jpg')`,
+			},
+		},
 		"seeds": {
 			CreateSQL: `CREATE TABLE IF NOT EXISTS seeds (
 				id varchar(64) NOT NULL,
-				category TEXT CHECK(category IN ('Herb', 'Onion', 'Pepper', 'Tomato')) NOT NULL,
+				category varchar(128) NOT NULL REFERENCES categories(name) ON UPDATE CASCADE,
 				genus varchar(512) NOT NULL,
 				species varchar(512) NOT NULL,
 				cultivar varchar(512),
 				commonName varcar(1024) NOT NULL,
 				description varcar(2048) NOT NULL,
 				hybrid tinyint(1) NOT NULL default 0,
-				price real NOT NULL,
+				price bigint NOT NULL,
 				perpacketcount int NOT NULL,
 				packets int NOT NULL,
-				image varcar(1024))`,
-			InsertSQL: "INSERT INTO seeds values(?,?,?,?,?,?,?,?,?,?,?,?)",
-			UpdateSQL: "UPDATE seeds set packets = ? where id = ?",
+				image varcar(1024),
+				reorderThreshold int NOT NULL default 0,
+				currency varchar(3) NOT NULL default 'USD')`,
+			InsertSQL: "INSERT INTO seeds values(?,?,?,?,?,?,?,?,?,?,?,?,?,?)",
+			UpdateSQL: `UPDATE seeds set category = ?, genus = ?, species = ?, cultivar = ?, commonName = ?, description = ?, hybrid = ?,
+				price = ?, perpacketcount = ?, image = ?, reorderThreshold = ?, currency = ? where id = ?`,
+			DeleteSQL: "DELETE FROM seeds where id = ?",
 			Indices: []string{
 				"CREATE INDEX IF NOT EXISTS seedid on seeds(id)",
 				"CREATE INDEX IF NOT EXISTS seedcategory on seeds(category)",
@@ -51,89 +80,128 @@ var (
 			Defaults: map[string]string{
 				"dill": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Herb', 'Anethum', 'graveolens', 'Ella', 'Dill Weed',
 				'Ella is a dwarf dill bred for container and hydroponic growing',
-				0, 2.67, 20, 100, '/common/images/herbs/ella_dill.jpg')`, uuid.New().String()),
+				0, 267, 20, 100, '/common/images/herbs/ella_dill.jpg', 10, 'USD')`, uuid.New().String()),
 				"basil": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Herb', 'Ocimum', 'basilicum', 'Genovese', 'Basil',
 				'Genovese basil was first bred in the Northwest coastal port of Genoa, gateway to the Italian Riviera.',
-				0, 4.28, 100, 100, '/common/images/herbs/genovese_basil.jpg')`, uuid.New().String()),
+				0, 428, 100, 100, '/common/images/herbs/genovese_basil.jpg', 10, 'USD')`, uuid.New().String()),
 				"oreagno": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Herb', 'Origanum', 'vulgare', 'Greek', 'Oregano',
 				'Strong oregano aroma and flavor; great for pizza and Italian cooking. Characteristic dark green leaves with white flowers.',
-				0, 3.95, 50, 100, '/common/images/herbs/greek_oregano.jpg')`, uuid.New().String()),
+				0, 395, 50, 100, '/common/images/herbs/greek_oregano.jpg', 10, 'USD')`, uuid.New().String()),
 				"chive": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Herb', 'Allium', 'schoenoprasum', 'Polyvert', 'Chive',
 				'Suitable for growing in field or containers. Dark green leaves with very good uniformity. USDA Certified Organic.',
-				0, 2.18, 100, 100, '/common/images/herbs/polyvert_chive.jpg')`, uuid.New().String()),
+				0, 218, 100, 100, '/common/images/herbs/polyvert_chive.jpg', 10, 'USD')`, uuid.New().String()),
 
 				"ailsa craig": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Onion', 'Allium', 'cepa', 'Ailsa Craig', 'Yellow',
 				'Long day. Very well-known globe-shaped heirloom onion that reaches a really huge size—5 lbs is rather common',
-				0, 2.67, 20, 100, '/common/images/onions/ailsa_craig.jpg')`, uuid.New().String()),
+				0, 267, 20, 100, '/common/images/onions/ailsa_craig.jpg', 10, 'USD')`, uuid.New().String()),
 				"patterson": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Onion', 'Allium', 'cepa', 'Patterson', 'Yellow',
 				'Patterson’ is a keeper—the longest-storing onion you can find. Straw-colored, globe-shaped bulbs with sweet, mildly pungent yellow flesh',
-				0, 4.28, 100, 100, '/common/images/onions/patterson.jpg')`, uuid.New().String()),
+				0, 428, 100, 100, '/common/images/onions/patterson.jpg', 10, 'USD')`, uuid.New().String()),
 				"red wing": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Onion', 'Allium', 'cepa', 'Red Wing', 'Red',
 				'Uniform, large onions with deep red color. Thick skin, very hard bulbs for long storage. Consistent internal color.',
-				1, 3.95, 50, 100, '/common/images/onions/red_wing.jpg')`, uuid.New().String()),
+				1, 395, 50, 100, '/common/images/onions/red_wing.jpg', 10, 'USD')`, uuid.New().String()),
 				"walla walla": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Onion', 'Allium', 'cepa', 'Walla Walla', 'Sweet',
 				'Juicy, sweet, regional favorite. In the Northwest,  very large, flattened, ultra-mild onions',
-				0, 2.18, 100, 100, '/common/images/onions/walla_walla.jpg')`, uuid.New().String()),
+				0, 218, 100, 100, '/common/images/onions/walla_walla.jpg', 10, 'USD')`, uuid.New().String()),
 
 				"bell pepper": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Pepper', 'Capsicum', 'annuum', 'Ozark Giant', 'Bell',
 				'Green bell peppers are bell peppers that have been harvested early. Red bell peppers have been allowed to ripen longer.',
-				0, 4.58, 50, 100, '/common/images/peppers/green_bell.jpg')`, uuid.New().String()),
+				0, 458, 50, 100, '/common/images/peppers/green_bell.jpg', 10, 'USD')`, uuid.New().String()),
 				"poblano": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Pepper', 'Capsicum', 'annuum', null, 'Poblano',
 				'The poblano is a mild chili pepper originating in the state of Puebla, Mexico. Dried, it is called ancho or chile ancho',
-				0, 2.96, 25, 100, '/common/images/peppers/poblano.jpg')`, uuid.New().String()),
+				0, 296, 25, 100, '/common/images/peppers/poblano.jpg', 10, 'USD')`, uuid.New().String()),
 				"jalapeno": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Pepper', 'Capsicum', 'annuum', 'Zapotec', 'Jalapeno',
 				'This jalapeno variety from Oaxaca, Mexico which is a more flavorful, gourmet jalapeño.',
-				0, 3.78, 12, 100, '/common/images/peppers/jalapeno.jpg')`, uuid.New().String()),
+				0, 378, 12, 100, '/common/images/peppers/jalapeno.jpg', 10, 'USD')`, uuid.New().String()),
 				"serrano": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Pepper', 'Capsicum', 'annuum', 'Tampiqueno', 'Serrano',
 				'This serrano variety comes from the mountains of the Hidalgo and Puebla states of Mexico.  This pepper is 2-3 times hotter than jalapenos',
-				0, 6.45, 15, 100, '/common/images/peppers/serrano.jpg')`, uuid.New().String()),
+				0, 645, 15, 100, '/common/images/peppers/serrano.jpg', 10, 'USD')`, uuid.New().String()),
 
 				"galahad": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Tomato', 'Solanum', 'lycopersicum', 'Galahad', 'Tomato',
 				'Delicious early determinate beefsteak.',
-				1, 4.58, 50, 100, '/common/images/tomatoes/determinate/galahad.jpg')`, uuid.New().String()),
+				1, 458, 50, 100, '/common/images/tomatoes/determinate/galahad.jpg', 10, 'USD')`, uuid.New().String()),
 				"plum regal": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Tomato', 'Solanum', 'lycopersicum', 'Plum Regal', 'Tomato',
 				'Medium-size plants with good leaf cover produce high yields of blocky, 4 oz. plum tomatoes. Fruits have a deep red color with good flavor. Determinate.',
-				1, 2.96, 25, 100, '/common/images/tomatoes/determinate/plum_regal.jpg')`, uuid.New().String()),
+				1, 296, 25, 100, '/common/images/tomatoes/determinate/plum_regal.jpg', 10, 'USD')`, uuid.New().String()),
 				"carbon": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Tomato', 'Solanum', 'lycopersicum', 'Carbon', 'Tomato',
 				'Indeterminate heirloom. Resists cracking better than other large, black heirlooms. Blocky-round, 10-14 oz. fruit with dark olive shoulders.',
-				0, 3.78, 12, 100, '/common/images/tomatoes/indeterminate/carbon.jpg')`, uuid.New().String()),
+				0, 378, 12, 100, '/common/images/tomatoes/indeterminate/carbon.jpg', 10, 'USD')`, uuid.New().String()),
 				"san marzano": fmt.Sprintf(`insert or ignore into seeds values ('%s', 'Tomato', 'Solanum', 'lycopersicum', 'San Marzano', 'Tomato',
 				'San Marzano is considered one of the best paste tomatoes of all time, with Old World look and taste.  Indeterminate',
-				0, 6.45, 15, 100, '/common/images/tomatoes/indeterminate/san_marzano.jpg')`, uuid.New().String()),
+				0, 645, 15, 100, '/common/images/tomatoes/indeterminate/san_marzano.jpg', 10, 'USD')`, uuid.New().String()),
 			},
 		},
 	}
 
-	inventoryCache = make(map[string]*InventoryCategory)
-	inventoryMutex sync.Mutex
+	// inventoryCache is a copy-on-write snapshot of the inventory.  A published snapshot is never modified so it can be
+	// handed to readers and JSON encoders without holding a lock, writes go to the database and replace the snapshot
+	inventoryCache map[string]*InventoryCategory
+	inventoryMutex sync.RWMutex
+	// inventoryLoadMutex makes sure only one snapshot is built at a time and that an invalidation waits for a load in
+	// progress, so a snapshot read before a write is committed cannot be published after it
+	inventoryLoadMutex sync.Mutex
 )
 
 // InventoryCategory is a way to hold inventory categories together
 type InventoryCategory struct {
-	Category *string                   `json:"category,omitempty"`
-	Items    map[string]*InventoryItem `json:"items,omitempty"`
-	Mutex    sync.Mutex                `json:"-"`
+	Category     *string                   `json:"category,omitempty"`
+	Description  *string                   `json:"description,omitempty"`
+	DisplayOrder *int                      `json:"displayOrder,omitempty"`
+	Image        *string                   `json:"image,omitempty"`
+	Items        map[string]*InventoryItem `json:"items,omitempty"`
 }
 
-// InventoryItem data we stored in the database
+// InventoryItem data we stored in the database, a low stock alert is raised when the packets fall below the
+// ReorderThreshold and a threshold of 0 only alerts when the seed sells out.  Price is the list price in the minor units
+// of the currency, EffectivePrice and Promotion are calculated from the running promotions when the item is returned
+// and are never stored
 type InventoryItem struct {
-	ID             *string  `json:"id,omitempty"`
-	Category       *string  `json:"category,omitempty"`
-	Genus          *string  `json:"genus,omitempty"`
-	Species        *string  `json:"species,omitempty"`
-	Cultivar       *string  `json:"cultivar,omitempty"`
-	CommonName     *string  `json:"commonName,omitempty"`
-	Description    *string  `json:"description,omitempty"`
-	Hybrid         *bool    `json:"hybrid,omitempty"`
-	Price          *float32 `json:"price,omitempty"`
-	PerPacketCount *int32   `json:"perPacketCount,omitempty"`
-	Packets        *int     `json:"packets,omitempty"`
-	Image          *string  `json:"image,omitempty"`
+	ID               *string    `json:"id,omitempty"`
+	Category         *string    `json:"category,omitempty"`
+	Genus            *string    `json:"genus,omitempty"`
+	Species          *string    `json:"species,omitempty"`
+	Cultivar         *string    `json:"cultivar,omitempty"`
+	CommonName       *string    `json:"commonName,omitempty"`
+	Description      *string    `json:"description,omitempty"`
+	Hybrid           *bool      `json:"hybrid,omitempty"`
+	Price            *Money     `json:"price,omitempty"`
+	PerPacketCount   *int32     `json:"perPacketCount,omitempty"`
+	Packets          *int       `json:"packets,omitempty"`
+	Image            *string    `json:"image,omitempty"`
+	ReorderThreshold *int       `json:"reorderThreshold,omitempty"`
+	Currency         *string    `json:"currency,omitempty"`
+	EffectivePrice   *Money     `json:"effectivePrice,omitempty"`
+	Promotion        *Promotion `json:"promotion,omitempty"`
 }
 
 func setupTables() error {
-	for tableName, table := range inventoryTables {
-		if table != nil {
+	if err := createTables(inventoryTableOrder, inventoryTables); err != nil {
+		return err
+	}
+	if err := createTables(orderTableOrder, orderTables); err != nil {
+		return err
+	}
+	if err := cartTable.CreateTable(&cartTableName); err != nil {
+		return err
+	}
+	if err := stockAlertTable.CreateTable(&stockAlertTableName); err != nil {
+		return err
+	}
+	if err := setupPricing(); err != nil {
+		return err
+	}
+	if err := setupGrowGuides(); err != nil {
+		return err
+	}
+	if err := setupPlantingRules(); err != nil {
+		return err
+	}
+	return setupSearch()
+}
+
+func createTables(order []string, tables map[string]*configs.Table) error {
+	for _, tableName := range order {
+		if table, ok := tables[tableName]; ok && table != nil {
 			if err := table.CreateTable(&tableName); err != nil {
 				return err
 			}
@@ -142,97 +210,233 @@ func setupTables() error {
 	return nil
 }
 
-// getInventory will query the database for the inventory data
+// getInventory returns the current inventory snapshot, loading it from the database if it has been invalidated
 func getInventory() (map[string]*InventoryCategory, error) {
+	if inventory := currentInventory(); inventory != nil {
+		return inventory, nil
+	}
+
+	inventoryLoadMutex.Lock()
+	defer inventoryLoadMutex.Unlock()
+
+	// another request may have loaded the inventory while this one was waiting
+	if inventory := currentInventory(); inventory != nil {
+		return inventory, nil
+	}
+
+	inventory, err := loadInventory()
+	if err != nil {
+		return nil, err
+	}
+
+	inventoryMutex.Lock()
+	inventoryCache = inventory
+	inventoryMutex.Unlock()
+	return inventory, nil
+}
+
+func currentInventory() map[string]*InventoryCategory {
+	inventoryMutex.RLock()
+	defer inventoryMutex.RUnlock()
+	return inventoryCache
+}
+
+// loadInventory builds a new snapshot from the categories and seeds tables read in the same transaction
+func loadInventory() (map[string]*InventoryCategory, error) {
 	db, dbErr := configs.GetSQLiteConnection()
 	if dbErr != nil {
 		return nil, fmt.Errorf("unable to retrieve inventory, sqlite error: %s", dbErr)
 	}
 
-	if db != nil {
-		if len(inventoryCache) == 0 {
-			rows, err := db.Query("select * from seeds")
-			if err != nil {
-				return nil, err
-			}
-			defer rows.Close()
-			for rows.Next() {
-				ii := InventoryItem{}
-				if err := rows.Scan(&ii.ID, &ii.Category, &ii.Genus, &ii.Species, &ii.Cultivar, &ii.CommonName, &ii.Description, &ii.Hybrid,
-					&ii.Price, &ii.PerPacketCount, &ii.Packets, &ii.Image); err != nil {
-					return nil, err
-				}
-				if ii.ID != nil && ii.Category != nil {
-					inventoryMutex.Lock()
-					category, ok := inventoryCache[*ii.Category]
-					inventoryMutex.Unlock()
-					if ok && category.Items != nil {
-						category.Mutex.Lock()
-						category.Items[*ii.ID] = &ii
-						category.Mutex.Unlock()
-					} else {
-						inventoryMutex.Lock()
-						inventoryCache[*ii.Category] = &InventoryCategory{
-							Category: ii.Category,
-							Items: map[string]*InventoryItem{
-								*ii.ID: &ii,
-							},
-						}
-						inventoryMutex.Unlock()
-					}
+	if db == nil {
+		return nil, errors.New("the sqlite database is nil, cannot get inventory")
+	}
+
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer configs.RollbackHelper(tx)
+
+	inventory, err := loadCategories(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("select * from seeds")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		ii := InventoryItem{}
+		if err := rows.Scan(&ii.ID, &ii.Category, &ii.Genus, &ii.Species, &ii.Cultivar, &ii.CommonName, &ii.Description, &ii.Hybrid,
+			&ii.Price, &ii.PerPacketCount, &ii.Packets, &ii.Image, &ii.ReorderThreshold, &ii.Currency); err != nil {
+			return nil, err
+		}
+		if ii.ID != nil && ii.Category != nil {
+			category, ok := inventory[*ii.Category]
+			if !ok {
+				category = &InventoryCategory{
+					Category: ii.Category,
+					Items:    make(map[string]*InventoryItem),
 				}
+				inventory[*ii.Category] = category
 			}
+			category.Items[*ii.ID] = &ii
 		}
-		return inventoryCache, nil
 	}
-	return nil, errors.New("the sqlite database is nil, cannot get last logins")
+	return inventory, rows.Err()
 }
 
+// getDetail returns a copy of the item so the caller cannot modify the published snapshot
 func getDetail(categoryName, id *string) (*InventoryItem, error) {
 	if categoryName != nil && id != nil {
-		inventoryMutex.Lock()
-		category, ok := inventoryCache[*categoryName]
-		inventoryMutex.Unlock()
-		if ok && category != nil {
-			category.Mutex.Lock()
+		inventory, err := getInventory()
+		if err != nil {
+			return nil, err
+		}
+		if category, ok := inventory[*categoryName]; ok && category != nil {
 			if item, ok := category.Items[*id]; ok {
-				return item, nil
+				detail := *item
+				return &detail, nil
 			}
-			category.Mutex.Unlock()
 		}
 	}
 	return nil, errors.New("item not found")
 }
 
 func updateItemInDB(item *InventoryItem) error {
-	db, dbErr := configs.GetSQLiteConnection()
-	if dbErr != nil {
-		return fmt.Errorf("unable to update the item, sqlite error: %s", dbErr)
+	if table, ok := inventoryTables["seeds"]; ok && table != nil && item != nil {
+		rows, err := table.Exec(table.UpdateSQL,
+			item.Category,
+			item.Genus,
+			item.Species,
+			item.Cultivar,
+			item.CommonName,
+			item.Description,
+			item.Hybrid,
+			item.Price,
+			item.PerPacketCount,
+			item.Image,
+			item.ReorderThreshold,
+			item.Currency,
+			item.ID)
+		if err != nil {
+			return err
+		}
+
+		log.Tracef("%d rows updated in seeds", rows)
+		return nil
 	}
+	return errors.New("requirements not met to update item in db")
+}
 
-	if table, ok := inventoryTables["seeds"]; db != nil && ok && table != nil && item != nil {
-		stmt, err := db.Prepare(table.UpdateSQL)
+func insertItemInDB(item *InventoryItem) error {
+	if table, ok := inventoryTables["seeds"]; ok && table != nil && item != nil {
+		rows, err := table.Exec(table.InsertSQL,
+			item.ID,
+			item.Category,
+			item.Genus,
+			item.Species,
+			item.Cultivar,
+			item.CommonName,
+			item.Description,
+			item.Hybrid,
+			item.Price,
+			item.PerPacketCount,
+			item.Packets,
+			item.Image,
+			item.ReorderThreshold,
+			item.Currency)
 		if err != nil {
 			return err
 		}
 
-		table.WriteMutex.Lock()
-		defer table.WriteMutex.Unlock()
-		result, err := stmt.Exec(
-			item.Packets,
-			item.ID)
+		log.Tracef("%d rows inserted into seeds", rows)
+		return nil
+	}
+	return errors.New("requirements not met to insert item in db")
+}
 
+func restockItemInDB(id *string, quantity int) error {
+	if table, ok := inventoryTables["seeds"]; ok && table != nil && id != nil {
+		rows, err := table.Exec("UPDATE seeds set packets = packets + ? where id = ?", quantity, id)
 		if err != nil {
 			return err
 		}
 
-		rows, err := result.RowsAffected()
+		if rows == 0 {
+			return fmt.Errorf("seed %s not found", *id)
+		}
+
+		log.Tracef("%d rows restocked in seeds", rows)
+		return nil
+	}
+	return errors.New("requirements not met to restock item in db")
+}
+
+func setItemImageInDB(id, imageURI *string) error {
+	if table, ok := inventoryTables["seeds"]; ok && table != nil && id != nil && imageURI != nil {
+		rows, err := table.Exec("UPDATE seeds set image = ? where id = ?", imageURI, id)
 		if err != nil {
 			return err
 		}
 
-		log.Tracef("%d rows updated in seeds", rows)
+		if rows == 0 {
+			return fmt.Errorf("seed %s not found", *id)
+		}
+
+		log.Tracef("%d rows updated with a new image in seeds", rows)
 		return nil
 	}
-	return errors.New("requirements not met to update item in db")
+	return errors.New("requirements not met to set item image in db")
+}
+
+// deleteItemFromDB removes the seed along with the cart lines holding it, a line left behind would stop the cart from
+// checking out
+func deleteItemFromDB(id *string) error {
+	if table, ok := inventoryTables["seeds"]; ok && table != nil && id != nil {
+		db, dbErr := configs.GetSQLiteConnection()
+		if dbErr != nil {
+			return dbErr
+		}
+
+		tx, err := db.BeginTx(context.Background(), nil)
+		if err != nil {
+			return err
+		}
+		defer configs.RollbackHelper(tx)
+
+		rows, err := table.ExecTx(tx, table.DeleteSQL, id)
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return fmt.Errorf("seed %s not found", *id)
+		}
+
+		lines, err := cartTable.ExecTx(tx, "DELETE FROM carts where seedID = ?", id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		log.Tracef("%d rows deleted from seeds and %d from carts", rows, lines)
+		return nil
+	}
+	return errors.New("requirements not met to delete item from db")
+}
+
+// invalidateInventoryCache drops the inventory snapshot so the next getInventory call reloads it from the database
+func invalidateInventoryCache() {
+	inventoryLoadMutex.Lock()
+	defer inventoryLoadMutex.Unlock()
+	inventoryMutex.Lock()
+	inventoryCache = nil
+	inventoryMutex.Unlock()
 }
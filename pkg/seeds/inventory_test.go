@@ -15,8 +15,12 @@
 package seeds
 
 import (
+	"encoding/json"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 
 	"stl-go/grow-with-stl-go/pkg/configs"
@@ -29,6 +33,9 @@ func initInventoryTest() {
 	if err := configs.SetGrowSTLGoConfig(); err != nil {
 		log.Fatalf("config %s", err)
 	}
+	if err := Init(); err != nil {
+		log.Fatalf("Error starting the seeds db: %s", err)
+	}
 }
 
 func TestInventoryFunctions(t *testing.T) {
@@ -40,3 +47,78 @@ func TestInventoryFunctions(t *testing.T) {
 		log.Info(data)
 	})
 }
+
+// TestInventoryConcurrency is meant to be run with go test -race, it hammers the inventory snapshot with purchases
+// and reads and then makes sure no packets were lost or oversold
+func TestInventoryConcurrency(t *testing.T) {
+	initInventoryTest()
+	const workers, packets = 8, 20
+	item, err := addInventory(nil, map[string]interface{}{
+		"category":       "Tomato",
+		"genus":          "Solanum",
+		"species":        "lycopersicum",
+		"cultivar":       "Race Test",
+		"commonName":     "Tomato",
+		"description":    "Concurrency test tomato",
+		"price":          1.25,
+		"perPacketCount": 10,
+		"packets":        packets,
+	})
+	require.NoError(t, err)
+	defer func() {
+		_, err := removeInventory(nil, item.Category, item.ID)
+		require.NoError(t, err)
+	}()
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	purchased, attempts := 0, workers*(packets/workers+1)
+	for i := 0; i < workers; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			user := uuid.New().String()
+			for j := 0; j < packets/workers+1; j++ {
+				_, err := purchase(nil, &user, map[string]interface{}{"category": *item.Category, "id": *item.ID, "quantity": 1})
+				if err != nil {
+					// running out of stock is the only acceptable reason for a purchase to fail
+					if !strings.Contains(err.Error(), "not enough packets") {
+						t.Error(err)
+					}
+					continue
+				}
+				mutex.Lock()
+				purchased++
+				mutex.Unlock()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				detail, err := getDetail(item.Category, item.ID)
+				if err == nil {
+					// the detail is a copy so writing to it must not race with the other readers
+					remainder := *detail.Packets - 1
+					detail.Packets = &remainder
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if inventory, err := getInventory(); err == nil {
+					if _, err := json.Marshal(inventory); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	detail, err := getDetail(item.Category, item.ID)
+	require.NoError(t, err)
+	require.Equal(t, min(packets, attempts), purchased)
+	require.Equal(t, packets-purchased, *detail.Packets)
+	require.GreaterOrEqual(t, *detail.Packets, 0)
+}
@@ -0,0 +1,253 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"stl-go/grow-with-stl-go/pkg/utils"
+)
+
+type restockRequest struct {
+	Quantity *interface{} `json:"quantity,omitempty"`
+}
+
+// validate makes sure an inventory item can be written to the seeds table
+func (item *InventoryItem) validate() error {
+	if item == nil {
+		return errors.New("inventory item is nil")
+	}
+
+	var missing []string
+	for field, value := range map[string]*string{
+		"category":    item.Category,
+		"genus":       item.Genus,
+		"species":     item.Species,
+		"commonName":  item.CommonName,
+		"description": item.Description,
+	} {
+		if value == nil || strings.TrimSpace(*value) == "" {
+			missing = append(missing, field)
+		}
+	}
+	if item.Price == nil {
+		missing = append(missing, "price")
+	}
+	if item.PerPacketCount == nil {
+		missing = append(missing, "perPacketCount")
+	}
+	if item.Packets == nil {
+		missing = append(missing, "packets")
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("inventory item is missing required fields: %s", strings.Join(missing, ", "))
+	}
+
+	if !categoryExists(item.Category) {
+		return fmt.Errorf("category %s does not exist", *item.Category)
+	}
+	if *item.Price <= 0 {
+		return errors.New("price must be greater than 0")
+	}
+	if *item.PerPacketCount <= 0 {
+		return errors.New("perPacketCount must be greater than 0")
+	}
+	if *item.Packets < 0 {
+		return errors.New("packets cannot be negative")
+	}
+
+	if item.ReorderThreshold != nil && *item.ReorderThreshold < 0 {
+		return errors.New("reorderThreshold cannot be negative")
+	}
+
+	currency, err := normalizeCurrency(item.Currency)
+	if err != nil {
+		return err
+	}
+	item.Currency = currency
+
+	if item.Hybrid == nil {
+		item.Hybrid = utils.BoolPointer(false)
+	}
+	if item.ReorderThreshold == nil {
+		reorderThreshold := 0
+		item.ReorderThreshold = &reorderThreshold
+	}
+	return nil
+}
+
+// itemFromData converts the generic request payload into an InventoryItem
+func itemFromData(data interface{}) (*InventoryItem, error) {
+	if data == nil {
+		return nil, errors.New("no inventory item supplied")
+	}
+
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var item InventoryItem
+	if err := json.Unmarshal(bytes, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// findItem will locate an item in the inventory cache, the category is optional and will narrow the search if supplied.
+// A copy of the item is returned so the caller cannot modify the published snapshot
+func findItem(categoryName, id *string) (*InventoryItem, error) {
+	if id == nil {
+		return nil, errors.New("no id supplied, cannot find item")
+	}
+
+	inventory, err := getInventory()
+	if err != nil {
+		return nil, err
+	}
+
+	for name, category := range inventory {
+		if category == nil || (categoryName != nil && *categoryName != "" && !strings.EqualFold(name, *categoryName)) {
+			continue
+		}
+		if item, ok := category.Items[*id]; ok {
+			found := *item
+			return &found, nil
+		}
+	}
+	return nil, fmt.Errorf("item %s not found", *id)
+}
+
+// addInventory creates a new seed in the inventory
+func addInventory(sessionID *string, data interface{}) (*InventoryItem, error) {
+	item, err := itemFromData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.New().String()
+	item.ID = &id
+	if err := item.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := insertItemInDB(item); err != nil {
+		return nil, err
+	}
+
+	return refreshAndNotify(addInventoryRequestKey, sessionID, item)
+}
+
+// updateInventory replaces the stored details of an existing seed
+func updateInventory(sessionID, categoryName, id *string, data interface{}) (*InventoryItem, error) {
+	current, err := findItem(categoryName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := itemFromData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// the stock only changes through a restock or a purchase, packets sent with an update are ignored
+	item.ID = current.ID
+	item.Packets = current.Packets
+	if err := item.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := updateItemInDB(item); err != nil {
+		return nil, err
+	}
+
+	return refreshAndNotify(updateInventoryRequestKey, sessionID, item)
+}
+
+// restockInventory adds packets to an existing seed
+func restockInventory(sessionID, categoryName, id *string, data interface{}) (*InventoryItem, error) {
+	current, err := findItem(categoryName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var request restockRequest
+	if err := json.Unmarshal(bytes, &request); err != nil {
+		return nil, err
+	}
+
+	if request.Quantity == nil {
+		return nil, errors.New("no quantity supplied, cannot restock")
+	}
+
+	quantity, err := determineQuantity(*request.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	if quantity == nil || *quantity <= 0 {
+		return nil, errors.New("restock quantity must be greater than 0")
+	}
+
+	// the packets are incremented in the db rather than written back so a purchase made during the restock isn't lost
+	if err := restockItemInDB(current.ID, *quantity); err != nil {
+		return nil, err
+	}
+
+	invalidateInventoryCache()
+	item, err := findItem(current.Category, current.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	go notifyAll(restockInventoryRequestKey, item.Category, item.ID, sessionID, item)
+	return item, nil
+}
+
+// removeInventory retires a seed from the inventory
+func removeInventory(sessionID, categoryName, id *string) (*InventoryItem, error) {
+	item, err := findItem(categoryName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := deleteItemFromDB(item.ID); err != nil {
+		return nil, err
+	}
+
+	return refreshAndNotify(removeInventoryRequestKey, sessionID, item)
+}
+
+// refreshAndNotify reloads the inventory cache after a write and lets the other connected clients know about the change
+func refreshAndNotify(requestType string, sessionID *string, item *InventoryItem) (*InventoryItem, error) {
+	invalidateInventoryCache()
+	if _, err := getInventory(); err != nil {
+		return nil, err
+	}
+
+	go notifyAll(requestType, item.Category, item.ID, sessionID, item)
+	return item, nil
+}
@@ -0,0 +1,94 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/utils"
+)
+
+func TestInventoryMaintenance(t *testing.T) {
+	initInventoryTest()
+	newSeed := map[string]interface{}{
+		"category":       "Herb",
+		"genus":          "Coriandrum",
+		"species":        "sativum",
+		"cultivar":       "Calypso",
+		"commonName":     "Cilantro",
+		"description":    "Slow bolting cilantro",
+		"price":          3.15,
+		"perPacketCount": 50,
+		"packets":        10,
+	}
+
+	t.Run("Test validation failures", func(t *testing.T) {
+		_, err := addInventory(nil, map[string]interface{}{"category": "Herb"})
+		require.Error(t, err)
+
+		badCategory := map[string]interface{}{}
+		for k, v := range newSeed {
+			badCategory[k] = v
+		}
+		badCategory["category"] = "Squash"
+		_, err = addInventory(nil, badCategory)
+		require.Error(t, err)
+	})
+
+	t.Run("Test add, update, restock and remove", func(t *testing.T) {
+		item, err := addInventory(nil, newSeed)
+		require.NoError(t, err)
+		require.NotNil(t, item.ID)
+
+		detail, err := getDetail(item.Category, item.ID)
+		require.NoError(t, err)
+		require.Equal(t, "Cilantro", *detail.CommonName)
+
+		newSeed["commonName"] = "Coriander"
+		newSeed["packets"] = 500
+		item, err = updateInventory(nil, item.Category, item.ID, newSeed)
+		require.NoError(t, err)
+		require.Equal(t, "Coriander", *item.CommonName)
+		require.Equal(t, 10, *item.Packets)
+
+		item, err = restockInventory(nil, item.Category, item.ID, map[string]interface{}{"quantity": 5})
+		require.NoError(t, err)
+		require.Equal(t, 15, *item.Packets)
+
+		_, err = removeInventory(nil, item.Category, item.ID)
+		require.NoError(t, err)
+
+		_, err = findItem(item.Category, item.ID)
+		require.Error(t, err)
+	})
+
+	t.Run("Test non admin websocket request is denied", func(t *testing.T) {
+		seeds := seedsKey
+		requestType := addInventoryRequestKey
+		request := &configs.WsMessage{
+			Route:   &seeds,
+			Type:    &requestType,
+			IsAdmin: utils.BoolPointer(false),
+			Data:    newSeed,
+		}
+		response := &configs.WsMessage{}
+		handleWebsocketRequest(request, response)
+		require.NotNil(t, response.Error)
+		require.Nil(t, response.Data)
+	})
+}
@@ -0,0 +1,384 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+// the original seeds table pinned the categories with a CHECK constraint
+const checkConstraintSeedsSQL = `CREATE TABLE IF NOT EXISTS seeds (
+	id varchar(64) NOT NULL,
+	category TEXT CHECK(category IN ('Herb', 'Onion', 'Pepper', 'Tomato')) NOT NULL,
+	genus varchar(512) NOT NULL,
+	species varchar(512) NOT NULL,
+	cultivar varchar(512),
+	commonName varcar(1024) NOT NULL,
+	description varcar(2048) NOT NULL,
+	hybrid tinyint(1) NOT NULL default 0,
+	price real NOT NULL,
+	perpacketcount int NOT NULL,
+	packets int NOT NULL,
+	image varcar(1024))`
+
+// seedsColumnsV1 are the columns the seeds table had before the reorder threshold was added
+const seedsColumnsV1 = "id, category, genus, species, cultivar, commonName, description, hybrid, price, perpacketcount, packets, image"
+
+// categoriesSeedsSQL is the seeds table once the categories table was introduced
+const categoriesSeedsSQL = `CREATE TABLE IF NOT EXISTS seeds (
+	id varchar(64) NOT NULL,
+	category varchar(128) NOT NULL REFERENCES categories(name) ON UPDATE CASCADE,
+	genus varchar(512) NOT NULL,
+	species varchar(512) NOT NULL,
+	cultivar varchar(512),
+	commonName varcar(1024) NOT NULL,
+	description varcar(2048) NOT NULL,
+	hybrid tinyint(1) NOT NULL default 0,
+	price real NOT NULL,
+	perpacketcount int NOT NULL,
+	packets int NOT NULL,
+	image varcar(1024))`
+
+// seedsColumnsV2 are the columns the seeds table had before prices were stored in cents with a currency
+const seedsColumnsV2 = seedsColumnsV1 + ", reorderThreshold"
+
+// reorderThresholdSeedsSQL is the seeds table once the reorder threshold was added, the price is still in dollars
+const reorderThresholdSeedsSQL = `CREATE TABLE IF NOT EXISTS seeds (
+	id varchar(64) NOT NULL,
+	category varchar(128) NOT NULL REFERENCES categories(name) ON UPDATE CASCADE,
+	genus varchar(512) NOT NULL,
+	species varchar(512) NOT NULL,
+	cultivar varchar(512),
+	commonName varcar(1024) NOT NULL,
+	description varcar(2048) NOT NULL,
+	hybrid tinyint(1) NOT NULL default 0,
+	price real NOT NULL,
+	perpacketcount int NOT NULL,
+	packets int NOT NULL,
+	image varcar(1024),
+	reorderThreshold int NOT NULL default 0)`
+
+// centsSeedsSQL is the seeds table with the price in cents and the currency it is in
+const centsSeedsSQL = `CREATE TABLE IF NOT EXISTS seeds (
+	id varchar(64) NOT NULL,
+	category varchar(128) NOT NULL REFERENCES categories(name) ON UPDATE CASCADE,
+	genus varchar(512) NOT NULL,
+	species varchar(512) NOT NULL,
+	cultivar varchar(512),
+	commonName varcar(1024) NOT NULL,
+	description varcar(2048) NOT NULL,
+	hybrid tinyint(1) NOT NULL default 0,
+	price bigint NOT NULL,
+	perpacketcount int NOT NULL,
+	packets int NOT NULL,
+	image varcar(1024),
+	reorderThreshold int NOT NULL default 0,
+	currency varchar(3) NOT NULL default 'USD')`
+
+// toCentsSQL converts a dollar amount column to whole cents, rounding off the float error of the real column
+const toCentsSQL = "CAST(ROUND(%[1]s * 100) AS INTEGER)"
+
+// the order and pricing tables as they were created when amounts were stored as real numbers of dollars
+const (
+	dollarsOrdersSQL = `CREATE TABLE IF NOT EXISTS %s (
+	id varchar(64) NOT NULL PRIMARY KEY,
+	user varchar(128) NOT NULL,
+	created bigint NOT NULL,
+	total real NOT NULL)`
+	dollarsOrderLinesSQL = `CREATE TABLE IF NOT EXISTS %s (
+	orderID varchar(64) NOT NULL REFERENCES %s(id) ON DELETE CASCADE,
+	seedID varchar(64) NOT NULL,
+	category varchar(128) NOT NULL,
+	commonName varchar(1024) NOT NULL,
+	cultivar varchar(512),
+	quantity int NOT NULL,
+	price real NOT NULL)`
+	dollarsCouponRedemptionsSQL = `CREATE TABLE IF NOT EXISTS %s (
+	code varchar(64) NOT NULL,
+	orderID varchar(64) NOT NULL REFERENCES %s(id) ON DELETE CASCADE,
+	user varchar(128) NOT NULL,
+	discount real NOT NULL,
+	redeemed bigint NOT NULL)`
+	dollarsPriceHistorySQL = `CREATE TABLE IF NOT EXISTS priceHistory (
+	seedID varchar(64) NOT NULL,
+	price real NOT NULL,
+	changed bigint NOT NULL)`
+	dollarsPromotionsSQL = `CREATE TABLE IF NOT EXISTS promotions (
+	id varchar(64) NOT NULL PRIMARY KEY,
+	name varchar(256) NOT NULL,
+	kind varchar(16) NOT NULL,
+	amount real NOT NULL,
+	seedID varchar(64),
+	category varchar(128),
+	starts bigint NOT NULL,
+	ends bigint NOT NULL,
+	created bigint NOT NULL)`
+	dollarsCouponsSQL = `CREATE TABLE IF NOT EXISTS coupons (
+	code varchar(64) NOT NULL PRIMARY KEY,
+	kind varchar(16) NOT NULL,
+	amount real NOT NULL,
+	starts bigint NOT NULL,
+	ends bigint,
+	maxRedemptions int,
+	redemptions int NOT NULL default 0,
+	created bigint NOT NULL)`
+)
+
+// the order and pricing tables with amounts in cents, a percent discount is in hundredths of a percent
+const (
+	centsOrdersSQL = `CREATE TABLE IF NOT EXISTS %s (
+	id varchar(64) NOT NULL PRIMARY KEY,
+	user varchar(128) NOT NULL,
+	created bigint NOT NULL,
+	total bigint NOT NULL,
+	currency varchar(3) NOT NULL default 'USD')`
+	centsOrderLinesSQL = `CREATE TABLE IF NOT EXISTS %s (
+	orderID varchar(64) NOT NULL REFERENCES %s(id) ON DELETE CASCADE,
+	seedID varchar(64) NOT NULL,
+	category varchar(128) NOT NULL,
+	commonName varchar(1024) NOT NULL,
+	cultivar varchar(512),
+	quantity int NOT NULL,
+	price bigint NOT NULL)`
+	centsCouponRedemptionsSQL = `CREATE TABLE IF NOT EXISTS %s (
+	code varchar(64) NOT NULL,
+	orderID varchar(64) NOT NULL REFERENCES %s(id) ON DELETE CASCADE,
+	user varchar(128) NOT NULL,
+	discount bigint NOT NULL,
+	redeemed bigint NOT NULL)`
+	centsPriceHistorySQL = `CREATE TABLE IF NOT EXISTS priceHistory (
+	seedID varchar(64) NOT NULL,
+	price bigint NOT NULL,
+	changed bigint NOT NULL)`
+	centsPromotionsSQL = `CREATE TABLE IF NOT EXISTS promotions (
+	id varchar(64) NOT NULL PRIMARY KEY,
+	name varchar(256) NOT NULL,
+	kind varchar(16) NOT NULL,
+	amount bigint NOT NULL,
+	seedID varchar(64),
+	category varchar(128),
+	starts bigint NOT NULL,
+	ends bigint NOT NULL,
+	created bigint NOT NULL)`
+	centsCouponsSQL = `CREATE TABLE IF NOT EXISTS coupons (
+	code varchar(64) NOT NULL PRIMARY KEY,
+	kind varchar(16) NOT NULL,
+	amount bigint NOT NULL,
+	starts bigint NOT NULL,
+	ends bigint,
+	maxRedemptions int,
+	redemptions int NOT NULL default 0,
+	created bigint NOT NULL)`
+)
+
+// seedsMigrations are the schema changes made to the seeds package tables after their initial release
+var seedsMigrations = []*configs.Migration{
+	{
+		Version:     1,
+		Description: "replace the seeds category CHECK constraint with the categories table",
+		Table:       "seeds",
+		Up:          categoriesUp,
+		Down:        categoriesDown,
+	},
+	{
+		Version:     2,
+		Description: "add the per seed reorder threshold",
+		Table:       "seeds",
+		UpSQL:       []string{"ALTER TABLE seeds ADD COLUMN reorderThreshold int NOT NULL default 0"},
+		Down: func(tx *sql.Tx) error {
+			return execStatements(tx, rebuildSeedsTable(categoriesSeedsSQL, seedsColumnsV1, seedsColumnsV1))
+		},
+	},
+	{
+		Version:     3,
+		Description: "store seed prices in cents with a currency",
+		Table:       "seeds",
+		Up: func(tx *sql.Tx) error {
+			return execStatements(tx, rebuildSeedsTable(centsSeedsSQL, seedsColumnsV2+", currency",
+				strings.Replace(seedsColumnsV2, "price", fmt.Sprintf(toCentsSQL, "price"), 1)+", 'USD'"))
+		},
+		Down: func(tx *sql.Tx) error {
+			return execStatements(tx, rebuildSeedsTable(reorderThresholdSeedsSQL, seedsColumnsV2,
+				strings.Replace(seedsColumnsV2, "price", "price / 100.0", 1)))
+		},
+	},
+	{
+		Version:     4,
+		Description: "store order totals and line prices in cents with a currency",
+		Table:       "orders",
+		Up:          ordersCentsUp,
+		Down:        ordersCentsDown,
+	},
+	{
+		Version:     5,
+		Description: "store the price history, promotion and coupon amounts in cents",
+		Table:       "priceHistory",
+		Up: func(tx *sql.Tx) error {
+			return rebuildPricingTables(tx, map[string]string{
+				"priceHistory": centsPriceHistorySQL,
+				"promotions":   centsPromotionsSQL,
+				"coupons":      centsCouponsSQL,
+			}, func(column string) string { return fmt.Sprintf(toCentsSQL, column) })
+		},
+		Down: func(tx *sql.Tx) error {
+			return rebuildPricingTables(tx, map[string]string{
+				"priceHistory": dollarsPriceHistorySQL,
+				"promotions":   dollarsPromotionsSQL,
+				"coupons":      dollarsCouponsSQL,
+			}, func(column string) string { return column + " / 100.0" })
+		},
+	},
+}
+
+func init() {
+	configs.RegisterMigrations(seedsKey, seedsMigrations...)
+}
+
+// categoriesUp converts a seeds table created with the hard coded category CHECK constraint to one that references the categories table
+func categoriesUp(tx *sql.Tx) error {
+	var createSQL string
+	if err := tx.QueryRow("select sql from sqlite_master where type = 'table' and name = 'seeds'").Scan(&createSQL); err != nil {
+		return err
+	}
+
+	// the table was created after the categories table was introduced
+	if !strings.Contains(createSQL, "CHECK(category IN") {
+		return nil
+	}
+
+	categories := inventoryTables["categories"]
+	statements := []string{categories.CreateSQL}
+	for _, insert := range categories.Defaults {
+		statements = append(statements, insert)
+	}
+	statements = append(statements, "insert or ignore into categories (name, displayOrder) select distinct category, 0 from seeds")
+	statements = append(statements, rebuildSeedsTable(categoriesSeedsSQL, seedsColumnsV1, seedsColumnsV1)...)
+	return execStatements(tx, statements)
+}
+
+// categoriesDown restores the CHECK constraint, it will fail if seeds have been assigned to categories outside the original four
+func categoriesDown(tx *sql.Tx) error {
+	return execStatements(tx, rebuildSeedsTable(checkConstraintSeedsSQL, seedsColumnsV1, seedsColumnsV1))
+}
+
+// rebuildSeedsTable returns the statements to recreate the seeds table with a new definition while keeping the rows,
+// the columns are filled from the select expressions of the old table in the same order
+func rebuildSeedsTable(createSQL, columns, selectColumns string) []string {
+	return rebuildTable("seeds", createSQL, columns, selectColumns, inventoryTables["seeds"].Indices)
+}
+
+// rebuildTable returns the statements to recreate a table that nothing references with a new definition
+func rebuildTable(table, createSQL, columns, selectColumns string, indices []string) []string {
+	statements := []string{
+		fmt.Sprintf("ALTER TABLE %[1]s RENAME TO %[1]s_rebuild", table),
+		createSQL,
+		fmt.Sprintf("INSERT INTO %s (%s) select %s from %s_rebuild", table, columns, selectColumns, table),
+		fmt.Sprintf("DROP TABLE %s_rebuild", table),
+	}
+	return append(statements, indices...)
+}
+
+// ordersCentsUp converts the order amounts to cents.  The lines and coupon redemptions reference the orders so renaming
+// the orders table would carry the references with it and dropping it would cascade the delete, so the family of tables
+// is copied to new tables that reference each other and the new orders table is renamed into place last
+func ordersCentsUp(tx *sql.Tx) error {
+	toCents := func(column string) string { return fmt.Sprintf(toCentsSQL, column) }
+	return rebuildOrderTables(tx, centsOrdersSQL, centsOrderLinesSQL, centsCouponRedemptionsSQL, toCents,
+		"id, user, created, total, currency", fmt.Sprintf("id, user, created, %s, 'USD'", toCents("total")))
+}
+
+// ordersCentsDown converts the order amounts back to dollars, the currency is dropped
+func ordersCentsDown(tx *sql.Tx) error {
+	return rebuildOrderTables(tx, dollarsOrdersSQL, dollarsOrderLinesSQL, dollarsCouponRedemptionsSQL,
+		func(column string) string { return column + " / 100.0" }, "id, user, created, total", "id, user, created, total / 100.0")
+}
+
+func rebuildOrderTables(tx *sql.Tx, ordersSQL, linesSQL, redemptionsSQL string, convert func(string) string,
+	orderColumns, orderSelect string) error {
+	redemptions, err := configs.TableExists(tx, "couponRedemptions")
+	if err != nil {
+		return err
+	}
+
+	lineColumns := "orderID, seedID, category, commonName, cultivar, quantity"
+	statements := []string{
+		fmt.Sprintf(ordersSQL, "orders_rebuild"),
+		fmt.Sprintf("INSERT INTO orders_rebuild (%s) select %s from orders", orderColumns, orderSelect),
+		fmt.Sprintf(linesSQL, "orderLines_rebuild", "orders_rebuild"),
+		fmt.Sprintf("INSERT INTO orderLines_rebuild (%[1]s, price) select %[1]s, %[2]s from orderLines", lineColumns, convert("price")),
+		"DROP TABLE orderLines",
+	}
+	if redemptions {
+		statements = append(statements,
+			fmt.Sprintf(redemptionsSQL, "couponRedemptions_rebuild", "orders_rebuild"),
+			fmt.Sprintf(`INSERT INTO couponRedemptions_rebuild (code, orderID, user, discount, redeemed)
+				select code, orderID, user, %s, redeemed from couponRedemptions`, convert("discount")),
+			"DROP TABLE couponRedemptions",
+		)
+	}
+
+	// the references of the rebuilt children follow the rename of orders_rebuild
+	statements = append(statements,
+		"DROP TABLE orders",
+		"ALTER TABLE orders_rebuild RENAME TO orders",
+		"ALTER TABLE orderLines_rebuild RENAME TO orderLines",
+	)
+	statements = append(statements, orderTables["orders"].Indices...)
+	statements = append(statements, orderTables["orderLines"].Indices...)
+	if redemptions {
+		statements = append(statements, "ALTER TABLE couponRedemptions_rebuild RENAME TO couponRedemptions")
+		statements = append(statements, pricingTables["couponRedemptions"].Indices...)
+	}
+	return execStatements(tx, statements)
+}
+
+// rebuildPricingTables converts the amount columns of the pricing tables.  The price history triggers are dropped first
+// so they don't follow the rename of the history table, they are recreated when the pricing tables are set up
+func rebuildPricingTables(tx *sql.Tx, createSQL map[string]string, convert func(string) string) error {
+	statements := []string{"DROP TRIGGER IF EXISTS seedprice_ai", "DROP TRIGGER IF EXISTS seedprice_au"}
+	statements = append(statements, rebuildTable("priceHistory", createSQL["priceHistory"], "seedID, price, changed",
+		fmt.Sprintf("seedID, %s, changed", convert("price")), pricingTables["priceHistory"].Indices)...)
+
+	for _, table := range []string{"promotions", "coupons"} {
+		exists, err := configs.TableExists(tx, table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		columns := "id, name, kind, amount, seedID, category, starts, ends, created"
+		if table == "coupons" {
+			columns = "code, kind, amount, starts, ends, maxRedemptions, redemptions, created"
+		}
+		statements = append(statements, rebuildTable(table, createSQL[table], columns,
+			strings.Replace(columns, "amount", convert("amount"), 1), pricingTables[table].Indices)...)
+	}
+	return execStatements(tx, statements)
+}
+
+func execStatements(tx *sql.Tx, statements []string) error {
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("unable to execute '%s'.  Error: %s", statement, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+func TestMoneyMigrations(t *testing.T) {
+	initInventoryTest()
+	user := uuid.New().String()
+	item, err := addInventory(nil, map[string]interface{}{
+		"category":       "Onion",
+		"genus":          "Allium",
+		"species":        "cepa",
+		"cultivar":       "Migration Test",
+		"commonName":     "Onion",
+		"description":    "Migration test onion",
+		"price":          "2.35",
+		"perPacketCount": 50,
+		"packets":        10,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := removeInventory(nil, item.Category, item.ID)
+		require.NoError(t, err)
+	})
+	_, err = purchase(nil, &user, map[string]interface{}{"category": "Onion", "id": *item.ID, "quantity": 3})
+	require.NoError(t, err)
+
+	db, err := configs.GetSQLiteConnection()
+	require.NoError(t, err)
+
+	// the migrations are taken down to dollars and back up to cents in a transaction that is never committed
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	defer configs.RollbackHelper(tx)
+
+	var price, total float64
+	for i := len(seedsMigrations) - 1; i >= 2; i-- {
+		require.NoError(t, seedsMigrations[i].Down(tx), seedsMigrations[i].Description)
+	}
+	require.NoError(t, tx.QueryRow("select price from seeds where id = ?", item.ID).Scan(&price))
+	require.InDelta(t, 2.35, price, 0.0001)
+	require.NoError(t, tx.QueryRow("select total from orders where user = ?", user).Scan(&total))
+	require.InDelta(t, 7.05, total, 0.0001)
+
+	for _, migration := range seedsMigrations[2:] {
+		require.NoError(t, migration.Up(tx), migration.Description)
+	}
+	var cents Money
+	var currency string
+	require.NoError(t, tx.QueryRow("select price, currency from seeds where id = ?", item.ID).Scan(&cents, &currency))
+	require.Equal(t, Money(235), cents)
+	require.Equal(t, defaultCurrency, currency)
+	require.NoError(t, tx.QueryRow(`select o.total, l.price from orders o join orderLines l on l.orderID = o.id
+		where o.user = ?`, user).Scan(&total, &cents))
+	require.InDelta(t, 705, total, 0.0001)
+	require.Equal(t, Money(235), cents)
+
+	var history int
+	require.NoError(t, tx.QueryRow("select count(*) from priceHistory where seedID = ? and price = 235", item.ID).Scan(&history))
+	require.Equal(t, 1, history)
+
+	// the order lines still cascade from the rebuilt orders table
+	_, err = tx.Exec("delete from orders where user = ?", user)
+	require.NoError(t, err)
+	var lines int
+	require.NoError(t, tx.QueryRow("select count(*) from orderLines where orderID not in (select id from orders)").Scan(&lines))
+	require.Equal(t, 0, lines)
+}
@@ -0,0 +1,112 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultCurrency is the ISO 4217 code of seeds and orders that don't specify one
+const defaultCurrency = "USD"
+
+var (
+	// decimalAmount is a plain decimal with at most two places, exponents are refused so nothing passes through a float
+	decimalAmount = regexp.MustCompile(`^(-?)(\d*)(?:\.(\d{0,2}))?$`)
+	currencyCode  = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// Money is an exact amount in minor units, cents for USD.  It is rendered in JSON as a decimal string such as "4.28"
+// and reads either a decimal string or a JSON number so clients that send prices as numbers keep working
+type Money int64
+
+// ParseMoney reads a decimal amount with at most two decimal places without going through a float
+func ParseMoney(value string) (Money, error) {
+	value = strings.TrimSpace(value)
+	parts := decimalAmount.FindStringSubmatch(value)
+	if parts == nil || (parts[2] == "" && parts[3] == "") {
+		return 0, fmt.Errorf("%s is not an amount with at most 2 decimal places", value)
+	}
+
+	var units, cents int64
+	var err error
+	if parts[2] != "" {
+		if units, err = strconv.ParseInt(parts[2], 10, 64); err != nil {
+			return 0, err
+		}
+	}
+	if parts[3] != "" {
+		// .5 is 50 cents
+		if cents, err = strconv.ParseInt((parts[3] + "0")[:2], 10, 64); err != nil {
+			return 0, err
+		}
+	}
+
+	amount := Money(units*100 + cents)
+	if parts[1] == "-" {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// MarshalJSON renders the amount as a decimal string
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts "4.28" or 4.28, the number is read from its text so it is as exact as the string
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := string(bytes.Trim(data, `"`))
+	if text == "null" {
+		return nil
+	}
+	amount, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+// Times is the amount multiplied by a quantity
+func (m Money) Times(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// normalizeCurrency upper cases the currency code, an unset code is the default currency
+func normalizeCurrency(currency *string) (*string, error) {
+	code := defaultCurrency
+	if currency != nil && strings.TrimSpace(*currency) != "" {
+		code = strings.ToUpper(strings.TrimSpace(*currency))
+	}
+	if !currencyCode.MatchString(code) {
+		return nil, fmt.Errorf("currency %s is not a 3 letter ISO 4217 code", code)
+	}
+	return &code, nil
+}
@@ -0,0 +1,74 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoney(t *testing.T) {
+	t.Run("Test parsing amounts", func(t *testing.T) {
+		for value, expected := range map[string]Money{
+			"4.28":  428,
+			"4.2":   420,
+			"4":     400,
+			".5":    50,
+			"0.07":  7,
+			"-1.10": -110,
+			" 12 ":  1200,
+		} {
+			amount, err := ParseMoney(value)
+			require.NoError(t, err, value)
+			require.Equal(t, expected, amount, value)
+		}
+
+		for _, value := range []string{"", ".", "4.281", "1e2", "four", "4,28", "$4"} {
+			_, err := ParseMoney(value)
+			require.Error(t, err, value)
+		}
+	})
+
+	t.Run("Test json round trip", func(t *testing.T) {
+		b, err := json.Marshal(map[string]Money{"price": 428, "credit": -5})
+		require.NoError(t, err)
+		require.JSONEq(t, `{"price": "4.28", "credit": "-0.05"}`, string(b))
+
+		var item InventoryItem
+		require.NoError(t, json.Unmarshal([]byte(`{"price": 4.10, "effectivePrice": "3.95"}`), &item))
+		require.Equal(t, Money(410), *item.Price)
+		require.Equal(t, Money(395), *item.EffectivePrice)
+
+		require.Error(t, json.Unmarshal([]byte(`{"price": 0.1e1}`), &item))
+		require.Equal(t, Money(1200), Money(400).Times(3))
+	})
+
+	t.Run("Test currency codes", func(t *testing.T) {
+		currency, err := normalizeCurrency(nil)
+		require.NoError(t, err)
+		require.Equal(t, defaultCurrency, *currency)
+
+		eur := " eur"
+		currency, err = normalizeCurrency(&eur)
+		require.NoError(t, err)
+		require.Equal(t, "EUR", *currency)
+
+		bad := "dollars"
+		_, err = normalizeCurrency(&bad)
+		require.Error(t, err)
+	})
+}
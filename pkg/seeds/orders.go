@@ -0,0 +1,337 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+var (
+	orderTableOrder = []string{"orders", "orderLines"}
+
+	orderTables = map[string]*configs.Table{
+		"orders": {
+			CreateSQL: `CREATE TABLE IF NOT EXISTS orders (
+				id varchar(64) NOT NULL PRIMARY KEY,
+				user varchar(128) NOT NULL,
+				created bigint NOT NULL,
+				total bigint NOT NULL,
+				currency varchar(3) NOT NULL default 'USD')`,
+			InsertSQL: "INSERT INTO orders values(?,?,?,?,?)",
+			Indices: []string{
+				"CREATE INDEX IF NOT EXISTS orderuser on orders(user)",
+				"CREATE INDEX IF NOT EXISTS ordercreated on orders(created)",
+			},
+		},
+		// the seed details are copied to the line so the history survives the seed being changed or removed
+		"orderLines": {
+			CreateSQL: `CREATE TABLE IF NOT EXISTS orderLines (
+				orderID varchar(64) NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+				seedID varchar(64) NOT NULL,
+				category varchar(128) NOT NULL,
+				commonName varchar(1024) NOT NULL,
+				cultivar varchar(512),
+				quantity int NOT NULL,
+				price bigint NOT NULL)`,
+			InsertSQL: "INSERT INTO orderLines values(?,?,?,?,?,?,?)",
+			Indices: []string{
+				"CREATE INDEX IF NOT EXISTS orderlineorder on orderLines(orderID)",
+				"CREATE INDEX IF NOT EXISTS orderlineseed on orderLines(seedID)",
+			},
+		},
+	}
+)
+
+// Order is a completed purchase, the total is after the discount of the coupon if one was redeemed.  Every line of an
+// order is in the order's currency
+type Order struct {
+	ID       *string      `json:"id,omitempty"`
+	User     *string      `json:"user,omitempty"`
+	Created  *int64       `json:"created,omitempty"`
+	Total    *Money       `json:"total,omitempty"`
+	Currency *string      `json:"currency,omitempty"`
+	Coupon   *string      `json:"coupon,omitempty"`
+	Discount *Money       `json:"discount,omitempty"`
+	Lines    []*OrderLine `json:"lines,omitempty"`
+}
+
+// OrderLine is a seed on an order with the price it was purchased at
+type OrderLine struct {
+	SeedID     *string `json:"seedID,omitempty"`
+	Category   *string `json:"category,omitempty"`
+	CommonName *string `json:"commonName,omitempty"`
+	Cultivar   *string `json:"cultivar,omitempty"`
+	Quantity   *int    `json:"quantity,omitempty"`
+	Price      *Money  `json:"price,omitempty"`
+}
+
+// orderFilter narrows the order history, from and to accept epoch milliseconds, YYYY-MM-DD or RFC3339 timestamps
+type orderFilter struct {
+	From   *string `json:"from,omitempty"`
+	To     *string `json:"to,omitempty"`
+	User   *string `json:"user,omitempty"`
+	SeedID *string `json:"seed,omitempty"`
+}
+
+// newOrder builds an order for the user and calculates its total from the lines
+func newOrder(userID, currency *string, lines []*OrderLine) *Order {
+	id := uuid.New().String()
+	created := time.Now().UnixMilli()
+	var total Money
+	for _, line := range lines {
+		if line.Price != nil && line.Quantity != nil {
+			total += line.Price.Times(*line.Quantity)
+		}
+	}
+	return &Order{
+		ID:       &id,
+		User:     userID,
+		Created:  &created,
+		Total:    &total,
+		Currency: currency,
+		Lines:    lines,
+	}
+}
+
+// newOrderLine captures the seed details at the time of purchase, the price is the effective price if it has been set
+func newOrderLine(item *InventoryItem, quantity int) *OrderLine {
+	price := item.Price
+	if item.EffectivePrice != nil {
+		price = item.EffectivePrice
+	}
+	return &OrderLine{
+		SeedID:     item.ID,
+		Category:   item.Category,
+		CommonName: item.CommonName,
+		Cultivar:   item.Cultivar,
+		Quantity:   &quantity,
+		Price:      price,
+	}
+}
+
+// recordOrder writes the order and its lines in a single transaction
+func recordOrder(order *Order) error {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return fmt.Errorf("unable to record order, sqlite error: %s", dbErr)
+	}
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer configs.RollbackHelper(tx)
+
+	if err := insertOrder(tx, order); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertOrder writes the order and its lines as part of an existing transaction
+func insertOrder(tx *sql.Tx, order *Order) error {
+	orders, lines := orderTables["orders"], orderTables["orderLines"]
+	if order == nil || order.User == nil || len(order.Lines) == 0 {
+		return errors.New("an order requires a user and at least one line")
+	}
+
+	if _, err := orders.ExecTx(tx, orders.InsertSQL, order.ID, order.User, order.Created, order.Total, order.Currency); err != nil {
+		return err
+	}
+
+	for _, line := range order.Lines {
+		if _, err := lines.ExecTx(tx, lines.InsertSQL, order.ID, line.SeedID, line.Category, line.CommonName, line.Cultivar,
+			line.Quantity, line.Price); err != nil {
+			return err
+		}
+	}
+
+	log.Tracef("order %s recorded for %s with %d lines", *order.ID, *order.User, len(order.Lines))
+	return nil
+}
+
+// getOrders returns the orders matching the filter, newest first
+func getOrders(filter *orderFilter) ([]*Order, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve orders, sqlite error: %s", dbErr)
+	}
+
+	query, args, err := filter.toSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []*Order{}
+	var current *Order
+	for rows.Next() {
+		order := Order{}
+		line := OrderLine{}
+		if err := rows.Scan(&order.ID, &order.User, &order.Created, &order.Total, &order.Currency, &order.Coupon, &order.Discount,
+			&line.SeedID, &line.Category, &line.CommonName, &line.Cultivar, &line.Quantity, &line.Price); err != nil {
+			return nil, err
+		}
+
+		// the rows are sorted by order so the lines for an order are contiguous
+		if current == nil || *current.ID != *order.ID {
+			current = &order
+			orders = append(orders, current)
+		}
+		current.Lines = append(current.Lines, &line)
+	}
+	return orders, rows.Err()
+}
+
+func (filter *orderFilter) toSQL() (string, []any, error) {
+	var clauses []string
+	var args []any
+	if filter != nil {
+		if filter.User != nil && *filter.User != "" {
+			clauses = append(clauses, "o.user = ?")
+			args = append(args, *filter.User)
+		}
+		if filter.SeedID != nil && *filter.SeedID != "" {
+			clauses = append(clauses, "o.id in (select orderID from orderLines where seedID = ?)")
+			args = append(args, *filter.SeedID)
+		}
+		if filter.From != nil && *filter.From != "" {
+			from, err := parseTimeFilter(*filter.From)
+			if err != nil {
+				return "", nil, err
+			}
+			clauses = append(clauses, "o.created >= ?")
+			args = append(args, from)
+		}
+		if filter.To != nil && *filter.To != "" {
+			// a date runs to the end of the day, the orders are taken up to the start of the next one
+			if day, err := time.ParseInLocation(time.DateOnly, *filter.To, time.Local); err == nil {
+				clauses = append(clauses, "o.created < ?")
+				args = append(args, day.AddDate(0, 0, 1).UnixMilli())
+			} else {
+				to, err := parseTimeFilter(*filter.To)
+				if err != nil {
+					return "", nil, err
+				}
+				clauses = append(clauses, "o.created <= ?")
+				args = append(args, to)
+			}
+		}
+	}
+
+	query := `select o.id, o.user, o.created, o.total, o.currency, r.code, r.discount,
+			l.seedID, l.category, l.commonName, l.cultivar, l.quantity, l.price
+		from orders o join orderLines l on l.orderID = o.id left join couponRedemptions r on r.orderID = o.id`
+	if len(clauses) > 0 {
+		query = fmt.Sprintf("%s where %s", query, strings.Join(clauses, " and "))
+	}
+	return fmt.Sprintf("%s order by o.created desc, o.id", query), args, nil
+}
+
+// parseTimeFilter converts the supported time formats to epoch milliseconds
+func parseTimeFilter(value string) (int64, error) {
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return millis, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.UnixMilli(), nil
+		}
+	}
+	return 0, fmt.Errorf("unable to parse time filter %s", value)
+}
+
+// orderFilterFromData converts the WebSocket request data into an order filter
+func orderFilterFromData(data interface{}) (*orderFilter, error) {
+	filter := orderFilter{}
+	if data != nil {
+		bytes, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(bytes, &filter); err != nil {
+			return nil, err
+		}
+	}
+	return &filter, nil
+}
+
+// orderFilterFromQuery converts the REST query parameters into an order filter
+func orderFilterFromQuery(values url.Values) *orderFilter {
+	filter := orderFilter{}
+	for key, field := range map[string]**string{
+		"from": &filter.From,
+		"to":   &filter.To,
+		"user": &filter.User,
+		"seed": &filter.SeedID,
+	} {
+		if value := values.Get(key); value != "" {
+			*field = &value
+		}
+	}
+	return &filter
+}
+
+// getUserOrders returns the order history for the requesting user, the user in the filter is ignored
+func getUserOrders(userID *string, filter *orderFilter) ([]*Order, error) {
+	if userID == nil {
+		return nil, errors.New("no user found, cannot retrieve orders")
+	}
+	filter.User = userID
+	return getOrders(filter)
+}
+
+// GetPurchasedSeed returns the line of the user's most recent order of the seed, the seed details are as they were
+// when it was purchased so they're available after the seed is changed or removed from the inventory
+func GetPurchasedSeed(userID, seedID *string) (*OrderLine, error) {
+	if userID == nil || seedID == nil {
+		return nil, errors.New("no user or seed found, cannot retrieve the purchase")
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve the purchase, sqlite error: %s", dbErr)
+	}
+
+	line := OrderLine{}
+	err := db.QueryRow(`select l.seedID, l.category, l.commonName, l.cultivar, l.quantity, l.price
+		from orders o join orderLines l on l.orderID = o.id where o.user = ? and l.seedID = ? order by o.created desc limit 1`,
+		userID, seedID).Scan(&line.SeedID, &line.Category, &line.CommonName, &line.Cultivar, &line.Quantity, &line.Price)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("seed %s has not been purchased by %s", *seedID, *userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
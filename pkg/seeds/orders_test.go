@@ -0,0 +1,126 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/utils"
+)
+
+func TestOrderFunctions(t *testing.T) {
+	initInventoryTest()
+	user := uuid.New().String()
+	item, err := addInventory(nil, map[string]interface{}{
+		"category":       "Pepper",
+		"genus":          "Capsicum",
+		"species":        "chinense",
+		"cultivar":       "Habanero",
+		"commonName":     "Hot Pepper",
+		"description":    "Very hot orange pepper",
+		"price":          4.50,
+		"perPacketCount": 20,
+		"packets":        10,
+	})
+	require.NoError(t, err)
+	defer func() {
+		_, err := removeInventory(nil, item.Category, item.ID)
+		require.NoError(t, err)
+	}()
+
+	t.Run("Test purchase requires a user", func(t *testing.T) {
+		_, err := purchase(nil, nil, map[string]interface{}{"category": "Pepper", "id": *item.ID, "quantity": 1})
+		require.Error(t, err)
+	})
+
+	t.Run("Test purchase records an order", func(t *testing.T) {
+		purchased, err := purchase(nil, &user, map[string]interface{}{"category": "Pepper", "id": *item.ID, "quantity": 2})
+		require.NoError(t, err)
+		require.Equal(t, 8, *purchased.Packets)
+
+		orders, err := getUserOrders(&user, &orderFilter{})
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.Equal(t, user, *orders[0].User)
+		require.Len(t, orders[0].Lines, 1)
+		require.Equal(t, *item.ID, *orders[0].Lines[0].SeedID)
+		require.Equal(t, 2, *orders[0].Lines[0].Quantity)
+		require.Equal(t, Money(900), *orders[0].Total)
+	})
+
+	t.Run("Test the purchased seed", func(t *testing.T) {
+		line, err := GetPurchasedSeed(&user, item.ID)
+		require.NoError(t, err)
+		require.Equal(t, "Habanero", *line.Cultivar)
+		require.Equal(t, 2, *line.Quantity)
+
+		other := uuid.New().String()
+		_, err = GetPurchasedSeed(&other, item.ID)
+		require.Error(t, err)
+	})
+
+	t.Run("Test order filters", func(t *testing.T) {
+		seedID := *item.ID
+		orders, err := getOrders(&orderFilter{User: &user, SeedID: &seedID})
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+
+		tomorrow := time.Now().AddDate(0, 0, 1).Format(time.DateOnly)
+		orders, err = getOrders(&orderFilter{User: &user, From: &tomorrow})
+		require.NoError(t, err)
+		require.Empty(t, orders)
+
+		// a date includes the whole day
+		today := time.Now().Format(time.DateOnly)
+		orders, err = getOrders(&orderFilter{User: &user, From: &today, To: &today})
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+
+		yesterday := time.Now().AddDate(0, 0, -1).Format(time.DateOnly)
+		orders, err = getOrders(&orderFilter{User: &user, To: &yesterday})
+		require.NoError(t, err)
+		require.Empty(t, orders)
+
+		bad := "not a date"
+		_, err = getOrders(&orderFilter{From: &bad})
+		require.Error(t, err)
+	})
+
+	t.Run("Test getAllOrders is restricted to admins", func(t *testing.T) {
+		seeds := seedsKey
+		requestType := getAllOrdersRequestKey
+		request := &configs.WsMessage{
+			Route:   &seeds,
+			Type:    &requestType,
+			User:    &user,
+			IsAdmin: utils.BoolPointer(false),
+		}
+		response := &configs.WsMessage{}
+		handleWebsocketRequest(request, response)
+		require.NotNil(t, response.Error)
+
+		request.IsAdmin = utils.BoolPointer(true)
+		request.Data = map[string]interface{}{"user": user}
+		response = &configs.WsMessage{}
+		handleWebsocketRequest(request, response)
+		require.Nil(t, response.Error)
+		require.Len(t, response.Data, 1)
+	})
+}
@@ -0,0 +1,587 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+const (
+	percentDiscount = "percent"
+	fixedDiscount   = "fixed"
+	// percentScale is 100% in the hundredths of a percent a percent discount is stored in
+	percentScale Money = 100 * 100
+
+	// epochMillisSQL is the current time in epoch milliseconds as SQLite sees it
+	epochMillisSQL = "CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)"
+)
+
+var (
+	// effectivePriceSQL is effectivePrice in SQL for the seeds table aliased as s with the promotions running now, the
+	// search filters and sorts on it so the two have to give the same price
+	effectivePriceSQL = fmt.Sprintf(`min(s.price, coalesce((select min(max(case p.kind
+			when '%[1]s' then (s.price * (%[3]d - p.amount) + %[3]d / 2) / %[3]d
+			when '%[2]s' then s.price - p.amount
+			else s.price end, 0))
+		from promotions p where p.starts <= %[4]s and p.ends > %[4]s
+			and (p.seedID = s.id or (p.seedID is null and p.category = s.category collate nocase))), s.price))`,
+		percentDiscount, fixedDiscount, percentScale, epochMillisSQL)
+
+	pricingTableOrder = []string{"priceHistory", "promotions", "coupons", "couponRedemptions"}
+
+	pricingTables = map[string]*configs.Table{
+		"priceHistory": {
+			CreateSQL: `CREATE TABLE IF NOT EXISTS priceHistory (
+				seedID varchar(64) NOT NULL,
+				price bigint NOT NULL,
+				changed bigint NOT NULL)`,
+			Indices: []string{
+				"CREATE INDEX IF NOT EXISTS pricehistoryseed on priceHistory(seedID, changed)",
+			},
+		},
+		// a promotion applies to either a single seed or every seed in a category, the amount of a percent discount is in
+		// hundredths of a percent and a fixed discount is in cents
+		"promotions": {
+			CreateSQL: `CREATE TABLE IF NOT EXISTS promotions (
+				id varchar(64) NOT NULL PRIMARY KEY,
+				name varchar(256) NOT NULL,
+				kind varchar(16) NOT NULL,
+				amount bigint NOT NULL,
+				seedID varchar(64),
+				category varchar(128),
+				starts bigint NOT NULL,
+				ends bigint NOT NULL,
+				created bigint NOT NULL)`,
+			InsertSQL: "INSERT INTO promotions values(?,?,?,?,?,?,?,?,?)",
+			DeleteSQL: "DELETE FROM promotions where id = ?",
+			Indices: []string{
+				"CREATE INDEX IF NOT EXISTS promotionends on promotions(ends)",
+			},
+		},
+		"coupons": {
+			CreateSQL: `CREATE TABLE IF NOT EXISTS coupons (
+				code varchar(64) NOT NULL PRIMARY KEY,
+				kind varchar(16) NOT NULL,
+				amount bigint NOT NULL,
+				starts bigint NOT NULL,
+				ends bigint,
+				maxRedemptions int,
+				redemptions int NOT NULL default 0,
+				created bigint NOT NULL)`,
+			InsertSQL: "INSERT INTO coupons values(?,?,?,?,?,?,0,?)",
+			// the redemption count is only incremented while the coupon has redemptions left so it can't be over redeemed
+			UpdateSQL: `UPDATE coupons set redemptions = redemptions + 1
+				where code = ? and (maxRedemptions is null or redemptions < maxRedemptions)`,
+			DeleteSQL: "DELETE FROM coupons where code = ?",
+		},
+		"couponRedemptions": {
+			CreateSQL: `CREATE TABLE IF NOT EXISTS couponRedemptions (
+				code varchar(64) NOT NULL,
+				orderID varchar(64) NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+				user varchar(128) NOT NULL,
+				discount bigint NOT NULL,
+				redeemed bigint NOT NULL)`,
+			InsertSQL: "INSERT INTO couponRedemptions values(?,?,?,?,?)",
+			Indices: []string{
+				"CREATE INDEX IF NOT EXISTS couponredemptionorder on couponRedemptions(orderID)",
+			},
+		},
+	}
+
+	// the price history is kept by triggers so every write of a seed's price is recorded, whichever path it comes from
+	priceHistorySQL = []string{
+		"DROP TRIGGER IF EXISTS seedprice_ai",
+		"DROP TRIGGER IF EXISTS seedprice_au",
+		fmt.Sprintf(`CREATE TRIGGER seedprice_ai AFTER INSERT ON seeds BEGIN
+			INSERT INTO priceHistory values (new.id, new.price, %s);
+		END`, epochMillisSQL),
+		fmt.Sprintf(`CREATE TRIGGER seedprice_au AFTER UPDATE OF price ON seeds WHEN old.price <> new.price BEGIN
+			INSERT INTO priceHistory values (new.id, new.price, %s);
+		END`, epochMillisSQL),
+		// seeds that were added before the history was kept start it with their current price
+		fmt.Sprintf(`INSERT INTO priceHistory select id, price, %s from seeds
+			where id not in (select distinct seedID from priceHistory)`, epochMillisSQL),
+	}
+)
+
+// PriceChange is a price a seed was set to and when
+type PriceChange struct {
+	SeedID  *string `json:"seedID,omitempty"`
+	Price   *Money  `json:"price,omitempty"`
+	Changed *int64  `json:"changed,omitempty"`
+}
+
+// Promotion is a time bounded percent or fixed discount on a seed or a whole category, times are epoch milliseconds.
+// The amount is a decimal with two places for both kinds, "12.50" is 12.5% off or $12.50 off
+type Promotion struct {
+	ID       *string `json:"id,omitempty"`
+	Name     *string `json:"name,omitempty"`
+	Kind     *string `json:"kind,omitempty"`
+	Amount   *Money  `json:"amount,omitempty"`
+	SeedID   *string `json:"seedID,omitempty"`
+	Category *string `json:"category,omitempty"`
+	Starts   *int64  `json:"starts,omitempty"`
+	Ends     *int64  `json:"ends,omitempty"`
+	Created  *int64  `json:"created,omitempty"`
+}
+
+// Coupon is a code redeemable at purchase for a percent or fixed discount off the order.  A coupon without an end
+// doesn't expire and one without a max redemptions can be redeemed any number of times
+type Coupon struct {
+	Code           *string `json:"code,omitempty"`
+	Kind           *string `json:"kind,omitempty"`
+	Amount         *Money  `json:"amount,omitempty"`
+	Starts         *int64  `json:"starts,omitempty"`
+	Ends           *int64  `json:"ends,omitempty"`
+	MaxRedemptions *int    `json:"maxRedemptions,omitempty"`
+	Redemptions    *int    `json:"redemptions,omitempty"`
+	Created        *int64  `json:"created,omitempty"`
+}
+
+type promotionsRequest struct {
+	All *bool `json:"all,omitempty"`
+}
+
+// couponRequest carries the optional coupon code of a purchase or checkout
+type couponRequest struct {
+	Coupon *string `json:"coupon,omitempty"`
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx so the promotions can be read in or out of a transaction
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func setupPricing() error {
+	if err := createTables(pricingTableOrder, pricingTables); err != nil {
+		return err
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return fmt.Errorf("unable to setup the price history, sqlite error: %s", dbErr)
+	}
+	for _, statement := range priceHistorySQL {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("unable to execute '%s'.  Error: %s", statement, err)
+		}
+	}
+	return nil
+}
+
+// applyDiscount takes a percent or fixed discount off the price, the result is never less than 0.  A percent amount is
+// in hundredths of a percent so the discounted price is rounded half up to the cent
+func applyDiscount(kind string, amount, price Money) Money {
+	discounted := price
+	switch kind {
+	case percentDiscount:
+		discounted = (price*(percentScale-amount) + percentScale/2) / percentScale
+	case fixedDiscount:
+		discounted = price - amount
+	}
+	return max(discounted, 0)
+}
+
+func validateDiscount(kind *string, amount *Money) error {
+	if kind == nil || amount == nil {
+		return errors.New("kind and amount are required")
+	}
+	switch *kind {
+	case percentDiscount:
+		if *amount <= 0 || *amount > percentScale {
+			return errors.New("a percent discount must be greater than 0 and no more than 100")
+		}
+	case fixedDiscount:
+		if *amount <= 0 {
+			return errors.New("a fixed discount must be greater than 0")
+		}
+	default:
+		return fmt.Errorf("kind must be %s or %s", percentDiscount, fixedDiscount)
+	}
+	return nil
+}
+
+// appliesTo is true if the promotion is for the item or the item's category
+func (promotion *Promotion) appliesTo(item *InventoryItem) bool {
+	if promotion.SeedID != nil {
+		return item.ID != nil && *promotion.SeedID == *item.ID
+	}
+	return promotion.Category != nil && item.Category != nil && strings.EqualFold(*promotion.Category, *item.Category)
+}
+
+// effectivePrice is the price the item sells for given the running promotions.  Promotions don't stack, the one that
+// gives the lowest price wins and is returned with it
+func effectivePrice(item *InventoryItem, promotions []*Promotion) (Money, *Promotion) {
+	if item == nil || item.Price == nil {
+		return 0, nil
+	}
+
+	price := *item.Price
+	var best *Promotion
+	for _, promotion := range promotions {
+		if !promotion.appliesTo(item) {
+			continue
+		}
+		if discounted := applyDiscount(*promotion.Kind, *promotion.Amount, *item.Price); discounted < price {
+			price, best = discounted, promotion
+		}
+	}
+	return price, best
+}
+
+// setEffectivePrice fills in the item's effective price and promotion, the item must not be part of the snapshot
+func setEffectivePrice(item *InventoryItem, promotions []*Promotion) {
+	if item == nil || item.Price == nil {
+		return
+	}
+	price, promotion := effectivePrice(item, promotions)
+	item.EffectivePrice = &price
+	item.Promotion = promotion
+}
+
+// priceItems sets the effective price of copies of inventory items using the promotions running now
+func priceItems(items ...*InventoryItem) error {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return fmt.Errorf("unable to retrieve promotions, sqlite error: %s", dbErr)
+	}
+
+	promotions, err := getActivePromotions(db, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		setEffectivePrice(item, promotions)
+	}
+	return nil
+}
+
+// getPricedInventory is a copy of the inventory snapshot with the effective prices filled in.  The snapshot itself
+// isn't priced because promotions start and end without the inventory changing
+func getPricedInventory() (map[string]*InventoryCategory, error) {
+	inventory, err := getInventory()
+	if err != nil {
+		return nil, err
+	}
+
+	priced := make(map[string]*InventoryCategory, len(inventory))
+	var items []*InventoryItem
+	for name, category := range inventory {
+		c := *category
+		c.Items = make(map[string]*InventoryItem, len(category.Items))
+		for id, item := range category.Items {
+			i := *item
+			c.Items[id] = &i
+			items = append(items, &i)
+		}
+		priced[name] = &c
+	}
+	return priced, priceItems(items...)
+}
+
+// getDetailPriced is getDetail with the effective price filled in
+func getDetailPriced(categoryName, id *string) (*InventoryItem, error) {
+	item, err := getDetail(categoryName, id)
+	if err != nil {
+		return nil, err
+	}
+	return item, priceItems(item)
+}
+
+// getActivePromotions returns the promotions running at the given epoch milliseconds
+func getActivePromotions(q queryer, at int64) ([]*Promotion, error) {
+	return queryPromotions(q, "select * from promotions where starts <= ? and ends > ? order by starts", at, at)
+}
+
+// getPromotions returns the running promotions, or every promotion if all is set
+func getPromotions(all bool) ([]*Promotion, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve promotions, sqlite error: %s", dbErr)
+	}
+
+	if all {
+		return queryPromotions(db, "select * from promotions order by starts desc")
+	}
+	return getActivePromotions(db, time.Now().UnixMilli())
+}
+
+func queryPromotions(q queryer, query string, args ...any) ([]*Promotion, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := []*Promotion{}
+	for rows.Next() {
+		promotion := Promotion{}
+		if err := rows.Scan(&promotion.ID, &promotion.Name, &promotion.Kind, &promotion.Amount, &promotion.SeedID,
+			&promotion.Category, &promotion.Starts, &promotion.Ends, &promotion.Created); err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, &promotion)
+	}
+	return promotions, rows.Err()
+}
+
+// addPromotion schedules a promotion, the prices change when it starts without the inventory being reloaded
+func addPromotion(sessionID *string, data interface{}) (*Promotion, error) {
+	var promotion Promotion
+	if err := unmarshalData(data, &promotion); err != nil {
+		return nil, err
+	}
+
+	if promotion.Name == nil || strings.TrimSpace(*promotion.Name) == "" {
+		return nil, errors.New("a promotion requires a name")
+	}
+	if err := validateDiscount(promotion.Kind, promotion.Amount); err != nil {
+		return nil, err
+	}
+	if (promotion.SeedID == nil) == (promotion.Category == nil) {
+		return nil, errors.New("a promotion applies to either a seedID or a category")
+	}
+	if promotion.SeedID != nil {
+		if _, err := findItem(nil, promotion.SeedID); err != nil {
+			return nil, err
+		}
+	}
+	if promotion.Category != nil && !categoryExists(promotion.Category) {
+		return nil, fmt.Errorf("category %s does not exist", *promotion.Category)
+	}
+	if promotion.Starts == nil || promotion.Ends == nil || *promotion.Ends <= *promotion.Starts {
+		return nil, errors.New("a promotion requires starts and ends with ends after starts")
+	}
+
+	id := uuid.New().String()
+	created := time.Now().UnixMilli()
+	promotion.ID = &id
+	promotion.Created = &created
+
+	promotions := pricingTables["promotions"]
+	if _, err := promotions.Exec(promotions.InsertSQL, promotion.ID, promotion.Name, promotion.Kind, promotion.Amount,
+		promotion.SeedID, promotion.Category, promotion.Starts, promotion.Ends, promotion.Created); err != nil {
+		return nil, err
+	}
+
+	log.Infof("promotion %s scheduled from %s to %s", *promotion.Name, time.UnixMilli(*promotion.Starts).Format(time.RFC3339),
+		time.UnixMilli(*promotion.Ends).Format(time.RFC3339))
+	go notifyAll(addPromotionRequestKey, promotion.Category, promotion.SeedID, sessionID, nil)
+	return &promotion, nil
+}
+
+// removePromotion ends a promotion early or cancels one that hasn't started
+func removePromotion(sessionID, id *string) (*Promotion, error) {
+	if id == nil {
+		return nil, errors.New("no promotion id supplied, cannot remove")
+	}
+
+	promotions := pricingTables["promotions"]
+	rows, err := promotions.Exec(promotions.DeleteSQL, id)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("promotion %s not found", *id)
+	}
+
+	go notifyAll(removePromotionRequestKey, nil, nil, sessionID, nil)
+	return &Promotion{ID: id}, nil
+}
+
+// getPriceHistory returns the prices a seed has been set to, newest first
+func getPriceHistory(id *string) ([]*PriceChange, error) {
+	if id == nil {
+		return nil, errors.New("no id supplied, cannot retrieve price history")
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve price history, sqlite error: %s", dbErr)
+	}
+
+	rows, err := db.Query("select seedID, price, changed from priceHistory where seedID = ? order by changed desc, rowid desc", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []*PriceChange{}
+	for rows.Next() {
+		change := PriceChange{}
+		if err := rows.Scan(&change.SeedID, &change.Price, &change.Changed); err != nil {
+			return nil, err
+		}
+		history = append(history, &change)
+	}
+	return history, rows.Err()
+}
+
+// normalizeCouponCode makes coupon codes case insensitive
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// addCoupon creates a coupon code, it starts now unless starts is set
+func addCoupon(data interface{}) (*Coupon, error) {
+	var coupon Coupon
+	if err := unmarshalData(data, &coupon); err != nil {
+		return nil, err
+	}
+
+	if coupon.Code == nil || normalizeCouponCode(*coupon.Code) == "" {
+		return nil, errors.New("a coupon requires a code")
+	}
+	if err := validateDiscount(coupon.Kind, coupon.Amount); err != nil {
+		return nil, err
+	}
+	if coupon.MaxRedemptions != nil && *coupon.MaxRedemptions <= 0 {
+		return nil, errors.New("maxRedemptions must be greater than 0")
+	}
+
+	code := normalizeCouponCode(*coupon.Code)
+	created := time.Now().UnixMilli()
+	redemptions := 0
+	coupon.Code = &code
+	coupon.Created = &created
+	coupon.Redemptions = &redemptions
+	if coupon.Starts == nil {
+		coupon.Starts = &created
+	}
+	if coupon.Ends != nil && *coupon.Ends <= *coupon.Starts {
+		return nil, errors.New("a coupon must end after it starts")
+	}
+
+	coupons := pricingTables["coupons"]
+	if _, err := coupons.Exec(coupons.InsertSQL, coupon.Code, coupon.Kind, coupon.Amount, coupon.Starts, coupon.Ends,
+		coupon.MaxRedemptions, coupon.Created); err != nil {
+		return nil, fmt.Errorf("unable to add coupon %s.  Error: %s", code, err)
+	}
+	return &coupon, nil
+}
+
+// removeCoupon deletes a coupon, the orders it was redeemed on keep their discount
+func removeCoupon(code *string) (*Coupon, error) {
+	if code == nil {
+		return nil, errors.New("no coupon code supplied, cannot remove")
+	}
+
+	normalized := normalizeCouponCode(*code)
+	coupons := pricingTables["coupons"]
+	rows, err := coupons.Exec(coupons.DeleteSQL, normalized)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("coupon %s not found", normalized)
+	}
+	return &Coupon{Code: &normalized}, nil
+}
+
+// getCoupons returns every coupon, newest first
+func getCoupons() ([]*Coupon, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve coupons, sqlite error: %s", dbErr)
+	}
+
+	rows, err := db.Query("select * from coupons order by created desc")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coupons := []*Coupon{}
+	for rows.Next() {
+		coupon := Coupon{}
+		if err := rows.Scan(&coupon.Code, &coupon.Kind, &coupon.Amount, &coupon.Starts, &coupon.Ends, &coupon.MaxRedemptions,
+			&coupon.Redemptions, &coupon.Created); err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, &coupon)
+	}
+	return coupons, rows.Err()
+}
+
+// redeemCoupon takes the coupon's discount off the order total as part of the order's transaction, if the order fails
+// the redemption is rolled back with it.  The redemption has to be recorded with recordRedemption once the order is
+func redeemCoupon(tx *sql.Tx, couponCode *string, order *Order) error {
+	code := normalizeCouponCode(*couponCode)
+	coupon := Coupon{}
+	if err := tx.QueryRow("select kind, amount, starts, ends from coupons where code = ?", code).
+		Scan(&coupon.Kind, &coupon.Amount, &coupon.Starts, &coupon.Ends); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("coupon %s not found", code)
+		}
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	if now < *coupon.Starts || (coupon.Ends != nil && now >= *coupon.Ends) {
+		return fmt.Errorf("coupon %s is not active", code)
+	}
+
+	coupons := pricingTables["coupons"]
+	rows, err := coupons.ExecTx(tx, coupons.UpdateSQL, code)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("coupon %s has been fully redeemed", code)
+	}
+
+	total := applyDiscount(*coupon.Kind, *coupon.Amount, *order.Total)
+	discount := *order.Total - total
+	order.Total = &total
+	order.Coupon = &code
+	order.Discount = &discount
+	return nil
+}
+
+func recordRedemption(tx *sql.Tx, order *Order) error {
+	redemptions := pricingTables["couponRedemptions"]
+	_, err := redemptions.ExecTx(tx, redemptions.InsertSQL, order.Coupon, order.ID, order.User, order.Discount, order.Created)
+	return err
+}
+
+// couponFromData pulls the optional coupon code out of the purchase or checkout request data
+func couponFromData(data interface{}) (*string, error) {
+	var request couponRequest
+	if data == nil {
+		return nil, nil
+	}
+	if err := unmarshalData(data, &request); err != nil {
+		return nil, err
+	}
+	return request.Coupon, nil
+}
+
+func unmarshalData(data, v interface{}) error {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
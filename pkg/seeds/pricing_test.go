@@ -0,0 +1,218 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscounts(t *testing.T) {
+	// percent discounts are in hundredths of a percent and round to the nearest cent
+	require.Equal(t, Money(360), applyDiscount(percentDiscount, 1000, 400))
+	require.Equal(t, Money(325), applyDiscount(fixedDiscount, 75, 400))
+	require.Equal(t, Money(0), applyDiscount(fixedDiscount, 500, 400))
+	require.Equal(t, Money(297), applyDiscount(percentDiscount, 3333, 445))
+
+	category, seedID := "Herb", uuid.New().String()
+	item := &InventoryItem{ID: &seedID, Category: &category, Price: moneyPointer(400)}
+	percent, fixed := percentDiscount, fixedDiscount
+	categoryPromotion := &Promotion{Kind: &percent, Amount: moneyPointer(1000), Category: &category}
+	seedPromotion := &Promotion{Kind: &fixed, Amount: moneyPointer(100), SeedID: &seedID}
+
+	price, promotion := effectivePrice(item, nil)
+	require.Equal(t, Money(400), price)
+	require.Nil(t, promotion)
+
+	// promotions don't stack, the lowest price wins
+	price, promotion = effectivePrice(item, []*Promotion{categoryPromotion, seedPromotion})
+	require.Equal(t, Money(300), price)
+	require.Equal(t, seedPromotion, promotion)
+
+	require.Error(t, validateDiscount(&percent, moneyPointer(12000)))
+	require.Error(t, validateDiscount(&fixed, moneyPointer(0)))
+}
+
+func moneyPointer(m Money) *Money {
+	return &m
+}
+
+func TestPricingFunctions(t *testing.T) {
+	initInventoryTest()
+	user := uuid.New().String()
+	item, err := addInventory(nil, map[string]interface{}{
+		"category":       "Pepper",
+		"genus":          "Capsicum",
+		"species":        "annuum",
+		"cultivar":       "Pricing Test",
+		"commonName":     "Bell",
+		"description":    "Pricing test pepper",
+		"price":          4.00,
+		"perPacketCount": 20,
+		"packets":        10,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := removeInventory(nil, item.Category, item.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("Test the price history", func(t *testing.T) {
+		history, err := getPriceHistory(item.ID)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		require.Equal(t, Money(400), *history[0].Price)
+
+		_, err = restockInventory(nil, item.Category, item.ID, map[string]interface{}{"quantity": 1})
+		require.NoError(t, err)
+		history, err = getPriceHistory(item.ID)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+
+		_, err = updateInventory(nil, item.Category, item.ID, map[string]interface{}{
+			"category":       "Pepper",
+			"genus":          "Capsicum",
+			"species":        "annuum",
+			"cultivar":       "Pricing Test",
+			"commonName":     "Bell",
+			"description":    "Pricing test pepper",
+			"price":          5.00,
+			"perPacketCount": 20,
+			"packets":        11,
+		})
+		require.NoError(t, err)
+
+		history, err = getPriceHistory(item.ID)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		require.Equal(t, Money(500), *history[0].Price)
+	})
+
+	t.Run("Test promotions set the effective price", func(t *testing.T) {
+		now := time.Now().UnixMilli()
+		_, err := addPromotion(nil, map[string]interface{}{
+			"name": "Both", "kind": percentDiscount, "amount": 10, "seedID": *item.ID, "category": "Pepper",
+			"starts": now - 1000, "ends": now + 60000,
+		})
+		require.Error(t, err)
+
+		future, err := addPromotion(nil, map[string]interface{}{
+			"name": "Next week", "kind": percentDiscount, "amount": 50, "seedID": *item.ID,
+			"starts": now + 7*24*3600000, "ends": now + 8*24*3600000,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_, err := removePromotion(nil, future.ID)
+			require.NoError(t, err)
+		})
+
+		sale, err := addPromotion(nil, map[string]interface{}{
+			"name": "Pepper sale", "kind": fixedDiscount, "amount": "1.25", "seedID": *item.ID,
+			"starts": now - 1000, "ends": now + 60000,
+		})
+		require.NoError(t, err)
+
+		detail, err := getDetailPriced(item.Category, item.ID)
+		require.NoError(t, err)
+		require.Equal(t, Money(500), *detail.Price)
+		require.Equal(t, Money(375), *detail.EffectivePrice)
+		require.Equal(t, *sale.ID, *detail.Promotion.ID)
+
+		inventory, err := getPricedInventory()
+		require.NoError(t, err)
+		require.Equal(t, Money(375), *inventory["Pepper"].Items[*item.ID].EffectivePrice)
+
+		// the search filters on the effective price rather than the list price
+		query := "pricing"
+		result, err := search(&SearchRequest{Query: &query, MaxPrice: moneyPointer(400)})
+		require.NoError(t, err)
+		require.Len(t, result.Items, 1)
+		require.Equal(t, Money(375), *result.Items[0].EffectivePrice)
+		result, err = search(&SearchRequest{Query: &query, MinPrice: moneyPointer(400)})
+		require.NoError(t, err)
+		require.Empty(t, result.Items)
+
+		// the snapshot itself is never priced
+		snapshot, err := getInventory()
+		require.NoError(t, err)
+		require.Nil(t, snapshot["Pepper"].Items[*item.ID].EffectivePrice)
+
+		purchased, err := purchase(nil, &user, map[string]interface{}{"category": "Pepper", "id": *item.ID, "quantity": 2})
+		require.NoError(t, err)
+		require.Equal(t, Money(375), *purchased.EffectivePrice)
+
+		orders, err := getUserOrders(&user, &orderFilter{})
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.Equal(t, Money(375), *orders[0].Lines[0].Price)
+		require.Equal(t, Money(750), *orders[0].Total)
+		require.Equal(t, defaultCurrency, *orders[0].Currency)
+
+		_, err = removePromotion(nil, sale.ID)
+		require.NoError(t, err)
+		detail, err = getDetailPriced(item.Category, item.ID)
+		require.NoError(t, err)
+		require.Equal(t, Money(500), *detail.EffectivePrice)
+		require.Nil(t, detail.Promotion)
+	})
+
+	t.Run("Test coupons are redeemed at purchase", func(t *testing.T) {
+		code := uuid.New().String()[:8]
+		coupon, err := addCoupon(map[string]interface{}{"code": code, "kind": percentDiscount, "amount": 20, "maxRedemptions": 1})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_, err := removeCoupon(coupon.Code)
+			require.NoError(t, err)
+		})
+
+		_, err = addCoupon(map[string]interface{}{"code": code, "kind": percentDiscount, "amount": 20})
+		require.Error(t, err)
+
+		_, err = addToCart(&user, map[string]interface{}{"id": *item.ID, "quantity": 2})
+		require.NoError(t, err)
+
+		// codes are case insensitive
+		order, err := checkout(nil, &user, &code)
+		require.NoError(t, err)
+		require.Equal(t, *coupon.Code, *order.Coupon)
+		require.Equal(t, Money(200), *order.Discount)
+		require.Equal(t, Money(800), *order.Total)
+
+		orders, err := getUserOrders(&user, &orderFilter{})
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		for _, o := range orders {
+			if *o.ID == *order.ID {
+				require.Equal(t, *coupon.Code, *o.Coupon)
+			}
+		}
+
+		// the coupon is used up so the purchase fails and nothing is taken from the inventory
+		before, err := findItem(item.Category, item.ID)
+		require.NoError(t, err)
+		_, err = purchase(nil, &user, map[string]interface{}{"category": "Pepper", "id": *item.ID, "quantity": 1, "coupon": code})
+		require.Error(t, err)
+		after, err := findItem(item.Category, item.ID)
+		require.NoError(t, err)
+		require.Equal(t, *before.Packets, *after.Packets)
+
+		missing := "NOSUCHCOUPON"
+		_, err = purchase(nil, &user, map[string]interface{}{"category": "Pepper", "id": *item.ID, "quantity": 1, "coupon": missing})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,409 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+const (
+	defaultSearchLimit = 25
+	maxSearchLimit     = 100
+)
+
+var (
+	// FTS5 is only compiled into sqlite with the sqlite_fts5 build tag, FTS4 is always available and is used without it.
+	// The index is created once so the module it was created with is the one it keeps
+	searchTableSQL = map[string]string{
+		"fts5": `CREATE VIRTUAL TABLE seedsearch USING fts5(
+			id UNINDEXED, commonName, cultivar, genus, species, description)`,
+		"fts4": `CREATE VIRTUAL TABLE seedsearch USING fts4(
+			id, commonName, cultivar, genus, species, description, notindexed=id)`,
+	}
+
+	// the triggers keep the search index in step with the seeds table
+	searchTriggers = []string{
+		`CREATE TRIGGER IF NOT EXISTS seedsearchinsert AFTER INSERT ON seeds BEGIN
+			INSERT INTO seedsearch values(new.id, new.commonName, new.cultivar, new.genus, new.species, new.description);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS seedsearchupdate AFTER UPDATE OF commonName, cultivar, genus, species, description ON seeds BEGIN
+			DELETE FROM seedsearch where id = old.id;
+			INSERT INTO seedsearch values(new.id, new.commonName, new.cultivar, new.genus, new.species, new.description);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS seedsearchdelete AFTER DELETE ON seeds BEGIN
+			DELETE FROM seedsearch where id = old.id;
+		END`,
+	}
+
+	// sortColumns maps the sort names accepted by the API to the columns they order by, price is the effective price
+	sortColumns = map[string]string{
+		"commonName": "s.commonName",
+		"cultivar":   "coalesce(s.cultivar, '')",
+		"category":   "s.category",
+		"price":      "s.effectivePrice",
+		"packets":    "s.packets",
+	}
+)
+
+// SearchRequest is the query, filters, sort and page of a catalog search
+type SearchRequest struct {
+	Query    *string `json:"q,omitempty"`
+	Category *string `json:"category,omitempty"`
+	Hybrid   *bool   `json:"hybrid,omitempty"`
+	MinPrice *Money  `json:"minPrice,omitempty"`
+	MaxPrice *Money  `json:"maxPrice,omitempty"`
+	InStock  *bool   `json:"inStock,omitempty"`
+	// Sort is one of commonName, cultivar, category, price or packets, prefixed with - for descending order
+	Sort   *string `json:"sort,omitempty"`
+	Limit  *int    `json:"limit,omitempty"`
+	Cursor *string `json:"cursor,omitempty"`
+}
+
+// SearchResult is a page of the catalog, NextCursor is set when there are more results
+type SearchResult struct {
+	Items      []*InventoryItem `json:"items"`
+	NextCursor *string          `json:"nextCursor,omitempty"`
+}
+
+// searchCursor is the position of the last item on a page, it is handed to the client base64 encoded
+type searchCursor struct {
+	Sort  string      `json:"s"`
+	Value interface{} `json:"v"`
+	ID    string      `json:"id"`
+}
+
+// setupSearch creates the full text index the first time and makes sure the triggers that keep it in step with the
+// seeds table exist.  FTS5 needs the sqlite_fts5 build tag, a binary built without it indexes with FTS4 and cannot open
+// an index that was created with FTS5
+func setupSearch() error {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return dbErr
+	}
+
+	var exists int
+	if err := db.QueryRow("select count(*) from sqlite_master where type = 'table' and name = 'seedsearch'").Scan(&exists); err != nil {
+		return err
+	}
+
+	// the index is created outside of a transaction, a create that fails for want of fts5 still leaves the table behind
+	// in the transaction it was part of
+	if exists == 0 {
+		module := "fts5"
+		if _, err := db.Exec(searchTableSQL[module]); err != nil {
+			if !strings.Contains(err.Error(), "no such module") {
+				return err
+			}
+			module = "fts4"
+			log.Warn("fts5 is not compiled in, build with the sqlite_fts5 tag, the seed search index is created with fts4")
+			if _, err := db.Exec(searchTableSQL[module]); err != nil {
+				return err
+			}
+		}
+		log.Infof("seed search index created with %s", module)
+	}
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer configs.RollbackHelper(tx)
+
+	// the index is only rebuilt if it has drifted from the seeds table, a new index is always empty
+	var indexed, seeds int
+	if err := tx.QueryRow("select (select count(*) from seedsearch), (select count(*) from seeds)").Scan(&indexed, &seeds); err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			return fmt.Errorf("the seed search index needs the sqlite_fts5 build tag.  Error: %s", err)
+		}
+		return err
+	}
+
+	statements := searchTriggers
+	if indexed != seeds {
+		statements = append([]string{
+			"DELETE FROM seedsearch",
+			"INSERT INTO seedsearch select id, commonName, cultivar, genus, species, description from seeds",
+		}, searchTriggers...)
+		log.Debugf("rebuilding the seed search index, %d of %d seeds are indexed", indexed, seeds)
+	}
+	if err := execStatements(tx, statements); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// search queries the catalog, the results are read from the database rather than the inventory snapshot
+func search(request *SearchRequest) (*SearchResult, error) {
+	if request == nil {
+		request = &SearchRequest{}
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to search inventory, sqlite error: %s", dbErr)
+	}
+
+	query, args, limit, err := request.toSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &SearchResult{Items: []*InventoryItem{}}
+	var sortValues []interface{}
+	for rows.Next() {
+		ii := InventoryItem{}
+		// the sort value is kept as sqlite returns it so the cursor compares exactly whatever the sort column's type
+		var sortValue interface{}
+		if err := rows.Scan(&ii.ID, &ii.Category, &ii.Genus, &ii.Species, &ii.Cultivar, &ii.CommonName, &ii.Description, &ii.Hybrid,
+			&ii.Price, &ii.PerPacketCount, &ii.Packets, &ii.Image, &ii.ReorderThreshold, &ii.Currency, &ii.EffectivePrice,
+			&sortValue); err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, &ii)
+		sortValues = append(sortValues, sortValue)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// one more row than the limit is requested to know if there is another page
+	if len(result.Items) > limit {
+		result.Items = result.Items[:limit]
+		cursor, err := encodeCursor(&searchCursor{
+			Sort:  request.sortKey(),
+			Value: sortValues[limit-1],
+			ID:    *result.Items[limit-1].ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.NextCursor = &cursor
+	}
+	return result, priceItems(result.Items...)
+}
+
+func (request *SearchRequest) toSQL() (string, []any, int, error) {
+	sortName, descending := request.sort()
+	column, ok := sortColumns[sortName]
+	if !ok {
+		return "", nil, 0, fmt.Errorf("cannot sort by %s", sortName)
+	}
+
+	limit := defaultSearchLimit
+	if request.Limit != nil {
+		if *request.Limit <= 0 || *request.Limit > maxSearchLimit {
+			return "", nil, 0, fmt.Errorf("limit must be between 1 and %d", maxSearchLimit)
+		}
+		limit = *request.Limit
+	}
+
+	var clauses []string
+	var args []any
+	if request.Query != nil {
+		if match := matchExpression(*request.Query); match != "" {
+			clauses = append(clauses, "s.id in (select id from seedsearch where seedsearch match ?)")
+			args = append(args, match)
+		}
+	}
+	if request.Category != nil && *request.Category != "" {
+		clauses = append(clauses, "s.category = ?")
+		args = append(args, *request.Category)
+	}
+	if request.Hybrid != nil {
+		clauses = append(clauses, "s.hybrid = ?")
+		args = append(args, *request.Hybrid)
+	}
+	if request.MinPrice != nil {
+		clauses = append(clauses, "s.effectivePrice >= ?")
+		args = append(args, *request.MinPrice)
+	}
+	if request.MaxPrice != nil {
+		clauses = append(clauses, "s.effectivePrice <= ?")
+		args = append(args, *request.MaxPrice)
+	}
+	if request.InStock != nil {
+		if *request.InStock {
+			clauses = append(clauses, "s.packets > 0")
+		} else {
+			clauses = append(clauses, "s.packets <= 0")
+		}
+	}
+
+	direction, comparison := "asc", ">"
+	if descending {
+		direction, comparison = "desc", "<"
+	}
+
+	if request.Cursor != nil && *request.Cursor != "" {
+		cursor, err := decodeCursor(*request.Cursor)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		if cursor.Sort != request.sortKey() {
+			return "", nil, 0, errors.New("the cursor does not match the requested sort")
+		}
+		clauses = append(clauses, fmt.Sprintf("(%[1]s %[2]s ? or (%[1]s = ? and s.id %[2]s ?))", column, comparison))
+		args = append(args, cursor.Value, cursor.Value, cursor.ID)
+	}
+
+	// the price filters and sort are on the price the seeds sell for, with the promotions running now
+	query := fmt.Sprintf("select s.*, %s from (select s.*, %s as effectivePrice from seeds s) s", column, effectivePriceSQL)
+	if len(clauses) > 0 {
+		query = fmt.Sprintf("%s where %s", query, strings.Join(clauses, " and "))
+	}
+	query = fmt.Sprintf("%s order by %s %s, s.id %s limit %d", query, column, direction, direction, limit+1)
+	return query, args, limit, nil
+}
+
+// sort returns the sort column name and if it's descending, the default is commonName ascending
+func (request *SearchRequest) sort() (string, bool) {
+	if request.Sort == nil || *request.Sort == "" {
+		return "commonName", false
+	}
+	if strings.HasPrefix(*request.Sort, "-") {
+		return strings.TrimPrefix(*request.Sort, "-"), true
+	}
+	return *request.Sort, false
+}
+
+// sortKey is the requested sort as it's recorded in the cursor
+func (request *SearchRequest) sortKey() string {
+	sortName, descending := request.sort()
+	if descending {
+		return "-" + sortName
+	}
+	return sortName
+}
+
+func encodeCursor(cursor *searchCursor) (string, error) {
+	bytes, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func decodeCursor(encoded string) (*searchCursor, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var cursor searchCursor
+	if err := json.Unmarshal(bytes, &cursor); err != nil || cursor.ID == "" {
+		return nil, errors.New("invalid cursor")
+	}
+	return &cursor, nil
+}
+
+// matchExpression turns the user's text into prefix terms that all have to match, anything that could be read as
+// full text query syntax is dropped
+func matchExpression(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		term := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, word)
+		if term != "" {
+			terms = append(terms, term+"*")
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// searchRequestFromData converts the WebSocket request data into a search request
+func searchRequestFromData(data interface{}) (*SearchRequest, error) {
+	request := SearchRequest{}
+	if data != nil {
+		bytes, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(bytes, &request); err != nil {
+			return nil, err
+		}
+	}
+	return &request, nil
+}
+
+// searchRequestFromQuery converts the REST query parameters into a search request
+func searchRequestFromQuery(values url.Values) (*SearchRequest, error) {
+	request := SearchRequest{}
+	for key, field := range map[string]**string{
+		"q":        &request.Query,
+		"category": &request.Category,
+		"sort":     &request.Sort,
+		"cursor":   &request.Cursor,
+	} {
+		if value := values.Get(key); value != "" {
+			*field = &value
+		}
+	}
+
+	for key, field := range map[string]**bool{
+		"hybrid":  &request.Hybrid,
+		"inStock": &request.InStock,
+	} {
+		if value := values.Get(key); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be true or false", key)
+			}
+			*field = &b
+		}
+	}
+
+	for key, field := range map[string]**Money{
+		"minPrice": &request.MinPrice,
+		"maxPrice": &request.MaxPrice,
+	} {
+		if value := values.Get(key); value != "" {
+			price, err := ParseMoney(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an amount with at most 2 decimal places", key)
+			}
+			*field = &price
+		}
+	}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errors.New("limit must be a number")
+		}
+		request.Limit = &limit
+	}
+	return &request, nil
+}
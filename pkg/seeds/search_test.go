@@ -0,0 +1,159 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/utils"
+)
+
+func TestSearchFunctions(t *testing.T) {
+	initInventoryTest()
+
+	t.Run("Test full text search", func(t *testing.T) {
+		query := "marz"
+		result, err := search(&SearchRequest{Query: &query})
+		require.NoError(t, err)
+		require.Len(t, result.Items, 1)
+		require.Equal(t, "San Marzano", *result.Items[0].Cultivar)
+
+		// query syntax is stripped rather than passed to sqlite
+		query = `capsicum" OR (`
+		result, err = search(&SearchRequest{Query: &query})
+		require.NoError(t, err)
+		require.NotEmpty(t, result.Items)
+		for _, item := range result.Items {
+			require.Equal(t, "Capsicum", *item.Genus)
+		}
+	})
+
+	t.Run("Test the index follows inventory changes", func(t *testing.T) {
+		item, err := addInventory(nil, map[string]interface{}{
+			"category":       "Herb",
+			"genus":          "Thymus",
+			"species":        "vulgaris",
+			"cultivar":       "Searchable",
+			"commonName":     "Thyme",
+			"description":    "Search index test thyme",
+			"price":          2.50,
+			"perPacketCount": 100,
+			"packets":        0,
+		})
+		require.NoError(t, err)
+
+		query := "searchable"
+		result, err := search(&SearchRequest{Query: &query})
+		require.NoError(t, err)
+		require.Len(t, result.Items, 1)
+
+		result, err = search(&SearchRequest{Query: &query, InStock: utils.BoolPointer(true)})
+		require.NoError(t, err)
+		require.Empty(t, result.Items)
+
+		_, err = removeInventory(nil, item.Category, item.ID)
+		require.NoError(t, err)
+
+		result, err = search(&SearchRequest{Query: &query})
+		require.NoError(t, err)
+		require.Empty(t, result.Items)
+	})
+
+	t.Run("Test the index is kept across restarts and rebuilt when it drifts", func(t *testing.T) {
+		require.NoError(t, setupSearch())
+		query := "marz"
+		result, err := search(&SearchRequest{Query: &query})
+		require.NoError(t, err)
+		require.Len(t, result.Items, 1)
+
+		db, err := configs.GetSQLiteConnection()
+		require.NoError(t, err)
+		_, err = db.Exec("DELETE FROM seedsearch where id = ?", *result.Items[0].ID)
+		require.NoError(t, err)
+		result, err = search(&SearchRequest{Query: &query})
+		require.NoError(t, err)
+		require.Empty(t, result.Items)
+
+		require.NoError(t, setupSearch())
+		result, err = search(&SearchRequest{Query: &query})
+		require.NoError(t, err)
+		require.Len(t, result.Items, 1)
+	})
+
+	t.Run("Test filters from query parameters", func(t *testing.T) {
+		request, err := searchRequestFromQuery(url.Values{
+			"category": {"Tomato"},
+			"hybrid":   {"true"},
+			"maxPrice": {"4"},
+		})
+		require.NoError(t, err)
+
+		result, err := search(request)
+		require.NoError(t, err)
+		require.NotEmpty(t, result.Items)
+		for _, item := range result.Items {
+			require.Equal(t, "Tomato", *item.Category)
+			require.True(t, *item.Hybrid)
+			require.LessOrEqual(t, *item.Price, Money(400))
+		}
+
+		_, err = searchRequestFromQuery(url.Values{"hybrid": {"maybe"}})
+		require.Error(t, err)
+	})
+
+	t.Run("Test sorting and cursor pagination", func(t *testing.T) {
+		maxLimit := maxSearchLimit
+		all, err := search(&SearchRequest{Limit: &maxLimit})
+		require.NoError(t, err)
+
+		sort := "-price"
+		limit := 3
+		request := &SearchRequest{Sort: &sort, Limit: &limit}
+		var paged []*InventoryItem
+		for {
+			result, err := search(request)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(result.Items), limit)
+			paged = append(paged, result.Items...)
+			if result.NextCursor == nil {
+				break
+			}
+			request.Cursor = result.NextCursor
+		}
+
+		require.Len(t, paged, len(all.Items))
+		seen := make(map[string]struct{})
+		for i, item := range paged {
+			seen[*item.ID] = struct{}{}
+			if i > 0 {
+				require.GreaterOrEqual(t, *paged[i-1].Price, *item.Price)
+			}
+		}
+		require.Len(t, seen, len(paged))
+
+		// a cursor can't be reused with a different sort
+		other := "packets"
+		_, err = search(&SearchRequest{Sort: &other, Cursor: request.Cursor})
+		require.Error(t, err)
+
+		bad := "price;drop table seeds"
+		_, err = search(&SearchRequest{Sort: &bad})
+		require.Error(t, err)
+	})
+}
@@ -15,6 +15,7 @@
 package seeds
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -34,12 +35,65 @@ const (
 	getDetailRequestKey    = "getDetail"
 	purchaseRequestKey     = "purchase"
 	addInventoryRequestKey = "addInventory"
+
+	updateInventoryRequestKey  = "updateInventory"
+	restockInventoryRequestKey = "restockInventory"
+	removeInventoryRequestKey  = "removeInventory"
+
+	getCategoriesRequestKey  = "getCategories"
+	addCategoryRequestKey    = "addCategory"
+	updateCategoryRequestKey = "updateCategory"
+	removeCategoryRequestKey = "removeCategory"
+
+	getOrdersRequestKey    = "getOrders"
+	getAllOrdersRequestKey = "getAllOrders"
+
+	getCartRequestKey        = "getCart"
+	addToCartRequestKey      = "addToCart"
+	updateCartRequestKey     = "updateCart"
+	removeFromCartRequestKey = "removeFromCart"
+	checkoutRequestKey       = "checkout"
+
+	searchRequestKey = "search"
+
+	stockAlertRequestKey            = "stockAlert"
+	getStockAlertsRequestKey        = "getStockAlerts"
+	acknowledgeStockAlertRequestKey = "acknowledgeStockAlert"
+
+	uploadImageRequestKey = "uploadImage"
+
+	importInventoryRequestKey = "importInventory"
+	exportInventoryRequestKey = "exportInventory"
+
+	getPriceHistoryRequestKey = "getPriceHistory"
+	getPromotionsRequestKey   = "getPromotions"
+	addPromotionRequestKey    = "addPromotion"
+	removePromotionRequestKey = "removePromotion"
+	getCouponsRequestKey      = "getCoupons"
+	addCouponRequestKey       = "addCoupon"
+	removeCouponRequestKey    = "removeCoupon"
+
+	getGrowGuideRequestKey        = "getGrowGuide"
+	updateGrowGuideRequestKey     = "updateGrowGuide"
+	getPlantingCalendarRequestKey = "getPlantingCalendar"
+
+	validateLayoutRequestKey      = "validateLayout"
+	getPlantingRulesRequestKey    = "getPlantingRules"
+	updateCompanionRuleRequestKey = "updateCompanionRule"
+	removeCompanionRuleRequestKey = "removeCompanionRule"
+	updateSpacingRuleRequestKey   = "updateSpacingRule"
+	removeSpacingRuleRequestKey   = "removeSpacingRule"
 )
 
+type stockAlertsRequest struct {
+	All *bool `json:"all,omitempty"`
+}
+
 type purchaseRequest struct {
 	Category *string      `json:"category,omitempty"`
 	ID       *string      `json:"id,omitempty"`
 	Quantity *interface{} `json:"quantity,omitempty"`
+	Coupon   *string      `json:"coupon,omitempty"`
 }
 
 // Init is called by the launch in the pkg/commands/root.go
@@ -53,24 +107,74 @@ func Init() error {
 	}
 
 	// warm up the inventory cache
-	if _, err := getInventory(); err != nil {
+	inventory, err := getInventory()
+	if err != nil {
 		return err
 	}
 
-	log.Debugf("%d categories in inventory cache", len(inventoryCache))
+	log.Debugf("%d categories in inventory cache", len(inventory))
+
+	startStockChecker()
 	return nil
 }
 
+// InitTables only makes sure the seed tables exist, it's for the one shot commands that work against the database
+// without serving it
+func InitTables() error {
+	return setupTables()
+}
+
 func handleWebsocketRequest(request, response *configs.WsMessage) {
 	if request.Type != nil && response != nil {
 		var err error
 		switch *request.Type {
 		case getInventoryRequestKey:
-			response.Data, err = getInventory()
+			response.Data, err = getPricedInventory()
 		case getDetailRequestKey:
-			response.Data, err = getDetail(request.Component, request.SubComponent)
+			response.Data, err = getDetailPriced(request.Component, request.SubComponent)
+		case searchRequestKey:
+			response.Data, err = searchFromRequest(request)
 		case purchaseRequestKey:
-			response.Data, err = purchase(request.SessionID, request.Data)
+			response.Data, err = purchase(request.SessionID, request.User, request.Data)
+		case getOrdersRequestKey:
+			response.Data, err = getOrdersFromRequest(request)
+		case getAllOrdersRequestKey:
+			response.Data, err = getAllOrdersFromRequest(request)
+		case getCartRequestKey:
+			response.Data, err = getCart(request.User)
+		case addToCartRequestKey:
+			response.Data, err = addToCart(request.User, request.Data)
+		case updateCartRequestKey:
+			response.Data, err = updateCart(request.User, request.SubComponent, request.Data)
+		case removeFromCartRequestKey:
+			response.Data, err = removeFromCart(request.User, request.SubComponent)
+		case checkoutRequestKey:
+			response.Data, err = checkoutFromRequest(request)
+		case addInventoryRequestKey, updateInventoryRequestKey, restockInventoryRequestKey, removeInventoryRequestKey:
+			response.Data, err = handleInventoryChange(request)
+		case getCategoriesRequestKey:
+			response.Data, err = getCategories()
+		case addCategoryRequestKey, updateCategoryRequestKey, removeCategoryRequestKey:
+			response.Data, err = handleCategoryChange(request)
+		case getStockAlertsRequestKey, acknowledgeStockAlertRequestKey:
+			response.Data, err = handleStockAlertRequest(request)
+		case getPriceHistoryRequestKey:
+			response.Data, err = getPriceHistory(request.SubComponent)
+		case getPromotionsRequestKey, addPromotionRequestKey, removePromotionRequestKey, getCouponsRequestKey, addCouponRequestKey,
+			removeCouponRequestKey:
+			response.Data, err = handlePricingRequest(request)
+		case getGrowGuideRequestKey:
+			response.Data, err = getGrowGuide(request.SubComponent)
+		case updateGrowGuideRequestKey:
+			response.Data, err = updateGrowGuideFromRequest(request)
+		case getPlantingCalendarRequestKey:
+			response.Data, err = getPlantingCalendar(request.User, request.SubComponent, request.Data)
+		case validateLayoutRequestKey:
+			response.Data, err = validateLayout(request.Data)
+		case getPlantingRulesRequestKey:
+			response.Data, err = getPlantingRules()
+		case updateCompanionRuleRequestKey, removeCompanionRuleRequestKey, updateSpacingRuleRequestKey, removeSpacingRuleRequestKey:
+			response.Data, err = handlePlantingRuleChange(request)
 		default:
 			err = fmt.Errorf("type %s not implemented", *request.Type)
 		}
@@ -91,8 +195,166 @@ func handleWebsocketRequest(request, response *configs.WsMessage) {
 	}
 }
 
+// updateGrowGuideFromRequest is restricted to admins, the seed id is the sub component
+func updateGrowGuideFromRequest(request *configs.WsMessage) (*GrowGuide, error) {
+	if request.IsAdmin == nil || !*request.IsAdmin {
+		return nil, fmt.Errorf("type %s is restricted to admins", *request.Type)
+	}
+	return updateGrowGuide(request.SubComponent, request.Data)
+}
+
+// handlePlantingRuleChange routes the admin only companion and spacing rule maintenance requests, a companion rule
+// is removed by its species as the component and sub component and a spacing rule by its species as the component
+func handlePlantingRuleChange(request *configs.WsMessage) (interface{}, error) {
+	if request.IsAdmin == nil || !*request.IsAdmin {
+		return nil, fmt.Errorf("type %s is restricted to admins", *request.Type)
+	}
+
+	switch *request.Type {
+	case updateCompanionRuleRequestKey:
+		return updateCompanionRule(request.Data)
+	case removeCompanionRuleRequestKey:
+		return removeCompanionRule(request.Component, request.SubComponent)
+	case updateSpacingRuleRequestKey:
+		return updateSpacingRule(request.Data)
+	case removeSpacingRuleRequestKey:
+		return removeSpacingRule(request.Component)
+	}
+	return nil, fmt.Errorf("type %s not implemented", *request.Type)
+}
+
+// handleInventoryChange routes the admin only inventory maintenance requests
+func handleInventoryChange(request *configs.WsMessage) (*InventoryItem, error) {
+	if request.IsAdmin == nil || !*request.IsAdmin {
+		return nil, fmt.Errorf("type %s is restricted to admins", *request.Type)
+	}
+
+	switch *request.Type {
+	case addInventoryRequestKey:
+		return addInventory(request.SessionID, request.Data)
+	case updateInventoryRequestKey:
+		return updateInventory(request.SessionID, request.Component, request.SubComponent, request.Data)
+	case restockInventoryRequestKey:
+		return restockInventory(request.SessionID, request.Component, request.SubComponent, request.Data)
+	case removeInventoryRequestKey:
+		return removeInventory(request.SessionID, request.Component, request.SubComponent)
+	}
+	return nil, fmt.Errorf("type %s not implemented", *request.Type)
+}
+
+func searchFromRequest(request *configs.WsMessage) (*SearchResult, error) {
+	searchRequest, err := searchRequestFromData(request.Data)
+	if err != nil {
+		return nil, err
+	}
+	return search(searchRequest)
+}
+
+func checkoutFromRequest(request *configs.WsMessage) (*Order, error) {
+	couponCode, err := couponFromData(request.Data)
+	if err != nil {
+		return nil, err
+	}
+	return checkout(request.SessionID, request.User, couponCode)
+}
+
+// handlePricingRequest routes the promotion and coupon requests, only the running promotions are available to every user
+func handlePricingRequest(request *configs.WsMessage) (interface{}, error) {
+	isAdmin := request.IsAdmin != nil && *request.IsAdmin
+	var promotionsData promotionsRequest
+	if *request.Type == getPromotionsRequestKey && request.Data != nil {
+		if err := unmarshalData(request.Data, &promotionsData); err != nil {
+			return nil, err
+		}
+	}
+	all := promotionsData.All != nil && *promotionsData.All
+
+	if !isAdmin && (*request.Type != getPromotionsRequestKey || all) {
+		return nil, fmt.Errorf("type %s is restricted to admins", *request.Type)
+	}
+
+	switch *request.Type {
+	case getPromotionsRequestKey:
+		return getPromotions(all)
+	case addPromotionRequestKey:
+		return addPromotion(request.SessionID, request.Data)
+	case removePromotionRequestKey:
+		return removePromotion(request.SessionID, request.Component)
+	case getCouponsRequestKey:
+		return getCoupons()
+	case addCouponRequestKey:
+		return addCoupon(request.Data)
+	case removeCouponRequestKey:
+		return removeCoupon(request.Component)
+	}
+	return nil, fmt.Errorf("type %s not implemented", *request.Type)
+}
+
+func getOrdersFromRequest(request *configs.WsMessage) ([]*Order, error) {
+	filter, err := orderFilterFromData(request.Data)
+	if err != nil {
+		return nil, err
+	}
+	return getUserOrders(request.User, filter)
+}
+
+func getAllOrdersFromRequest(request *configs.WsMessage) ([]*Order, error) {
+	if request.IsAdmin == nil || !*request.IsAdmin {
+		return nil, fmt.Errorf("type %s is restricted to admins", *request.Type)
+	}
+
+	filter, err := orderFilterFromData(request.Data)
+	if err != nil {
+		return nil, err
+	}
+	return getOrders(filter)
+}
+
+// handleStockAlertRequest routes the admin only stock alert requests
+func handleStockAlertRequest(request *configs.WsMessage) (interface{}, error) {
+	if request.IsAdmin == nil || !*request.IsAdmin {
+		return nil, fmt.Errorf("type %s is restricted to admins", *request.Type)
+	}
+
+	switch *request.Type {
+	case getStockAlertsRequestKey:
+		var alertsRequest stockAlertsRequest
+		if request.Data != nil {
+			bytes, err := json.Marshal(request.Data)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(bytes, &alertsRequest); err != nil {
+				return nil, err
+			}
+		}
+		return getStockAlerts(alertsRequest.All != nil && *alertsRequest.All)
+	case acknowledgeStockAlertRequestKey:
+		return acknowledgeStockAlert(request.SessionID, request.User, request.Component)
+	}
+	return nil, fmt.Errorf("type %s not implemented", *request.Type)
+}
+
+// handleCategoryChange routes the admin only category maintenance requests
+func handleCategoryChange(request *configs.WsMessage) (*Category, error) {
+	if request.IsAdmin == nil || !*request.IsAdmin {
+		return nil, fmt.Errorf("type %s is restricted to admins", *request.Type)
+	}
+
+	switch *request.Type {
+	case addCategoryRequestKey:
+		return addCategory(request.SessionID, request.Data)
+	case updateCategoryRequestKey:
+		return updateCategory(request.SessionID, request.Component, request.Data)
+	case removeCategoryRequestKey:
+		return removeCategory(request.SessionID, request.Component)
+	}
+	return nil, fmt.Errorf("type %s not implemented", *request.Type)
+}
+
 func handleRESTRequest(w http.ResponseWriter, r *http.Request) {
-	restURI := strings.TrimPrefix(r.RequestURI, fmt.Sprintf("/REST/v1.0.0/%s/", seedsKey))
+	// the path is used rather than the request uri so query parameters don't end up in the last uri part
+	restURI := strings.TrimPrefix(r.URL.Path, fmt.Sprintf("/REST/v1.0.0/%s/", seedsKey))
 	uriParts := strings.Split(restURI, "/")
 	w.Header().Set("content-type", "application/json")
 	switch r.Method {
@@ -100,6 +362,12 @@ func handleRESTRequest(w http.ResponseWriter, r *http.Request) {
 		getHelper(restURI, uriParts, w, r)
 	case http.MethodPost:
 		postHelper(restURI, uriParts, w, r)
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		if uriParts[0] == updateCartRequestKey || uriParts[0] == removeFromCartRequestKey {
+			cartChangeHelper(restURI, uriParts, w, r)
+			return
+		}
+		inventoryChangeHelper(restURI, uriParts, w, r)
 	default:
 		log.Errorf("%s URI with Method %s not possible", restURI, r.Method)
 		http.Error(w, configs.NotImplementedError, http.StatusNotImplemented)
@@ -108,9 +376,16 @@ func handleRESTRequest(w http.ResponseWriter, r *http.Request) {
 
 func getHelper(restURI string, uriParts []string, w http.ResponseWriter, r *http.Request) {
 	switch uriParts[0] {
+	case searchRequestKey:
+		searchREST(w, r)
 	case getInventoryRequestKey:
+		// query parameters turn the request into a search of the catalog
+		if r.URL.RawQuery != "" {
+			searchREST(w, r)
+			return
+		}
 		if strings.EqualFold(r.Method, http.MethodGet) {
-			data, err := getInventory()
+			data, err := getPricedInventory()
 			if err != nil {
 				log.Error(err)
 				http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
@@ -120,7 +395,7 @@ func getHelper(restURI string, uriParts []string, w http.ResponseWriter, r *http
 		}
 	case getDetailRequestKey:
 		if len(uriParts) >= 2 {
-			data, err := getDetail(&uriParts[1], &uriParts[2])
+			data, err := getDetailPriced(&uriParts[1], &uriParts[2])
 			if err != nil {
 				log.Error(err)
 				http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
@@ -130,22 +405,381 @@ func getHelper(restURI string, uriParts []string, w http.ResponseWriter, r *http
 			return
 		}
 		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+	case getCategoriesRequestKey:
+		data, err := getCategories()
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
+			return
+		}
+		writeRESTResponse(data, w, http.StatusOK)
+	case getOrdersRequestKey, getAllOrdersRequestKey:
+		ordersREST(uriParts[0], w, r)
+	case getStockAlertsRequestKey:
+		if !webservice.IsRESTAdmin(r) {
+			http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+			return
+		}
+		all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+		data, err := getStockAlerts(all)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
+			return
+		}
+		writeRESTResponse(data, w, http.StatusOK)
+	case exportInventoryRequestKey:
+		exportInventoryREST(w, r)
+	case getPriceHistoryRequestKey:
+		if len(uriParts) < 2 {
+			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+			return
+		}
+		data, err := getPriceHistory(&uriParts[1])
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
+			return
+		}
+		writeRESTResponse(data, w, http.StatusOK)
+	case getPromotionsRequestKey:
+		all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+		if all && !webservice.IsRESTAdmin(r) {
+			http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+			return
+		}
+		data, err := getPromotions(all)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
+			return
+		}
+		writeRESTResponse(data, w, http.StatusOK)
+	case getCouponsRequestKey:
+		if !webservice.IsRESTAdmin(r) {
+			http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+			return
+		}
+		data, err := getCoupons()
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
+			return
+		}
+		writeRESTResponse(data, w, http.StatusOK)
+	case getCartRequestKey:
+		data, err := getCart(webservice.GetRESTUser(r))
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+			return
+		}
+		writeRESTResponse(data, w, http.StatusOK)
+	case getGrowGuideRequestKey:
+		if len(uriParts) < 2 {
+			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+			return
+		}
+		data, err := getGrowGuide(&uriParts[1])
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.NotFoundError, http.StatusNotFound)
+			return
+		}
+		writeRESTResponse(data, w, http.StatusOK)
+	case getPlantingCalendarRequestKey:
+		plantingCalendarREST(uriParts, w, r)
 	default:
 		log.Errorf("%s URI with Method %s not possible", restURI, r.Method)
 		http.Error(w, configs.NotImplementedError, http.StatusNotImplemented)
 	}
 }
 
+// plantingCalendarREST handles GET /seeds/getPlantingCalendar[/{id}] with the optional location and year query parameters
+func plantingCalendarREST(uriParts []string, w http.ResponseWriter, r *http.Request) {
+	var seedID *string
+	if len(uriParts) > 1 && uriParts[1] != "" {
+		seedID = &uriParts[1]
+	}
+
+	request := map[string]interface{}{}
+	if location := r.URL.Query().Get("location"); location != "" {
+		request["location"] = location
+	}
+	if value := r.URL.Query().Get("year"); value != "" {
+		year, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+			return
+		}
+		request["year"] = year
+	}
+
+	data, err := getPlantingCalendar(webservice.GetRESTUser(r), seedID, request)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+	writeRESTResponse(data, w, http.StatusOK)
+}
+
+func searchREST(w http.ResponseWriter, r *http.Request) {
+	request, err := searchRequestFromQuery(r.URL.Query())
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+
+	data, err := search(request)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+	writeRESTResponse(data, w, http.StatusOK)
+}
+
+// ordersREST returns the order history, getOrders is limited to the user's own orders and getAllOrders to admins
+func ordersREST(requestType string, w http.ResponseWriter, r *http.Request) {
+	userID := webservice.GetRESTUser(r)
+	if userID == nil {
+		http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+		return
+	}
+
+	filter := orderFilterFromQuery(r.URL.Query())
+	var data []*Order
+	var err error
+	if requestType == getAllOrdersRequestKey {
+		if !webservice.IsRESTAdmin(r) {
+			http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+			return
+		}
+		data, err = getOrders(filter)
+	} else {
+		data, err = getUserOrders(userID, filter)
+	}
+
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+	writeRESTResponse(data, w, http.StatusOK)
+}
+
 func postHelper(restURI string, uriParts []string, w http.ResponseWriter, r *http.Request) {
 	switch uriParts[0] {
 	case purchaseRequestKey:
 		purchaseREST(w, r)
+	case uploadImageRequestKey:
+		uploadImageREST(w, r)
+	case importInventoryRequestKey:
+		importInventoryREST(w, r)
+	case addToCartRequestKey:
+		requestBody, ok := readRESTBody(w, r)
+		if !ok {
+			return
+		}
+		data, err := addToCart(webservice.GetRESTUser(r), requestBody)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+			return
+		}
+		writeRESTResponse(data, w, http.StatusOK)
+	case checkoutRequestKey:
+		// the body is optional, it only carries a coupon code
+		var request couponRequest
+		if body, err := io.ReadAll(r.Body); err != nil || (len(body) > 0 && json.Unmarshal(body, &request) != nil) {
+			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+			return
+		}
+		data, err := checkout(nil, webservice.GetRESTUser(r), request.Coupon)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+			return
+		}
+		writeRESTResponse(data, w, http.StatusCreated)
 	case addInventoryRequestKey:
+		if !webservice.IsRESTAdmin(r) {
+			http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+			return
+		}
+		requestBody, ok := readRESTBody(w, r)
+		if !ok {
+			return
+		}
+		data, err := addInventory(nil, requestBody)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+			return
+		}
+		writeRESTResponse(data, w, http.StatusCreated)
+	case addPromotionRequestKey, addCouponRequestKey:
+		if !webservice.IsRESTAdmin(r) {
+			http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+			return
+		}
+		requestBody, ok := readRESTBody(w, r)
+		if !ok {
+			return
+		}
+		var data interface{}
+		var err error
+		if uriParts[0] == addPromotionRequestKey {
+			data, err = addPromotion(nil, requestBody)
+		} else {
+			data, err = addCoupon(requestBody)
+		}
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+			return
+		}
+		writeRESTResponse(data, w, http.StatusCreated)
+	case addCategoryRequestKey:
+		if !webservice.IsRESTAdmin(r) {
+			http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+			return
+		}
+		requestBody, ok := readRESTBody(w, r)
+		if !ok {
+			return
+		}
+		data, err := addCategory(nil, requestBody)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+			return
+		}
+		writeRESTResponse(data, w, http.StatusCreated)
+	default:
+		log.Errorf("%s URI with Method %s not possible", restURI, r.Method)
 		http.Error(w, configs.NotImplementedError, http.StatusNotImplemented)
+	}
+}
+
+// cartChangeHelper handles the PUT /seeds/updateCart/{id} and DELETE /seeds/removeFromCart/{id} requests
+func cartChangeHelper(restURI string, uriParts []string, w http.ResponseWriter, r *http.Request) {
+	if len(uriParts) < 2 {
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+
+	var data *Cart
+	var err error
+	switch {
+	case uriParts[0] == updateCartRequestKey && r.Method == http.MethodPut:
+		requestBody, ok := readRESTBody(w, r)
+		if !ok {
+			return
+		}
+		data, err = updateCart(webservice.GetRESTUser(r), &uriParts[1], requestBody)
+	case uriParts[0] == removeFromCartRequestKey && r.Method == http.MethodDelete:
+		data, err = removeFromCart(webservice.GetRESTUser(r), &uriParts[1])
 	default:
 		log.Errorf("%s URI with Method %s not possible", restURI, r.Method)
 		http.Error(w, configs.NotImplementedError, http.StatusNotImplemented)
+		return
 	}
+
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+	writeRESTResponse(data, w, http.StatusOK)
+}
+
+// inventoryChangeHelper handles the admin PUT / PATCH / DELETE requests in the form of /seeds/{type}/{category}/{id}
+// and /seeds/{type}/{category} for the category requests or /seeds/{type}/{id} for the stock alert, promotion, coupon
+// and grow guide requests
+func inventoryChangeHelper(restURI string, uriParts []string, w http.ResponseWriter, r *http.Request) {
+	if !webservice.IsRESTAdmin(r) {
+		http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+		return
+	}
+
+	if len(uriParts) < 2 {
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+
+	var data interface{}
+	var err error
+	switch {
+	case uriParts[0] == updateCategoryRequestKey && r.Method == http.MethodPut:
+		requestBody, ok := readRESTBody(w, r)
+		if !ok {
+			return
+		}
+		data, err = updateCategory(nil, &uriParts[1], requestBody)
+	case uriParts[0] == removeCategoryRequestKey && r.Method == http.MethodDelete:
+		data, err = removeCategory(nil, &uriParts[1])
+	case uriParts[0] == acknowledgeStockAlertRequestKey && r.Method == http.MethodPut:
+		data, err = acknowledgeStockAlert(nil, webservice.GetRESTUser(r), &uriParts[1])
+	case uriParts[0] == removePromotionRequestKey && r.Method == http.MethodDelete:
+		data, err = removePromotion(nil, &uriParts[1])
+	case uriParts[0] == removeCouponRequestKey && r.Method == http.MethodDelete:
+		data, err = removeCoupon(&uriParts[1])
+	case uriParts[0] == updateGrowGuideRequestKey && r.Method == http.MethodPut:
+		requestBody, ok := readRESTBody(w, r)
+		if !ok {
+			return
+		}
+		data, err = updateGrowGuide(&uriParts[1], requestBody)
+	case len(uriParts) < 3:
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	case uriParts[0] == updateInventoryRequestKey && r.Method == http.MethodPut:
+		requestBody, ok := readRESTBody(w, r)
+		if !ok {
+			return
+		}
+		data, err = updateInventory(nil, &uriParts[1], &uriParts[2], requestBody)
+	case uriParts[0] == restockInventoryRequestKey && r.Method == http.MethodPatch:
+		requestBody, ok := readRESTBody(w, r)
+		if !ok {
+			return
+		}
+		data, err = restockInventory(nil, &uriParts[1], &uriParts[2], requestBody)
+	case uriParts[0] == removeInventoryRequestKey && r.Method == http.MethodDelete:
+		data, err = removeInventory(nil, &uriParts[1], &uriParts[2])
+	default:
+		log.Errorf("%s URI with Method %s not possible", restURI, r.Method)
+		http.Error(w, configs.NotImplementedError, http.StatusNotImplemented)
+		return
+	}
+
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+	writeRESTResponse(data, w, http.StatusOK)
+}
+
+// readRESTBody unmarshals the body of the request, on failure the error is written and false returned
+func readRESTBody(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return nil, false
+	}
+
+	var requestBody map[string]interface{}
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		log.Errorf("bad request body: %s", body)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return nil, false
+	}
+	return requestBody, true
 }
 
 func writeRESTResponse(data interface{}, w http.ResponseWriter, httpStatus int) {
@@ -167,21 +801,12 @@ func writeRESTResponse(data interface{}, w http.ResponseWriter, httpStatus int)
 
 func purchaseREST(w http.ResponseWriter, r *http.Request) {
 	if strings.EqualFold(r.Method, http.MethodPost) {
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			log.Error(err)
-			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
-			return
-		}
-
-		var requestBody map[string]interface{}
-		if err := json.Unmarshal(body, &requestBody); err != nil {
-			log.Errorf("bad request body: %s", body)
-			http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		requestBody, ok := readRESTBody(w, r)
+		if !ok {
 			return
 		}
 
-		data, err := purchase(nil, requestBody)
+		data, err := purchase(nil, webservice.GetRESTUser(r), requestBody)
 		if err != nil {
 			log.Error(err)
 			http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
@@ -196,7 +821,11 @@ func purchaseREST(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, configs.BadRequestError, http.StatusBadRequest)
 }
 
-func purchase(sessionID *string, data interface{}) (*InventoryItem, error) {
+func purchase(sessionID, userID *string, data interface{}) (*InventoryItem, error) {
+	if userID == nil {
+		return nil, errors.New("no user found, cannot purchase")
+	}
+
 	bytes, err := json.Marshal(data)
 	if err != nil {
 		return nil, err
@@ -209,34 +838,60 @@ func purchase(sessionID *string, data interface{}) (*InventoryItem, error) {
 	}
 
 	if request.Category != nil && request.ID != nil && request.Quantity != nil {
-		inventoryMutex.Lock()
-		category, ok := inventoryCache[*request.Category]
-		inventoryMutex.Unlock()
-		if ok && category != nil {
-			if item, ok := category.Items[*request.ID]; ok {
-				quantity, err := determineQuantity(*request.Quantity)
-				if err != nil {
-					return nil, err
-				}
-				if quantity != nil && *item.Packets >= *quantity {
-					remainder := *item.Packets - *quantity
-					item.Packets = &remainder
-					if err := updateItemInDB(item); err != nil {
-						return nil, err
-					}
-					go notifyAll(request.Category, request.ID, sessionID, item)
-					return item, nil
-				}
-				return nil, errors.New("cannot purchase more packets than what the inventory contains")
-			}
+		item, err := findItem(request.Category, request.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		quantity, err := determineQuantity(*request.Quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		order, err := purchaseInDB(userID, &orderRequest{SeedID: request.ID, Quantity: *quantity}, request.Coupon)
+		if err != nil {
+			return nil, err
+		}
+
+		// the purchase is made once it's committed, failing here would have the client retry and purchase twice
+		if err := notifyOrder(sessionID, order); err != nil {
+			log.Errorf("order %s was placed but the inventory wasn't reloaded: %s", *order.ID, err)
+		}
+		if purchased, err := findItem(request.Category, request.ID); err == nil {
+			item = purchased
+		} else {
+			log.Error(err)
+		}
+		if err := priceItems(item); err != nil {
+			log.Error(err)
 		}
+		return item, nil
 	}
 	return nil, fmt.Errorf("item not found")
 }
 
-func notifyAll(categoryName, seedID, sessionID *string, item *InventoryItem) {
+// purchaseInDB decrements the packets and records the order in one transaction so an order is never lost or duplicated
+func purchaseInDB(userID *string, request *orderRequest, couponCode *string) (*Order, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to purchase, sqlite error: %s", dbErr)
+	}
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer configs.RollbackHelper(tx)
+
+	order, err := placeOrder(tx, userID, []*orderRequest{request}, couponCode)
+	if err != nil {
+		return nil, err
+	}
+	return order, tx.Commit()
+}
+
+func notifyAll(requestType string, categoryName, seedID, sessionID *string, item *InventoryItem) {
 	route := seedsKey
-	requestType := "purchase"
 	response := &configs.WsMessage{
 		Route:        &route,
 		Type:         &requestType,
@@ -30,6 +30,9 @@ func initSeedsTest() {
 	if err := configs.SetGrowSTLGoConfig(); err != nil {
 		log.Fatalf("config %s", err)
 	}
+	if err := Init(); err != nil {
+		log.Fatalf("Error starting the seeds db: %s", err)
+	}
 }
 
 func TestSeedFunctions(t *testing.T) {
@@ -0,0 +1,428 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+	"stl-go/grow-with-stl-go/pkg/webservice"
+)
+
+const (
+	// CSVFormat is the spreadsheet friendly import and export format
+	CSVFormat = "csv"
+	// JSONFormat imports and exports the catalog as an array of InventoryItem
+	JSONFormat = "json"
+
+	maxImportSize = 16 << 20
+)
+
+// csvColumns are the InventoryItem json names in the order they are exported, imports match the header by name
+var csvColumns = []string{"id", "category", "genus", "species", "cultivar", "commonName", "description", "hybrid", "price",
+	"currency", "perPacketCount", "packets", "image", "reorderThreshold"}
+
+// importUpdateSQL replaces an existing seed along with its stock, an import is the stock count of the whole catalog
+const importUpdateSQL = `UPDATE seeds set category = ?, genus = ?, species = ?, cultivar = ?, commonName = ?, description = ?, hybrid = ?,
+	price = ?, perpacketcount = ?, packets = ?, image = ?, reorderThreshold = ?, currency = ? where id = ?`
+
+// ImportReport is the outcome of validating, and if requested committing, an import
+type ImportReport struct {
+	Committed bool           `json:"committed"`
+	Total     int            `json:"total"`
+	Inserted  int            `json:"inserted"`
+	Updated   int            `json:"updated"`
+	Errors    []*ImportError `json:"errors,omitempty"`
+}
+
+// ImportError is a record that failed validation, rows are counted from 1 not including the csv header
+type ImportError struct {
+	Row     int     `json:"row"`
+	ID      *string `json:"id,omitempty"`
+	Message string  `json:"message"`
+}
+
+// importInventoryREST validates the request body as a catalog import, it is only written if the commit query parameter
+// is true.  The format comes from the format query parameter, or the content type if it isn't set
+func importInventoryREST(w http.ResponseWriter, r *http.Request) {
+	if !webservice.IsRESTAdmin(r) {
+		http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = JSONFormat
+		if strings.Contains(r.Header.Get("content-type"), CSVFormat) {
+			format = CSVFormat
+		}
+	}
+	commit, _ := strconv.ParseBool(r.URL.Query().Get("commit"))
+
+	report, err := Import(http.MaxBytesReader(w, r.Body, maxImportSize), format, commit)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(report.Errors) > 0:
+		writeRESTResponse(report, w, http.StatusUnprocessableEntity)
+	case report.Committed:
+		writeRESTResponse(report, w, http.StatusCreated)
+	default:
+		writeRESTResponse(report, w, http.StatusOK)
+	}
+}
+
+// exportInventoryREST downloads the catalog as json or, with format=csv, as csv
+func exportInventoryREST(w http.ResponseWriter, r *http.Request) {
+	if !webservice.IsRESTAdmin(r) {
+		http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	contentType := "application/json"
+	switch format {
+	case "", JSONFormat:
+		format = JSONFormat
+	case CSVFormat:
+		contentType = "text/csv"
+	default:
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := Export(&buf, format); err != nil {
+		log.Error(err)
+		http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", contentType)
+	w.Header().Set("content-disposition", fmt.Sprintf("attachment; filename=\"seeds.%s\"", format))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Error(err)
+	}
+}
+
+// Import reads the catalog records and validates every one of them.  Records with the id of an existing seed update it,
+// the others are added.  Nothing is written unless commit is set and every record is valid, the whole import is then
+// written in one transaction
+func Import(r io.Reader, format string, commit bool) (*ImportReport, error) {
+	items, report, err := readImport(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]struct{})
+	inventory, err := getInventory()
+	if err != nil {
+		return nil, err
+	}
+	for _, category := range inventory {
+		for id := range category.Items {
+			existing[id] = struct{}{}
+		}
+	}
+
+	seen := make(map[string]int)
+	updates := make([]bool, len(items))
+	for i, item := range items {
+		if item == nil {
+			continue
+		}
+		row := i + 1
+
+		if item.ID == nil || strings.TrimSpace(*item.ID) == "" {
+			id := uuid.New().String()
+			item.ID = &id
+		}
+		if first, ok := seen[*item.ID]; ok {
+			report.Errors = append(report.Errors, &ImportError{Row: row, ID: item.ID, Message: fmt.Sprintf("duplicate of row %d", first)})
+			continue
+		}
+		seen[*item.ID] = row
+
+		if err := item.validate(); err != nil {
+			report.Errors = append(report.Errors, &ImportError{Row: row, ID: item.ID, Message: err.Error()})
+			continue
+		}
+
+		if _, ok := existing[*item.ID]; ok {
+			updates[i] = true
+			report.Updated++
+		} else {
+			report.Inserted++
+		}
+	}
+
+	slices.SortFunc(report.Errors, func(a, b *ImportError) int {
+		return a.Row - b.Row
+	})
+
+	if !commit || len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	if err := commitImport(items, updates); err != nil {
+		return nil, err
+	}
+	report.Committed = true
+
+	invalidateInventoryCache()
+	if _, err := getInventory(); err != nil {
+		return nil, err
+	}
+
+	log.Infof("catalog import committed, %d seeds added and %d updated", report.Inserted, report.Updated)
+	go notifyAll(importInventoryRequestKey, nil, nil, nil, nil)
+	return report, nil
+}
+
+func readImport(r io.Reader, format string) ([]*InventoryItem, *ImportReport, error) {
+	report := &ImportReport{}
+	var items []*InventoryItem
+	switch strings.ToLower(format) {
+	case JSONFormat:
+		if err := json.NewDecoder(r).Decode(&items); err != nil {
+			return nil, nil, fmt.Errorf("unable to read the json import.  Error: %s", err)
+		}
+		for i, item := range items {
+			if item == nil {
+				report.Errors = append(report.Errors, &ImportError{Row: i + 1, Message: "the record is null"})
+			}
+		}
+	case CSVFormat:
+		reader := csv.NewReader(r)
+		reader.TrimLeadingSpace = true
+		header, err := reader.Read()
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read the csv header.  Error: %s", err)
+		}
+		// a csv saved by Excel starts with a byte order mark that would otherwise be part of the first column name
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+		for row := 1; ; row++ {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to read csv row %d.  Error: %s", row, err)
+			}
+
+			item, err := itemFromCSV(header, record)
+			if err != nil {
+				report.Errors = append(report.Errors, &ImportError{Row: row, Message: err.Error()})
+			}
+			items = append(items, item)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown import format %s", format)
+	}
+
+	report.Total = len(items)
+	return items, report, nil
+}
+
+// itemFromCSV maps the csv record to an InventoryItem by the header names, empty cells are left unset
+func itemFromCSV(header, record []string) (*InventoryItem, error) {
+	item := &InventoryItem{}
+	for i, column := range header {
+		if i >= len(record) {
+			break
+		}
+
+		value := strings.TrimSpace(record[i])
+		if value == "" {
+			continue
+		}
+
+		switch strings.TrimSpace(column) {
+		case "id":
+			item.ID = &value
+		case "category":
+			item.Category = &value
+		case "genus":
+			item.Genus = &value
+		case "species":
+			item.Species = &value
+		case "cultivar":
+			item.Cultivar = &value
+		case "commonName":
+			item.CommonName = &value
+		case "description":
+			item.Description = &value
+		case "image":
+			item.Image = &value
+		case "currency":
+			item.Currency = &value
+		case "hybrid":
+			hybrid, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("hybrid must be true or false, not %s", value)
+			}
+			item.Hybrid = &hybrid
+		case "price":
+			price, err := ParseMoney(value)
+			if err != nil {
+				return nil, fmt.Errorf("price must be an amount, %s", err)
+			}
+			item.Price = &price
+		case "perPacketCount":
+			count, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("perPacketCount must be a whole number, not %s", value)
+			}
+			c := int32(count)
+			item.PerPacketCount = &c
+		case "packets":
+			packets, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("packets must be a whole number, not %s", value)
+			}
+			item.Packets = &packets
+		case "reorderThreshold":
+			threshold, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("reorderThreshold must be a whole number, not %s", value)
+			}
+			item.ReorderThreshold = &threshold
+		default:
+			return nil, fmt.Errorf("unknown column %s", column)
+		}
+	}
+	return item, nil
+}
+
+func commitImport(items []*InventoryItem, updates []bool) error {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return fmt.Errorf("unable to import, sqlite error: %s", dbErr)
+	}
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer configs.RollbackHelper(tx)
+
+	seeds := inventoryTables["seeds"]
+	for i, item := range items {
+		if updates[i] {
+			_, err = seeds.ExecTx(tx, importUpdateSQL, item.Category, item.Genus, item.Species, item.Cultivar, item.CommonName,
+				item.Description, item.Hybrid, item.Price, item.PerPacketCount, item.Packets, item.Image, item.ReorderThreshold, item.Currency, item.ID)
+		} else {
+			_, err = seeds.ExecTx(tx, seeds.InsertSQL, item.ID, item.Category, item.Genus, item.Species, item.Cultivar, item.CommonName,
+				item.Description, item.Hybrid, item.Price, item.PerPacketCount, item.Packets, item.Image, item.ReorderThreshold, item.Currency)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to write seed %s.  Error: %s", *item.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Export writes the whole catalog sorted by category and common name
+func Export(w io.Writer, format string) error {
+	inventory, err := getInventory()
+	if err != nil {
+		return err
+	}
+
+	items := []*InventoryItem{}
+	for _, category := range inventory {
+		for _, item := range category.Items {
+			items = append(items, item)
+		}
+	}
+	slices.SortFunc(items, func(a, b *InventoryItem) int {
+		if c := strings.Compare(*a.Category, *b.Category); c != 0 {
+			return c
+		}
+		if c := strings.Compare(*a.CommonName, *b.CommonName); c != 0 {
+			return c
+		}
+		return strings.Compare(*a.ID, *b.ID)
+	})
+
+	switch strings.ToLower(format) {
+	case JSONFormat:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "    ")
+		return encoder.Encode(items)
+	case CSVFormat:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(csvColumns); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := writer.Write(item.csvRecord()); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("unknown export format %s", format)
+}
+
+// csvRecord returns the item's fields in csvColumns order
+func (item *InventoryItem) csvRecord() []string {
+	str := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	num := func(i *int) string {
+		if i == nil {
+			return ""
+		}
+		return strconv.Itoa(*i)
+	}
+
+	var hybrid, price, perPacketCount string
+	if item.Hybrid != nil {
+		hybrid = strconv.FormatBool(*item.Hybrid)
+	}
+	if item.Price != nil {
+		price = item.Price.String()
+	}
+	if item.PerPacketCount != nil {
+		perPacketCount = strconv.FormatInt(int64(*item.PerPacketCount), 10)
+	}
+
+	return []string{str(item.ID), str(item.Category), str(item.Genus), str(item.Species), str(item.Cultivar), str(item.CommonName),
+		str(item.Description), hybrid, price, str(item.Currency), perPacketCount, num(item.Packets), str(item.Image), num(item.ReorderThreshold)}
+}
@@ -0,0 +1,130 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package seeds
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogTransfer(t *testing.T) {
+	initInventoryTest()
+	firstID, secondID := uuid.New().String(), uuid.New().String()
+	herb := "Herb"
+	defer func() {
+		for _, id := range []string{firstID, secondID} {
+			if _, err := findItem(&herb, &id); err == nil {
+				_, err := removeInventory(nil, &herb, &id)
+				require.NoError(t, err)
+			}
+		}
+	}()
+
+	importCSV := "id,category,genus,species,cultivar,commonName,description,hybrid,price,perPacketCount,packets\n" +
+		firstID + ",Herb,Ocimum,basilicum,Import Test,Basil,Imported basil,false,2.50,40,12\n" +
+		secondID + ",Herb,Anethum,graveolens,,Dill,\"Imported dill, fernleaf\",,1.75,100,8\n"
+
+	t.Run("Test a dry run doesn't write anything", func(t *testing.T) {
+		report, err := Import(strings.NewReader(importCSV), CSVFormat, false)
+		require.NoError(t, err)
+		require.Empty(t, report.Errors)
+		require.False(t, report.Committed)
+		require.Equal(t, 2, report.Total)
+		require.Equal(t, 2, report.Inserted)
+
+		_, err = findItem(&herb, &firstID)
+		require.Error(t, err)
+
+		// the byte order mark Excel starts a csv with doesn't hide the first column
+		report, err = Import(strings.NewReader("\ufeff"+importCSV), CSVFormat, false)
+		require.NoError(t, err)
+		require.Empty(t, report.Errors)
+		require.Equal(t, 2, report.Inserted)
+	})
+
+	t.Run("Test an invalid record stops the commit", func(t *testing.T) {
+		invalid := importCSV + ",Squash,Cucurbita,pepo,,Zucchini,Not a category,false,2.00,10,3\n" +
+			",Herb,Salvia,officinalis,,Sage,Bad price,false,cheap,10,3\n" +
+			firstID + ",Herb,Ocimum,basilicum,,Basil,Duplicate,false,2.50,40,12\n"
+		report, err := Import(strings.NewReader(invalid), CSVFormat, true)
+		require.NoError(t, err)
+		require.False(t, report.Committed)
+		require.Len(t, report.Errors, 3)
+		require.Equal(t, []int{3, 4, 5}, []int{report.Errors[0].Row, report.Errors[1].Row, report.Errors[2].Row})
+
+		_, err = findItem(&herb, &firstID)
+		require.Error(t, err)
+
+		report, err = Import(strings.NewReader("id,category,colour\n1,Herb,green\n"), CSVFormat, false)
+		require.NoError(t, err)
+		require.Len(t, report.Errors, 1)
+		_, err = Import(strings.NewReader("{}"), JSONFormat, false)
+		require.Error(t, err)
+		_, err = Import(strings.NewReader(""), "xml", false)
+		require.Error(t, err)
+	})
+
+	t.Run("Test committing adds and then updates", func(t *testing.T) {
+		report, err := Import(strings.NewReader(importCSV), CSVFormat, true)
+		require.NoError(t, err)
+		require.True(t, report.Committed)
+		require.Equal(t, 2, report.Inserted)
+
+		item, err := findItem(&herb, &secondID)
+		require.NoError(t, err)
+		require.Equal(t, "Imported dill, fernleaf", *item.Description)
+		require.Equal(t, 8, *item.Packets)
+
+		packets := 20
+		item.Packets = &packets
+		b, err := json.Marshal([]*InventoryItem{item})
+		require.NoError(t, err)
+		report, err = Import(bytes.NewReader(b), JSONFormat, true)
+		require.NoError(t, err)
+		require.True(t, report.Committed)
+		require.Equal(t, 1, report.Updated)
+
+		item, err = findItem(&herb, &secondID)
+		require.NoError(t, err)
+		require.Equal(t, 20, *item.Packets)
+	})
+
+	t.Run("Test exports round trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Export(&buf, CSVFormat))
+		records, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		require.Equal(t, csvColumns, records[0])
+
+		buf.Reset()
+		require.NoError(t, Export(&buf, CSVFormat))
+		report, err := Import(&buf, CSVFormat, false)
+		require.NoError(t, err)
+		require.Empty(t, report.Errors)
+		require.Equal(t, len(records)-1, report.Updated)
+
+		buf.Reset()
+		require.NoError(t, Export(&buf, JSONFormat))
+		var items []*InventoryItem
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &items))
+		require.Len(t, items, len(records)-1)
+	})
+}
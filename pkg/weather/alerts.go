@@ -0,0 +1,238 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+const (
+	alertKey = "alert"
+
+	frostAlert         = "frost"
+	heatAlert          = "heat"
+	precipitationAlert = "precipitation"
+
+	// the thresholds are in fahrenheit and percent chance of precipitation
+	frostThreshold         = 34
+	heatThreshold          = 95
+	precipitationThreshold = 70
+)
+
+var (
+	alertTableName = "weatherAlerts"
+	alertTable     = &configs.Table{
+		CreateSQL: `CREATE TABLE IF NOT EXISTS weatherAlerts (
+			id varchar(64) NOT NULL PRIMARY KEY,
+			user varchar(128) NOT NULL,
+			location varchar(1024) NOT NULL,
+			kind varchar(16) NOT NULL,
+			period varchar(128),
+			startTime varchar(64) NOT NULL,
+			endTime varchar(64),
+			value int NOT NULL,
+			unit varchar(8) NOT NULL,
+			message varchar(1024) NOT NULL,
+			created bigint NOT NULL,
+			UNIQUE(user, location, kind, startTime))`,
+		// the unique constraint keeps a period from being alerted on every refresh
+		InsertSQL: "INSERT OR IGNORE INTO weatherAlerts values(?,?,?,?,?,?,?,?,?,?,?)",
+		Indices: []string{
+			"CREATE INDEX IF NOT EXISTS weatheralertuser on weatherAlerts(user)",
+			"CREATE INDEX IF NOT EXISTS weatheralertcreated on weatherAlerts(created)",
+		},
+	}
+
+	// userNotifier pushes a message to the websocket sessions of a user, it is set by the webservice because the
+	// webservice imports weather and not the other way around
+	userNotifier func(userID *string, message *configs.WsMessage)
+)
+
+// Alert is raised when a forecast period for a user's location crosses the frost, heat or precipitation thresholds
+type Alert struct {
+	ID        *string `json:"id,omitempty"`
+	User      *string `json:"user,omitempty"`
+	Location  *string `json:"location,omitempty"`
+	Kind      *string `json:"kind,omitempty"`
+	Period    *string `json:"period,omitempty"`
+	StartTime *string `json:"startTime,omitempty"`
+	EndTime   *string `json:"endTime,omitempty"`
+	Value     *int    `json:"value,omitempty"`
+	Unit      *string `json:"unit,omitempty"`
+	Message   *string `json:"message,omitempty"`
+	Created   *int64  `json:"created,omitempty"`
+}
+
+// SetUserNotifier sets the function used to push alerts to a user's active sessions
+func SetUserNotifier(notifier func(userID *string, message *configs.WsMessage)) {
+	userNotifier = notifier
+}
+
+func setupAlerts() error {
+	return alertTable.CreateTable(&alertTableName)
+}
+
+// checkAlerts evaluates the forecast periods of every user's location and raises the alerts that haven't been raised
+// for the same period before, the locations are keyed by user id
+func checkAlerts(locations map[string]string) ([]*Alert, error) {
+	var raised []*Alert
+	var errs []error
+	for userID, location := range locations {
+		ZipCodeCacheMutex.Lock()
+		zip, ok := ZipcodeLookup[location]
+		ZipCodeCacheMutex.Unlock()
+		if !ok {
+			continue
+		}
+
+		zip.Mutex.Lock()
+		periods := zip.Periods
+		zip.Mutex.Unlock()
+
+		for _, period := range periods {
+			for _, alert := range forecastAlerts(period) {
+				alert.User = &userID
+				alert.Location = &location
+				inserted, err := raiseAlert(alert)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				if inserted {
+					raised = append(raised, alert)
+				}
+			}
+		}
+	}
+	return raised, errors.Join(errs...)
+}
+
+// forecastAlerts evaluates the rules against a single forecast period
+func forecastAlerts(period *Forecast) []*Alert {
+	if period == nil || period.StartTime == nil {
+		return nil
+	}
+
+	name := "the forecast period"
+	if period.Name != nil {
+		name = *period.Name
+	}
+
+	alerts := []*Alert{}
+	if period.Temperature != nil {
+		temperature := fahrenheit(*period.Temperature, period.TemperatureUnit)
+		switch {
+		case temperature < frostThreshold:
+			alerts = append(alerts, newAlert(period, frostAlert, temperature, "F",
+				fmt.Sprintf("Frost possible %s with a temperature of %d°F", name, temperature)))
+		case temperature > heatThreshold:
+			alerts = append(alerts, newAlert(period, heatAlert, temperature, "F",
+				fmt.Sprintf("Heat warning %s with a temperature of %d°F", name, temperature)))
+		}
+	}
+	if period.ProbabilityOfPrecipitation != nil && period.ProbabilityOfPrecipitation.Value != nil &&
+		*period.ProbabilityOfPrecipitation.Value >= precipitationThreshold {
+		chance := *period.ProbabilityOfPrecipitation.Value
+		alerts = append(alerts, newAlert(period, precipitationAlert, chance, "%",
+			fmt.Sprintf("%d%% chance of precipitation %s", chance, name)))
+	}
+	return alerts
+}
+
+func newAlert(period *Forecast, kind string, value int, unit, message string) *Alert {
+	return &Alert{
+		Kind:      &kind,
+		Period:    period.Name,
+		StartTime: period.StartTime,
+		EndTime:   period.EndTime,
+		Value:     &value,
+		Unit:      &unit,
+		Message:   &message,
+	}
+}
+
+// fahrenheit converts the forecast temperature if it was given in celsius
+func fahrenheit(temperature int, unit *string) int {
+	if unit != nil && strings.EqualFold(*unit, "C") {
+		return int(math.Round(float64(temperature)*9/5 + 32))
+	}
+	return temperature
+}
+
+// raiseAlert records the alert and pushes it to the user's sessions, false is returned if it was already raised
+func raiseAlert(alert *Alert) (bool, error) {
+	id := uuid.New().String()
+	created := time.Now().UnixMilli()
+	alert.ID = &id
+	alert.Created = &created
+
+	rows, err := alertTable.Exec(alertTable.InsertSQL, alert.ID, alert.User, alert.Location, alert.Kind, alert.Period,
+		alert.StartTime, alert.EndTime, alert.Value, alert.Unit, alert.Message, alert.Created)
+	if err != nil || rows == 0 {
+		return false, err
+	}
+
+	log.Infof("%s weather alert raised for user %s: %s", *alert.Kind, *alert.User, *alert.Message)
+
+	if userNotifier != nil {
+		route := Route
+		requestType := alertKey
+		go userNotifier(alert.User, &configs.WsMessage{
+			Route:     &route,
+			Type:      &requestType,
+			Component: alert.Kind,
+			Data:      alert,
+		})
+	}
+	return true, nil
+}
+
+// getAlerts returns the alert history of a user, newest first
+func getAlerts(userID *string) ([]*Alert, error) {
+	if userID == nil {
+		return nil, errors.New("no user supplied, cannot retrieve weather alerts")
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve weather alerts, sqlite error: %s", dbErr)
+	}
+
+	rows, err := db.Query("select * from weatherAlerts where user = ? order by created desc", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []*Alert{}
+	for rows.Next() {
+		alert := Alert{}
+		if err := rows.Scan(&alert.ID, &alert.User, &alert.Location, &alert.Kind, &alert.Period, &alert.StartTime,
+			&alert.EndTime, &alert.Value, &alert.Unit, &alert.Message, &alert.Created); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, &alert)
+	}
+	return alerts, rows.Err()
+}
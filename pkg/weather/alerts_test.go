@@ -0,0 +1,132 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+	"stl-go/grow-with-stl-go/pkg/utils"
+)
+
+func initWeatherTest() {
+	configFile := "../../etc/grow-with-stl-go.json"
+	configs.ConfigFile = &configFile
+	if err := configs.SetGrowSTLGoConfig(); err != nil {
+		log.Fatalf("config %s", err)
+	}
+	if err := setupAlerts(); err != nil {
+		log.Fatalf("Error creating the weather alerts table: %s", err)
+	}
+	if err := setupCache(); err != nil {
+		log.Fatalf("Error creating the weather points table: %s", err)
+	}
+	if err := setupLocations(); err != nil {
+		log.Fatalf("Error creating the location tables: %s", err)
+	}
+	if err := setupObservations(); err != nil {
+		log.Fatalf("Error creating the observation tables: %s", err)
+	}
+}
+
+func testPeriod(name, start string, temperature int, unit string, precipitation int) *Forecast {
+	return &Forecast{
+		Name:                       utils.StringPointer(name),
+		StartTime:                  utils.StringPointer(start),
+		Temperature:                &temperature,
+		TemperatureUnit:            utils.StringPointer(unit),
+		ProbabilityOfPrecipitation: &probabilityOfPrecipitation{Value: &precipitation},
+	}
+}
+
+func TestAlertFunctions(t *testing.T) {
+	initWeatherTest()
+
+	t.Run("Test the alert rules", func(t *testing.T) {
+		require.Empty(t, forecastAlerts(testPeriod("Today", "2026-05-01T06:00:00-05:00", 72, "F", 10)))
+
+		alerts := forecastAlerts(testPeriod("Tonight", "2026-05-01T18:00:00-05:00", 31, "F", 80))
+		require.Len(t, alerts, 2)
+		require.Equal(t, frostAlert, *alerts[0].Kind)
+		require.Equal(t, precipitationAlert, *alerts[1].Kind)
+		require.Equal(t, 80, *alerts[1].Value)
+
+		// 36°C is 97°F
+		alerts = forecastAlerts(testPeriod("Tuesday", "2026-07-07T06:00:00-05:00", 36, "C", 0))
+		require.Len(t, alerts, 1)
+		require.Equal(t, heatAlert, *alerts[0].Kind)
+		require.Equal(t, 97, *alerts[0].Value)
+
+		// the thresholds themselves don't alert
+		require.Empty(t, forecastAlerts(testPeriod("Wednesday", "2026-07-08T06:00:00-05:00", 34, "F", 69)))
+		require.Empty(t, forecastAlerts(testPeriod("Thursday", "2026-07-09T06:00:00-05:00", 95, "F", 0)))
+	})
+
+	t.Run("Test alerts are raised once per user period", func(t *testing.T) {
+		location := "Alert Test, MO. " + uuid.New().String()
+		userID := uuid.New().String()
+		ZipCodeCacheMutex.Lock()
+		ZipcodeLookup[location] = &ZipCode{
+			Periods: []*Forecast{
+				testPeriod("Tonight", "2026-10-16T18:00:00-05:00", 30, "F", 20),
+				testPeriod("Saturday", "2026-10-17T06:00:00-05:00", 55, "F", 90),
+				testPeriod("Saturday Night", "2026-10-17T18:00:00-05:00", 40, "F", 0),
+			},
+		}
+		ZipCodeCacheMutex.Unlock()
+		defer func() {
+			ZipCodeCacheMutex.Lock()
+			delete(ZipcodeLookup, location)
+			ZipCodeCacheMutex.Unlock()
+		}()
+
+		notified := make(chan *configs.WsMessage, 10)
+		SetUserNotifier(func(user *string, message *configs.WsMessage) {
+			require.Equal(t, userID, *user)
+			notified <- message
+		})
+		defer SetUserNotifier(nil)
+
+		raised, err := checkAlerts(map[string]string{userID: location})
+		require.NoError(t, err)
+		require.Len(t, raised, 2)
+		for range raised {
+			message := <-notified
+			require.Equal(t, Route, *message.Route)
+			require.Equal(t, alertKey, *message.Type)
+		}
+
+		// the next refresh doesn't raise them again
+		raised, err = checkAlerts(map[string]string{userID: location})
+		require.NoError(t, err)
+		require.Empty(t, raised)
+
+		history, err := getAlerts(&userID)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		for _, alert := range history {
+			require.Equal(t, location, *alert.Location)
+		}
+
+		other := uuid.New().String()
+		history, err = getAlerts(&other)
+		require.NoError(t, err)
+		require.Empty(t, history)
+	})
+}
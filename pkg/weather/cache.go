@@ -0,0 +1,244 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+const (
+	// a request is tried this many times when the provider can't be reached or answers with a 429 or a 5xx
+	maxAttempts = 4
+	retryBase   = 500 * time.Millisecond
+	retryCap    = 8 * time.Second
+
+	// responses that can only be revalidated are dropped from the cache once they are this old
+	revalidateFor = 24 * time.Hour
+)
+
+var (
+	pointTableName = "weatherPoints"
+	pointTable     = &configs.Table{
+		CreateSQL: `CREATE TABLE IF NOT EXISTS weatherPoints (
+			api varchar(1024) NOT NULL,
+			point varchar(64) NOT NULL,
+			forecast varchar(1024) NOT NULL,
+			forecastHourly varchar(1024),
+			created bigint NOT NULL,
+			PRIMARY KEY (api, point))`,
+		InsertSQL: `INSERT INTO weatherPoints values(?,?,?,?,?) ON CONFLICT(api, point) DO UPDATE SET
+			forecast = excluded.forecast, forecastHourly = excluded.forecastHourly, created = excluded.created`,
+		DeleteSQL: "DELETE FROM weatherPoints where api = ? and point = ?",
+	}
+
+	responseCache      = make(map[string]*cachedResponse)
+	responseCacheMutex sync.Mutex
+
+	// sleep is replaced by the tests so the backoff doesn't slow them down
+	sleep = time.Sleep
+)
+
+// cachedResponse is a provider response kept until it expires, one with a Last-Modified date can be revalidated with
+// If-Modified-Since after that
+type cachedResponse struct {
+	body         string
+	expires      time.Time
+	lastModified string
+	stored       time.Time
+}
+
+func setupCache() error {
+	return pointTable.CreateTable(&pointTableName)
+}
+
+// cachedGet returns the body of a GET request.  A response that hasn't expired is served from the cache, an expired
+// one is revalidated, and requests that fail in a way that may pass on a retry are retried with backoff
+func cachedGet(url string) (string, error) {
+	now := time.Now()
+	responseCacheMutex.Lock()
+	cached, ok := responseCache[url]
+	responseCacheMutex.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.body, nil
+	}
+
+	headers := map[string]string{}
+	if ok && cached.lastModified != "" {
+		headers["If-Modified-Since"] = cached.lastModified
+	}
+
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := backoff(attempt)
+			log.Debugf("retrying %s in %s after %s", url, delay, err)
+			sleep(delay)
+		}
+
+		response, statusCode, responseHeaders, requestErr := configs.HTTPGetWithHeaders(url, headers)
+		if requestErr != nil {
+			err = requestErr
+			continue
+		}
+		if statusCode == nil {
+			err = fmt.Errorf("no response from url %s", url)
+			continue
+		}
+
+		switch {
+		case *statusCode == http.StatusNotModified && ok:
+			// a 304 doesn't have to repeat the Last-Modified, the cached one still validates the body
+			if responseHeaders.Get("Last-Modified") == "" && cached.lastModified != "" {
+				responseHeaders = responseHeaders.Clone()
+				if responseHeaders == nil {
+					responseHeaders = http.Header{}
+				}
+				responseHeaders.Set("Last-Modified", cached.lastModified)
+			}
+			storeResponse(url, cached.body, responseHeaders, now)
+			return cached.body, nil
+		case *statusCode == http.StatusTooManyRequests || *statusCode >= http.StatusInternalServerError:
+			err = badResponse(url, response, *statusCode)
+			continue
+		case *statusCode >= http.StatusMultipleChoices:
+			return "", badResponse(url, response, *statusCode)
+		}
+
+		if response == nil {
+			return "", fmt.Errorf("empty response from url %s", url)
+		}
+		storeResponse(url, *response, responseHeaders, now)
+		return *response, nil
+	}
+	return "", err
+}
+
+func badResponse(url string, response *string, statusCode int) error {
+	if response == nil {
+		return fmt.Errorf("bad response from url %s.  HTTP Status Code %d", url, statusCode)
+	}
+	return fmt.Errorf("bad response from url %s.  Response '%s'.  HTTP Status Code %d", url, *response, statusCode)
+}
+
+// backoff is the exponential delay before the attempt, half of it is random so lookups that failed together don't
+// retry together
+func backoff(attempt int) time.Duration {
+	delay := retryBase << (attempt - 1)
+	if delay > retryCap || delay <= 0 {
+		delay = retryCap
+	}
+	return delay/2 + rand.N(delay/2)
+}
+
+// storeResponse caches the response for as long as its headers allow, or until it is too old to revalidate
+func storeResponse(url, body string, headers http.Header, now time.Time) {
+	lastModified := headers.Get("Last-Modified")
+	ttl := cacheTTL(headers, now)
+
+	responseCacheMutex.Lock()
+	defer responseCacheMutex.Unlock()
+	for key, response := range responseCache {
+		if now.Sub(response.stored) > revalidateFor && now.After(response.expires) {
+			delete(responseCache, key)
+		}
+	}
+
+	if ttl <= 0 && lastModified == "" {
+		delete(responseCache, url)
+		return
+	}
+	responseCache[url] = &cachedResponse{
+		body:         body,
+		expires:      now.Add(ttl),
+		lastModified: lastModified,
+		stored:       now,
+	}
+}
+
+// cacheTTL is how long a response can be used without revalidating it, from Cache-Control's max-age or else Expires
+func cacheTTL(headers http.Header, now time.Time) time.Duration {
+	if cacheControl := headers.Get("Cache-Control"); cacheControl != "" {
+		for _, directive := range strings.Split(strings.ToLower(cacheControl), ",") {
+			directive = strings.TrimSpace(directive)
+			switch {
+			case directive == "no-store" || directive == "no-cache":
+				return 0
+			case strings.HasPrefix(directive, "max-age="):
+				seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+				if err != nil {
+					return 0
+				}
+				ttl := time.Duration(seconds) * time.Second
+				if age, err := strconv.Atoi(headers.Get("Age")); err == nil {
+					ttl -= time.Duration(age) * time.Second
+				}
+				return ttl
+			}
+		}
+	}
+
+	if expires := headers.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		// measured from the server's clock when it sent one
+		if date, err := http.ParseTime(headers.Get("Date")); err == nil {
+			return expiresAt.Sub(date)
+		}
+		return expiresAt.Sub(now)
+	}
+	return 0
+}
+
+// getPoint returns the forecast urls cached for the point of the api
+func getPoint(api, point string) (*forecastURL, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve weather points, sqlite error: %s", dbErr)
+	}
+
+	urls := forecastURL{}
+	err := db.QueryRow("select forecast, forecastHourly from weatherPoints where api = ? and point = ?", api, point).
+		Scan(&urls.ForecastURL, &urls.ForecastHourlyURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &urls, nil
+}
+
+func storePoint(api, point string, urls *forecastURL) error {
+	_, err := pointTable.Exec(pointTable.InsertSQL, api, point, urls.ForecastURL, urls.ForecastHourlyURL, time.Now().UnixMilli())
+	return err
+}
+
+func removePoint(api, point string) error {
+	_, err := pointTable.Exec(pointTable.DeleteSQL, api, point)
+	return err
+}
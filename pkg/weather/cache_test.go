@@ -0,0 +1,220 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+func TestCacheFunctions(t *testing.T) {
+	initWeatherTest()
+
+	var delays []time.Duration
+	sleep = func(delay time.Duration) { delays = append(delays, delay) }
+	defer func() { sleep = time.Sleep }()
+
+	t.Run("Test the cache ttl", func(t *testing.T) {
+		now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+		headers := http.Header{}
+		require.Zero(t, cacheTTL(headers, now))
+
+		headers.Set("Cache-Control", "public, max-age=600")
+		require.Equal(t, 10*time.Minute, cacheTTL(headers, now))
+		headers.Set("Age", "60")
+		require.Equal(t, 9*time.Minute, cacheTTL(headers, now))
+
+		headers.Set("Cache-Control", "no-cache, max-age=600")
+		require.Zero(t, cacheTTL(headers, now))
+
+		headers = http.Header{}
+		headers.Set("Expires", now.Add(30*time.Minute).Format(http.TimeFormat))
+		require.Equal(t, 30*time.Minute, cacheTTL(headers, now))
+		// the server's date is used over our clock
+		headers.Set("Date", now.Add(-10*time.Minute).Format(http.TimeFormat))
+		require.Equal(t, 40*time.Minute, cacheTTL(headers, now))
+
+		headers.Set("Expires", "0")
+		require.Zero(t, cacheTTL(headers, now))
+	})
+
+	t.Run("Test the backoff", func(t *testing.T) {
+		for attempt := 1; attempt < 10; attempt++ {
+			delay := retryBase << (attempt - 1)
+			if delay > retryCap {
+				delay = retryCap
+			}
+			for range 10 {
+				b := backoff(attempt)
+				require.GreaterOrEqual(t, b, delay/2)
+				require.Less(t, b, delay)
+			}
+		}
+	})
+
+	t.Run("Test cached and conditional requests", func(t *testing.T) {
+		hits, revalidations, bareRevalidations := 0, 0, 0
+		lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			switch r.URL.Path {
+			case "/fresh":
+				w.Header().Set("Cache-Control", "max-age=300")
+			case "/revalidate":
+				w.Header().Set("Cache-Control", "no-cache")
+				w.Header().Set("Last-Modified", lastModified)
+				if r.Header.Get("If-Modified-Since") == lastModified {
+					revalidations++
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			case "/bare":
+				// the 304 leaves out the headers of the response it validates
+				if r.Header.Get("If-Modified-Since") == lastModified {
+					bareRevalidations++
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("Last-Modified", lastModified)
+			}
+			fmt.Fprintf(w, `{"path": "%s"}`, r.URL.Path)
+		}))
+		defer server.Close()
+
+		for range 3 {
+			body, err := cachedGet(server.URL + "/fresh")
+			require.NoError(t, err)
+			require.Equal(t, `{"path": "/fresh"}`, body)
+		}
+		require.Equal(t, 1, hits)
+
+		for range 3 {
+			body, err := cachedGet(server.URL + "/revalidate")
+			require.NoError(t, err)
+			require.Equal(t, `{"path": "/revalidate"}`, body)
+		}
+		require.Equal(t, 4, hits)
+		require.Equal(t, 2, revalidations)
+
+		// without caching headers every request goes to the server
+		for range 2 {
+			_, err := cachedGet(server.URL + "/uncached")
+			require.NoError(t, err)
+		}
+		require.Equal(t, 6, hits)
+
+		// a 304 without a Last-Modified keeps the cached one
+		for range 3 {
+			body, err := cachedGet(server.URL + "/bare")
+			require.NoError(t, err)
+			require.Equal(t, `{"path": "/bare"}`, body)
+		}
+		require.Equal(t, 9, hits)
+		require.Equal(t, 2, bareRevalidations)
+	})
+
+	t.Run("Test retries", func(t *testing.T) {
+		failures := map[string]int{"/flaky": 2, "/down": maxAttempts}
+		hits := map[string]int{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[r.URL.Path]++
+			if r.URL.Path == "/missing" {
+				http.NotFound(w, r)
+				return
+			}
+			if hits[r.URL.Path] <= failures[r.URL.Path] {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `{}`)
+		}))
+		defer server.Close()
+
+		delays = nil
+		_, err := cachedGet(server.URL + "/flaky")
+		require.NoError(t, err)
+		require.Equal(t, 3, hits["/flaky"])
+		require.Len(t, delays, 2)
+
+		delays = nil
+		_, err = cachedGet(server.URL + "/down")
+		require.Error(t, err)
+		require.Equal(t, maxAttempts, hits["/down"])
+		require.Len(t, delays, maxAttempts-1)
+
+		// a client error isn't retried
+		_, err = cachedGet(server.URL + "/missing")
+		require.Error(t, err)
+		require.Equal(t, 1, hits["/missing"])
+	})
+
+	t.Run("Test the points cache", func(t *testing.T) {
+		lookups := 0
+		server := testWeatherAPI(&lookups)
+		defer server.Close()
+
+		provider := &nwsProvider{url: server.URL}
+		for range 2 {
+			_, _, err := provider.Forecast(38.6, -90.2)
+			require.NoError(t, err)
+		}
+		require.Equal(t, 1, lookups)
+
+		urls, err := getPoint(server.URL, "38.6000,-90.2000")
+		require.NoError(t, err)
+		require.Equal(t, server.URL+"/forecast", *urls.ForecastURL)
+
+		// a grid that has moved is looked up again
+		moved := server.URL + "/moved"
+		require.NoError(t, storePoint(server.URL, "38.6000,-90.2000", &forecastURL{ForecastURL: &moved}))
+		periods, _, err := provider.Forecast(38.6, -90.2)
+		require.NoError(t, err)
+		require.Len(t, periods, 2)
+		require.Equal(t, 2, lookups)
+	})
+
+	t.Run("Test a failing location doesn't stop the others", func(t *testing.T) {
+		lookups := 0
+		server := testWeatherAPI(&lookups)
+		defer server.Close()
+		down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.Contains(r.URL.Path, "10.0000") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, server.URL+r.URL.Path, http.StatusTemporaryRedirect)
+		}))
+		defer down.Close()
+		defer useProvider(configs.NWSProvider, &down.URL, nil)()
+
+		good, bad := 20.0, 10.0
+		goodZip := &ZipCode{Latitude: &good, Longitude: &good}
+		badZip := &ZipCode{Latitude: &bad, Longitude: &bad}
+		err := getWeatherHelper(map[string]*ZipCode{"good": goodZip, "bad": badZip})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "bad")
+		require.NotContains(t, err.Error(), "good")
+		require.NotNil(t, goodZip.Forecast)
+		require.Nil(t, badZip.Forecast)
+	})
+}
@@ -0,0 +1,355 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+// maxDegreeDayDays is the longest span of dates a growing degree day report covers
+const maxDegreeDayDays = 366
+
+var (
+	// when the newest days of a location were last asked for by a report, the archive is a few days behind so the days
+	// it didn't have aren't asked for again until the observations are next due to be refreshed
+	recentObservationFetches      = make(map[string]time.Time)
+	recentObservationFetchesMutex sync.Mutex
+
+	cropTableName = "degreeDayCrops"
+	cropTable     = &configs.Table{
+		// the temperatures are fahrenheit and the maturity is the growing degree days from planting to harvest
+		CreateSQL: `CREATE TABLE IF NOT EXISTS degreeDayCrops (
+			name varchar(128) NOT NULL PRIMARY KEY COLLATE NOCASE,
+			base real NOT NULL,
+			cap real,
+			maturity real)`,
+		InsertSQL: `INSERT INTO degreeDayCrops values(?,?,?,?) ON CONFLICT(name) DO UPDATE set
+			base = excluded.base, cap = excluded.cap, maturity = excluded.maturity`,
+		DeleteSQL: "DELETE FROM degreeDayCrops where name = ?",
+		Defaults: map[string]string{
+			"tomato":  "insert or ignore into degreeDayCrops values ('Tomato', 50, 86, 1400)",
+			"pepper":  "insert or ignore into degreeDayCrops values ('Pepper', 50, 86, 1500)",
+			"onion":   "insert or ignore into degreeDayCrops values ('Onion', 40, 86, 1800)",
+			"corn":    "insert or ignore into degreeDayCrops values ('Sweet Corn', 50, 86, 1400)",
+			"pea":     "insert or ignore into degreeDayCrops values ('Pea', 40, null, 1200)",
+			"lettuce": "insert or ignore into degreeDayCrops values ('Lettuce', 40, null, 900)",
+		},
+	}
+)
+
+// Crop is the base temperature a crop grows above, the cap it stops growing faster above and the growing degree days
+// it takes to mature.  The temperatures are fahrenheit
+type Crop struct {
+	Name     *string  `json:"name,omitempty"`
+	Base     *float64 `json:"base,omitempty"`
+	Cap      *float64 `json:"cap,omitempty"`
+	Maturity *float64 `json:"maturity,omitempty"`
+}
+
+// DegreeDayReport is the growing degree days accumulated at a location between two dates, YYYY-MM-DD, for each crop
+type DegreeDayReport struct {
+	Location *string           `json:"location,omitempty"`
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Observed int               `json:"observed"`
+	Crops    []*CropDegreeDays `json:"crops"`
+}
+
+// CropDegreeDays is the growing degree days a crop accumulated, the progress is the percent of the way to maturity
+type CropDegreeDays struct {
+	Crop       *Crop        `json:"crop,omitempty"`
+	DegreeDays float64      `json:"degreeDays"`
+	Progress   *float64     `json:"progress,omitempty"`
+	Days       []*DegreeDay `json:"days"`
+}
+
+// DegreeDay is the growing degree days of one day and the total through it
+type DegreeDay struct {
+	Date        string  `json:"date"`
+	High        float64 `json:"high"`
+	Low         float64 `json:"low"`
+	DegreeDays  float64 `json:"degreeDays"`
+	Accumulated float64 `json:"accumulated"`
+}
+
+// degreeDayRequest is the location, as a weather report is asked for, the crop and the dates, YYYY-MM-DD, of a
+// growing degree day report.  Every crop is reported without one, the dates default to the start of the year to today
+type degreeDayRequest struct {
+	Location *string `json:"location,omitempty"`
+	Country  *string `json:"country,omitempty"`
+	Zip      *string `json:"zip,omitempty"`
+	Crop     *string `json:"crop,omitempty"`
+	From     *string `json:"from,omitempty"`
+	To       *string `json:"to,omitempty"`
+}
+
+func setupDegreeDayCrops() error {
+	return cropTable.CreateTable(&cropTableName)
+}
+
+// GetDegreeDayCrops returns the crops growing degree days are reported for by name
+func GetDegreeDayCrops() ([]*Crop, error) {
+	return queryCrops("")
+}
+
+func queryCrops(where string, args ...any) ([]*Crop, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve crops, sqlite error: %s", dbErr)
+	}
+
+	rows, err := db.Query(fmt.Sprintf("select name, base, cap, maturity from degreeDayCrops %s order by name", where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	crops := []*Crop{}
+	for rows.Next() {
+		crop := Crop{}
+		if err := rows.Scan(&crop.Name, &crop.Base, &crop.Cap, &crop.Maturity); err != nil {
+			return nil, err
+		}
+		crops = append(crops, &crop)
+	}
+	return crops, rows.Err()
+}
+
+// UpdateDegreeDayCrop adds or replaces a crop, the base temperature is required and the cap has to be above it
+func UpdateDegreeDayCrop(data interface{}) (*Crop, error) {
+	var crop Crop
+	if err := unmarshalData(data, &crop); err != nil {
+		return nil, err
+	}
+	if crop.Name == nil || strings.TrimSpace(*crop.Name) == "" {
+		return nil, errors.New("a crop requires a name")
+	}
+	if crop.Base == nil {
+		return nil, errors.New("a crop requires a base temperature")
+	}
+	if crop.Cap != nil && *crop.Cap <= *crop.Base {
+		return nil, errors.New("the cap temperature of a crop must be above its base temperature")
+	}
+	if crop.Maturity != nil && *crop.Maturity <= 0 {
+		return nil, errors.New("the maturity of a crop must be greater than 0")
+	}
+
+	name := strings.TrimSpace(*crop.Name)
+	crop.Name = &name
+	if _, err := cropTable.Exec(cropTable.InsertSQL, crop.Name, crop.Base, crop.Cap, crop.Maturity); err != nil {
+		return nil, err
+	}
+	return &crop, nil
+}
+
+// RemoveDegreeDayCrop deletes a crop
+func RemoveDegreeDayCrop(name *string) (*Crop, error) {
+	if name == nil {
+		return nil, errors.New("a crop name is required")
+	}
+	rows, err := cropTable.Exec(cropTable.DeleteSQL, name)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("crop %s not found", *name)
+	}
+	return &Crop{Name: name}, nil
+}
+
+// getGrowingDegreeDays accumulates the growing degree days of the crops at the location requested, or the user's
+// location, from the observations between the dates.  The observations missing from either end of the dates are
+// retrieved first
+func getGrowingDegreeDays(userID *string, request *degreeDayRequest) (*DegreeDayReport, error) {
+	location, zip, err := findLocation(userID, &locationRequest{Location: request.Location, Country: request.Country,
+		Zip: request.Zip})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	to := today
+	if request.To != nil {
+		if to, err = time.ParseInLocation(time.DateOnly, *request.To, time.Local); err != nil {
+			return nil, fmt.Errorf("to must be a date, YYYY-MM-DD: %s", err)
+		}
+	}
+	from := time.Date(to.Year(), time.January, 1, 0, 0, 0, 0, time.Local)
+	if request.From != nil {
+		if from, err = time.ParseInLocation(time.DateOnly, *request.From, time.Local); err != nil {
+			return nil, fmt.Errorf("from must be a date, YYYY-MM-DD: %s", err)
+		}
+	}
+	if from.After(to) {
+		return nil, errors.New("from must be on or before to")
+	}
+	if to.After(from.AddDate(0, 0, maxDegreeDayDays-1)) {
+		return nil, fmt.Errorf("a growing degree day report covers at most %d days", maxDegreeDayDays)
+	}
+
+	var crops []*Crop
+	if request.Crop != nil {
+		crops, err = queryCrops("where name = ?", *request.Crop)
+		if err == nil && len(crops) == 0 {
+			err = fmt.Errorf("crop %s not found", *request.Crop)
+		}
+	} else {
+		crops, err = GetDegreeDayCrops()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fillObservations(*location, zip, from, to, today); err != nil {
+		return nil, err
+	}
+
+	observations, err := getStoredObservations(*location, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DegreeDayReport{
+		Location: location,
+		From:     from.Format(time.DateOnly),
+		To:       to.Format(time.DateOnly),
+		Observed: len(observations),
+		Crops:    make([]*CropDegreeDays, 0, len(crops)),
+	}
+	for _, crop := range crops {
+		report.Crops = append(report.Crops, accumulate(crop, observations))
+	}
+	return report, nil
+}
+
+// fillObservations retrieves the observations from the dates, through yesterday, that are before the location's oldest
+// observation or after its most recent one.  A user's location is kept up to date on the schedule but its observations
+// may not go back as far as the dates do
+func fillObservations(location string, zip *ZipCode, from, to, today time.Time) error {
+	last := today.AddDate(0, 0, -1)
+	if to.Before(last) {
+		last = to
+	}
+	if from.After(last) {
+		return nil
+	}
+
+	earliest, latest, err := observationRange(location)
+	if err != nil {
+		return err
+	}
+	if earliest == nil {
+		_, err := fetchObservations(location, zip, from, last)
+		markObservationFetch(location)
+		return err
+	}
+
+	if from.Before(*earliest) {
+		end := earliest.AddDate(0, 0, -1)
+		if last.Before(end) {
+			end = last
+		}
+		if _, err := fetchObservations(location, zip, from, end); err != nil {
+			return err
+		}
+	}
+
+	if latest.Before(last) && !fetchedRecently(location) {
+		start := latest.AddDate(0, 0, 1)
+		if start.Before(from) {
+			start = from
+		}
+		_, err := fetchObservations(location, zip, start, last)
+		markObservationFetch(location)
+		return err
+	}
+	return nil
+}
+
+// markObservationFetch records that the newest days of the location were asked for, the older records are dropped
+func markObservationFetch(location string) {
+	now := time.Now()
+	recentObservationFetchesMutex.Lock()
+	defer recentObservationFetchesMutex.Unlock()
+	for key, fetched := range recentObservationFetches {
+		if now.Sub(fetched) >= observationInterval() {
+			delete(recentObservationFetches, key)
+		}
+	}
+	recentObservationFetches[location] = now
+}
+
+// fetchedRecently is true when the newest days of the location were asked for less than an observation interval ago
+func fetchedRecently(location string) bool {
+	recentObservationFetchesMutex.Lock()
+	defer recentObservationFetchesMutex.Unlock()
+	fetched, ok := recentObservationFetches[location]
+	return ok && time.Since(fetched) < observationInterval()
+}
+
+// accumulate adds up the growing degree days of the crop over the observations
+func accumulate(crop *Crop, observations []*Observation) *CropDegreeDays {
+	cropDays := &CropDegreeDays{Crop: crop, Days: make([]*DegreeDay, 0, len(observations))}
+	accumulated := 0.0
+	for _, observation := range observations {
+		if observation.Date == nil || observation.High == nil || observation.Low == nil {
+			log.Debugf("skipping an incomplete observation for %s", *crop.Name)
+			continue
+		}
+		degreeDays := degreeDays(*observation.High, *observation.Low, *crop.Base, crop.Cap)
+		accumulated += degreeDays
+		cropDays.Days = append(cropDays.Days, &DegreeDay{
+			Date:        *observation.Date,
+			High:        *observation.High,
+			Low:         *observation.Low,
+			DegreeDays:  round(degreeDays),
+			Accumulated: round(accumulated),
+		})
+	}
+
+	cropDays.DegreeDays = round(accumulated)
+	if crop.Maturity != nil {
+		progress := round(math.Min(accumulated / *crop.Maturity * 100, 100))
+		cropDays.Progress = &progress
+	}
+	return cropDays
+}
+
+// degreeDays is the modified average method, the high and low are held between the base and the cap before they are
+// averaged so a cold night or a day too hot to grow in doesn't count against the day
+func degreeDays(high, low, base float64, upper *float64) float64 {
+	clamp := func(temperature float64) float64 {
+		temperature = math.Max(temperature, base)
+		if upper != nil {
+			temperature = math.Min(temperature, *upper)
+		}
+		return temperature
+	}
+	return (clamp(high)+clamp(low))/2 - base
+}
+
+// round is to a tenth of a degree day
+func round(value float64) float64 {
+	return math.Round(value*10) / 10
+}
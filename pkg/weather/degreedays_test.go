@@ -0,0 +1,169 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/utils"
+)
+
+func TestDegreeDayFunctions(t *testing.T) {
+	initWeatherTest()
+	lookups := 0
+	server := testObservationAPI(t, &lookups)
+	defer server.Close()
+	defer useObservationURL(server.URL)()
+
+	location := "Degree Day Test, MO. 99904"
+	latitude, longitude := 38.6, -90.2
+	defer storeTestLocation(t, &ZipCode{
+		Country:           utils.StringPointer("US"),
+		ZipCode:           utils.StringPointer("99904"),
+		City:              utils.StringPointer("Degree Day Test"),
+		StateAbbreviation: utils.StringPointer("MO"),
+		Latitude:          &latitude,
+		Longitude:         &longitude,
+	})()
+	removeTestObservations(t, location)
+	defer removeTestObservations(t, location)
+	defer func() {
+		ZipCodeCacheMutex.Lock()
+		delete(ZipcodeLookup, location)
+		ZipCodeCacheMutex.Unlock()
+	}()
+
+	t.Run("Test the degree days of a day", func(t *testing.T) {
+		upper := 86.0
+		require.Equal(t, 20.0, degreeDays(80, 60, 50, &upper))
+		// the low is held at the base and the high at the cap
+		require.Equal(t, 18.0, degreeDays(90, 40, 50, &upper))
+		require.Equal(t, 0.0, degreeDays(45, 30, 50, &upper))
+		require.Equal(t, 25.0, degreeDays(90, 40, 40, nil))
+	})
+
+	t.Run("Test maintaining the crops", func(t *testing.T) {
+		crops, err := GetDegreeDayCrops()
+		require.NoError(t, err)
+		require.NotEmpty(t, crops)
+
+		_, err = UpdateDegreeDayCrop(map[string]interface{}{"name": "Squash"})
+		require.Error(t, err)
+		_, err = UpdateDegreeDayCrop(map[string]interface{}{"name": "Squash", "base": 50, "cap": 45})
+		require.Error(t, err)
+		_, err = UpdateDegreeDayCrop(map[string]interface{}{"name": "Squash", "base": 50, "maturity": -1})
+		require.Error(t, err)
+
+		crop, err := UpdateDegreeDayCrop(map[string]interface{}{"name": " Squash ", "base": 50, "cap": 92, "maturity": 1000})
+		require.NoError(t, err)
+		require.Equal(t, "Squash", *crop.Name)
+
+		name := "squash"
+		_, err = RemoveDegreeDayCrop(&name)
+		require.NoError(t, err)
+		_, err = RemoveDegreeDayCrop(&name)
+		require.Error(t, err)
+	})
+
+	t.Run("Test the growing degree day report", func(t *testing.T) {
+		crop, from, to := "tomato", "2026-05-01", "2026-05-11"
+		report, err := getGrowingDegreeDays(nil, &degreeDayRequest{Location: &location, Crop: &crop, From: &from, To: &to})
+		require.NoError(t, err)
+		require.Equal(t, 1, lookups)
+		require.Equal(t, location, *report.Location)
+		// the archive doesn't have the last day asked for yet
+		require.Equal(t, 10, report.Observed)
+		require.Len(t, report.Crops, 1)
+		require.Equal(t, "Tomato", *report.Crops[0].Crop.Name)
+		require.Equal(t, 200.0, report.Crops[0].DegreeDays)
+		require.Equal(t, 14.3, *report.Crops[0].Progress)
+		require.Len(t, report.Crops[0].Days, 10)
+		require.Equal(t, 20.0, report.Crops[0].Days[0].DegreeDays)
+		require.Equal(t, 200.0, report.Crops[0].Days[9].Accumulated)
+
+		// every crop is reported and the stored observations are used
+		report, err = getGrowingDegreeDays(nil, &degreeDayRequest{Location: &location, From: &from, To: &to})
+		require.NoError(t, err)
+		require.Equal(t, 1, lookups)
+		require.Greater(t, len(report.Crops), 1)
+
+		// only the days before the stored observations are retrieved, the newest day was asked for too recently to ask again
+		// the test api leaves the last day it's asked for empty so 04-30 stays unobserved
+		earlier := "2026-04-25"
+		report, err = getGrowingDegreeDays(nil, &degreeDayRequest{Location: &location, Crop: &crop, From: &earlier, To: &to})
+		require.NoError(t, err)
+		require.Equal(t, 2, lookups)
+		require.Equal(t, 15, report.Observed)
+		require.Equal(t, 300.0, report.Crops[0].DegreeDays)
+
+		// a report can't cover more than a year
+		longAgo := "2024-05-01"
+		_, err = getGrowingDegreeDays(nil, &degreeDayRequest{Location: &location, From: &longAgo, To: &to})
+		require.Error(t, err)
+
+		bogus := "bogus"
+		_, err = getGrowingDegreeDays(nil, &degreeDayRequest{Location: &location, Crop: &bogus})
+		require.Error(t, err)
+		_, err = getGrowingDegreeDays(nil, &degreeDayRequest{Location: &location, From: &to, To: &from})
+		require.Error(t, err)
+		_, err = getGrowingDegreeDays(nil, &degreeDayRequest{Location: &location, From: &bogus})
+		require.Error(t, err)
+		_, err = getGrowingDegreeDays(nil, &degreeDayRequest{})
+		require.Error(t, err)
+	})
+
+	t.Run("Test the routes", func(t *testing.T) {
+		route := Route
+		requestType := getGrowingDegreeDaysRequestKey
+		request := &configs.WsMessage{Route: &route, Type: &requestType, Data: map[string]interface{}{
+			"location": location, "crop": "Pepper", "from": "2026-05-01", "to": "2026-05-11",
+		}}
+		response := &configs.WsMessage{}
+		HandleWebsocketRequest(request, response)
+		require.Nil(t, response.Error)
+		report, ok := response.Data.(*DegreeDayReport)
+		require.True(t, ok)
+		require.Equal(t, "Pepper", *report.Crops[0].Crop.Name)
+
+		requestType = getDegreeDayCropsRequestKey
+		response = &configs.WsMessage{}
+		HandleWebsocketRequest(request, response)
+		require.Nil(t, response.Error)
+		require.NotEmpty(t, response.Data)
+
+		w := httptest.NewRecorder()
+		HandleRESTRequest(w, httptest.NewRequest(http.MethodGet,
+			"/REST/v1.0.0/weather/getGrowingDegreeDays?zip=99904&crop=Tomato&from=2026-05-01&to=2026-05-06", nil), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		require.Equal(t, 6, report.Observed)
+		require.Equal(t, 120.0, report.Crops[0].DegreeDays)
+
+		w = httptest.NewRecorder()
+		HandleRESTRequest(w, httptest.NewRequest(http.MethodGet, "/REST/v1.0.0/weather/getDegreeDayCrops", nil), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = httptest.NewRecorder()
+		HandleRESTRequest(w, httptest.NewRequest(http.MethodGet,
+			"/REST/v1.0.0/weather/getGrowingDegreeDays?zip=99904&from=someday", nil), nil)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,61 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+// shippedFixture is the forecast the fixture provider serves when no file is configured, it has a frost and a
+// precipitation period so the alerts can be seen offline
+//
+//go:embed fixture_forecast.json
+var shippedFixture []byte
+
+// fixtureProvider serves the same forecast for every location from a file, the file is read on every lookup so it
+// can be edited while the application is running
+type fixtureProvider struct {
+	file *string
+}
+
+type fixtureForecast struct {
+	Periods []*Forecast `json:"periods,omitempty"`
+	Hourly  []*Forecast `json:"hourly,omitempty"`
+}
+
+func (provider *fixtureProvider) Name() string {
+	return configs.FixtureProvider
+}
+
+func (provider *fixtureProvider) Forecast(_, _ float64) ([]*Forecast, []*Forecast, error) {
+	data := shippedFixture
+	if provider.file != nil {
+		var err error
+		if data, err = os.ReadFile(*provider.file); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var fixture fixtureForecast
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, nil, fmt.Errorf("invalid weather fixture: %s", err)
+	}
+	return fixture.Periods, fixture.Hourly, nil
+}
@@ -0,0 +1,259 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+	"stl-go/grow-with-stl-go/pkg/utils"
+)
+
+const earthRadiusKm = 6371.0
+
+var (
+	// shippedFrostDates is a sample of US stations with their approximate median frost dates, a complete dataset
+	// can be configured with configs.FrostDates
+	//go:embed frost_dates.csv
+	shippedFrostDates []byte
+
+	frostDateStations      []*FrostDateStation
+	frostDateStationsMutex sync.RWMutex
+)
+
+// FrostDateStation is a weather station with the median dates of the last spring frost and the first fall frost as MM-DD,
+// a station without frost dates doesn't get a frost
+type FrostDateStation struct {
+	Station         *string  `json:"station,omitempty"`
+	Name            *string  `json:"name,omitempty"`
+	State           *string  `json:"state,omitempty"`
+	Latitude        *float64 `json:"latitude,omitempty"`
+	Longitude       *float64 `json:"longitude,omitempty"`
+	LastSpringFrost *string  `json:"lastSpringFrost,omitempty"`
+	FirstFallFrost  *string  `json:"firstFallFrost,omitempty"`
+	// DistanceKm is how far the station is from the location it was looked up for
+	DistanceKm *float64 `json:"distanceKm,omitempty"`
+}
+
+// getFrostDates loads the shipped frost dates and then, if a dataset is configured, replaces them with the download
+func getFrostDates() error {
+	if err := loadFrostDates(bytes.NewReader(shippedFrostDates)); err != nil {
+		return err
+	}
+
+	if configs.GrowSTLGo != nil && configs.GrowSTLGo.FrostDates != nil && configs.GrowSTLGo.FrostDates.URL != nil {
+		go func() {
+			fileName, err := configs.GrowSTLGo.FrostDates.GetFrostDateData()
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			f, err := os.Open(*fileName)
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			defer f.Close()
+			if err := loadFrostDates(f); err != nil {
+				log.Error(err)
+			}
+		}()
+	}
+	return nil
+}
+
+// loadFrostDates replaces the frost date stations with the csv, records that can't be read are skipped
+func loadFrostDates(r io.Reader) error {
+	defer log.FunctionTimer()()
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 7
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	stations := []*FrostDateStation{}
+	for i, record := range records {
+		// skip the header
+		if i == 0 && record[0] == "station" {
+			continue
+		}
+
+		latitude, latitudeErr := strconv.ParseFloat(record[3], 64)
+		longitude, longitudeErr := strconv.ParseFloat(record[4], 64)
+		if latitudeErr != nil || longitudeErr != nil {
+			log.Errorf("frost date station %s has an invalid latitude / longitude", record[0])
+			continue
+		}
+
+		station := &FrostDateStation{
+			Station:   utils.StringPointer(record[0]),
+			Name:      utils.StringPointer(record[1]),
+			State:     utils.StringPointer(record[2]),
+			Latitude:  &latitude,
+			Longitude: &longitude,
+		}
+		for i, field := range []**string{&station.LastSpringFrost, &station.FirstFallFrost} {
+			if value := strings.TrimSpace(record[5+i]); value != "" {
+				if _, err := time.Parse("01-02", value); err != nil {
+					log.Errorf("frost date station %s has an invalid frost date %s", record[0], value)
+					continue
+				}
+				*field = utils.StringPointer(value)
+			}
+		}
+		if (station.LastSpringFrost == nil) != (station.FirstFallFrost == nil) {
+			log.Errorf("frost date station %s needs both frost dates or neither", record[0])
+			continue
+		}
+		stations = append(stations, station)
+	}
+
+	if len(stations) == 0 {
+		return errors.New("no frost date stations were loaded")
+	}
+
+	frostDateStationsMutex.Lock()
+	frostDateStations = stations
+	frostDateStationsMutex.Unlock()
+	log.Tracef("%d frost date stations loaded", len(stations))
+	return nil
+}
+
+// GetFrostDates returns a copy of the station nearest to the latitude / longitude, the shipped frost dates are loaded
+// if nothing has been
+func GetFrostDates(latitude, longitude float64) (*FrostDateStation, error) {
+	frostDateStationsMutex.RLock()
+	loaded := len(frostDateStations) > 0
+	frostDateStationsMutex.RUnlock()
+	if !loaded {
+		if err := loadFrostDates(bytes.NewReader(shippedFrostDates)); err != nil {
+			return nil, err
+		}
+	}
+
+	frostDateStationsMutex.RLock()
+	defer frostDateStationsMutex.RUnlock()
+
+	var nearest *FrostDateStation
+	distance := math.MaxFloat64
+	for _, station := range frostDateStations {
+		if d := haversineKm(latitude, longitude, *station.Latitude, *station.Longitude); d < distance {
+			nearest, distance = station, d
+		}
+	}
+	if nearest == nil {
+		return nil, errors.New("no frost date stations are loaded")
+	}
+
+	station := *nearest
+	distance = math.Round(distance*10) / 10
+	station.DistanceKm = &distance
+	return &station, nil
+}
+
+// FrostFree is true for stations that don't get a frost
+func (station *FrostDateStation) FrostFree() bool {
+	return station.LastSpringFrost == nil
+}
+
+// LastFrost is the date of the last spring frost in the year
+func (station *FrostDateStation) LastFrost(year int) (*time.Time, error) {
+	return frostDate(station.LastSpringFrost, year)
+}
+
+// FirstFrost is the date of the first fall frost in the year
+func (station *FrostDateStation) FirstFrost(year int) (*time.Time, error) {
+	return frostDate(station.FirstFallFrost, year)
+}
+
+func frostDate(monthDay *string, year int) (*time.Time, error) {
+	if monthDay == nil {
+		return nil, errors.New("the station doesn't get a frost")
+	}
+	date, err := time.Parse("2006-01-02", fmt.Sprintf("%d-%s", year, *monthDay))
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
+
+// haversineKm is the great circle distance between two latitude / longitudes
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// UserLocation returns the location key configured for the user
+func UserLocation(userID *string) (*string, error) {
+	if userID == nil || configs.GrowSTLGo == nil {
+		return nil, errors.New("cannot find the location without a user")
+	}
+
+	configs.GrowSTLGo.UsersMutex.Lock()
+	defer configs.GrowSTLGo.UsersMutex.Unlock()
+	if user, ok := configs.GrowSTLGo.Users[*userID]; ok && user.Location != nil {
+		return user.Location, nil
+	}
+	return nil, fmt.Errorf("user %s doesn't have a location", *userID)
+}
+
+// GetUserLocation returns the zip code of the user's configured location
+func GetUserLocation(userID *string) (*ZipCode, error) {
+	location, err := UserLocation(userID)
+	if err != nil {
+		return nil, err
+	}
+	return GetLocation(location)
+}
+
+// GetLocation returns the zip code for a location key such as "Saint Louis, MO. 63101", a location that hasn't been
+// looked up yet is loaded from the database
+func GetLocation(location *string) (*ZipCode, error) {
+	if location == nil {
+		return nil, errors.New("no location given")
+	}
+	ZipCodeCacheMutex.Lock()
+	zipCode, ok := ZipcodeLookup[*location]
+	ZipCodeCacheMutex.Unlock()
+	if !ok {
+		key, zc, err := queryLocation("location = ?", *location)
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			zipCode, ok = cacheLocation(*key, zc), true
+		}
+	}
+	if !ok || zipCode.Latitude == nil || zipCode.Longitude == nil {
+		return nil, fmt.Errorf("location %s not found", *location)
+	}
+	return zipCode, nil
+}
@@ -0,0 +1,98 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+func TestFrostDateFunctions(t *testing.T) {
+	t.Run("Test the shipped frost dates", func(t *testing.T) {
+		require.NoError(t, loadFrostDates(bytes.NewReader(shippedFrostDates)))
+
+		station, err := GetFrostDates(38.6270, -90.1994)
+		require.NoError(t, err)
+		require.Equal(t, "KSTL", *station.Station)
+		require.Less(t, *station.DistanceKm, 25.0)
+		require.False(t, station.FrostFree())
+
+		lastFrost, err := station.LastFrost(2025)
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2025, time.April, 5, 0, 0, 0, 0, time.UTC), *lastFrost)
+
+		station, err = GetFrostDates(25.7617, -80.1918)
+		require.NoError(t, err)
+		require.True(t, station.FrostFree())
+		_, err = station.FirstFrost(2025)
+		require.Error(t, err)
+	})
+
+	t.Run("Test invalid frost dates are skipped", func(t *testing.T) {
+		t.Cleanup(func() {
+			require.NoError(t, loadFrostDates(bytes.NewReader(shippedFrostDates)))
+		})
+
+		dataset := "station,name,state,latitude,longitude,lastSpringFrost,firstFallFrost\n" +
+			"A,Good,MO,38.6,-90.2,04-05,10-28\n" +
+			"B,No latitude,MO,north,-90.2,04-05,10-28\n" +
+			"C,Bad date,MO,38.6,-90.2,13-45,10-28\n" +
+			"D,One date,MO,38.6,-90.2,04-05,\n"
+		require.NoError(t, loadFrostDates(strings.NewReader(dataset)))
+		frostDateStationsMutex.RLock()
+		require.Len(t, frostDateStations, 1)
+		frostDateStationsMutex.RUnlock()
+
+		require.Error(t, loadFrostDates(strings.NewReader("station,name,state,latitude,longitude,lastSpringFrost,firstFallFrost\n")))
+		require.Error(t, loadFrostDates(strings.NewReader("too,few,columns\n")))
+	})
+
+	t.Run("Test user locations", func(t *testing.T) {
+		location := "Frost Test, MO. 63101"
+		latitude, longitude := 38.63, -90.2
+		ZipCodeCacheMutex.Lock()
+		ZipcodeLookup[location] = &ZipCode{Latitude: &latitude, Longitude: &longitude}
+		ZipCodeCacheMutex.Unlock()
+		t.Cleanup(func() {
+			ZipCodeCacheMutex.Lock()
+			delete(ZipcodeLookup, location)
+			ZipCodeCacheMutex.Unlock()
+		})
+
+		zipCode, err := GetLocation(&location)
+		require.NoError(t, err)
+		require.InDelta(t, 38.63, *zipCode.Latitude, 0.001)
+
+		missing := "Nowhere, ZZ. 00000"
+		_, err = GetLocation(&missing)
+		require.Error(t, err)
+
+		config := configs.GrowSTLGo
+		configs.GrowSTLGo = &configs.Config{Users: map[string]*configs.User{"frost": {Location: &location}, "nowhere": {}}}
+		t.Cleanup(func() { configs.GrowSTLGo = config })
+		user := "frost"
+		_, err = GetUserLocation(&user)
+		require.NoError(t, err)
+		user = "nowhere"
+		_, err = GetUserLocation(&user)
+		require.Error(t, err)
+	})
+}
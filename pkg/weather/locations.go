@@ -16,36 +16,100 @@ package weather
 
 import (
 	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
 	"encoding/csv"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"maps"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"stl-go/grow-with-stl-go/pkg/configs"
 	"stl-go/grow-with-stl-go/pkg/log"
 	"stl-go/grow-with-stl-go/pkg/utils"
 )
 
+const (
+	// DefaultSearchLimit is how many locations a search returns when the request doesn't say
+	DefaultSearchLimit = 10
+	// MaxSearchLimit is the most locations a search will return
+	MaxSearchLimit = 50
+
+	// locationKeyVersion is part of the archive checksum so changing how the locations are keyed loads them again
+	locationKeyVersion = "2"
+
+	// adHocLocationTTL is how long a location that was asked for by zip code or key, rather than being a user's, is kept
+	// in memory with its forecast
+	adHocLocationTTL = time.Hour
+	// maxAdHocLocations is the most of those locations kept in memory, the oldest is dropped to make room
+	maxAdHocLocations = 100
+)
+
 var (
-	// ZipcodeLookup is a lookup for the typeahead
+	// ZipcodeLookup holds the locations that have been looked up along with their forecasts, the rest of the
+	// locations stay in the database until they are asked for
 	ZipcodeLookup = make(map[string]*ZipCode)
-	// ZipCodeCacheMutex is the controlling mutex for ZipCodeCache
+	// ZipCodeCacheMutex is the controlling mutex for ZipcodeLookup
 	ZipCodeCacheMutex sync.Mutex
-	// ZipCodeTypeahead used by the UI to do type ahead for locations
-	ZipCodeTypeahead = []string{}
+
+	// adHocLocations are kept apart from ZipcodeLookup so they aren't refreshed on the schedule and are dropped once
+	// they expire
+	adHocLocations      = make(map[string]*adHocLocation)
+	adHocLocationsMutex sync.Mutex
+
+	locationTableName     = "locations"
+	locationFileTableName = "locationFiles"
+	locationTables        = map[string]*configs.Table{
+		locationTableName: {
+			CreateSQL: `CREATE TABLE IF NOT EXISTS locations (
+				location varchar(255) NOT NULL COLLATE NOCASE,
+				country varchar(2) NOT NULL,
+				zipCode varchar(20) NOT NULL COLLATE NOCASE,
+				city varchar(255),
+				state varchar(255),
+				stateAbbreviation varchar(20),
+				county varchar(255),
+				latitude real NOT NULL,
+				longitude real NOT NULL,
+				PRIMARY KEY (location))`,
+			InsertSQL: `INSERT INTO locations values(?,?,?,?,?,?,?,?,?) ON CONFLICT(location) DO UPDATE SET
+				country = excluded.country, zipCode = excluded.zipCode, city = excluded.city, state = excluded.state,
+				stateAbbreviation = excluded.stateAbbreviation, county = excluded.county, latitude = excluded.latitude,
+				longitude = excluded.longitude`,
+			DeleteSQL: "DELETE FROM locations where country = ?",
+			Indices: []string{
+				"CREATE INDEX IF NOT EXISTS locationzip on locations(zipCode)",
+				"CREATE INDEX IF NOT EXISTS locationcountry on locations(country)",
+			},
+		},
+		// the checksum of the archive the country's locations were loaded from so an unchanged download isn't loaded again
+		locationFileTableName: {
+			CreateSQL: `CREATE TABLE IF NOT EXISTS locationFiles (
+				country varchar(2) NOT NULL,
+				checksum varchar(64) NOT NULL,
+				locations integer NOT NULL,
+				loaded bigint NOT NULL,
+				PRIMARY KEY (country))`,
+			InsertSQL: `INSERT INTO locationFiles values(?,?,?,?) ON CONFLICT(country) DO UPDATE SET
+				checksum = excluded.checksum, locations = excluded.locations, loaded = excluded.loaded`,
+		},
+	}
 )
 
 // ZipCode contains the extracted city & location details for the data extracted from https://download.geonames.org/export/zip/
 type ZipCode struct {
-	// the record is in this order
+	// the record is in this order, postal codes are kept as published since many countries' aren't numbers
 	Country           *string  `json:"country,omitempty"`
-	ZipCode           *int     `json:"zip_code,omitempty"`
+	ZipCode           *string  `json:"zip_code,omitempty"`
 	City              *string  `json:"city,omitempty"`
 	State             *string  `json:"state,omitempty"`
 	StateAbbreviation *string  `json:"state_abbreviation,omitempty"`
@@ -55,20 +119,35 @@ type ZipCode struct {
 
 	Mutex sync.Mutex `json:"-"`
 
-	// stuff we're using for weather lookups
-	Forecast *Forecast `json:"forecast,omitempty"`
+	// stuff we're using for weather lookups, the forecast is the current period of the full list.  The periods and
+	// hourly forecast are served by the weather route rather than sent with the location
+	Forecast *Forecast   `json:"forecast,omitempty"`
+	Periods  []*Forecast `json:"-"`
+	Hourly   []*Forecast `json:"-"`
+	Updated  *int64      `json:"-"`
 }
 
 func getLocations() error {
-	// we're going to get our zip code based locations on the country here: https://download.geonames.org/export/zip/
-	if configs.GrowSTLGo != nil && configs.GrowSTLGo.Country != nil && configs.GrowSTLGo.Country.Country != nil {
+	// we're going to get our postal code based locations for each country here: https://download.geonames.org/export/zip/
+	if configs.GrowSTLGo != nil && configs.GrowSTLGo.Country != nil && len(configs.GrowSTLGo.Country.Countries) > 0 {
+		markLocationRefresh()
+		countries := slices.Clone(configs.GrowSTLGo.Country.Countries)
 		go func() {
-			fileName, err := configs.GrowSTLGo.Country.GetCountryData()
-			if err != nil {
+			// a country that can't be downloaded doesn't keep the others from loading
+			for _, country := range countries {
+				fileName, err := configs.GrowSTLGo.Country.GetCountryData(country)
+				if err != nil {
+					log.Errorf("cannot download the locations for %s: %s", country, err)
+					continue
+				}
+				if err := extractLocationData(fileName, &country); err != nil {
+					log.Error(err)
+				}
+			}
+			if err := removeCountries(countries); err != nil {
 				log.Error(err)
-				return
 			}
-			if err := extractLocationData(fileName, configs.GrowSTLGo.Country.Country); err != nil {
+			if err := migrateUserLocations(); err != nil {
 				log.Error(err)
 			}
 
@@ -82,10 +161,31 @@ func getLocations() error {
 	return errors.New("invalid country config cannot get locations")
 }
 
-// extractLocationData will extract a zip with country locations, obviously this is US centric and will need to be modified for other countries
+func setupLocations() error {
+	for tableName, table := range locationTables {
+		if err := table.CreateTable(&tableName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractLocationData loads the country's locations from the archive into the database, an archive with the same
+// checksum as the one last loaded is skipped
 func extractLocationData(fileName, country *string) error {
 	defer log.FunctionTimer()()
 	if fileName != nil && country != nil {
+		checksum, err := fileChecksum(*fileName)
+		if err != nil {
+			return err
+		}
+		if loaded, err := locationChecksum(*country); err != nil {
+			return err
+		} else if loaded != nil && *loaded == checksum {
+			log.Tracef("the locations in the %s archive are already loaded", *fileName)
+			return nil
+		}
+
 		log.Tracef("attempting to extract location information from the %s archive", *fileName)
 		f, fileOpenErr := os.Open(*fileName)
 		if fileOpenErr != nil {
@@ -106,62 +206,431 @@ func extractLocationData(fileName, country *string) error {
 		// Iterate through the files in the ZIP archive
 		for _, zf := range zr.File {
 			if strings.EqualFold(*country, strings.TrimSuffix(zf.Name, filepath.Ext(zf.Name))) {
-				// Open the file
-				rc, zipFileErr := zf.Open()
-				if zipFileErr != nil {
-					return zipFileErr
+				count, err := loadLocations(zf, *country, checksum)
+				if err != nil {
+					return err
 				}
-				defer rc.Close()
+				log.Tracef("finished extracting location information from the %s archive.  There are %s zip codes in the database",
+					*fileName, utils.FormatNumber(count))
+				return nil
+			}
+		}
+		return fmt.Errorf("the %s archive has no locations for %s", *fileName, *country)
+	}
+	return errors.New("invalid input file")
+}
 
-				csvReader := csv.NewReader(rc)
-				csvReader.Comma = '\t'
-				rows, csvErr := csvReader.ReadAll()
-				if csvErr != nil {
-					return csvErr
-				}
+// loadLocations replaces the country's locations with the records of the file in a single transaction so lookups see
+// either the old or the new locations, never a partial load
+func loadLocations(zf *zip.File, country, checksum string) (int, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
 
-				for _, record := range rows {
-					if len(record) == 12 {
-						// limit the us territories
-						zipCode, zipCodeErr := strconv.Atoi(record[1])
-						if zipCodeErr != nil {
-							log.Error(zipCode)
-							continue
-						}
-						latitude, latitudeErr := strconv.ParseFloat(record[9], 64)
-						if latitudeErr != nil {
-							log.Error(latitudeErr)
-							continue
-						}
-						longitude, longitudeErr := strconv.ParseFloat(record[10], 64)
-						if longitudeErr != nil {
-							log.Error(longitudeErr)
-							continue
-						}
-
-						zc := &ZipCode{
-							Country:           utils.StringPointer(record[0]),
-							ZipCode:           &zipCode,
-							City:              utils.StringPointer(record[2]),
-							State:             utils.StringPointer(record[3]),
-							StateAbbreviation: utils.StringPointer(record[4]),
-							County:            utils.StringPointer(record[5]),
-							Latitude:          &latitude,
-							Longitude:         &longitude,
-						}
-						ZipCodeCacheMutex.Lock()
-						ZipcodeLookup[fmt.Sprintf("%s, %s. %d", record[2], record[4], zipCode)] = zc
-						ZipCodeCacheMutex.Unlock()
-					}
-				}
-			}
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return 0, fmt.Errorf("unable to load locations, sqlite error: %s", dbErr)
+	}
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer configs.RollbackHelper(tx)
+
+	locations, files := locationTables[locationTableName], locationTables[locationFileTableName]
+	if _, err := locations.ExecTx(tx, locations.DeleteSQL, country); err != nil {
+		return 0, err
+	}
+	insert, err := tx.Prepare(locations.InsertSQL)
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close()
+
+	csvReader := csv.NewReader(rc)
+	csvReader.Comma = '\t'
+	// records without every field are skipped rather than failing the load
+	csvReader.FieldsPerRecord = -1
+	count := 0
+	for {
+		record, csvErr := csvReader.Read()
+		if errors.Is(csvErr, io.EOF) {
+			break
+		}
+		if csvErr != nil {
+			return 0, csvErr
 		}
-		ZipCodeCacheMutex.Lock()
-		ZipCodeTypeahead = slices.Collect(maps.Keys(ZipcodeLookup))
-		ZipCodeCacheMutex.Unlock()
-		log.Tracef("finished extracting location information from the %s archive.  There are %s zip codes in the cache",
-			*fileName, utils.FormatNumber(len(ZipcodeLookup)))
+
+		zc := parseLocation(record)
+		if zc == nil {
+			continue
+		}
+		if _, err := insert.Exec(locationKey(zc), zc.Country, zc.ZipCode, zc.City, zc.State, zc.StateAbbreviation, zc.County,
+			zc.Latitude, zc.Longitude); err != nil {
+			return 0, err
+		}
+		count++
+	}
+
+	if _, err := files.ExecTx(tx, files.InsertSQL, country, checksum, count, time.Now().UnixMilli()); err != nil {
+		return 0, err
+	}
+	return count, tx.Commit()
+}
+
+// parseLocation returns the zip code of a geonames record, or nil for a record that can't be used
+func parseLocation(record []string) *ZipCode {
+	if len(record) != 12 {
 		return nil
 	}
-	return errors.New("invalid input file")
+	country, zipCode := strings.ToUpper(strings.TrimSpace(record[0])), normalizePostalCode(record[1])
+	if country == "" || zipCode == "" || strings.TrimSpace(record[2]) == "" {
+		return nil
+	}
+	latitude, latitudeErr := strconv.ParseFloat(record[9], 64)
+	if latitudeErr != nil {
+		log.Error(latitudeErr)
+		return nil
+	}
+	longitude, longitudeErr := strconv.ParseFloat(record[10], 64)
+	if longitudeErr != nil {
+		log.Error(longitudeErr)
+		return nil
+	}
+
+	return &ZipCode{
+		Country:           &country,
+		ZipCode:           &zipCode,
+		City:              utils.StringPointer(strings.TrimSpace(record[2])),
+		State:             utils.StringPointer(record[3]),
+		StateAbbreviation: utils.StringPointer(record[4]),
+		County:            utils.StringPointer(record[5]),
+		Latitude:          &latitude,
+		Longitude:         &longitude,
+	}
+}
+
+// locationKey is how a parsed location is displayed and saved with the users.  US locations keep the "Saint Louis,
+// MO. 63101" form they've always had, the others end with their country, "Toronto, ON. M5A, CA", so the same city and
+// postal code in two countries are told apart
+func locationKey(zc *ZipCode) string {
+	region := ""
+	switch {
+	case zc.StateAbbreviation != nil && *zc.StateAbbreviation != "":
+		region = *zc.StateAbbreviation
+	case zc.State != nil && *zc.State != "":
+		region = *zc.State
+	}
+
+	key := *zc.City
+	if region != "" {
+		key = fmt.Sprintf("%s, %s", key, region)
+	}
+	key = fmt.Sprintf("%s. %s", key, *zc.ZipCode)
+	if *zc.Country != configs.DefaultCountry {
+		key = fmt.Sprintf("%s, %s", key, *zc.Country)
+	}
+	return key
+}
+
+// normalizePostalCode upper cases the postal code and collapses its spaces so "ec1a  1bb" finds "EC1A 1BB"
+func normalizePostalCode(postalCode string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(postalCode)), " ")
+}
+
+func fileChecksum(fileName string) (string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	hash.Write([]byte(locationKeyVersion))
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// locationChecksum returns the checksum of the archive the country's locations were last loaded from
+func locationChecksum(country string) (*string, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve location checksum, sqlite error: %s", dbErr)
+	}
+
+	var checksum string
+	err := db.QueryRow("select checksum from locationFiles where country = ?", country).Scan(&checksum)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &checksum, nil
+}
+
+// queryLocation returns the first location matching the where clause, nil when there isn't one
+func queryLocation(where string, args ...any) (*string, *ZipCode, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve location, sqlite error: %s", dbErr)
+	}
+
+	var location string
+	zc := ZipCode{}
+	err := db.QueryRow("select location, country, zipCode, city, state, stateAbbreviation, county, latitude, longitude "+
+		"from locations where "+where+" order by location limit 1", args...).
+		Scan(&location, &zc.Country, &zc.ZipCode, &zc.City, &zc.State, &zc.StateAbbreviation, &zc.County, &zc.Latitude, &zc.Longitude)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &location, &zc, nil
+}
+
+// cacheLocation keeps the location looked up in memory so its forecast is kept with it, a location that was already
+// cached is returned rather than replaced
+func cacheLocation(location string, zc *ZipCode) *ZipCode {
+	ZipCodeCacheMutex.Lock()
+	defer ZipCodeCacheMutex.Unlock()
+	if cached, ok := ZipcodeLookup[location]; ok {
+		return cached
+	}
+	ZipcodeLookup[location] = zc
+	return zc
+}
+
+// adHocLocation is a location that was looked up for a request and when it was
+type adHocLocation struct {
+	zip    *ZipCode
+	cached time.Time
+}
+
+// cacheAdHocLocation keeps a location asked for by a request with its forecast for a while, a location that is already
+// in ZipcodeLookup is returned from there
+func cacheAdHocLocation(location string, zc *ZipCode) *ZipCode {
+	ZipCodeCacheMutex.Lock()
+	cached, ok := ZipcodeLookup[location]
+	ZipCodeCacheMutex.Unlock()
+	if ok {
+		return cached
+	}
+
+	now := time.Now()
+	adHocLocationsMutex.Lock()
+	defer adHocLocationsMutex.Unlock()
+	if cached, ok := adHocLocations[location]; ok && now.Sub(cached.cached) < adHocLocationTTL {
+		return cached.zip
+	}
+
+	var oldest string
+	for key, cached := range adHocLocations {
+		if now.Sub(cached.cached) >= adHocLocationTTL {
+			delete(adHocLocations, key)
+			continue
+		}
+		if oldest == "" || cached.cached.Before(adHocLocations[oldest].cached) {
+			oldest = key
+		}
+	}
+	if len(adHocLocations) >= maxAdHocLocations {
+		delete(adHocLocations, oldest)
+	}
+	adHocLocations[location] = &adHocLocation{zip: zc, cached: now}
+	return zc
+}
+
+// findAdHocLocation returns the zip code for a location key asked for by a request, it's kept in the ad hoc locations
+// rather than ZipcodeLookup unless it's already there
+func findAdHocLocation(location string) (*ZipCode, error) {
+	ZipCodeCacheMutex.Lock()
+	zc, ok := ZipcodeLookup[location]
+	ZipCodeCacheMutex.Unlock()
+	if !ok {
+		adHocLocationsMutex.Lock()
+		if cached, found := adHocLocations[location]; found && time.Since(cached.cached) < adHocLocationTTL {
+			zc, ok = cached.zip, true
+		}
+		adHocLocationsMutex.Unlock()
+	}
+	if !ok {
+		key, found, err := queryLocation("location = ?", location)
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			zc, ok = cacheAdHocLocation(*key, found), true
+		}
+	}
+	if !ok || zc.Latitude == nil || zc.Longitude == nil {
+		return nil, fmt.Errorf("location %s not found", location)
+	}
+	return zc, nil
+}
+
+// FindPostalCode returns the location key and zip code of the first location with the country's postal code, without
+// a country the postal code is looked up in the first of the configured countries.  The zip code is the one in
+// ZipcodeLookup if the location has been looked up, it isn't added there otherwise
+func FindPostalCode(country, postalCode string) (*string, *ZipCode, error) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if country == "" {
+		country = configs.DefaultCountry
+		if configs.GrowSTLGo != nil {
+			country = configs.GrowSTLGo.Country.PrimaryCountry()
+		}
+	}
+	postalCode = normalizePostalCode(postalCode)
+	if postalCode == "" {
+		return nil, nil, errors.New("no postal code given")
+	}
+
+	location, zc, err := queryLocation("country = ? and zipCode = ?", country, postalCode)
+	if err != nil {
+		return nil, nil, err
+	}
+	if location == nil {
+		return nil, nil, fmt.Errorf("postal code %s not found in %s", postalCode, country)
+	}
+
+	ZipCodeCacheMutex.Lock()
+	defer ZipCodeCacheMutex.Unlock()
+	if cached, ok := ZipcodeLookup[*location]; ok {
+		return location, cached, nil
+	}
+	return location, zc, nil
+}
+
+// removeCountries drops the locations of the countries that are no longer configured
+func removeCountries(countries []string) error {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return fmt.Errorf("unable to remove locations, sqlite error: %s", dbErr)
+	}
+	rows, err := db.Query("select country from locationFiles")
+	if err != nil {
+		return err
+	}
+	var removed []string
+	for rows.Next() {
+		var country string
+		if err := rows.Scan(&country); err != nil {
+			rows.Close()
+			return err
+		}
+		if !slices.Contains(countries, country) {
+			removed = append(removed, country)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	locations, files := locationTables[locationTableName], locationTables[locationFileTableName]
+	for _, country := range removed {
+		log.Debugf("removing the locations of %s, it is no longer configured", country)
+		if _, err := locations.Exec(locations.DeleteSQL, country); err != nil {
+			return err
+		}
+		if _, err := files.Exec("DELETE FROM locationFiles where country = ?", country); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyZipCode matches the US locations saved before postal codes were kept as published, their leading zeros were
+// dropped, "Agawam, MA. 1001"
+var legacyZipCode = regexp.MustCompile(`^(.+\. )(\d{1,4})$`)
+
+// migrateUserLocations moves the users saved with a location keyed the old way to the location's current key
+func migrateUserLocations() error {
+	if configs.GrowSTLGo == nil {
+		return nil
+	}
+	// the locations are copied out under the lock, the users are only changed under it again
+	migrations := make(map[string]string)
+	configs.GrowSTLGo.UsersMutex.Lock()
+	for userID, user := range configs.GrowSTLGo.Users {
+		if user.Location != nil && legacyZipCode.MatchString(*user.Location) {
+			migrations[userID] = *user.Location
+		}
+	}
+	configs.GrowSTLGo.UsersMutex.Unlock()
+
+	var errs []error
+	for userID, legacy := range migrations {
+		if _, err := GetLocation(&legacy); err == nil {
+			continue
+		}
+		parts := legacyZipCode.FindStringSubmatch(legacy)
+		zipCode, err := strconv.Atoi(parts[2])
+		if err != nil {
+			continue
+		}
+		location := fmt.Sprintf("%s%05d", parts[1], zipCode)
+		if _, err := GetLocation(&location); err != nil {
+			continue
+		}
+
+		configs.GrowSTLGo.UsersMutex.Lock()
+		user, ok := configs.GrowSTLGo.Users[userID]
+		moved := ok && user.Location != nil && *user.Location == legacy
+		if moved {
+			user.Location = &location
+		}
+		configs.GrowSTLGo.UsersMutex.Unlock()
+		if !moved {
+			continue
+		}
+
+		log.Debugf("moving %s from location %s to %s", userID, legacy, location)
+		if err := user.Persist(&userID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SearchLocations returns the location keys that start with the prefix, or whose zip code does, in order.  The limit
+// defaults to DefaultSearchLimit and is capped at MaxSearchLimit
+func SearchLocations(prefix string, limit int) ([]string, error) {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return nil, errors.New("a location search requires a prefix")
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to search locations, sqlite error: %s", dbErr)
+	}
+
+	// the wildcards typed in are searched for as themselves
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+	rows, err := db.Query(`select location from locations where location like ? escape '\' or zipCode like ? escape '\'
+		order by location limit ?`, pattern, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locations := []string{}
+	for rows.Next() {
+		var location string
+		if err := rows.Scan(&location); err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, rows.Err()
 }
@@ -15,23 +15,235 @@
 package weather
 
 import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 
-	"stl-go/grow-with-stl-go/pkg/log"
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/utils"
 )
 
-func TestZipFunctions(t *testing.T) {
-	t.Skip()
-	t.Run("Test now timestamp pointer", func(t *testing.T) {
-		file := "C:\\Users\\root\\AppData\\Local\\Temp\\gwstlg500524809\\US.zip"
-		country := "US"
+// the country the test archives are loaded as so they don't replace real locations
+const testCountry = "XT"
 
-		err := extractLocationData(&file, &country)
-		if err != nil {
-			log.Error(err)
+func writeTestArchive(t *testing.T, records ...string) string {
+	fileName := filepath.Join(t.TempDir(), testCountry+".zip")
+	f, err := os.Create(fileName)
+	require.NoError(t, err)
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	w, err := zw.Create(testCountry + ".txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(strings.Join(records, "\n") + "\n"))
+	require.NoError(t, err)
+	readme, err := zw.Create("readme.txt")
+	require.NoError(t, err)
+	_, err = readme.Write([]byte("not locations"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return fileName
+}
+
+func testRecord(zipCode, city string) string {
+	return strings.Join([]string{testCountry, zipCode, city, "Missouri", "MO", "Saint Louis (city)", "510", "", "", "38.6", "-90.2", "4"}, "\t")
+}
+
+// storeTestLocation puts the location in the database the way loading an archive would, the returned function
+// removes it
+func storeTestLocation(t *testing.T, zc *ZipCode) func() {
+	locations := locationTables[locationTableName]
+	_, err := locations.Exec(locations.InsertSQL, locationKey(zc), zc.Country, zc.ZipCode, zc.City, zc.State,
+		zc.StateAbbreviation, zc.County, zc.Latitude, zc.Longitude)
+	require.NoError(t, err)
+	return func() {
+		_, err := locations.Exec("DELETE FROM locations where location = ?", locationKey(zc))
+		require.NoError(t, err)
+	}
+}
+
+func TestLocationFunctions(t *testing.T) {
+	initWeatherTest()
+
+	files := locationTables[locationFileTableName]
+	_, err := files.Exec("DELETE FROM locationFiles where country = ?", testCountry)
+	require.NoError(t, err)
+	defer func() {
+		locations := locationTables[locationTableName]
+		_, err := locations.Exec(locations.DeleteSQL, testCountry)
+		require.NoError(t, err)
+	}()
+
+	t.Run("Test the location keys", func(t *testing.T) {
+		zc := &ZipCode{
+			Country:           utils.StringPointer("US"),
+			ZipCode:           utils.StringPointer("01001"),
+			City:              utils.StringPointer("Agawam"),
+			State:             utils.StringPointer("Massachusetts"),
+			StateAbbreviation: utils.StringPointer("MA"),
+		}
+		require.Equal(t, "Agawam, MA. 01001", locationKey(zc))
+
+		zc.Country, zc.ZipCode, zc.City, zc.StateAbbreviation = utils.StringPointer("CA"), utils.StringPointer("M5A"),
+			utils.StringPointer("Toronto"), utils.StringPointer("ON")
+		require.Equal(t, "Toronto, ON. M5A, CA", locationKey(zc))
+
+		zc.Country, zc.ZipCode, zc.City, zc.State, zc.StateAbbreviation = utils.StringPointer("NL"),
+			utils.StringPointer("1012"), utils.StringPointer("Amsterdam"), utils.StringPointer("Noord-Holland"), utils.StringPointer("")
+		require.Equal(t, "Amsterdam, Noord-Holland. 1012, NL", locationKey(zc))
+
+		zc.State = nil
+		require.Equal(t, "Amsterdam. 1012, NL", locationKey(zc))
+
+		require.Equal(t, "EC1A 1BB", normalizePostalCode(" ec1a   1bb "))
+	})
+
+	country := testCountry
+	t.Run("Test loading an archive", func(t *testing.T) {
+		fileName := writeTestArchive(t,
+			testRecord("99101", "Loadtest City"),
+			testRecord("99102", "Loadtest Town"),
+			testRecord("ab1 2cd", "Loadtest Burgh"),
+			testRecord("", "No Postal Code"),
+			"too\tshort")
+		require.NoError(t, extractLocationData(&fileName, &country))
+
+		checksum, err := locationChecksum(testCountry)
+		require.NoError(t, err)
+		require.NotNil(t, checksum)
+
+		location, zc, err := queryLocation("location = ?", "Loadtest City, MO. 99101, XT")
+		require.NoError(t, err)
+		require.NotNil(t, location)
+		require.Equal(t, "99101", *zc.ZipCode)
+		require.Equal(t, testCountry, *zc.Country)
+		require.Equal(t, "Missouri", *zc.State)
+		require.InDelta(t, -90.2, *zc.Longitude, 0.0001)
+
+		location, zc, err = queryLocation("location = ?", "Loadtest Burgh, MO. AB1 2CD, XT")
+		require.NoError(t, err)
+		require.NotNil(t, location)
+		require.Equal(t, "AB1 2CD", *zc.ZipCode)
+
+		// the same archive isn't loaded again
+		_, err = files.Exec("UPDATE locationFiles set locations = -1 where country = ?", testCountry)
+		require.NoError(t, err)
+		require.NoError(t, extractLocationData(&fileName, &country))
+		var count int
+		db, err := configs.GetSQLiteConnection()
+		require.NoError(t, err)
+		require.NoError(t, db.QueryRow("select locations from locationFiles where country = ?", testCountry).Scan(&count))
+		require.Equal(t, -1, count)
+
+		// a new archive replaces the country's locations
+		fileName = writeTestArchive(t, testRecord("99103", "Loadtest Village"), testRecord("AB1 2CD", "Loadtest Burgh"))
+		require.NoError(t, extractLocationData(&fileName, &country))
+		require.NoError(t, db.QueryRow("select locations from locationFiles where country = ?", testCountry).Scan(&count))
+		require.Equal(t, 2, count)
+		location, _, err = queryLocation("location = ?", "Loadtest City, MO. 99101, XT")
+		require.NoError(t, err)
+		require.Nil(t, location)
+
+		missing := filepath.Join(t.TempDir(), "missing.zip")
+		require.Error(t, extractLocationData(&missing, &country))
+	})
+
+	t.Run("Test looking up and searching locations", func(t *testing.T) {
+		location := "Loadtest Village, MO. 99103, XT"
+		defer func() {
+			ZipCodeCacheMutex.Lock()
+			delete(ZipcodeLookup, location)
+			delete(ZipcodeLookup, "Loadtest Burgh, MO. AB1 2CD, XT")
+			ZipCodeCacheMutex.Unlock()
+		}()
+
+		zc, err := GetLocation(&location)
+		require.NoError(t, err)
+		require.Equal(t, "99103", *zc.ZipCode)
+		// the location is kept in memory once it has been looked up
+		cached, err := GetLocation(&location)
+		require.NoError(t, err)
+		require.Same(t, zc, cached)
+
+		key, byZip, err := FindPostalCode("xt", "99103")
+		require.NoError(t, err)
+		require.Equal(t, location, *key)
+		require.Same(t, zc, byZip)
+		key, _, err = FindPostalCode(testCountry, "ab1  2cd")
+		require.NoError(t, err)
+		require.Equal(t, "Loadtest Burgh, MO. AB1 2CD, XT", *key)
+		_, _, err = FindPostalCode(testCountry, "99199")
+		require.Error(t, err)
+		// without a country the postal code is looked up in the first configured country
+		_, _, err = FindPostalCode("", "99103")
+		require.Error(t, err)
+		_, _, err = FindPostalCode(testCountry, " ")
+		require.Error(t, err)
+
+		results, err := SearchLocations("loadtest v", 0)
+		require.NoError(t, err)
+		require.Equal(t, []string{location}, results)
+		results, err = SearchLocations("ab1", 0)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Loadtest Burgh, MO. AB1 2CD, XT"}, results)
+		results, err = SearchLocations("Loadtest%", 0)
+		require.NoError(t, err)
+		require.Empty(t, results)
+		_, err = SearchLocations("  ", 0)
+		require.Error(t, err)
+	})
+
+	t.Run("Test removing a country that isn't configured", func(t *testing.T) {
+		db, err := configs.GetSQLiteConnection()
+		require.NoError(t, err)
+		rows, err := db.Query("select country from locationFiles where country != ?", testCountry)
+		require.NoError(t, err)
+		var keep []string
+		for rows.Next() {
+			var country string
+			require.NoError(t, rows.Scan(&country))
+			keep = append(keep, country)
 		}
+		require.NoError(t, rows.Close())
+
+		require.NoError(t, removeCountries(keep))
+		checksum, err := locationChecksum(testCountry)
+		require.NoError(t, err)
+		require.Nil(t, checksum)
+		location, _, err := queryLocation("country = ?", testCountry)
 		require.NoError(t, err)
+		require.Nil(t, location)
+	})
+
+	t.Run("Test users saved with the old keys are migrated", func(t *testing.T) {
+		latitude, longitude := 38.6, -90.2
+		defer storeTestLocation(t, &ZipCode{
+			Country:           utils.StringPointer("US"),
+			ZipCode:           utils.StringPointer("01234"),
+			City:              utils.StringPointer("Migrate Test"),
+			StateAbbreviation: utils.StringPointer("MO"),
+			Latitude:          &latitude,
+			Longitude:         &longitude,
+		})()
+		defer func() {
+			ZipCodeCacheMutex.Lock()
+			delete(ZipcodeLookup, "Migrate Test, MO. 01234")
+			ZipCodeCacheMutex.Unlock()
+		}()
+
+		userID := "migrate-" + uuid.New().String()
+		user := &configs.User{Location: utils.StringPointer("Migrate Test, MO. 1234")}
+		require.NoError(t, user.Persist(&userID))
+		defer func() {
+			require.NoError(t, user.Remove(&userID))
+		}()
+
+		require.NoError(t, migrateUserLocations())
+		require.Equal(t, "Migrate Test, MO. 01234", *user.Location)
 	})
 }
@@ -0,0 +1,260 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+const observationsURL = "https://archive-api.open-meteo.com"
+
+var (
+	observationTableName = "weatherObservations"
+	observationTable     = &configs.Table{
+		// the date is YYYY-MM-DD in the location's time zone and the temperatures are fahrenheit
+		CreateSQL: `CREATE TABLE IF NOT EXISTS weatherObservations (
+			location varchar(1024) NOT NULL COLLATE NOCASE,
+			date varchar(10) NOT NULL,
+			high real NOT NULL,
+			low real NOT NULL,
+			retrieved bigint NOT NULL,
+			PRIMARY KEY (location, date))`,
+		InsertSQL: `INSERT INTO weatherObservations values(?,?,?,?,?) ON CONFLICT(location, date) DO UPDATE set
+			high = excluded.high, low = excluded.low, retrieved = excluded.retrieved`,
+	}
+
+	// an observation refresh that is still running when the next one comes due is not started twice
+	observationRefreshMutex sync.Mutex
+	errObservationsRunning  = errors.New("an observation refresh is already running")
+)
+
+// Observation is the observed high and low of a day at a location in fahrenheit, the date is YYYY-MM-DD
+type Observation struct {
+	Date *string  `json:"date,omitempty"`
+	High *float64 `json:"high,omitempty"`
+	Low  *float64 `json:"low,omitempty"`
+}
+
+func setupObservations() error {
+	if err := observationTable.CreateTable(&observationTableName); err != nil {
+		return err
+	}
+	return setupDegreeDayCrops()
+}
+
+func observationInterval() time.Duration {
+	if configs.GrowSTLGo != nil && configs.GrowSTLGo.Weather != nil && configs.GrowSTLGo.Weather.ObservationInterval != nil {
+		return time.Duration(*configs.GrowSTLGo.Weather.ObservationInterval) * time.Minute
+	}
+	return configs.DefaultObservationInterval * time.Minute
+}
+
+func observationURL() string {
+	if configs.GrowSTLGo != nil && configs.GrowSTLGo.Weather != nil {
+		return urlOrDefault(configs.GrowSTLGo.Weather.ObservationURL, observationsURL)
+	}
+	return observationsURL
+}
+
+// getObservations retrieves the observations every user's location is missing, from the start of the year for a
+// location that doesn't have any yet through yesterday
+func getObservations() error {
+	if !observationRefreshMutex.TryLock() {
+		return errObservationsRunning
+	}
+	defer observationRefreshMutex.Unlock()
+
+	if configs.GrowSTLGo == nil || configs.GrowSTLGo.Users == nil {
+		return errors.New("cannot get observations, configuration is invalid")
+	}
+
+	markObservationRefresh()
+	userLocations := make(map[string]bool)
+	configs.GrowSTLGo.UsersMutex.Lock()
+	for _, user := range configs.GrowSTLGo.Users {
+		if user.Location != nil {
+			userLocations[*user.Location] = true
+		}
+	}
+	configs.GrowSTLGo.UsersMutex.Unlock()
+
+	// a location that fails doesn't keep the others from being retrieved
+	var errs []error
+	now := time.Now()
+	for location := range userLocations {
+		zip, err := GetLocation(&location)
+		if err != nil {
+			log.Debug(err)
+			continue
+		}
+		if _, err := refreshObservations(location, zip, now); err != nil {
+			errs = append(errs, fmt.Errorf("cannot get the observations for %s: %w", location, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// refreshObservations retrieves the days since the location's latest observation through yesterday
+func refreshObservations(location string, zip *ZipCode, now time.Time) (int, error) {
+	latest, err := latestObservation(location)
+	if err != nil {
+		return 0, err
+	}
+
+	from := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+	if latest != nil {
+		from = latest.AddDate(0, 0, 1)
+	}
+	return fetchObservations(location, zip, from, now.AddDate(0, 0, -1))
+}
+
+// latestObservation is the date of the location's most recent observation, nil when it doesn't have any
+func latestObservation(location string) (*time.Time, error) {
+	_, latest, err := observationRange(location)
+	return latest, err
+}
+
+// observationRange is the dates of the location's oldest and most recent observations, nil when it doesn't have any
+func observationRange(location string) (earliest, latest *time.Time, err error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve observations, sqlite error: %s", dbErr)
+	}
+
+	var first, last sql.NullString
+	if err := db.QueryRow("select min(date), max(date) from weatherObservations where location = ?", location).
+		Scan(&first, &last); err != nil {
+		return nil, nil, err
+	}
+	if !first.Valid || !last.Valid {
+		return nil, nil, nil
+	}
+	from, err := time.ParseInLocation(time.DateOnly, first.String, time.Local)
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := time.ParseInLocation(time.DateOnly, last.String, time.Local)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &from, &to, nil
+}
+
+// fetchObservations asks the historical api for the daily highs and lows of the location between the dates and
+// stores them, the days the api doesn't have yet are left for the next refresh
+func fetchObservations(location string, zip *ZipCode, from, to time.Time) (int, error) {
+	if zip == nil || zip.Latitude == nil || zip.Longitude == nil {
+		return 0, fmt.Errorf("location %s doesn't have a latitude and longitude", location)
+	}
+	if from.After(to) {
+		return 0, nil
+	}
+
+	archiveURL, err := url.JoinPath(observationURL(), "v1", "archive")
+	if err != nil {
+		return 0, err
+	}
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%f", *zip.Latitude))
+	query.Set("longitude", fmt.Sprintf("%f", *zip.Longitude))
+	query.Set("start_date", from.Format(time.DateOnly))
+	query.Set("end_date", to.Format(time.DateOnly))
+	query.Set("daily", "temperature_2m_max,temperature_2m_min")
+	query.Set("temperature_unit", "fahrenheit")
+	query.Set("timezone", "auto")
+
+	responseText, status, err := configs.HTTPRequest(archiveURL+"?"+query.Encode(), http.MethodGet, nil)
+	if err != nil {
+		return 0, err
+	}
+	if status == nil || *status != http.StatusOK {
+		code := 0
+		if status != nil {
+			code = *status
+		}
+		return 0, fmt.Errorf("observations for %s returned status %d", location, code)
+	}
+
+	var response openMeteoResponse
+	if err := json.Unmarshal([]byte(*responseText), &response); err != nil {
+		return 0, err
+	}
+	if response.Daily == nil {
+		return 0, nil
+	}
+	return storeObservations(location, response.Daily)
+}
+
+func storeObservations(location string, daily *openMeteoDaily) (int, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return 0, fmt.Errorf("unable to store observations, sqlite error: %s", dbErr)
+	}
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer configs.RollbackHelper(tx)
+
+	stored := 0
+	retrieved := time.Now().UnixMilli()
+	for i, day := range daily.Time {
+		high, low := valueAt(daily.TemperatureMax, i), valueAt(daily.TemperatureMin, i)
+		if high == nil || low == nil {
+			continue
+		}
+		if _, err := observationTable.ExecTx(tx, observationTable.InsertSQL, location, day, high, low, retrieved); err != nil {
+			return 0, err
+		}
+		stored++
+	}
+	return stored, tx.Commit()
+}
+
+// getStoredObservations returns the location's observations between the dates, YYYY-MM-DD, in date order
+func getStoredObservations(location, from, to string) ([]*Observation, error) {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return nil, fmt.Errorf("unable to retrieve observations, sqlite error: %s", dbErr)
+	}
+
+	rows, err := db.Query(`select date, high, low from weatherObservations where location = ? and date >= ? and date <= ?
+		order by date`, location, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	observations := []*Observation{}
+	for rows.Next() {
+		observation := Observation{}
+		if err := rows.Scan(&observation.Date, &observation.High, &observation.Low); err != nil {
+			return nil, err
+		}
+		observations = append(observations, &observation)
+	}
+	return observations, rows.Err()
+}
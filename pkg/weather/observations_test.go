@@ -0,0 +1,168 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/utils"
+)
+
+// testObservationAPI answers every day asked for with a high of 80 and a low of 60, except the last day which the
+// archive doesn't have yet
+func testObservationAPI(t *testing.T, lookups *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*lookups++
+		require.Equal(t, "/v1/archive", r.URL.Path)
+		from, err := time.Parse(time.DateOnly, r.URL.Query().Get("start_date"))
+		require.NoError(t, err)
+		to, err := time.Parse(time.DateOnly, r.URL.Query().Get("end_date"))
+		require.NoError(t, err)
+
+		high, low := 80.0, 60.0
+		daily := openMeteoDaily{}
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			daily.Time = append(daily.Time, day.Format(time.DateOnly))
+			if day.Equal(to) {
+				daily.TemperatureMax = append(daily.TemperatureMax, nil)
+				daily.TemperatureMin = append(daily.TemperatureMin, nil)
+				continue
+			}
+			daily.TemperatureMax = append(daily.TemperatureMax, &high)
+			daily.TemperatureMin = append(daily.TemperatureMin, &low)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(openMeteoResponse{Daily: &daily}))
+	}))
+}
+
+// useObservationURL points the observations at the test api
+func useObservationURL(url string) func() {
+	previous := configs.GrowSTLGo.Weather
+	weather := *previous
+	weather.ObservationURL = &url
+	configs.GrowSTLGo.Weather = &weather
+	return func() { configs.GrowSTLGo.Weather = previous }
+}
+
+func removeTestObservations(t *testing.T, location string) {
+	_, err := observationTable.Exec("DELETE FROM weatherObservations where location = ?", location)
+	require.NoError(t, err)
+}
+
+func TestObservationFunctions(t *testing.T) {
+	initWeatherTest()
+	lookups := 0
+	server := testObservationAPI(t, &lookups)
+	defer server.Close()
+	defer useObservationURL(server.URL)()
+
+	location := "Observation Test, MO. 99902"
+	latitude, longitude := 38.6, -90.2
+	zip := &ZipCode{Latitude: &latitude, Longitude: &longitude}
+	removeTestObservations(t, location)
+	defer removeTestObservations(t, location)
+
+	t.Run("Test retrieving the observations", func(t *testing.T) {
+		latest, err := latestObservation(location)
+		require.NoError(t, err)
+		require.Nil(t, latest)
+
+		// the first refresh starts at the beginning of the year, the archive doesn't have yesterday yet
+		now := time.Date(2026, time.January, 11, 9, 0, 0, 0, time.Local)
+		stored, err := refreshObservations(location, zip, now)
+		require.NoError(t, err)
+		require.Equal(t, 9, stored)
+		require.Equal(t, 1, lookups)
+
+		latest, err = latestObservation(location)
+		require.NoError(t, err)
+		require.Equal(t, "2026-01-09", latest.Format(time.DateOnly))
+
+		// the next refresh picks up from the latest observation
+		stored, err = refreshObservations(location, zip, now.AddDate(0, 0, 2))
+		require.NoError(t, err)
+		require.Equal(t, 2, stored)
+
+		observations, err := getStoredObservations(location, "2026-01-01", "2026-12-31")
+		require.NoError(t, err)
+		require.Len(t, observations, 11)
+		require.Equal(t, "2026-01-01", *observations[0].Date)
+		require.Equal(t, 80.0, *observations[0].High)
+		require.Equal(t, 60.0, *observations[0].Low)
+
+		// nothing is asked for when the location is up to date
+		lookups = 0
+		stored, err = fetchObservations(location, zip, now, now.AddDate(0, 0, -1))
+		require.NoError(t, err)
+		require.Zero(t, stored)
+		require.Zero(t, lookups)
+	})
+
+	t.Run("Test the observation errors", func(t *testing.T) {
+		_, err := fetchObservations(location, &ZipCode{}, time.Now().AddDate(0, 0, -2), time.Now())
+		require.Error(t, err)
+
+		down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer down.Close()
+		restore := useObservationURL(down.URL)
+		defer restore()
+		_, err = fetchObservations(location, zip, time.Now().AddDate(0, 0, -2), time.Now())
+		require.Error(t, err)
+	})
+
+	t.Run("Test the user locations are refreshed", func(t *testing.T) {
+		userLocation := "Observation User, MO. 99903"
+		defer storeTestLocation(t, &ZipCode{
+			Country:           utils.StringPointer("US"),
+			ZipCode:           utils.StringPointer("99903"),
+			City:              utils.StringPointer("Observation User"),
+			StateAbbreviation: utils.StringPointer("MO"),
+			Latitude:          &latitude,
+			Longitude:         &longitude,
+		})()
+		defer removeTestObservations(t, userLocation)
+
+		userID := "observationUser"
+		configs.GrowSTLGo.UsersMutex.Lock()
+		configs.GrowSTLGo.Users[userID] = &configs.User{Location: &userLocation}
+		configs.GrowSTLGo.UsersMutex.Unlock()
+		defer func() {
+			configs.GrowSTLGo.UsersMutex.Lock()
+			delete(configs.GrowSTLGo.Users, userID)
+			configs.GrowSTLGo.UsersMutex.Unlock()
+		}()
+
+		require.NoError(t, getObservations())
+		latest, err := latestObservation(userLocation)
+		require.NoError(t, err)
+		require.NotNil(t, latest)
+		require.NotNil(t, GetRefreshStatus().LastObservationRefresh)
+
+		// a refresh isn't started while one is running
+		observationRefreshMutex.Lock()
+		err = getObservations()
+		observationRefreshMutex.Unlock()
+		require.ErrorIs(t, err, errObservationsRunning)
+	})
+}
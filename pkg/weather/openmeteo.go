@@ -0,0 +1,224 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/utils"
+)
+
+const openMeteoURL = "https://api.open-meteo.com"
+
+// weatherCodes are the short forecasts of the WMO weather interpretation codes Open-Meteo uses
+var weatherCodes = map[int]string{
+	0:  "Clear",
+	1:  "Mainly Clear",
+	2:  "Partly Cloudy",
+	3:  "Overcast",
+	45: "Fog",
+	48: "Freezing Fog",
+	51: "Light Drizzle",
+	53: "Drizzle",
+	55: "Heavy Drizzle",
+	56: "Freezing Drizzle",
+	57: "Freezing Drizzle",
+	61: "Light Rain",
+	63: "Rain",
+	65: "Heavy Rain",
+	66: "Freezing Rain",
+	67: "Freezing Rain",
+	71: "Light Snow",
+	73: "Snow",
+	75: "Heavy Snow",
+	77: "Snow Grains",
+	80: "Rain Showers",
+	81: "Rain Showers",
+	82: "Heavy Rain Showers",
+	85: "Snow Showers",
+	86: "Heavy Snow Showers",
+	95: "Thunderstorms",
+	96: "Thunderstorms With Hail",
+	99: "Thunderstorms With Hail",
+}
+
+// openMeteoProvider is an Open-Meteo style api, the daily highs and lows are split into day and night periods
+type openMeteoProvider struct {
+	url string
+}
+
+type openMeteoResponse struct {
+	UTCOffsetSeconds *int             `json:"utc_offset_seconds,omitempty"`
+	Daily            *openMeteoDaily  `json:"daily,omitempty"`
+	Hourly           *openMeteoHourly `json:"hourly,omitempty"`
+}
+
+type openMeteoDaily struct {
+	Time                     []string   `json:"time,omitempty"`
+	TemperatureMax           []*float64 `json:"temperature_2m_max,omitempty"`
+	TemperatureMin           []*float64 `json:"temperature_2m_min,omitempty"`
+	PrecipitationProbability []*int     `json:"precipitation_probability_max,omitempty"`
+	WeatherCode              []*int     `json:"weather_code,omitempty"`
+}
+
+type openMeteoHourly struct {
+	Time                     []string   `json:"time,omitempty"`
+	Temperature              []*float64 `json:"temperature_2m,omitempty"`
+	PrecipitationProbability []*int     `json:"precipitation_probability,omitempty"`
+	WeatherCode              []*int     `json:"weather_code,omitempty"`
+}
+
+func (provider *openMeteoProvider) Name() string {
+	return configs.OpenMeteoProvider
+}
+
+func (provider *openMeteoProvider) Forecast(latitude, longitude float64) ([]*Forecast, []*Forecast, error) {
+	forecastURL, err := url.JoinPath(provider.url, "v1", "forecast")
+	if err != nil {
+		return nil, nil, err
+	}
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%f", latitude))
+	query.Set("longitude", fmt.Sprintf("%f", longitude))
+	query.Set("daily", "temperature_2m_max,temperature_2m_min,precipitation_probability_max,weather_code")
+	query.Set("hourly", "temperature_2m,precipitation_probability,weather_code")
+	query.Set("temperature_unit", "fahrenheit")
+	query.Set("timezone", "auto")
+
+	var response openMeteoResponse
+	if err := getJSON(forecastURL+"?"+query.Encode(), &response); err != nil {
+		return nil, nil, err
+	}
+	return response.periods(time.Now()), response.hourly(time.Now()), nil
+}
+
+func (response *openMeteoResponse) location() *time.Location {
+	offset := 0
+	if response.UTCOffsetSeconds != nil {
+		offset = *response.UTCOffsetSeconds
+	}
+	return time.FixedZone("", offset)
+}
+
+// periods splits every day into a 6am to 6pm period with the high and a 6pm to 6am period with the low, the periods
+// that have already ended are left out
+func (response *openMeteoResponse) periods(now time.Time) []*Forecast {
+	if response.Daily == nil {
+		return nil
+	}
+
+	location := response.location()
+	forecasts := []*Forecast{}
+	for i, day := range response.Daily.Time {
+		date, err := time.ParseInLocation(time.DateOnly, day, location)
+		if err != nil {
+			continue
+		}
+
+		dayName, nightName := date.Weekday().String(), date.Weekday().String()+" Night"
+		if i == 0 {
+			dayName, nightName = "Today", "Tonight"
+		}
+		code := valueAt(response.Daily.WeatherCode, i)
+		precipitation := valueAt(response.Daily.PrecipitationProbability, i)
+
+		for _, period := range []struct {
+			name        string
+			start       time.Time
+			temperature *float64
+			daytime     bool
+			description string
+		}{
+			{dayName, date.Add(6 * time.Hour), valueAt(response.Daily.TemperatureMax, i), true, "high near"},
+			{nightName, date.Add(18 * time.Hour), valueAt(response.Daily.TemperatureMin, i), false, "low around"},
+		} {
+			end := period.start.Add(12 * time.Hour)
+			if period.temperature == nil || !end.After(now) {
+				continue
+			}
+			forecast := newForecast(len(forecasts)+1, period.start, end, period.temperature, precipitation, code)
+			forecast.Name = utils.StringPointer(period.name)
+			forecast.IsDaytime = &period.daytime
+			forecast.DetailedForecast = utils.StringPointer(fmt.Sprintf("%s, with a %s %d.", *forecast.ShortForecast,
+				period.description, *forecast.Temperature))
+			forecasts = append(forecasts, forecast)
+		}
+	}
+	return forecasts
+}
+
+// hourly is the hourly forecast from the current hour on
+func (response *openMeteoResponse) hourly(now time.Time) []*Forecast {
+	if response.Hourly == nil {
+		return nil
+	}
+
+	location := response.location()
+	forecasts := []*Forecast{}
+	for i, hour := range response.Hourly.Time {
+		start, err := time.ParseInLocation("2006-01-02T15:04", hour, location)
+		if err != nil {
+			continue
+		}
+		end := start.Add(time.Hour)
+		temperature := valueAt(response.Hourly.Temperature, i)
+		if temperature == nil || !end.After(now) {
+			continue
+		}
+		forecast := newForecast(len(forecasts)+1, start, end, temperature,
+			valueAt(response.Hourly.PrecipitationProbability, i), valueAt(response.Hourly.WeatherCode, i))
+		daytime := start.Hour() >= 6 && start.Hour() < 18
+		forecast.IsDaytime = &daytime
+		forecasts = append(forecasts, forecast)
+	}
+	return forecasts
+}
+
+func newForecast(number int, start, end time.Time, temperature *float64, precipitation, code *int) *Forecast {
+	rounded := int(math.Round(*temperature))
+	shortForecast := "Unknown"
+	if code != nil {
+		if description, ok := weatherCodes[*code]; ok {
+			shortForecast = description
+		}
+	}
+	forecast := &Forecast{
+		Number:          &number,
+		StartTime:       utils.StringPointer(start.Format(time.RFC3339)),
+		EndTime:         utils.StringPointer(end.Format(time.RFC3339)),
+		Temperature:     &rounded,
+		TemperatureUnit: utils.StringPointer("F"),
+		ShortForecast:   &shortForecast,
+	}
+	if precipitation != nil {
+		forecast.ProbabilityOfPrecipitation = &probabilityOfPrecipitation{
+			UnitCode: utils.StringPointer("wmoUnit:percent"),
+			Value:    precipitation,
+		}
+	}
+	return forecast
+}
+
+// valueAt guards against the series being shorter than the times
+func valueAt[T any](values []*T, i int) *T {
+	if i < len(values) {
+		return values[i]
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+const nwsURL = "https://api.weather.gov"
+
+// WeatherProvider retrieves the forecast for a latitude / longitude.  The periods are the forecast in day and night
+// periods, the first being the current one, and the hourly forecast is one period an hour
+type WeatherProvider interface {
+	Name() string
+	Forecast(latitude, longitude float64) (periods, hourly []*Forecast, err error)
+}
+
+// getProvider returns the provider selected in the config, it's looked up every time so a config change is picked up
+func getProvider() (WeatherProvider, error) {
+	if configs.GrowSTLGo == nil || configs.GrowSTLGo.Weather == nil || configs.GrowSTLGo.Weather.Provider == nil {
+		return nil, errors.New("invalid configuration cannot get weather")
+	}
+
+	config := configs.GrowSTLGo.Weather
+	switch *config.Provider {
+	case configs.NWSProvider:
+		return &nwsProvider{url: urlOrDefault(config.URL, nwsURL)}, nil
+	case configs.OpenMeteoProvider:
+		return &openMeteoProvider{url: urlOrDefault(config.URL, openMeteoURL)}, nil
+	case configs.FixtureProvider:
+		return &fixtureProvider{file: config.File}, nil
+	}
+	return nil, fmt.Errorf("unknown weather provider %s", *config.Provider)
+}
+
+func urlOrDefault(configured *string, defaultURL string) string {
+	if configured != nil && *configured != "" {
+		return *configured
+	}
+	return defaultURL
+}
+
+// getJSON unmarshals the response of a GET request, it is served from the response cache while the provider allows
+func getJSON(url string, v interface{}) error {
+	response, err := cachedGet(url)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(response), v)
+}
+
+// nwsProvider is api.weather.gov, the points lookup of the latitude / longitude gives the urls of the forecasts of its
+// grid.  The grid of a point doesn't change so the urls are kept in the weatherPoints table
+type nwsProvider struct {
+	url string
+}
+
+type latLongLookup struct {
+	Properties *forecastURL `json:"properties,omitempty"`
+}
+
+type forecastURL struct {
+	ForecastURL       *string `json:"forecast,omitempty"`
+	ForecastHourlyURL *string `json:"forecastHourly,omitempty"`
+}
+
+type forecastLookup struct {
+	Properties *periods `json:"properties,omitempty"`
+}
+
+type periods struct {
+	Periods []*Forecast `json:"periods,omitempty"`
+}
+
+func (provider *nwsProvider) Name() string {
+	return configs.NWSProvider
+}
+
+func (provider *nwsProvider) Forecast(latitude, longitude float64) ([]*Forecast, []*Forecast, error) {
+	point := fmt.Sprintf("%.4f,%.4f", latitude, longitude)
+	urls, err := getPoint(provider.url, point)
+	if err != nil {
+		log.Error(err)
+	}
+
+	if urls != nil {
+		periods, hourly, err := provider.forecast(urls)
+		if err == nil {
+			return periods, hourly, nil
+		}
+		// the grid may have been moved, look the point up again
+		log.Infof("forecast for the cached weather point %s failed, looking it up again: %s", point, err)
+		if err := removePoint(provider.url, point); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if urls, err = provider.lookupPoint(point); err != nil {
+		return nil, nil, err
+	}
+	return provider.forecast(urls)
+}
+
+// lookupPoint asks the api for the forecast urls of the point and caches them
+func (provider *nwsProvider) lookupPoint(point string) (*forecastURL, error) {
+	pointsURL, err := url.JoinPath(provider.url, "points", point)
+	if err != nil {
+		return nil, err
+	}
+	var lookup latLongLookup
+	if err := getJSON(pointsURL, &lookup); err != nil {
+		return nil, err
+	}
+	if lookup.Properties == nil || lookup.Properties.ForecastURL == nil {
+		return nil, fmt.Errorf("no forecast found for %s", point)
+	}
+
+	if err := storePoint(provider.url, point, lookup.Properties); err != nil {
+		log.Error(err)
+	}
+	return lookup.Properties, nil
+}
+
+func (provider *nwsProvider) forecast(urls *forecastURL) ([]*Forecast, []*Forecast, error) {
+	periods, err := provider.periods(*urls.ForecastURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var hourly []*Forecast
+	if urls.ForecastHourlyURL != nil {
+		if hourly, err = provider.periods(*urls.ForecastHourlyURL); err != nil {
+			return nil, nil, err
+		}
+	}
+	return periods, hourly, nil
+}
+
+func (provider *nwsProvider) periods(url string) ([]*Forecast, error) {
+	var lookup forecastLookup
+	if err := getJSON(url, &lookup); err != nil {
+		return nil, err
+	}
+	if lookup.Properties == nil {
+		return nil, nil
+	}
+	return lookup.Properties.Periods, nil
+}
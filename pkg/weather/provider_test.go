@@ -0,0 +1,136 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+// useProvider switches the configured weather provider, the returned function restores the previous one
+func useProvider(provider string, url, file *string) func() {
+	previous := configs.GrowSTLGo.Weather
+	configs.GrowSTLGo.Weather = &configs.Weather{Provider: &provider, URL: url, File: file}
+	return func() { configs.GrowSTLGo.Weather = previous }
+}
+
+func TestProviderFunctions(t *testing.T) {
+	initWeatherTest()
+
+	t.Run("Test the provider selection", func(t *testing.T) {
+		for _, name := range []string{configs.NWSProvider, configs.OpenMeteoProvider, configs.FixtureProvider} {
+			restore := useProvider(name, nil, nil)
+			provider, err := getProvider()
+			require.NoError(t, err)
+			require.Equal(t, name, provider.Name())
+			restore()
+		}
+
+		restore := useProvider("bogus", nil, nil)
+		defer restore()
+		_, err := getProvider()
+		require.Error(t, err)
+	})
+
+	t.Run("Test the nws provider", func(t *testing.T) {
+		lookups := 0
+		server := testWeatherAPI(&lookups)
+		defer server.Close()
+
+		provider := &nwsProvider{url: server.URL}
+		periods, hourly, err := provider.Forecast(38.6, -90.2)
+		require.NoError(t, err)
+		require.Len(t, periods, 2)
+		require.Len(t, hourly, 3)
+		require.Equal(t, 1, lookups)
+	})
+
+	t.Run("Test the fixture provider", func(t *testing.T) {
+		provider := &fixtureProvider{}
+		periods, hourly, err := provider.Forecast(0, 0)
+		require.NoError(t, err)
+		require.Len(t, periods, 4)
+		require.Len(t, hourly, 3)
+
+		file := filepath.Join(t.TempDir(), "forecast.json")
+		require.NoError(t, os.WriteFile(file, []byte(`{"periods": [{"name": "Today", "temperature": 99, "temperatureUnit": "F"}]}`), 0o600))
+		provider = &fixtureProvider{file: &file}
+		periods, hourly, err = provider.Forecast(0, 0)
+		require.NoError(t, err)
+		require.Len(t, periods, 1)
+		require.Equal(t, 99, *periods[0].Temperature)
+		require.Empty(t, hourly)
+
+		require.NoError(t, os.WriteFile(file, []byte(`not json`), 0o600))
+		_, _, err = provider.Forecast(0, 0)
+		require.Error(t, err)
+	})
+
+	t.Run("Test the open meteo provider", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/v1/forecast", r.URL.Path)
+			require.Equal(t, "fahrenheit", r.URL.Query().Get("temperature_unit"))
+			fmt.Fprint(w, `{"utc_offset_seconds": -18000,
+				"daily": {"time": ["2026-10-16", "2026-10-17"], "temperature_2m_max": [64.6, 71.2],
+					"temperature_2m_min": [33.1, null], "precipitation_probability_max": [10, 75], "weather_code": [1, 63]},
+				"hourly": {"time": ["2026-10-16T00:00", "2026-10-16T01:00"], "temperature_2m": [40.2, 39.8],
+					"precipitation_probability": [0, 5], "weather_code": [0, 0]}}`)
+		}))
+		defer server.Close()
+
+		provider := &openMeteoProvider{url: server.URL}
+		periods, _, err := provider.Forecast(38.6, -90.2)
+		require.NoError(t, err)
+		require.NotNil(t, periods)
+
+		var response openMeteoResponse
+		require.NoError(t, getJSON(server.URL+"/v1/forecast?temperature_unit=fahrenheit", &response))
+
+		// in the afternoon of the first day today is still current, the second night has no low
+		now := time.Date(2026, 10, 16, 14, 0, 0, 0, time.FixedZone("", -18000))
+		periods = response.periods(now)
+		require.Len(t, periods, 3)
+		require.Equal(t, "Today", *periods[0].Name)
+		require.Equal(t, 65, *periods[0].Temperature)
+		require.Equal(t, "Mainly Clear", *periods[0].ShortForecast)
+		require.Equal(t, "2026-10-16T06:00:00-05:00", *periods[0].StartTime)
+		require.Equal(t, "Tonight", *periods[1].Name)
+		require.Equal(t, 33, *periods[1].Temperature)
+		require.False(t, *periods[1].IsDaytime)
+		require.Equal(t, "Saturday", *periods[2].Name)
+		require.Equal(t, 75, *periods[2].ProbabilityOfPrecipitation.Value)
+		require.Equal(t, 3, *periods[2].Number)
+
+		// the tonight period raises a frost alert and saturday a precipitation alert
+		require.Equal(t, frostAlert, *forecastAlerts(periods[1])[0].Kind)
+		require.Equal(t, precipitationAlert, *forecastAlerts(periods[2])[0].Kind)
+
+		// hours that have ended are left out
+		hourly := response.hourly(time.Date(2026, 10, 16, 0, 30, 0, 0, time.FixedZone("", -18000)))
+		require.Len(t, hourly, 2)
+		hourly = response.hourly(time.Date(2026, 10, 16, 1, 30, 0, 0, time.FixedZone("", -18000)))
+		require.Len(t, hourly, 1)
+		require.Equal(t, 40, *hourly[0].Temperature)
+	})
+}
@@ -0,0 +1,306 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+const (
+	// Route is the websocket route and REST prefix of the weather requests, the webservice registers them because it
+	// imports weather
+	Route = "weather"
+
+	getCurrentRequestKey  = "getCurrent"
+	getForecastRequestKey = "getForecast"
+	getHourlyRequestKey   = "getHourly"
+	getAlertsRequestKey   = "getAlerts"
+	searchRequestKey      = "searchLocations"
+
+	getGrowingDegreeDaysRequestKey = "getGrowingDegreeDays"
+	getDegreeDayCropsRequestKey    = "getDegreeDayCrops"
+
+	// forecasts for locations that aren't refreshed with the users' are retrieved again once they are this old
+	forecastMaxAge = time.Hour
+)
+
+// locationRequest is the location key or the postal code, in the country given or the first configured one, a
+// forecast is asked for
+type locationRequest struct {
+	Location *string `json:"location,omitempty"`
+	Country  *string `json:"country,omitempty"`
+	Zip      *string `json:"zip,omitempty"`
+}
+
+type searchRequest struct {
+	Prefix *string `json:"prefix,omitempty"`
+	Limit  *int    `json:"limit,omitempty"`
+}
+
+// Report is the forecast of a location, only the part of the forecast asked for is populated
+type Report struct {
+	Location  *string     `json:"location,omitempty"`
+	City      *string     `json:"city,omitempty"`
+	State     *string     `json:"state,omitempty"`
+	Country   *string     `json:"country,omitempty"`
+	ZipCode   *string     `json:"zip_code,omitempty"`
+	Latitude  *float64    `json:"latitude,omitempty"`
+	Longitude *float64    `json:"longitude,omitempty"`
+	Updated   *int64      `json:"updated,omitempty"`
+	Current   *Forecast   `json:"current,omitempty"`
+	Periods   []*Forecast `json:"periods,omitempty"`
+	Hourly    []*Forecast `json:"hourly,omitempty"`
+}
+
+// HandleWebsocketRequest serves the weather route, the token has been validated by the webservice
+func HandleWebsocketRequest(request, response *configs.WsMessage) {
+	if request.Type != nil && response != nil {
+		var err error
+		switch *request.Type {
+		case getCurrentRequestKey, getForecastRequestKey, getHourlyRequestKey:
+			var location locationRequest
+			if request.Data != nil {
+				if err = unmarshalData(request.Data, &location); err != nil {
+					break
+				}
+			}
+			response.Data, err = getReport(*request.Type, request.User, &location)
+		case getAlertsRequestKey:
+			response.Data, err = getAlerts(request.User)
+		case searchRequestKey:
+			var search searchRequest
+			if request.Data != nil {
+				if err = unmarshalData(request.Data, &search); err != nil {
+					break
+				}
+			}
+			response.Data, err = search.search()
+		case getGrowingDegreeDaysRequestKey:
+			var degreeDays degreeDayRequest
+			if request.Data != nil {
+				if err = unmarshalData(request.Data, &degreeDays); err != nil {
+					break
+				}
+			}
+			response.Data, err = getGrowingDegreeDays(request.User, &degreeDays)
+		case getDegreeDayCropsRequestKey:
+			response.Data, err = GetDegreeDayCrops()
+		default:
+			err = fmt.Errorf("type %s not implemented", *request.Type)
+		}
+
+		if err != nil {
+			log.Error(err)
+			e := err.Error()
+			response.Error = &e
+			response.Data = nil
+		}
+	}
+}
+
+// HandleRESTRequest serves the GET requests under /REST/v1.0.0/weather/ for the authenticated user
+func HandleRESTRequest(w http.ResponseWriter, r *http.Request, userID *string) {
+	w.Header().Set("content-type", "application/json")
+	if r.Method != http.MethodGet {
+		log.Errorf("%s URI with Method %s not possible", r.URL.Path, r.Method)
+		http.Error(w, configs.NotImplementedError, http.StatusNotImplemented)
+		return
+	}
+
+	var data interface{}
+	var err error
+	switch requestType := strings.Trim(strings.TrimPrefix(r.URL.Path, fmt.Sprintf("/REST/v1.0.0/%s", Route)), "/"); requestType {
+	case getCurrentRequestKey, getForecastRequestKey, getHourlyRequestKey:
+		location := locationRequest{}
+		if value := r.URL.Query().Get("location"); value != "" {
+			location.Location = &value
+		}
+		if value := r.URL.Query().Get("country"); value != "" {
+			location.Country = &value
+		}
+		if value := r.URL.Query().Get("zip"); value != "" {
+			location.Zip = &value
+		}
+		data, err = getReport(requestType, userID, &location)
+	case getAlertsRequestKey:
+		data, err = getAlerts(userID)
+	case searchRequestKey:
+		search := searchRequest{}
+		if value := r.URL.Query().Get("prefix"); value != "" {
+			search.Prefix = &value
+		}
+		if value := r.URL.Query().Get("limit"); value != "" {
+			limit, limitErr := strconv.Atoi(value)
+			if limitErr != nil {
+				http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+				return
+			}
+			search.Limit = &limit
+		}
+		data, err = search.search()
+	case getGrowingDegreeDaysRequestKey:
+		degreeDays := degreeDayRequest{}
+		for parameter, value := range map[string]**string{
+			"location": &degreeDays.Location,
+			"country":  &degreeDays.Country,
+			"zip":      &degreeDays.Zip,
+			"crop":     &degreeDays.Crop,
+			"from":     &degreeDays.From,
+			"to":       &degreeDays.To,
+		} {
+			if query := r.URL.Query().Get(parameter); query != "" {
+				*value = &query
+			}
+		}
+		data, err = getGrowingDegreeDays(userID, &degreeDays)
+	case getDegreeDayCropsRequestKey:
+		data, err = GetDegreeDayCrops()
+	default:
+		http.Error(w, configs.NotFoundError, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.BadRequestError, http.StatusBadRequest)
+		return
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(b); err != nil {
+		log.Error(err)
+	}
+}
+
+// getReport returns the part of the forecast asked for by the request type for the zip code or location requested,
+// or for the user's location if neither is
+func getReport(requestType string, userID *string, request *locationRequest) (*Report, error) {
+	location, zip, err := findLocation(userID, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if isStale(zip) {
+		// the fetches are serialized with the scheduled refreshes, a request that waited on one may find it fresh
+		forecastRefreshMutex.Lock()
+		if isStale(zip) {
+			err = fetchForecast(zip)
+		}
+		forecastRefreshMutex.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get the forecast for %s: %w", *location, err)
+		}
+	}
+
+	zip.Mutex.Lock()
+	defer zip.Mutex.Unlock()
+	report := &Report{
+		Location:  location,
+		City:      zip.City,
+		State:     zip.StateAbbreviation,
+		Country:   zip.Country,
+		ZipCode:   zip.ZipCode,
+		Latitude:  zip.Latitude,
+		Longitude: zip.Longitude,
+		Updated:   zip.Updated,
+	}
+	switch requestType {
+	case getCurrentRequestKey:
+		report.Current = zip.Forecast
+	case getForecastRequestKey:
+		report.Periods = zip.Periods
+	case getHourlyRequestKey:
+		report.Hourly = zip.Hourly
+	}
+	return report, nil
+}
+
+// isStale is true when the zip code's forecast is missing or older than the forecasts are kept
+func isStale(zip *ZipCode) bool {
+	zip.Mutex.Lock()
+	defer zip.Mutex.Unlock()
+	return zip.Updated == nil || time.Since(time.UnixMilli(*zip.Updated)) > forecastMaxAge
+}
+
+// findLocation returns the location key and zip code for the zip code or location key requested, falling back to
+// the user's location.  The locations requested are kept apart from the users' locations
+func findLocation(userID *string, request *locationRequest) (*string, *ZipCode, error) {
+	if request != nil && request.Zip != nil {
+		country := ""
+		if request.Country != nil {
+			country = *request.Country
+		}
+		location, zip, err := FindPostalCode(country, *request.Zip)
+		if err != nil {
+			return nil, nil, err
+		}
+		return location, cacheAdHocLocation(*location, zip), nil
+	}
+
+	if request != nil && request.Location != nil {
+		zip, err := findAdHocLocation(*request.Location)
+		if err != nil {
+			return nil, nil, err
+		}
+		return request.Location, zip, nil
+	}
+
+	location, err := UserLocation(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	zip, err := GetLocation(location)
+	if err != nil {
+		return nil, nil, err
+	}
+	return location, zip, nil
+}
+
+func (request *searchRequest) search() ([]string, error) {
+	if request.Prefix == nil {
+		return nil, errors.New("a location search requires a prefix")
+	}
+	limit := 0
+	if request.Limit != nil {
+		limit = *request.Limit
+	}
+	return SearchLocations(*request.Prefix, limit)
+}
+
+func unmarshalData(data, v interface{}) error {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(bytes, v); err != nil {
+		return errors.New("the request data is invalid")
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/utils"
+)
+
+// testWeatherAPI serves a points lookup and its forecasts, the number of lookups is counted
+func testWeatherAPI(lookups *int) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/points/"):
+			*lookups++
+			fmt.Fprintf(w, `{"properties": {"forecast": "%[1]s/forecast", "forecastHourly": "%[1]s/hourly"}}`, server.URL)
+		case r.URL.Path == "/forecast":
+			fmt.Fprint(w, `{"properties": {"periods": [
+				{"number": 1, "name": "Tonight", "startTime": "2026-10-16T18:00:00-05:00", "temperature": 45, "temperatureUnit": "F"},
+				{"number": 2, "name": "Saturday", "startTime": "2026-10-17T06:00:00-05:00", "temperature": 63, "temperatureUnit": "F"}]}}`)
+		case r.URL.Path == "/hourly":
+			fmt.Fprint(w, `{"properties": {"periods": [
+				{"number": 1, "startTime": "2026-10-16T18:00:00-05:00", "temperature": 52, "temperatureUnit": "F"},
+				{"number": 2, "startTime": "2026-10-16T19:00:00-05:00", "temperature": 50, "temperatureUnit": "F"},
+				{"number": 3, "startTime": "2026-10-16T20:00:00-05:00", "temperature": 49, "temperatureUnit": "F"}]}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return server
+}
+
+func TestRouteFunctions(t *testing.T) {
+	initWeatherTest()
+
+	lookups := 0
+	server := testWeatherAPI(&lookups)
+	defer server.Close()
+	defer useProvider(configs.NWSProvider, &server.URL, nil)()
+
+	location := "Route Test, MO. 99901"
+	zipCode := "99901"
+	latitude, longitude := 38.6, -90.2
+	defer storeTestLocation(t, &ZipCode{
+		Country:           utils.StringPointer("US"),
+		ZipCode:           &zipCode,
+		City:              utils.StringPointer("Route Test"),
+		StateAbbreviation: utils.StringPointer("MO"),
+		Latitude:          &latitude,
+		Longitude:         &longitude,
+	})()
+	defer func() {
+		adHocLocationsMutex.Lock()
+		delete(adHocLocations, location)
+		adHocLocationsMutex.Unlock()
+	}()
+
+	t.Run("Test the websocket", func(t *testing.T) {
+		route := Route
+		requestType := getCurrentRequestKey
+		request := &configs.WsMessage{Route: &route, Type: &requestType, Data: map[string]interface{}{"zip": "99901"}}
+		response := &configs.WsMessage{}
+		HandleWebsocketRequest(request, response)
+		require.Nil(t, response.Error)
+		report, ok := response.Data.(*Report)
+		require.True(t, ok)
+		require.Equal(t, location, *report.Location)
+		require.Equal(t, "Tonight", *report.Current.Name)
+		require.Empty(t, report.Periods)
+		require.Equal(t, 1, lookups)
+
+		// the forecast is fresh so the next request doesn't look it up again
+		requestType = getForecastRequestKey
+		request.Data = map[string]interface{}{"location": location}
+		HandleWebsocketRequest(request, response)
+		require.Nil(t, response.Error)
+		report, ok = response.Data.(*Report)
+		require.True(t, ok)
+		require.Len(t, report.Periods, 2)
+		require.Nil(t, report.Current)
+		require.Equal(t, 1, lookups)
+
+		// a location that was asked for isn't refreshed on the schedule or reported in the refresh status
+		ZipCodeCacheMutex.Lock()
+		_, ok = ZipcodeLookup[location]
+		ZipCodeCacheMutex.Unlock()
+		require.False(t, ok)
+		for _, status := range GetRefreshStatus().Locations {
+			require.NotEqual(t, location, *status.Location)
+		}
+
+		request.Data = map[string]interface{}{"zip": "00000"}
+		HandleWebsocketRequest(request, response)
+		require.NotNil(t, response.Error)
+
+		// the postal code is looked up in the country asked for
+		request.Data = map[string]interface{}{"zip": "99901", "country": "ca"}
+		response = &configs.WsMessage{}
+		HandleWebsocketRequest(request, response)
+		require.NotNil(t, response.Error)
+
+		requestType = searchRequestKey
+		request.Data = map[string]interface{}{"prefix": "route test, mo", "limit": 5}
+		response = &configs.WsMessage{}
+		HandleWebsocketRequest(request, response)
+		require.Nil(t, response.Error)
+		require.Equal(t, []string{location}, response.Data)
+
+		requestType = "bogus"
+		HandleWebsocketRequest(request, response)
+		require.NotNil(t, response.Error)
+	})
+
+	t.Run("Test the locations asked for are bounded", func(t *testing.T) {
+		adHocLocationsMutex.Lock()
+		saved := adHocLocations
+		adHocLocations = make(map[string]*adHocLocation)
+		adHocLocationsMutex.Unlock()
+		defer func() {
+			adHocLocationsMutex.Lock()
+			adHocLocations = saved
+			adHocLocationsMutex.Unlock()
+		}()
+
+		for i := range maxAdHocLocations + 5 {
+			cacheAdHocLocation(fmt.Sprintf("Bound Test %d", i), &ZipCode{})
+		}
+		adHocLocationsMutex.Lock()
+		require.Len(t, adHocLocations, maxAdHocLocations)
+		adHocLocations["Bound Test 0"] = &adHocLocation{zip: &ZipCode{}, cached: time.Now().Add(-adHocLocationTTL)}
+		adHocLocationsMutex.Unlock()
+
+		// an expired location is looked up again rather than kept
+		fresh := &ZipCode{}
+		require.Same(t, fresh, cacheAdHocLocation("Bound Test 0", fresh))
+	})
+
+	t.Run("Test REST", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		HandleRESTRequest(w, httptest.NewRequest(http.MethodGet, "/REST/v1.0.0/weather/getHourly?country=US&zip=99901", nil), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var report Report
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		require.Len(t, report.Hourly, 3)
+		require.Equal(t, "99901", *report.ZipCode)
+		require.Equal(t, "US", *report.Country)
+
+		// without a location the user's location is used, there isn't one without a user
+		w = httptest.NewRecorder()
+		HandleRESTRequest(w, httptest.NewRequest(http.MethodGet, "/REST/v1.0.0/weather/getCurrent", nil), nil)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = httptest.NewRecorder()
+		HandleRESTRequest(w, httptest.NewRequest(http.MethodGet, "/REST/v1.0.0/weather/searchLocations?prefix=99901", nil), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `["Route Test, MO. 99901"]`, w.Body.String())
+
+		w = httptest.NewRecorder()
+		HandleRESTRequest(w, httptest.NewRequest(http.MethodGet, "/REST/v1.0.0/weather/searchLocations?prefix=99901&limit=x", nil), nil)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = httptest.NewRecorder()
+		HandleRESTRequest(w, httptest.NewRequest(http.MethodGet, "/REST/v1.0.0/weather/bogus", nil), nil)
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		w = httptest.NewRecorder()
+		HandleRESTRequest(w, httptest.NewRequest(http.MethodPost, "/REST/v1.0.0/weather/getCurrent", nil), nil)
+		require.Equal(t, http.StatusNotImplemented, w.Code)
+	})
+}
@@ -0,0 +1,254 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+var (
+	scheduleMutex       sync.Mutex
+	lastForecastRefresh *int64
+	lastLocationRefresh *int64
+	// the observations are retrieved on their own schedule, they change once a day rather than with every forecast
+	lastObservationRefresh *int64
+	locationStatus         = make(map[string]*LocationStatus)
+
+	// a refresh that is still running when the next one comes due is not started twice
+	forecastRefreshMutex sync.Mutex
+	errRefreshRunning    = errors.New("a forecast refresh is already running")
+)
+
+// LocationStatus is how the last forecast refresh of a location went
+type LocationStatus struct {
+	Location    *string `json:"location,omitempty"`
+	Users       int     `json:"users"`
+	LastAttempt *int64  `json:"lastAttempt,omitempty"`
+	LastSuccess *int64  `json:"lastSuccess,omitempty"`
+	Failures    int     `json:"failures"`
+	Error       *string `json:"error,omitempty"`
+}
+
+// RefreshStatus is the schedule of the forecast and location refreshes with the status of every location refreshed
+type RefreshStatus struct {
+	Provider               *string           `json:"provider,omitempty"`
+	ForecastInterval       int               `json:"forecastInterval"`
+	LocationInterval       int               `json:"locationInterval"`
+	ObservationInterval    int               `json:"observationInterval"`
+	LastForecastRefresh    *int64            `json:"lastForecastRefresh,omitempty"`
+	NextForecastRefresh    *int64            `json:"nextForecastRefresh,omitempty"`
+	LastLocationRefresh    *int64            `json:"lastLocationRefresh,omitempty"`
+	NextLocationRefresh    *int64            `json:"nextLocationRefresh,omitempty"`
+	LastObservationRefresh *int64            `json:"lastObservationRefresh,omitempty"`
+	NextObservationRefresh *int64            `json:"nextObservationRefresh,omitempty"`
+	Locations              []*LocationStatus `json:"locations"`
+}
+
+func timedTask() {
+	// move the timer to the top of the minute for execution
+	time.Sleep(time.Duration(60-time.Now().Local().Second()) * time.Second)
+	log.Infof("weather refreshes will run every %d minutes and location refreshes every %d minutes",
+		forecastInterval()/time.Minute, locationInterval()/time.Minute)
+
+	for range time.NewTicker(1 * time.Minute).C {
+		now := time.Now()
+		scheduleMutex.Lock()
+		forecastDue := isDue(lastForecastRefresh, forecastInterval(), now)
+		locationDue := isDue(lastLocationRefresh, locationInterval(), now)
+		observationDue := isDue(lastObservationRefresh, observationInterval(), now)
+		scheduleMutex.Unlock()
+
+		if observationDue {
+			go func() {
+				if err := getObservations(); err != nil {
+					log.Errorf("error retrieving observations: %s", err)
+				}
+			}()
+		}
+
+		// reloading the locations refreshes the forecasts when it's done
+		if locationDue {
+			if err := getLocations(); err != nil {
+				log.Errorf("error refreshing locations: %s", err)
+			}
+		} else if forecastDue {
+			go func() {
+				if err := getWeather(); err != nil {
+					log.Errorf("error refreshing forecasts: %s", err)
+				}
+			}()
+		}
+	}
+}
+
+func isDue(last *int64, interval time.Duration, now time.Time) bool {
+	return last == nil || !now.Before(time.UnixMilli(*last).Add(interval))
+}
+
+func forecastInterval() time.Duration {
+	if configs.GrowSTLGo != nil && configs.GrowSTLGo.Weather != nil && configs.GrowSTLGo.Weather.ForecastInterval != nil {
+		return time.Duration(*configs.GrowSTLGo.Weather.ForecastInterval) * time.Minute
+	}
+	return configs.DefaultForecastInterval * time.Minute
+}
+
+func locationInterval() time.Duration {
+	if configs.GrowSTLGo != nil && configs.GrowSTLGo.Weather != nil && configs.GrowSTLGo.Weather.LocationInterval != nil {
+		return time.Duration(*configs.GrowSTLGo.Weather.LocationInterval) * time.Minute
+	}
+	return configs.DefaultLocationInterval * time.Minute
+}
+
+func markLocationRefresh() {
+	now := time.Now().UnixMilli()
+	scheduleMutex.Lock()
+	lastLocationRefresh = &now
+	scheduleMutex.Unlock()
+}
+
+func markObservationRefresh() {
+	now := time.Now().UnixMilli()
+	scheduleMutex.Lock()
+	lastObservationRefresh = &now
+	scheduleMutex.Unlock()
+}
+
+func markForecastRefresh() {
+	now := time.Now().UnixMilli()
+	scheduleMutex.Lock()
+	lastForecastRefresh = &now
+	scheduleMutex.Unlock()
+}
+
+// recordRefresh keeps how the refresh of the location went, failures are counted until the next success
+func recordRefresh(location string, err error) {
+	now := time.Now().UnixMilli()
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+
+	status, ok := locationStatus[location]
+	if !ok {
+		status = &LocationStatus{Location: &location}
+		locationStatus[location] = status
+	}
+	status.LastAttempt = &now
+	if err != nil {
+		e := err.Error()
+		status.Error = &e
+		status.Failures++
+		return
+	}
+	status.LastSuccess = &now
+	status.Error = nil
+	status.Failures = 0
+}
+
+// GetRefreshStatus returns the refresh schedule and the status of the locations that have been refreshed, sorted by
+// location
+func GetRefreshStatus() *RefreshStatus {
+	users := make(map[string]int)
+	if configs.GrowSTLGo != nil {
+		configs.GrowSTLGo.UsersMutex.Lock()
+		for _, user := range configs.GrowSTLGo.Users {
+			if user.Location != nil {
+				users[*user.Location]++
+			}
+		}
+		configs.GrowSTLGo.UsersMutex.Unlock()
+	}
+
+	status := &RefreshStatus{
+		ForecastInterval:    int(forecastInterval() / time.Minute),
+		LocationInterval:    int(locationInterval() / time.Minute),
+		ObservationInterval: int(observationInterval() / time.Minute),
+		Locations:           []*LocationStatus{},
+	}
+	if configs.GrowSTLGo != nil && configs.GrowSTLGo.Weather != nil {
+		status.Provider = configs.GrowSTLGo.Weather.Provider
+	}
+
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+	status.LastForecastRefresh = lastForecastRefresh
+	status.NextForecastRefresh = nextRefresh(lastForecastRefresh, forecastInterval())
+	status.LastLocationRefresh = lastLocationRefresh
+	status.NextLocationRefresh = nextRefresh(lastLocationRefresh, locationInterval())
+	status.LastObservationRefresh = lastObservationRefresh
+	status.NextObservationRefresh = nextRefresh(lastObservationRefresh, observationInterval())
+	for _, location := range locationStatus {
+		// a copy so the caller isn't reading it while a refresh updates it
+		copied := *location
+		copied.Users = users[*location.Location]
+		status.Locations = append(status.Locations, &copied)
+	}
+	slices.SortFunc(status.Locations, func(a, b *LocationStatus) int {
+		return strings.Compare(*a.Location, *b.Location)
+	})
+	return status
+}
+
+func nextRefresh(last *int64, interval time.Duration) *int64 {
+	if last == nil {
+		return nil
+	}
+	next := time.UnixMilli(*last).Add(interval).UnixMilli()
+	return &next
+}
+
+// RefreshForecasts refreshes the forecast of the location, or of every user's location when none is given, right
+// away rather than waiting for the schedule.  Locations that fail are reported in the status rather than as an error
+func RefreshForecasts(location *string) (*RefreshStatus, error) {
+	if location == nil {
+		if err := getWeather(); err != nil {
+			if errors.Is(err, errRefreshRunning) {
+				return nil, err
+			}
+			log.Errorf("error refreshing forecasts: %s", err)
+		}
+		return GetRefreshStatus(), nil
+	}
+
+	zip, err := GetLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	if err := getWeatherHelper(map[string]*ZipCode{*location: zip}); err != nil {
+		log.Errorf("error refreshing forecasts: %s", err)
+		return GetRefreshStatus(), nil
+	}
+
+	// the users of the location get their alerts as they would on the schedule
+	locations := make(map[string]string)
+	if configs.GrowSTLGo != nil {
+		configs.GrowSTLGo.UsersMutex.Lock()
+		for userID, user := range configs.GrowSTLGo.Users {
+			if user.Location != nil && *user.Location == *location {
+				locations[userID] = *location
+			}
+		}
+		configs.GrowSTLGo.UsersMutex.Unlock()
+	}
+	if _, err := checkAlerts(locations); err != nil {
+		log.Errorf("error checking weather alerts: %s", err)
+	}
+	return GetRefreshStatus(), nil
+}
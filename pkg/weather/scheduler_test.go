@@ -0,0 +1,101 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package weather
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+func findStatus(status *RefreshStatus, location string) *LocationStatus {
+	for _, locationStatus := range status.Locations {
+		if *locationStatus.Location == location {
+			return locationStatus
+		}
+	}
+	return nil
+}
+
+func TestSchedulerFunctions(t *testing.T) {
+	initWeatherTest()
+
+	t.Run("Test when a refresh is due", func(t *testing.T) {
+		now := time.Now()
+		require.True(t, isDue(nil, time.Hour, now))
+		last := now.Add(-59 * time.Minute).UnixMilli()
+		require.False(t, isDue(&last, time.Hour, now))
+		last = now.Add(-time.Hour).UnixMilli()
+		require.True(t, isDue(&last, time.Hour, now))
+	})
+
+	t.Run("Test the location status", func(t *testing.T) {
+		location := "Status Test, MO. " + uuid.New().String()
+		recordRefresh(location, errors.New("provider is down"))
+		recordRefresh(location, errors.New("provider is still down"))
+		status := findStatus(GetRefreshStatus(), location)
+		require.NotNil(t, status)
+		require.Equal(t, 2, status.Failures)
+		require.Equal(t, "provider is still down", *status.Error)
+		require.Nil(t, status.LastSuccess)
+
+		recordRefresh(location, nil)
+		status = findStatus(GetRefreshStatus(), location)
+		require.Zero(t, status.Failures)
+		require.Nil(t, status.Error)
+		require.NotNil(t, status.LastSuccess)
+	})
+
+	t.Run("Test a manual refresh", func(t *testing.T) {
+		defer useProvider(configs.FixtureProvider, nil, nil)()
+
+		location := "Refresh Test, MO. " + uuid.New().String()
+		latitude, longitude := 38.6, -90.2
+		zip := &ZipCode{Latitude: &latitude, Longitude: &longitude}
+		ZipCodeCacheMutex.Lock()
+		ZipcodeLookup[location] = zip
+		ZipCodeCacheMutex.Unlock()
+		defer func() {
+			ZipCodeCacheMutex.Lock()
+			delete(ZipcodeLookup, location)
+			ZipCodeCacheMutex.Unlock()
+		}()
+
+		status, err := RefreshForecasts(&location)
+		require.NoError(t, err)
+		require.NotNil(t, findStatus(status, location).LastSuccess)
+		require.Len(t, zip.Periods, 4)
+
+		bogus := "Nowhere, XX. 00000"
+		_, err = RefreshForecasts(&bogus)
+		require.Error(t, err)
+
+		// a refresh isn't started while one is running
+		forecastRefreshMutex.Lock()
+		_, err = RefreshForecasts(nil)
+		forecastRefreshMutex.Unlock()
+		require.ErrorIs(t, err, errRefreshRunning)
+
+		status, err = RefreshForecasts(nil)
+		require.NoError(t, err)
+		require.NotNil(t, status.LastForecastRefresh)
+		require.Equal(t, *status.LastForecastRefresh+int64(status.ForecastInterval)*time.Minute.Milliseconds(), *status.NextForecastRefresh)
+	})
+}
@@ -15,33 +15,14 @@
 package weather
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
-	"net/url"
 	"time"
 
 	"stl-go/grow-with-stl-go/pkg/configs"
 	"stl-go/grow-with-stl-go/pkg/log"
 )
 
-type latLongLookup struct {
-	Properties *forecastURL `json:"properties,omitempty"`
-}
-
-type forecastURL struct {
-	ForecastURL *string `json:"forecast,omitempty"`
-}
-
-type forecastLookup struct {
-	Properties *periods `json:"properties,omitempty"`
-}
-
-type periods struct {
-	Periods []*Forecast `json:"periods,omitempty"`
-}
-
 // Forecast will be held with the zip code lookups for display purposes
 type Forecast struct {
 	Number                     *int                        `json:"number,omitempty"`
@@ -67,103 +48,115 @@ type probabilityOfPrecipitation struct {
 
 // Init is different than the standard init because it is called outside of the object load
 func Init() error {
+	if err := getFrostDates(); err != nil {
+		return err
+	}
+	if err := setupAlerts(); err != nil {
+		return err
+	}
+	if err := setupCache(); err != nil {
+		return err
+	}
+	if err := setupLocations(); err != nil {
+		return err
+	}
+	if err := setupObservations(); err != nil {
+		return err
+	}
 	go timedTask()
 	return getLocations()
 }
 
+// getWeather refreshes the forecasts of every user's location and raises their alerts
 func getWeather() error {
+	if !forecastRefreshMutex.TryLock() {
+		return errRefreshRunning
+	}
+	defer forecastRefreshMutex.Unlock()
+
 	if configs.GrowSTLGo != nil && configs.GrowSTLGo.Users != nil {
-		lookups := make(map[string]*ZipCode)
-		for _, user := range configs.GrowSTLGo.Users {
+		markForecastRefresh()
+		userLocations := make(map[string]string)
+		configs.GrowSTLGo.UsersMutex.Lock()
+		for userID, user := range configs.GrowSTLGo.Users {
 			if user.Location != nil {
-				ZipCodeCacheMutex.Lock()
-				zip, ok := ZipcodeLookup[*user.Location]
-				ZipCodeCacheMutex.Unlock()
-				if ok {
-					lookups[*user.Location] = zip
-				}
+				userLocations[userID] = *user.Location
 			}
 		}
-		return getWeatherHelper(lookups)
+		configs.GrowSTLGo.UsersMutex.Unlock()
+
+		// the locations are looked up once the users are unlocked, those not cached yet come from the database
+		lookups := make(map[string]*ZipCode)
+		locations := make(map[string]string)
+		for userID, location := range userLocations {
+			zip, err := GetLocation(&location)
+			if err != nil {
+				log.Debug(err)
+				continue
+			}
+			lookups[location] = zip
+			locations[userID] = location
+		}
+
+		weatherErr := getWeatherHelper(lookups)
+		// alert on whatever forecasts were retrieved even if some of the lookups failed
+		if _, err := checkAlerts(locations); err != nil {
+			log.Errorf("error checking weather alerts: %s", err)
+		}
+		return weatherErr
 	}
 	return errors.New("cannot get weather, configuration is invalid")
 }
 
 func getWeatherHelper(lookups map[string]*ZipCode) error {
-	if configs.GrowSTLGo != nil && configs.GrowSTLGo.WeatherAPI != nil {
-		for _, zip := range lookups {
-			if zip.Latitude != nil && zip.Longitude != nil {
-				url, urlErr := url.JoinPath(*configs.GrowSTLGo.WeatherAPI, "points", fmt.Sprintf("%f,%f", *zip.Latitude, *zip.Longitude))
-				if urlErr != nil {
-					return urlErr
-				}
-				response, statusCode, requestErr := configs.HTTPRequest(url, http.MethodGet, nil)
-				if requestErr != nil {
-					return requestErr
-				}
-				if statusCode != nil && *statusCode >= 300 {
-					if response == nil {
-						return fmt.Errorf("bad response from url %s.  HTTP Status Code %d", url, *statusCode)
-					}
-					return fmt.Errorf("bad response from url %s.  Response '%s'.  HTTP Status Code %d", url, *response, *statusCode)
-				}
-				var lookup latLongLookup
-				unmarshalErr := json.Unmarshal([]byte(*response), &lookup)
-				if unmarshalErr != nil {
-					return unmarshalErr
-				}
-
-				if lookup.Properties != nil && lookup.Properties.ForecastURL != nil {
-					if forecastErr := getForecast(lookup.Properties.ForecastURL, zip); forecastErr != nil {
-						return forecastErr
-					}
-				}
+	provider, err := getProvider()
+	if err != nil {
+		return err
+	}
+
+	// a location that fails doesn't keep the others from being refreshed
+	var errs []error
+	for location, zip := range lookups {
+		if zip.Latitude != nil && zip.Longitude != nil {
+			periods, hourly, err := provider.Forecast(*zip.Latitude, *zip.Longitude)
+			recordRefresh(location, err)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("cannot get the forecast for %s: %w", location, err))
+				continue
 			}
+			setForecast(zip, periods, hourly)
 		}
-		return nil
 	}
-	return errors.New("invalid configuration cannot get weather")
+	return errors.Join(errs...)
 }
 
-func getForecast(url *string, zip *ZipCode) error {
-	if url != nil && zip != nil {
-		response, statusCode, requestErr := configs.HTTPRequest(*url, http.MethodGet, nil)
-		if requestErr != nil {
-			return requestErr
-		}
-		if statusCode != nil && *statusCode >= 300 {
-			if response == nil {
-				return fmt.Errorf("bad response from url %s.  HTTP Status Code %d", *url, *statusCode)
-			}
-			return fmt.Errorf("bad response from url %s.  Response '%s'.  HTTP Status Code %d", *url, *response, *statusCode)
-		}
-		var lookup forecastLookup
-		unmarshalErr := json.Unmarshal([]byte(*response), &lookup)
-		if unmarshalErr != nil {
-			return unmarshalErr
-		}
-		if lookup.Properties != nil && len(lookup.Properties.Periods) > 0 {
-			zip.Mutex.Lock()
-			zip.Forecast = lookup.Properties.Periods[0]
-			zip.Mutex.Unlock()
-		}
-		return nil
+// fetchForecast retrieves the forecast of a location that was asked for, it's left out of the refresh status which
+// only covers the users' locations
+func fetchForecast(zip *ZipCode) error {
+	provider, err := getProvider()
+	if err != nil {
+		return err
+	}
+	if zip.Latitude == nil || zip.Longitude == nil {
+		return errors.New("the location has no coordinates")
 	}
-	return errors.New("invalid url or zip cannot get weather forecast")
+	periods, hourly, err := provider.Forecast(*zip.Latitude, *zip.Longitude)
+	if err != nil {
+		return err
+	}
+	setForecast(zip, periods, hourly)
+	return nil
 }
 
-func timedTask() {
-	seconds := time.Duration((60-time.Now().Local().Second())+120) * time.Second
-	log.Infof("location timed tasks will start at %s", time.Now().Add(seconds).Format(configs.HHMMSS))
-
-	for range time.NewTicker(1 * time.Minute).C {
-		// hit it at the top of every hour
-		if time.Now().Minute() == 0 {
-			go func() {
-				if err := getLocations(); err != nil {
-					log.Errorf("error refreshing locations: %s", err)
-				}
-			}()
-		}
+// setForecast keeps the forecast with the zip code, the current period is the first one
+func setForecast(zip *ZipCode, periods, hourly []*Forecast) {
+	if len(periods) > 0 {
+		updated := time.Now().UnixMilli()
+		zip.Mutex.Lock()
+		zip.Forecast = periods[0]
+		zip.Periods = periods
+		zip.Hourly = hourly
+		zip.Updated = &updated
+		zip.Mutex.Unlock()
 	}
 }
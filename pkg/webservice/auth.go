@@ -25,16 +25,19 @@ import (
 	"stl-go/grow-with-stl-go/pkg/log"
 
 	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
-// Create the JWT key used to create the signature
-// TODO: use a private key for this instead of a phrase
-var jwtKey = []byte("grow-with-stl-go!")
-
 const (
 	username   = "username"
 	sessionID  = "sessionID"
 	expiration = "exp"
+	issuedAt   = "iat"
+	tokenID    = "jti"
+	keyID      = "kid"
+
+	// how long a token, or a refresh of it, is valid for
+	tokenLifetime = time.Hour
 )
 
 func validateAPIUser(vhost string, body []byte) (*string, error) {
@@ -79,6 +82,12 @@ func validateJWTClaim(token *jwt.Token, requestSessionID *string, request *confi
 			return nil, fmt.Errorf("invalid JWT session id %s attempted for %s session", *requestSessionID, sessionID)
 		}
 
+		// a token without an id can't be revoked so it isn't accepted
+		jti, ok := claim[tokenID].(string)
+		if !ok || isRevoked(jti) {
+			return nil, errors.New("JWT has been revoked")
+		}
+
 		// extract the user from the claim
 		if username, ok := claim[username].(string); ok {
 			// test to see if we need to refresh the token
@@ -95,27 +104,87 @@ func validateJWTClaim(token *jwt.Token, requestSessionID *string, request *confi
 func createJWTToken(userid, sessionid *string) (token *string, validTill *int64, err error) {
 	if userid != nil && sessionid != nil {
 		// set some claims
-		validTill := time.Now().Add(time.Hour * 1).Unix()
+		now := time.Now()
+		validTill := now.Add(tokenLifetime).Unix()
+		jti := uuid.New().String()
 
 		claims := make(jwt.MapClaims)
 		claims[username] = *userid
 		claims[sessionID] = *sessionid
 		claims[expiration] = validTill
-
-		// create the token
-		jwtClaim := jwt.New(jwt.SigningMethodHS256)
-		jwtClaim.Claims = claims
+		claims[issuedAt] = now.Unix()
+		claims[tokenID] = jti
 
 		// the JWT requires epoch seconds, we prefer milliseconds
 		validTillMilli := validTill * 1000
 
+		// the token is tracked before it's handed out so it can always be revoked
+		if err := recordToken(jti, *userid, *sessionid, validTillMilli); err != nil {
+			return nil, nil, err
+		}
+
 		// Sign and get the complete encoded token as string
-		token, err := jwtClaim.SignedString(jwtKey)
-		return &token, &validTillMilli, err
+		token, err := signJWT(claims)
+		return token, &validTillMilli, err
 	}
 	return nil, nil, errors.New("nil user id of session id, cannot create JWT")
 }
 
+// signJWT signs the claims with the active signing key, the kid header says which key so it can be validated after the
+// key is rotated
+func signJWT(claims jwt.MapClaims) (*string, error) {
+	kid, algorithm, signer, err := configs.GetJWTSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	method := jwt.GetSigningMethod(*algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("unknown JWT signing method %s", *algorithm)
+	}
+
+	jwtClaim := jwt.NewWithClaims(method, claims)
+	jwtClaim.Header[keyID] = *kid
+	token, err := jwtClaim.SignedString(signer)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// jwtKeyFunc finds the public key of the kid the token was signed with, a token signed by a retired key is only accepted
+// during its grace period and the token's algorithm has to be the key's
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header[keyID].(string)
+	if !ok {
+		return nil, errors.New("JWT has no kid")
+	}
+
+	algorithm, publicKey, err := configs.GetJWTVerificationKey(kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Method == nil || token.Method.Alg() != *algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return publicKey, nil
+}
+
+// getTokenID returns the id of a token signed by one of the signing keys, an expired token still has its id
+func getTokenID(tokenString string) (*string, error) {
+	token, err := jwt.NewParser(jwt.WithoutClaimsValidation()).Parse(tokenString, jwtKeyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if claim, ok := token.Claims.(jwt.MapClaims); ok {
+		if jti, ok := claim[tokenID].(string); ok {
+			return &jti, nil
+		}
+	}
+	return nil, errors.New("JWT has no id")
+}
+
 // from time to time we might want to send a refresh token to the UI.  The UI should not be in charge of requesting it
 func testForRefresh(claim jwt.MapClaims, request *configs.WsMessage) {
 	// for some reason the exp is stored as an float and not an int in the claim conversion
@@ -135,15 +204,22 @@ func createRefreshToken(claim jwt.MapClaims, request *configs.WsMessage) {
 	sessionsMutex.Unlock()
 	if ok {
 		// add the new expiration to the claim
-		validTill := time.Now().Add(time.Hour * 1).Unix()
+		validTill := time.Now().Add(tokenLifetime).Unix()
 		claim[expiration] = validTill
 
-		// create the token
-		jwtClaim := jwt.New(jwt.SigningMethodHS256)
-		jwtClaim.Claims = claim
+		// the refresh keeps the token id so revoking the token revokes its refreshes too
+		jti, ok := claim[tokenID].(string)
+		if !ok {
+			log.Error("cannot refresh a JWT without an id")
+			return
+		}
+		if err := extendToken(jti, validTill*1000); err != nil {
+			log.Error(err)
+			return
+		}
 
 		// Sign and get the complete encoded token as string
-		refreshToken, err := jwtClaim.SignedString(jwtKey)
+		refreshToken, err := signJWT(claim)
 		if err != nil {
 			log.Error(err)
 			return
@@ -162,7 +238,7 @@ func createRefreshToken(claim jwt.MapClaims, request *configs.WsMessage) {
 			Component:    &auth,
 			SubComponent: &refresh,
 			ValidTill:    &validTillMilli,
-			RefreshToken: &refreshToken,
+			RefreshToken: refreshToken,
 		}); err != nil {
 			log.Error(err)
 			session.onError()
@@ -0,0 +1,234 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package webservice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+	"stl-go/grow-with-stl-go/pkg/log"
+)
+
+var (
+	tokenTableName = "issuedTokens"
+	tokenTable     = &configs.Table{
+		// issued, expires and revoked are epoch milliseconds, a refresh token keeps the jti and moves the expiration
+		CreateSQL: `CREATE TABLE IF NOT EXISTS issuedTokens (
+			jti varchar(64) NOT NULL PRIMARY KEY,
+			user varchar(128) NOT NULL,
+			sessionID varchar(64) NOT NULL,
+			issued bigint NOT NULL,
+			expires bigint NOT NULL,
+			revoked bigint)`,
+		InsertSQL: "INSERT INTO issuedTokens values(?,?,?,?,?,null)",
+		UpdateSQL: "UPDATE issuedTokens set expires = ? where jti = ? and revoked is null",
+		DeleteSQL: "DELETE FROM issuedTokens where expires < ?",
+		Indices: []string{
+			"CREATE INDEX IF NOT EXISTS issuedtokenuser on issuedTokens(user)",
+			"CREATE INDEX IF NOT EXISTS issuedtokenexpires on issuedTokens(expires)",
+		},
+	}
+
+	// every request checks its token against the revoked tokens that haven't expired, by jti with when they expire
+	revokedTokens      = map[string]int64{}
+	revokedTokensMutex sync.RWMutex
+)
+
+// Init creates the table the issued tokens are tracked in and loads the revoked tokens that haven't expired yet
+func Init() error {
+	if err := tokenTable.CreateTable(&tokenTableName); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	if _, err := tokenTable.Exec(tokenTable.DeleteSQL, now); err != nil {
+		return err
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return fmt.Errorf("unable to load revoked tokens, sqlite error: %s", dbErr)
+	}
+	rows, err := db.Query("select jti, expires from issuedTokens where revoked is not null and expires >= ?", now)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	revokedTokensMutex.Lock()
+	defer revokedTokensMutex.Unlock()
+	clear(revokedTokens)
+	for rows.Next() {
+		var jti string
+		var expires int64
+		if err := rows.Scan(&jti, &expires); err != nil {
+			return err
+		}
+		revokedTokens[jti] = expires
+	}
+	return rows.Err()
+}
+
+// recordToken tracks a token that was handed out so it can be revoked, the tokens that have expired are dropped
+func recordToken(jti, userID, sessionID string, expires int64) error {
+	now := time.Now().UnixMilli()
+	if _, err := tokenTable.Exec(tokenTable.DeleteSQL, now); err != nil {
+		return err
+	}
+	_, err := tokenTable.Exec(tokenTable.InsertSQL, jti, userID, sessionID, now, expires)
+	return err
+}
+
+// extendToken moves the expiration of a token that was refreshed, a revoked token is never refreshed
+func extendToken(jti string, expires int64) error {
+	rows, err := tokenTable.Exec(tokenTable.UpdateSQL, expires, jti)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("token %s is not an active token", jti)
+	}
+	return nil
+}
+
+// isRevoked tests the jti against the revoked tokens
+func isRevoked(jti string) bool {
+	revokedTokensMutex.RLock()
+	defer revokedTokensMutex.RUnlock()
+	_, ok := revokedTokens[jti]
+	return ok
+}
+
+// revokeToken revokes the token with the jti, it's rejected from then on
+func revokeToken(jti string) error {
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return fmt.Errorf("unable to revoke token, sqlite error: %s", dbErr)
+	}
+
+	var expires int64
+	err := db.QueryRow("select expires from issuedTokens where jti = ? and revoked is null", jti).Scan(&expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("token %s is not an active token", jti)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tokenTable.Exec("UPDATE issuedTokens set revoked = ? where jti = ?", time.Now().UnixMilli(), jti); err != nil {
+		return err
+	}
+	cacheRevoked(map[string]int64{jti: expires})
+	return nil
+}
+
+// RevokeUserTokens revokes every token the user has been handed out that hasn't expired and closes the user's
+// websocket sessions, it returns how many tokens were revoked
+func RevokeUserTokens(userID *string) (int, error) {
+	if userID == nil {
+		return 0, errors.New("no user found, cannot revoke tokens")
+	}
+
+	db, dbErr := configs.GetSQLiteConnection()
+	if dbErr != nil {
+		return 0, fmt.Errorf("unable to revoke tokens, sqlite error: %s", dbErr)
+	}
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer configs.RollbackHelper(tx)
+
+	// the update goes first so the transaction holds the write lock before the revoked tokens are read back, the
+	// bundled sqlite predates update ... returning
+	now := time.Now().UnixMilli()
+	count, err := tokenTable.ExecTx(tx, "UPDATE issuedTokens set revoked = ? where user = ? and revoked is null and expires >= ?",
+		now, userID, now)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query("select jti, expires from issuedTokens where user = ? and revoked = ?", userID, now)
+	if err != nil {
+		return 0, err
+	}
+	revoked := map[string]int64{}
+	for rows.Next() {
+		var jti string
+		var expires int64
+		if err := rows.Scan(&jti, &expires); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		revoked[jti] = expires
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	cacheRevoked(revoked)
+
+	closeUserSessions(userID)
+	log.Infof("%d tokens revoked for user '%s'", count, *userID)
+	return int(count), nil
+}
+
+// cacheRevoked adds the revoked tokens to the cache and drops the ones that have expired
+func cacheRevoked(revoked map[string]int64) {
+	now := time.Now().UnixMilli()
+	revokedTokensMutex.Lock()
+	defer revokedTokensMutex.Unlock()
+	for jti, expires := range revokedTokens {
+		if expires < now {
+			delete(revokedTokens, jti)
+		}
+	}
+	for jti, expires := range revoked {
+		revokedTokens[jti] = expires
+	}
+}
+
+// closeUserSessions sends the user's websocket sessions back to the login screen and closes them
+func closeUserSessions(userID *string) {
+	ui := configs.UI
+	auth := configs.Auth
+	denied := configs.Denied
+	e := configs.UnauthorizedError
+
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+	for _, session := range sessions {
+		if session == nil || session.user == nil || *session.user != *userID {
+			continue
+		}
+		if err := session.webSocketSend(&configs.WsMessage{
+			Type:         &ui,
+			Component:    &auth,
+			SubComponent: &denied,
+			Error:        &e,
+		}); err != nil {
+			log.Error(err)
+		}
+		session.onClose()
+	}
+}
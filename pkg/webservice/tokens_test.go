@@ -0,0 +1,136 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package webservice
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+
+	"stl-go/grow-with-stl-go/pkg/configs"
+)
+
+func initTokenTest(t *testing.T) {
+	configFile := "../../etc/grow-with-stl-go.json"
+	configs.ConfigFile = &configFile
+	require.NoError(t, configs.SetGrowSTLGoConfig())
+	require.NoError(t, Init())
+}
+
+// validateTestToken validates the token the same way a request's token is
+func validateTestToken(token, requestSessionID *string) (*string, error) {
+	parsed, err := jwt.Parse(*token, jwtKeyFunc)
+	if err != nil {
+		return nil, err
+	}
+	return validateJWTClaim(parsed, requestSessionID, nil)
+}
+
+func TestTokenFunctions(t *testing.T) {
+	initTokenTest(t)
+	userID, otherUserID := "tokenTestUser", "tokenTestOther"
+	firstSession, secondSession := "token-test-session", "token-test-other-session"
+
+	t.Run("Test revoking a token", func(t *testing.T) {
+		token, _, err := createJWTToken(&userID, &firstSession)
+		require.NoError(t, err)
+		user, err := validateTestToken(token, &firstSession)
+		require.NoError(t, err)
+		require.Equal(t, userID, *user)
+
+		jti, err := getTokenID(*token)
+		require.NoError(t, err)
+		require.NoError(t, revokeToken(*jti))
+		_, err = validateTestToken(token, &firstSession)
+		require.Error(t, err)
+		require.Error(t, revokeToken(*jti))
+
+		// the revocation survives a restart
+		revokedTokensMutex.Lock()
+		clear(revokedTokens)
+		revokedTokensMutex.Unlock()
+		require.NoError(t, Init())
+		require.True(t, isRevoked(*jti))
+	})
+
+	t.Run("Test a token without an id", func(t *testing.T) {
+		kid, algorithm, signer, err := configs.GetJWTSigningKey()
+		require.NoError(t, err)
+		unidentified := jwt.NewWithClaims(jwt.GetSigningMethod(*algorithm), jwt.MapClaims{username: userID, sessionID: firstSession})
+		unidentified.Header[keyID] = *kid
+		token, err := unidentified.SignedString(signer)
+		require.NoError(t, err)
+		_, err = validateTestToken(&token, &firstSession)
+		require.Error(t, err)
+	})
+
+	t.Run("Test revoking every token of a user", func(t *testing.T) {
+		first, _, err := createJWTToken(&userID, &firstSession)
+		require.NoError(t, err)
+		second, _, err := createJWTToken(&userID, &secondSession)
+		require.NoError(t, err)
+		other, _, err := createJWTToken(&otherUserID, &secondSession)
+		require.NoError(t, err)
+
+		revoked, err := RevokeUserTokens(&userID)
+		require.NoError(t, err)
+		require.Equal(t, 2, revoked)
+		_, err = validateTestToken(first, &firstSession)
+		require.Error(t, err)
+		_, err = validateTestToken(second, &secondSession)
+		require.Error(t, err)
+		_, err = validateTestToken(other, &secondSession)
+		require.NoError(t, err)
+
+		revoked, err = RevokeUserTokens(&userID)
+		require.NoError(t, err)
+		require.Equal(t, 0, revoked)
+		_, err = RevokeUserTokens(nil)
+		require.Error(t, err)
+
+		// a revoked token can't be refreshed
+		jti, err := getTokenID(*first)
+		require.NoError(t, err)
+		require.Error(t, extendToken(*jti, 0))
+
+		_, err = RevokeUserTokens(&otherUserID)
+		require.NoError(t, err)
+	})
+
+	t.Run("Test the REST logout", func(t *testing.T) {
+		token, _, err := createJWTToken(&userID, &firstSession)
+		require.NoError(t, err)
+
+		logout := func() int {
+			r := httptest.NewRequest(http.MethodDelete, "/REST/v1.0.0/token", nil)
+			r.Header.Set("Authorization", "Bearer "+*token)
+			r.Header.Set("sessionID", firstSession)
+			w := httptest.NewRecorder()
+			handleRESTRequest(w, r)
+			return w.Code
+		}
+		require.Equal(t, http.StatusNoContent, logout())
+		_, err = validateTestToken(token, &firstSession)
+		require.Error(t, err)
+		require.Equal(t, http.StatusUnauthorized, logout())
+
+		w := httptest.NewRecorder()
+		handleRESTRequest(w, httptest.NewRequest(http.MethodDelete, "/REST/v1.0.0/token", nil))
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
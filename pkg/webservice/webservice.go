@@ -15,6 +15,7 @@
 package webservice
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -29,6 +30,7 @@ import (
 	"stl-go/grow-with-stl-go/pkg/configs"
 	"stl-go/grow-with-stl-go/pkg/log"
 	"stl-go/grow-with-stl-go/pkg/utils"
+	"stl-go/grow-with-stl-go/pkg/weather"
 
 	jwt "github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
@@ -44,12 +46,29 @@ var (
 
 	// this is a hack to move common stuff between vhosts
 	staticCommonPrefixes = regexp.MustCompile(`^(/node_modules/|/common)`)
+
+	// uploaded images are named by the sha256 of their content, anything else under the prefix is refused
+	uploadedImageNames = regexp.MustCompile(`^[0-9a-f]{64}(-thumb)?\.(jpg|png|gif)$`)
 )
 
+type restContextKey string
+
 const (
 	fontAwesomePrefix = "/node_modules/font-awesome/fonts/fontawesome-webfont"
+
+	// the authenticated user is attached to the REST request context with this key
+	restUserKey restContextKey = "user"
 )
 
+// the weather package can't register its own routes because the webservice imports it
+func init() {
+	AppendToWebsocketFunctionMap(weather.Route, weather.HandleWebsocketRequest)
+	AppendToRESTFunctionMap(weather.Route, func(w http.ResponseWriter, r *http.Request) {
+		weather.HandleRESTRequest(w, r, GetRESTUser(r))
+	})
+	weather.SetUserNotifier(NotifyUser)
+}
+
 // AppendToRESTFunctionMap allows us to break up the circular reference from the other packages
 // It does however require them to implement an init function to append them
 // TODO: maybe some form of an interface to enforce this may be necessary?
@@ -61,9 +80,15 @@ func AppendToRESTFunctionMap(requestType string, function func(w http.ResponseWr
 func handleRESTRequest(w http.ResponseWriter, r *http.Request) {
 	uri := r.RequestURI
 
-	if strings.EqualFold(uri, "/REST/v1.0.0/token") && strings.EqualFold(r.Method, http.MethodPost) {
-		handelRESTAuthRequest(w, r)
-		return
+	if strings.EqualFold(uri, "/REST/v1.0.0/token") {
+		switch {
+		case strings.EqualFold(r.Method, http.MethodPost):
+			handelRESTAuthRequest(w, r)
+			return
+		case strings.EqualFold(r.Method, http.MethodDelete):
+			handleRESTLogout(w, r)
+			return
+		}
 	}
 
 	restURI := strings.TrimPrefix(uri, "/REST/v1.0.0/")
@@ -80,16 +105,43 @@ func handleRESTRequest(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		log.Infof("User '%s' authenticated for %s on %s", *id, r.Method, uri)
-		restFunction(w, r)
+		restFunction(w, r.WithContext(context.WithValue(r.Context(), restUserKey, *id)))
 		return
 	}
 
 	serveFile(w, r)
 }
 
+// GetRESTUser returns the user that was authenticated for the REST request
+func GetRESTUser(r *http.Request) *string {
+	if r != nil {
+		if userID, ok := r.Context().Value(restUserKey).(string); ok {
+			return &userID
+		}
+	}
+	return nil
+}
+
+// IsRESTAdmin will test if the user authenticated for the REST request is an active admin
+func IsRESTAdmin(r *http.Request) bool {
+	userID := GetRESTUser(r)
+	if userID != nil && configs.GrowSTLGo != nil {
+		configs.GrowSTLGo.UsersMutex.Lock()
+		user, ok := configs.GrowSTLGo.Users[*userID]
+		configs.GrowSTLGo.UsersMutex.Unlock()
+		return ok && user != nil && user.Active != nil && *user.Active && user.Admin != nil && *user.Admin
+	}
+	return false
+}
+
 // serveFile test if path and file exists, if it does send a page, else 404 or redirect
 func serveFile(w http.ResponseWriter, r *http.Request) {
 	uri := r.RequestURI
+	if strings.HasPrefix(r.URL.Path, configs.ImagesURIPrefix) {
+		serveImage(w, r)
+		return
+	}
+
 	// have to make a special condition for font awesome node modules
 	if strings.HasPrefix(uri, fontAwesomePrefix) {
 		uri = strings.Split(uri, "?")[0]
@@ -118,6 +170,34 @@ func serveFile(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/index.html", http.StatusFound)
 }
 
+// serveImage sends an uploaded image from the data dir, the names are content hashes so the image can never change
+// and the browser is allowed to cache it indefinitely
+func serveImage(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, configs.ImagesURIPrefix)
+	if !uploadedImageNames.MatchString(name) {
+		http.Error(w, configs.NotFoundError, http.StatusNotFound)
+		return
+	}
+
+	dir, err := configs.GetImagesDir()
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.NotFoundError, http.StatusNotFound)
+		return
+	}
+
+	path := filepath.Join(*dir, name)
+	if !fileExists(path) {
+		http.Error(w, configs.NotFoundError, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, strings.TrimSuffix(name, filepath.Ext(name))))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, r, path)
+}
+
 func fileExists(path string) bool {
 	if _, err := os.Stat(path); err != nil {
 		return false
@@ -179,22 +259,49 @@ func handelRESTAuthRequest(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
 }
 
-func handleRESTAuth(r *http.Request) (*string, error) {
+// handleRESTLogout revokes the token the request was made with
+func handleRESTLogout(w http.ResponseWriter, r *http.Request) {
 	defer log.FunctionTimer()()
+	id, err := handleRESTAuth(r)
+	if err != nil {
+		log.Infof("Logout from %s failed.  Error: %s", r.RemoteAddr, err)
+		http.Error(w, configs.UnauthorizedError, http.StatusUnauthorized)
+		return
+	}
 
+	jti, err := getTokenID(bearerToken(r))
+	if err == nil {
+		err = revokeToken(*jti)
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, configs.InternalServerError, http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("User '%s' logged out of session %s", *id, r.Header.Get("sessionID"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bearerToken is the token of the Authorization header, empty without one
+func bearerToken(r *http.Request) string {
 	reqToken := strings.Split(r.Header.Get("Authorization"), "Bearer ")
-	if len(reqToken) == 2 && reqToken[1] != "" {
+	if len(reqToken) == 2 {
+		return reqToken[1]
+	}
+	return ""
+}
+
+func handleRESTAuth(r *http.Request) (*string, error) {
+	defer log.FunctionTimer()()
+
+	if reqToken := bearerToken(r); reqToken != "" {
 		sessionID := r.Header.Get("sessionID")
 		if sessionID == "" {
 			return nil, errors.New("no sessionID found on request header")
 		}
 
-		token, err := jwt.Parse(reqToken[1], func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return jwtKey, nil
-		})
+		token, err := jwt.Parse(reqToken, jwtKeyFunc)
 
 		if err != nil {
 			return nil, err
@@ -119,6 +119,7 @@ func (session *session) onMessage() {
 		go func() {
 			transaction := audit.NewWSTransaction(session.Vhost, session.user, &request)
 			request.IsAdmin = session.isAdmin
+			request.User = session.user
 			// test the auth token for request validity on non auth requests
 			if request.Route != nil && !strings.EqualFold(*request.Route, configs.WebsocketClient) &&
 				request.Type != nil && !strings.EqualFold(*request.Type, configs.Auth) &&
@@ -179,6 +180,17 @@ func (session *session) onError() {
 }
 
 func (session *session) handleRequest(request *configs.WsMessage, transaction *audit.WSTransaction) {
+	// the session handlers look the session up by the id in the request, a request can only act on its own socket's
+	if request.SessionID != nil && *request.SessionID != *session.sessionID {
+		mismatch := fmt.Sprintf("session %s cannot send a request for session %s", *session.sessionID, *request.SessionID)
+		log.Error(mismatch)
+		if err := session.webSocketSend(requestErrorHelper(&mismatch, request)); err != nil {
+			log.Error(err)
+			session.onError()
+		}
+		return
+	}
+
 	if request.Route != nil && request.Type != nil {
 		request.Vhost = session.Vhost
 		if handleMessageFunc, ok := websocketFuncMap[*request.Route]; ok {
@@ -198,6 +210,10 @@ func (session *session) handleRequest(request *configs.WsMessage, transaction *a
 			}
 
 			handleMessageFunc(request, response)
+			// a handler that closed the session, a logout or a failed login, has already sent its response
+			if session.closing != nil && *session.closing {
+				return
+			}
 			if err := session.webSocketSend(response); err != nil {
 				log.Error(err)
 				session.onError()
@@ -231,12 +247,7 @@ func (session *session) validateWSToken(request *configs.WsMessage) error {
 			tokenString = request.RefreshToken
 		}
 
-		token, err := jwt.Parse(*tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return jwtKey, nil
-		})
+		token, err := jwt.Parse(*tokenString, jwtKeyFunc)
 
 		if err != nil {
 			return err
@@ -330,10 +341,7 @@ func handleWebSocketAuth(request, response *configs.WsMessage) (*string, error)
 				response.Error = nil
 
 				if user.Location != nil {
-					weather.ZipCodeCacheMutex.Lock()
-					zipCode, ok := weather.ZipcodeLookup[*user.Location]
-					weather.ZipCodeCacheMutex.Unlock()
-					if ok && zipCode.Forecast != nil {
+					if zipCode, err := weather.GetLocation(user.Location); err == nil && zipCode.Forecast != nil {
 						response.Data = zipCode
 					}
 				}
@@ -377,6 +385,13 @@ func handleMessage(request, response *configs.WsMessage) {
 					session.onClose()
 				}
 			}
+		case configs.Logout:
+			sessionsMutex.Lock()
+			session, ok := sessions[*request.SessionID]
+			sessionsMutex.Unlock()
+			if ok {
+				session.logout(response)
+			}
 		default:
 			err := fmt.Sprintf("component %s not implemented", *request.Component)
 			log.Error(err)
@@ -388,6 +403,34 @@ func handleMessage(request, response *configs.WsMessage) {
 	response.Error = &err
 }
 
+// logout revokes the session's token, along with its refreshes, and closes the session once the UI has been told
+func (session *session) logout(response *configs.WsMessage) {
+	if session.jwt != nil {
+		jti, err := getTokenID(*session.jwt)
+		if err == nil {
+			err = revokeToken(*jti)
+		}
+		if err != nil {
+			log.Error(err)
+		}
+	}
+
+	user := "unknown"
+	if session.user != nil {
+		user = *session.user
+	}
+	log.Infof("User '%s' logged out of session %s", user, *session.sessionID)
+
+	approved := configs.Approved
+	response.SubComponent = &approved
+	if err := session.webSocketSend(response); err != nil {
+		log.Error(err)
+	}
+	session.user = nil
+	session.jwt = nil
+	session.onClose()
+}
+
 func getPagelet(request, response *configs.WsMessage) {
 	err := errors.New(configs.NotFoundError).Error()
 	response.Error = &err
@@ -438,6 +481,42 @@ func NotifyAll(sessionID *string, message *configs.WsMessage) {
 	}
 }
 
+// NotifyAdmins will broadcast an event to the websocket clients logged in as an admin
+func NotifyAdmins(sessionID *string, message *configs.WsMessage) {
+	if message != nil {
+		sessionsMutex.Lock()
+		for _, session := range sessions {
+			if session == nil || session.isAdmin == nil || !*session.isAdmin {
+				continue
+			}
+			// don't send a notification to the user that requested the action
+			if session.sessionID != nil && sessionID != nil && strings.EqualFold(*sessionID, *session.sessionID) {
+				continue
+			}
+			if err := session.webSocketSend(message); err != nil {
+				log.Error(err)
+			}
+		}
+		sessionsMutex.Unlock()
+	}
+}
+
+// NotifyUser will send an event to every websocket session the user is logged in with
+func NotifyUser(userID *string, message *configs.WsMessage) {
+	if userID != nil && message != nil {
+		sessionsMutex.Lock()
+		for _, session := range sessions {
+			if session == nil || session.user == nil || *session.user != *userID {
+				continue
+			}
+			if err := session.webSocketSend(message); err != nil {
+				log.Error(err)
+			}
+		}
+		sessionsMutex.Unlock()
+	}
+}
+
 func idleHandsTester() {
 	time.Sleep(time.Duration(60-time.Now().Local().Second()) * time.Second)
 	for range time.NewTicker(10 * time.Second).C {
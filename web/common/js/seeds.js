@@ -33,7 +33,8 @@ class Seeds {
 
     showSeeds(data) {
         const div = document.getElementById('SeedsDiv');
-        Object.keys(data).forEach((category) => {
+        div.innerHTML = '';
+        Object.keys(data).sort((a, b) => (data[a].displayOrder || 0) - (data[b].displayOrder || 0)).forEach((category) => {
             let heading = document.createElement('h2');
             heading.innerHTML = category;
             div.appendChild(heading);
@@ -86,7 +87,7 @@ class Seeds {
 
                     let columns = {
                         '<b>Seeds Per Packet</b>': seed.perPacketCount,
-                        '<b>Price Per Packet</b>': `$${seed.price}`
+                        '<b>Price Per Packet</b>': this.formatPrice(seed)
                     };
 
                     Object.keys(columns).forEach((key) => {
@@ -151,7 +152,7 @@ class Seeds {
             } else {
                 let p = document.createElement('paragraph');
                 p.innerHTML = `No ${category} found in inventory`;
-                div.appendChild(heading);
+                div.appendChild(p);
             }
         });
         this.ws.displayHelper([ 'SeedsDiv' ], '');
@@ -197,7 +198,7 @@ class Seeds {
         dtr.insertCell(-1).appendChild(availableDiv);
         dtr = dtb.insertRow(-1);
         dtr.insertCell(-1).innerHTML = '<b>Price</b>';
-        dtr.insertCell(-1).innerHTML = `$${data.price}`;
+        dtr.insertCell(-1).innerHTML = this.formatPrice(data);
         dtr = dtb.insertRow(-1);
         let element = document.getElementById(`${data.id}-quantity`);
         let parent = element.parentElement;
@@ -234,6 +235,31 @@ class Seeds {
         }
     }
 
+    refreshSeeds() {
+        // only reload the catalog if the seeds page is the one being viewed
+        if (document.getElementById('SeedsDiv') !== null) {
+            this.ws.sendMessage({
+                route: this.route,
+                type: 'getInventory'
+            });
+        }
+    }
+
+    // a seed on promotion shows the list price struck through next to the effective price, prices are decimal strings
+    formatPrice(seed) {
+        if (seed.effectivePrice !== undefined && Number(seed.effectivePrice) < Number(seed.price)) {
+            return `<s>$${seed.price}</s> $${seed.effectivePrice}`;
+        }
+        return `$${seed.price}`;
+    }
+
+    showStockAlert(alert) {
+        let name = alert.cultivar ? `${alert.cultivar} ${alert.commonName}` : alert.commonName;
+        let message = alert.kind === 'soldOut' ? `${name} is sold out` : `${name} is down to ${alert.packets} packets`;
+        this.log.info(`\n${JSON.stringify(alert, null, 4)}`);
+        this.ws.showSnackbarMessage(message);
+    }
+
     handleMessage(json) {
         if (Object.prototype.hasOwnProperty.call(json, 'error')) {
             this.log.error(json.error);
@@ -247,9 +273,27 @@ class Seeds {
                 this.showSeeds(json.data);
                 break;
             case 'purchase':
+            case 'restockInventory':
+            case 'updateInventory':
                 this.updateSeed(json.data);
                 this.log.info(`\n${JSON.stringify(json, null, 4)}`);
                 break;
+            case 'addInventory':
+            case 'removeInventory':
+            case 'addCategory':
+            case 'updateCategory':
+            case 'removeCategory':
+            case 'importInventory':
+            case 'addPromotion':
+            case 'removePromotion':
+                this.refreshSeeds();
+                break;
+            case 'stockAlert':
+                this.showStockAlert(json.data);
+                break;
+            case 'acknowledgeStockAlert':
+                this.log.info(`stock alert ${json.component} acknowledged`);
+                break;
             default:
                 this.log.error(`Cannot handle component '${json.component}' for ${this.type}`);
                 break;
@@ -0,0 +1,46 @@
+/*
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+*/
+
+class Weather {
+    constructor(ws, log) {
+        this.ws = ws;
+        this.log = log;
+
+        this.route = this.constructor.name.toLowerCase();
+        this.ws.registerHandlers(this.route, this);
+    }
+
+    showAlert(alert) {
+        this.log.info(`\n${JSON.stringify(alert, null, 4)}`);
+        this.ws.showSnackbarMessage(alert.message);
+    }
+
+    handleMessage(json) {
+        if (Object.prototype.hasOwnProperty.call(json, 'error')) {
+            this.log.error(json.error);
+            this.ws.showSnackbarMessage(json.error);
+        } else {
+            switch(json.type) {
+            case 'alert':
+                this.showAlert(json.data);
+                break;
+            default:
+                this.log.error(`Cannot handle type '${json.type}' for ${this.route}`);
+                break;
+            }
+        }
+    }
+}
+
+export { Weather };
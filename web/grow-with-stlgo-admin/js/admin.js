@@ -20,9 +20,9 @@ class Admin {
 
         this.route = this.constructor.name.toLowerCase();
         this.ws.registerHandlers(this.route, this);
-        this.typeaheadData = null;
-
         this.locationTypeAhead = null;
+        // the pending location search of each input, the results are looked up by the server as they're typed
+        this.locationSearches = {};
 
         document.addEventListener('admin', () => {
             this.ws.sendMessage({
@@ -425,36 +425,37 @@ class Admin {
             document.getElementById(`${user}-LocationInput`).value = data.location;
         }
 
-        $(`#${user}-LocationInput`).typeahead({
-            hint: true,
-            highlight: true,
-            minLength: 3
-        },
-        {
-            name: 'Locations',
-            source: this.typeaheadData
-        });
+        this.bindTypeAhead(`${user}-LocationInput`);
     }
 
-    bindTypeAhead(data) {
-        this.typeaheadData = new Bloodhound({
-            datumTokenizer: Bloodhound.tokenizers.whitespace,
-            queryTokenizer: Bloodhound.tokenizers.whitespace,
-            // `states` is an array of state names defined in "The Basics"
-            local: data
-        });
-
-        $('#LocationInput').typeahead({
+    bindTypeAhead(inputID) {
+        $(`#${inputID}`).typeahead({
             hint: true,
             highlight: true,
             minLength: 3
         },
         {
             name: 'Locations',
-            source: this.typeaheadData
+            limit: 10,
+            source: (query, syncResults, asyncResults) => {
+                this.locationSearches[inputID] = asyncResults;
+                this.ws.sendMessage({
+                    route: this.route,
+                    type: 'searchLocations',
+                    component: inputID,
+                    data: query,
+                });
+            }
         });
     }
 
+    showLocations(inputID, locations) {
+        if (Object.prototype.hasOwnProperty.call(this.locationSearches, inputID)) {
+            this.locationSearches[inputID](locations);
+            delete this.locationSearches[inputID];
+        }
+    }
+
     handleMessage(json) {
         if (Object.prototype.hasOwnProperty.call(json, 'error')) {
             this.log.error(json.error);
@@ -473,10 +474,14 @@ class Admin {
                 if (Object.prototype.hasOwnProperty.call(json.data, 'version')) {
                     document.getElementById('VersionDiv').innerHTML = `Current Version: ${json.data.version}`;
                 }
-                if (Object.prototype.hasOwnProperty.call(json.data, 'zipCodes')) {
-                    this.bindTypeAhead(json.data.zipCodes);
+                if (this.locationTypeAhead === null) {
+                    this.bindTypeAhead('LocationInput');
+                    this.locationTypeAhead = true;
                 }
                 break;
+            case 'searchLocations':
+                this.showLocations(json.component, json.data);
+                break;
             case 'generatePassword':
                 this.populatePassword(json.component, json.data);
                 break;
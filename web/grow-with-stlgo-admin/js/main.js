@@ -15,6 +15,7 @@
 import { Admin } from './admin.js';
 import { Seeds } from '/common/js/seeds.js';
 import { Log } from '/common/js/log.js';
+import { Weather } from '/common/js/weather.js';
 import { WebSocketClient } from '/common/js/websocket.js';
 
 class GrowWithSTLGO {
@@ -53,6 +54,7 @@ class GrowWithSTLGO {
                 this.ws = new WebSocketClient(this.log);
                 const admin = new Admin(this.ws, this.log);
                 const seeds = new Seeds(this.ws, this.log);
+                const weather = new Weather(this.ws, this.log);
             }
 
             this.ws.login(id, password);
@@ -14,6 +14,7 @@
 
 import { Seeds } from '/common/seeds.js';
 import { Log } from '/common/js/log.js';
+import { Weather } from '/common/js/weather.js';
 import { WebSocketClient } from '/common/js/websocket.js';
 
 class GrowWithSTLGO {
@@ -51,6 +52,7 @@ class GrowWithSTLGO {
             if (this.ws === null) {
                 this.ws = new WebSocketClient(this.log);
                 const seeds = new Seeds(this.ws, this.log);
+                const weather = new Weather(this.ws, this.log);
             }
 
             this.ws.login(id, password);